@@ -0,0 +1,68 @@
+package lipservice
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Attr is a typed key/value pair. Log methods and With accept Attrs mixed
+// with alternating keys and values, as slog does; an Attr keeps ints,
+// floats, bools and times unboxed until a record is exported.
+type Attr = slog.Attr
+
+// ErrorAttribute is the key Err uses.
+const ErrorAttribute = "error"
+
+// String returns an Attr for a string value.
+func String(key, value string) Attr {
+	return slog.String(key, value)
+}
+
+// Int returns an Attr for an int value.
+func Int(key string, value int) Attr {
+	return slog.Int(key, value)
+}
+
+// Float returns an Attr for a float64 value.
+func Float(key string, value float64) Attr {
+	return slog.Float64(key, value)
+}
+
+// Bool returns an Attr for a bool value.
+func Bool(key string, value bool) Attr {
+	return slog.Bool(key, value)
+}
+
+// Time returns an Attr for a time.Time value.
+func Time(key string, value time.Time) Attr {
+	return slog.Time(key, value)
+}
+
+// Err returns an Attr for an error under ErrorAttribute. A nil error is
+// logged as nil.
+func Err(err error) Attr {
+	return slog.Any(ErrorAttribute, err)
+}
+
+// eachAttribute calls fn for each key/value in args, which holds Attrs and
+// alternating string keys and values, until fn returns false. Pairs whose
+// key isn't a string are skipped, and a trailing key without a value is
+// ignored.
+func eachAttribute(args []interface{}, fn func(key string, value interface{}) bool) {
+	for i := 0; i < len(args); i++ {
+		if attr, ok := args[i].(Attr); ok {
+			if !fn(attr.Key, attr.Value.Resolve().Any()) {
+				return
+			}
+			continue
+		}
+		if i+1 >= len(args) {
+			return
+		}
+		key, ok := args[i].(string)
+		i++
+		if ok && !fn(key, args[i]) {
+			return
+		}
+	}
+}
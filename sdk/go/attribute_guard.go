@@ -0,0 +1,290 @@
+package lipservice
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultMaxAttributeKeys is the number of distinct attribute keys allowed
+// per window before new keys are folded into overflow buckets.
+const defaultMaxAttributeKeys = 256
+
+// defaultAttributeKeyWindow is how long the distinct key cap applies
+// before it starts over.
+const defaultAttributeKeyWindow = time.Hour
+
+// attributeOverflowBuckets is the number of buckets keys are hashed into
+// once the cardinality limit is reached.
+const attributeOverflowBuckets = 16
+
+// attributeKeyReportOffenders is how many offenders a window's diagnostic
+// names.
+const attributeKeyReportOffenders = 5
+
+// attributeKeyIDPattern matches ID-like segments embedded in attribute keys,
+// such as the 12345 in user_12345_status.
+var attributeKeyIDPattern = regexp.MustCompile(
+	`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{12,}|\d{3,}`)
+
+// AttributeKeyOffender is an attribute key that arrived after the window's
+// distinct key cap was reached and was folded into an overflow bucket.
+type AttributeKeyOffender struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// AttributeKeyReport describes attribute key cardinality in the current
+// window.
+type AttributeKeyReport struct {
+	// Limit is the distinct key cap per window, and Keys how many distinct
+	// keys have been exported in this one
+	Limit int `json:"limit"`
+	Keys  int `json:"keys"`
+
+	// WindowStart is when the current window began
+	WindowStart time.Time `json:"window_start"`
+
+	// Offenders are the keys folded into overflow buckets this window,
+	// most frequent first
+	Offenders []AttributeKeyOffender `json:"offenders"`
+}
+
+// attributeKeyGuard normalizes attribute keys and bounds their cardinality
+// per window, so IDs baked into keys don't create an unbounded number of
+// columns downstream.
+type attributeKeyGuard struct {
+	maxKeys int
+	window  time.Duration
+	diag    *diagnostics
+
+	mu          sync.Mutex
+	windowStart time.Time
+	seen        map[string]struct{}
+	cache       map[string]guardedKey
+	offenders   map[string]int64
+}
+
+// guardedKey is the guarded form of a raw key and, if it overflowed, the
+// normalized key counted as the offender.
+type guardedKey struct {
+	key      string
+	offender string
+}
+
+// newAttributeKeyGuard creates a guard allowing maxKeys distinct keys per
+// window, reporting each window's offenders to diag.
+func newAttributeKeyGuard(maxKeys int, window time.Duration, diag *diagnostics) *attributeKeyGuard {
+	if maxKeys <= 0 {
+		maxKeys = defaultMaxAttributeKeys
+	}
+	if window <= 0 {
+		window = defaultAttributeKeyWindow
+	}
+	return &attributeKeyGuard{
+		maxKeys:     maxKeys,
+		window:      window,
+		diag:        diag,
+		windowStart: time.Now(),
+		seen:        make(map[string]struct{}),
+		cache:       make(map[string]guardedKey),
+		offenders:   make(map[string]int64),
+	}
+}
+
+// apply returns attributes with normalized, cardinality-bounded keys. The
+// input map is returned unchanged when no key needs rewriting.
+func (g *attributeKeyGuard) apply(attributes map[string]interface{}) map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.roll(time.Now())
+
+	changed := false
+	for key := range attributes {
+		changed = g.observe(key) || changed
+	}
+	if !changed {
+		return attributes
+	}
+
+	// Keys that stay as they are claim their names before renamed ones,
+	// which are guarded in order so collisions resolve the same way every
+	// time
+	guarded := make(map[string]interface{}, len(attributes))
+	var renamed []string
+	for key, value := range attributes {
+		if g.key(key) == key {
+			guarded[key] = value
+		} else {
+			renamed = append(renamed, key)
+		}
+	}
+	sort.Strings(renamed)
+	for _, key := range renamed {
+		guarded[uncollidedKey(g.key(key), func(k string) bool {
+			_, ok := guarded[k]
+			return ok
+		})] = attributes[key]
+	}
+	return guarded
+}
+
+// applyFields is apply for a record's fields. They are returned unchanged
+// when no key needs rewriting, and copied otherwise, as the logger shares
+// them with its other sinks.
+func (g *attributeKeyGuard) applyFields(fields recordFields) recordFields {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.roll(time.Now())
+
+	changed := false
+	for _, field := range fields {
+		changed = g.observe(field.key) || changed
+	}
+	if !changed {
+		return fields
+	}
+
+	guarded := make(recordFields, 0, len(fields))
+	for _, field := range fields {
+		if g.key(field.key) == field.key {
+			guarded.set(field.key, field.value)
+		}
+	}
+	for _, field := range fields {
+		if key := g.key(field.key); key != field.key {
+			guarded.set(uncollidedKey(key, func(k string) bool {
+				_, ok := guarded.get(k)
+				return ok
+			}), field.value)
+		}
+	}
+	return guarded
+}
+
+// uncollidedKey returns key, or key with the lowest numeric suffix that is
+// not taken, so two attributes guarded to the same key both survive.
+func uncollidedKey(key string, taken func(string) bool) string {
+	if !taken(key) {
+		return key
+	}
+	for n := 2; ; n++ {
+		if candidate := key + "_" + strconv.Itoa(n); !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// observe guards one key of a record, counting it if it overflows, and
+// reports whether it is rewritten. Callers must hold g.mu.
+func (g *attributeKeyGuard) observe(key string) bool {
+	guarded := g.guard(key)
+	if guarded.offender != "" {
+		if _, ok := g.offenders[guarded.offender]; ok || len(g.offenders) < g.maxKeys {
+			g.offenders[guarded.offender]++
+		}
+	}
+	return guarded.key != key
+}
+
+// key returns the guarded form of a single key. Callers must hold g.mu.
+func (g *attributeKeyGuard) key(key string) string {
+	return g.guard(key).key
+}
+
+// guard normalizes a key and applies the window's cap. Callers must hold
+// g.mu.
+func (g *attributeKeyGuard) guard(key string) guardedKey {
+	if guarded, ok := g.cache[key]; ok {
+		return guarded
+	}
+
+	normalized := attributeKeyIDPattern.ReplaceAllString(key, "ID")
+	guarded := guardedKey{key: normalized}
+	if _, ok := g.seen[normalized]; !ok {
+		if len(g.seen) >= g.maxKeys {
+			h := fnv.New32a()
+			h.Write([]byte(normalized))
+			guarded = guardedKey{
+				key:      fmt.Sprintf("lipservice.overflow_%d", h.Sum32()%attributeOverflowBuckets),
+				offender: normalized,
+			}
+		} else {
+			g.seen[normalized] = struct{}{}
+		}
+	}
+
+	// Bound the cache too, since raw keys are exactly what's unbounded
+	if len(g.cache) < 4*g.maxKeys {
+		g.cache[key] = guarded
+	}
+
+	return guarded
+}
+
+// roll starts a new window once the current one has passed, reporting the
+// keys that overflowed in it. Callers must hold g.mu.
+func (g *attributeKeyGuard) roll(now time.Time) {
+	if now.Sub(g.windowStart) < g.window {
+		return
+	}
+
+	if len(g.offenders) > 0 && g.diag != nil {
+		offenders := g.sortedOffenders()
+		if len(offenders) > attributeKeyReportOffenders {
+			offenders = offenders[:attributeKeyReportOffenders]
+		}
+		names := make([]string, len(offenders))
+		for i, offender := range offenders {
+			names[i] = fmt.Sprintf("%s=%d", offender.Key, offender.Count)
+		}
+		g.diag.log(slog.Default(), "WARN", "Attribute keys exceeded the distinct key cap",
+			"limit", g.maxKeys, "window", g.window, "overflowed_keys", len(g.offenders), "top", names)
+	}
+
+	g.windowStart = now
+	g.seen = make(map[string]struct{})
+	g.cache = make(map[string]guardedKey)
+	g.offenders = make(map[string]int64)
+}
+
+// sortedOffenders returns the window's offenders, most frequent first.
+// Callers must hold g.mu.
+func (g *attributeKeyGuard) sortedOffenders() []AttributeKeyOffender {
+	offenders := make([]AttributeKeyOffender, 0, len(g.offenders))
+	for key, count := range g.offenders {
+		offenders = append(offenders, AttributeKeyOffender{Key: key, Count: count})
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Count != offenders[j].Count {
+			return offenders[i].Count > offenders[j].Count
+		}
+		return offenders[i].Key < offenders[j].Key
+	})
+	return offenders
+}
+
+// report describes the current window.
+func (g *attributeKeyGuard) report() AttributeKeyReport {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.roll(time.Now())
+
+	return AttributeKeyReport{
+		Limit:       g.maxKeys,
+		Keys:        len(g.seen),
+		WindowStart: g.windowStart,
+		Offenders:   g.sortedOffenders(),
+	}
+}
+
+// AttributeKeyReport returns the attribute key cardinality of the current
+// window, naming the keys that overflowed the cap.
+func (ls *LipService) AttributeKeyReport() AttributeKeyReport {
+	return ls.logger.privacy.keyGuard.report()
+}
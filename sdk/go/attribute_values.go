@@ -0,0 +1,192 @@
+package lipservice
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// attributeTailBuckets is the number of buckets long-tail attribute values
+// are hashed into.
+const attributeTailBuckets = 16
+
+// attributeValueTracking is how many candidate values are counted per
+// attribute, as a multiple of its limit, when looking for new top values.
+const attributeValueTracking = 4
+
+// attributeValueSampler keeps the most frequent values of high-cardinality
+// attributes such as query or url verbatim and folds the long tail into a
+// fixed set of hashed buckets, so downstream cardinality stays bounded.
+// Each attribute is tracked under its own lock, in O(log k) per value.
+type attributeValueSampler struct {
+	values map[string]*topValues
+}
+
+// topValues tracks approximate top-K values of one attribute using the
+// Space-Saving algorithm. Candidates and top values are each kept in a
+// min-heap by count, so the least frequent is found without a scan.
+type topValues struct {
+	k int
+
+	mu         sync.Mutex
+	entries    map[string]*valueEntry
+	candidates valueHeap
+	kept       valueHeap
+}
+
+// valueEntry is one counted value, with its place in each heap.
+type valueEntry struct {
+	value     string
+	count     int64
+	index     int
+	keptIndex int
+}
+
+// valueHeap is a min-heap of values by count, over either every candidate
+// or only the top values.
+type valueHeap struct {
+	entries []*valueEntry
+	kept    bool
+}
+
+func (h *valueHeap) Len() int           { return len(h.entries) }
+func (h *valueHeap) Less(i, j int) bool { return h.entries[i].count < h.entries[j].count }
+
+func (h *valueHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.setIndex(i)
+	h.setIndex(j)
+}
+
+func (h *valueHeap) Push(x interface{}) {
+	h.entries = append(h.entries, x.(*valueEntry))
+	h.setIndex(len(h.entries) - 1)
+}
+
+func (h *valueHeap) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	if h.kept {
+		last.keptIndex = -1
+	}
+	return last
+}
+
+// setIndex records entry i's position in the heap.
+func (h *valueHeap) setIndex(i int) {
+	if h.kept {
+		h.entries[i].keptIndex = i
+	} else {
+		h.entries[i].index = i
+	}
+}
+
+// newAttributeValueSampler creates a value sampler for
+// Config.AttributeValueLimits, or returns nil when none are set.
+func newAttributeValueSampler(config Config) *attributeValueSampler {
+	values := make(map[string]*topValues, len(config.AttributeValueLimits))
+	for key, k := range config.AttributeValueLimits {
+		if k > 0 {
+			values[key] = &topValues{
+				k:       k,
+				entries: make(map[string]*valueEntry, attributeValueTracking*k),
+				kept:    valueHeap{kept: true},
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return &attributeValueSampler{values: values}
+}
+
+// apply returns attributes with long-tail values of limited attributes
+// replaced by their bucket. The input map is returned unchanged when no
+// value needs rewriting.
+func (v *attributeValueSampler) apply(attributes map[string]interface{}) map[string]interface{} {
+	if v == nil {
+		return attributes
+	}
+
+	var sampled map[string]interface{}
+	for key, top := range v.values {
+		value, ok := attributes[key]
+		if !ok {
+			continue
+		}
+		s := fmt.Sprintf("%v", value)
+		if top.observe(s) {
+			continue
+		}
+
+		if sampled == nil {
+			sampled = make(map[string]interface{}, len(attributes))
+			for k, value := range attributes {
+				sampled[k] = value
+			}
+		}
+		sampled[key] = tailBucket(s)
+	}
+
+	if sampled == nil {
+		return attributes
+	}
+	return sampled
+}
+
+// observe counts value and reports whether it is currently one of the top
+// k values.
+func (t *topValues) observe(value string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[value]
+	switch {
+	case ok:
+		entry.count++
+		heap.Fix(&t.candidates, entry.index)
+		if entry.keptIndex >= 0 {
+			heap.Fix(&t.kept, entry.keptIndex)
+		}
+	case len(t.entries) < attributeValueTracking*t.k:
+		entry = &valueEntry{value: value, count: 1, keptIndex: -1}
+		t.entries[value] = entry
+		heap.Push(&t.candidates, entry)
+	default:
+		// Replace the least frequent candidate, inheriting its count as
+		// Space-Saving does
+		victim := t.candidates.entries[0]
+		delete(t.entries, victim.value)
+		if victim.keptIndex >= 0 {
+			heap.Remove(&t.kept, victim.keptIndex)
+		}
+		entry = &valueEntry{value: value, count: victim.count + 1, keptIndex: -1}
+		t.entries[value] = entry
+		t.candidates.entries[0] = entry
+		heap.Fix(&t.candidates, 0)
+	}
+
+	if entry.keptIndex >= 0 {
+		return true
+	}
+	if t.kept.Len() < t.k {
+		heap.Push(&t.kept, entry)
+		return true
+	}
+
+	// Promote the value once it overtakes the least frequent top value
+	if entry.count <= t.kept.entries[0].count {
+		return false
+	}
+	heap.Pop(&t.kept)
+	heap.Push(&t.kept, entry)
+	return true
+}
+
+// tailBucket returns the bucket a long-tail value is folded into.
+func tailBucket(value string) string {
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	return fmt.Sprintf("lipservice.tail_%d", h.Sum32()%attributeTailBuckets)
+}
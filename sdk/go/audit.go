@@ -0,0 +1,159 @@
+package lipservice
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Policy audit sources.
+const (
+	PolicySourceBackend    = "backend"
+	PolicySourceCheckpoint = "checkpoint"
+)
+
+// PolicyAuditEvent records a sampling policy change applied by the SDK.
+// Events written to the audit log are hash-chained: each Hash covers the
+// event and the previous event's hash, so edits or deletions are evident.
+type PolicyAuditEvent struct {
+	Timestamp        time.Time       `json:"timestamp"`
+	ServiceName      string          `json:"service_name"`
+	InstanceID       string          `json:"instance_id"`
+	Source           string          `json:"source"`
+	PreviousPolicyID string          `json:"previous_policy_id,omitempty"`
+	PolicyID         string          `json:"policy_id"`
+	Policy           *SamplingPolicy `json:"policy"`
+	PrevHash         string          `json:"prev_hash"`
+	Hash             string          `json:"hash"`
+}
+
+// policyAuditor appends policy change events to an audit log and/or hook.
+type policyAuditor struct {
+	serviceName string
+	instanceID  string
+	hook        func(PolicyAuditEvent)
+	mu          sync.Mutex
+	file        *os.File
+	lastHash    string
+}
+
+// newPolicyAuditor opens the configured audit log, or returns nil if
+// auditing is not configured.
+func newPolicyAuditor(config Config) (*policyAuditor, error) {
+	if config.AuditLogPath == "" && config.AuditHook == nil {
+		return nil, nil
+	}
+
+	auditor := &policyAuditor{
+		serviceName: config.ServiceName,
+		instanceID:  config.InstanceID,
+		hook:        config.AuditHook,
+	}
+	if auditor.instanceID == "" {
+		auditor.instanceID = defaultInstanceID()
+	}
+
+	if config.AuditLogPath != "" {
+		lastHash, err := lastAuditHash(config.AuditLogPath)
+		if err != nil {
+			return nil, err
+		}
+		auditor.lastHash = lastHash
+
+		file, err := os.OpenFile(config.AuditLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		auditor.file = file
+	}
+
+	return auditor, nil
+}
+
+// record writes an audit event for a policy change from previous to next.
+// Nothing is recorded if the policy did not change.
+func (a *policyAuditor) record(previous, next *SamplingPolicy, source string) error {
+	if reflect.DeepEqual(previous, next) {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	event := PolicyAuditEvent{
+		Timestamp:   time.Now().UTC(),
+		ServiceName: a.serviceName,
+		InstanceID:  a.instanceID,
+		Source:      source,
+		Policy:      next,
+		PrevHash:    a.lastHash,
+	}
+	if previous != nil {
+		event.PreviousPolicyID = previous.PolicyID
+	}
+	if next != nil {
+		event.PolicyID = next.PolicyID
+	}
+
+	unsigned, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+	sum := sha256.Sum256(unsigned)
+	event.Hash = hex.EncodeToString(sum[:])
+
+	if a.file != nil {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit event: %w", err)
+		}
+		if _, err := a.file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+	}
+	a.lastHash = event.Hash
+
+	if a.hook != nil {
+		a.hook(event)
+	}
+
+	return nil
+}
+
+// close closes the audit log.
+func (a *policyAuditor) close() error {
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+// lastAuditHash returns the hash of the last event in an existing audit
+// log so the chain continues across restarts.
+func lastAuditHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(last) == 0 {
+		return "", nil
+	}
+
+	var event PolicyAuditEvent
+	if err := json.Unmarshal(last, &event); err != nil {
+		return "", fmt.Errorf("failed to decode last audit event: %w", err)
+	}
+	return event.Hash, nil
+}
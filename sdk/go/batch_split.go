@@ -0,0 +1,73 @@
+package lipservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// statusError is returned when PostHog responds with an error status.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("PostHog returned status %d", e.code)
+}
+
+// isRejection reports whether err means PostHog rejected the batch's
+// contents, so resending it unchanged can never succeed. Other client
+// errors, such as a bad API key (401, 403) or a wrong endpoint (404), are
+// about the request rather than the records, so the batch is retried and
+// spooled until the configuration is fixed.
+func isRejection(err error) bool {
+	var status *statusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch status.code {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// splitRejected bisects a rejected batch, sending each half separately,
+// until the offending records are isolated. Those are dead-lettered and
+// dropped while the rest are delivered.
+func (e *PostHogExporter) splitRejected(ctx context.Context, records []*logs.LogRecord, rejection error) error {
+	if len(records) == 1 {
+		e.deadLetter(DropReasonRejected, rejection, records[0])
+		e.stats.drop(DropReasonRejected, 1)
+		e.trails.stepAll(e.trails.tracedIDs(records), TrailDeadLettered, "%s: %v", DropReasonRejected, rejection)
+		return nil
+	}
+
+	mid := len(records) / 2
+	err := e.sendRecords(ctx, records[:mid])
+	if rerr := e.sendRecords(ctx, records[mid:]); rerr != nil && err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// requestRecords returns the log records in a serialized OTLP request.
+func requestRecords(data []byte) ([]*logs.LogRecord, error) {
+	var request collectorlogs.ExportLogsServiceRequest
+	if err := proto.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal OTLP request: %w", err)
+	}
+
+	var records []*logs.LogRecord
+	for _, resourceLogs := range request.ResourceLogs {
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			records = append(records, scopeLogs.LogRecords...)
+		}
+	}
+	return records, nil
+}
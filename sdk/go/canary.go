@@ -0,0 +1,90 @@
+package lipservice
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CanaryResourceAttribute marks exports from canary instances, so their
+// richer telemetry can be compared with the stable fleet's.
+const CanaryResourceAttribute = "lipservice.canary"
+
+// defaultCanaryRate is the flat sampling rate on canary instances.
+const defaultCanaryRate = 0.5
+
+// Environment variables consulted to detect a canary instance.
+const (
+	canaryEnv            = "LIPSERVICE_CANARY"
+	deploymentTrackEnv   = "DEPLOYMENT_TRACK"
+	resourceAttributeEnv = "OTEL_RESOURCE_ATTRIBUTES"
+)
+
+// canaryResourceKeys are resource attribute keys that name a deployment's
+// track or canary status.
+var canaryResourceKeys = map[string]bool{
+	"deployment.track":  true,
+	"deployment.canary": true,
+	"canary":            true,
+	"lipservice.canary": true,
+}
+
+// detectCanary reports whether this instance is a canary: Config.Canary is
+// set, LIPSERVICE_CANARY is true, or DEPLOYMENT_TRACK or
+// OTEL_RESOURCE_ATTRIBUTES names a canary or blue-green preview track.
+func detectCanary(config Config, getenv func(string) string) bool {
+	if config.Canary {
+		return true
+	}
+	if canary, err := strconv.ParseBool(getenv(canaryEnv)); err == nil {
+		return canary
+	}
+	if canaryTrack(getenv(deploymentTrackEnv)) {
+		return true
+	}
+	for _, pair := range strings.Split(getenv(resourceAttributeEnv), ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if ok && canaryResourceKeys[strings.TrimSpace(key)] && canaryTrack(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// canaryTrack reports whether a track name or flag marks a canary.
+func canaryTrack(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "canary", "preview", "true":
+		return true
+	}
+	return false
+}
+
+// canaryRate returns the flat rate for canary instances, and false on the
+// stable fleet. It stands in for the policy's flat rate only: pattern,
+// severity and tier rates are left as the policy sets them, so a canary
+// never keeps records the policy rates at zero. A policy's CanaryRate
+// overrides Config.CanaryRate. Callers must hold s.mu.
+func (s *AdaptiveSampler) canaryRate() (float64, bool) {
+	if !s.canary {
+		return 0, false
+	}
+	if s.policy != nil && s.policy.CanaryRate > 0 {
+		return s.policy.CanaryRate, true
+	}
+	if s.config.CanaryRate > 0 {
+		return s.config.CanaryRate, true
+	}
+	return defaultCanaryRate, true
+}
+
+// Canary reports whether this instance was detected as a canary and is
+// sampled at the canary rate.
+func (s *AdaptiveSampler) Canary() bool {
+	return s.canary
+}
+
+// isCanary is detectCanary against the process environment.
+func isCanary(config Config) bool {
+	return detectCanary(config, os.Getenv)
+}
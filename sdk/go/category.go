@@ -0,0 +1,90 @@
+package lipservice
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Common log categories. Any name may be used; these are the usual ways
+// organizations segment their log streams.
+const (
+	CategoryAccess     = "access"
+	CategoryAudit      = "audit"
+	CategoryApp        = "app"
+	CategoryDebugTrace = "debugtrace"
+)
+
+// Attributes added to records logged through a category.
+const (
+	CategoryAttribute  = "lipservice.category"
+	RetentionAttribute = "lipservice.retention"
+)
+
+// DropReasonCategoryBudget is recorded when a kept record is dropped because
+// its category's per-minute budget is spent.
+const DropReasonCategoryBudget = "category_budget"
+
+// CategoryConfig sets how records in one log category are kept and routed.
+type CategoryConfig struct {
+	// MaxLogsPerMinute caps the category's kept records per minute, on top
+	// of sampling (0 is unlimited)
+	MaxLogsPerMinute int
+
+	// Sinks send the category's records to keys of Config.Sinks or
+	// PostHogSink, in place of ExportRoutes
+	Sinks []string
+
+	// Retention is a retention hint exported as lipservice.retention, such
+	// as "30d", for downstream storage policies
+	Retention string
+}
+
+// logCategory is a configured category and its budget.
+type logCategory struct {
+	config CategoryConfig
+
+	mu     sync.Mutex
+	budget tierBudget
+}
+
+// newLogCategories validates config.Categories, returning nil if there are
+// none.
+func newLogCategories(config Config) (map[string]*logCategory, error) {
+	if len(config.Categories) == 0 {
+		return nil, nil
+	}
+
+	categories := make(map[string]*logCategory, len(config.Categories))
+	for name, category := range config.Categories {
+		for _, sink := range category.Sinks {
+			if _, ok := config.Sinks[sink]; !ok && sink != PostHogSink {
+				return nil, fmt.Errorf("category %q names unknown sink %q", name, sink)
+			}
+		}
+		categories[name] = &logCategory{config: category}
+	}
+	return categories, nil
+}
+
+// admit reports whether another record fits the category's budget,
+// counting it if so. A nil category admits everything.
+func (c *logCategory) admit(now time.Time) bool {
+	if c == nil || c.config.MaxLogsPerMinute <= 0 {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.budget.take(c.config.MaxLogsPerMinute, now)
+}
+
+// Category returns a logger whose records belong to the named category,
+// such as CategoryAudit. They carry a lipservice.category attribute and
+// are budgeted, routed and given retention hints per Config.Categories.
+func (l *LipServiceLogger) Category(name string) *LipServiceLogger {
+	clone := *l
+	clone.category = name
+	clone.baseLogger = l.baseLogger.With(CategoryAttribute, name)
+	return &clone
+}
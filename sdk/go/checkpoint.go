@@ -0,0 +1,156 @@
+package lipservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultCheckpointInterval is how often sampler state is persisted when a
+// state file is configured without an explicit interval.
+const defaultCheckpointInterval = time.Minute
+
+// corruptStateSuffix is appended to a state file that can't be restored
+// when it is moved aside.
+const corruptStateSuffix = ".corrupt"
+
+// samplerCheckpoint is the on-disk form of the sampler's learned state.
+type samplerCheckpoint struct {
+	SavedAt        time.Time                `json:"saved_at"`
+	Policy         *SamplingPolicy          `json:"policy,omitempty"`
+	PatternStats   map[string]*PatternStats `json:"pattern_stats"`
+	RateMultiplier float64                  `json:"rate_multiplier,omitempty"`
+}
+
+// checkpoint writes the sampler state to the configured state file. The
+// file is replaced atomically so a crash mid-write never leaves a torn file.
+func (s *AdaptiveSampler) checkpoint() error {
+	if s.config.StateFile == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	state := samplerCheckpoint{
+		SavedAt:      time.Now(),
+		Policy:       s.policy,
+		PatternStats: s.patternStats,
+	}
+	if s.coordinator != nil {
+		state.RateMultiplier = s.coordinator.multiplier
+	}
+	data, err := json.Marshal(state)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal sampler state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.config.StateFile), ".lipservice-state-*")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.config.StateFile); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	s.mu.Lock()
+	s.lastCheckpoint = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// restore loads sampler state saved by a previous process, so a restart
+// keeps learned pattern rates instead of falling back to cold-start
+// defaults. A missing state file is not an error, and a corrupt one is
+// moved aside so the sampler cold-starts.
+func (s *AdaptiveSampler) restore() error {
+	if s.config.StateFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.config.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state samplerCheckpoint
+	err = json.Unmarshal(data, &state)
+	if err == nil && state.Policy != nil {
+		err = validatePolicy(state.Policy)
+	}
+	if err != nil {
+		return s.discardState(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state.Policy != nil {
+		s.applyPolicy(state.Policy, PolicySourceCheckpoint)
+		s.lastPolicyUpdate = state.SavedAt
+	}
+	if state.PatternStats != nil {
+		s.patternStats = state.PatternStats
+		s.indexPatterns()
+		s.trimPatterns(0)
+	}
+	if s.coordinator != nil && state.RateMultiplier > 0 {
+		s.coordinator.multiplier = state.RateMultiplier
+	}
+
+	return nil
+}
+
+// discardState moves a state file that couldn't be restored aside, keeping
+// it for inspection, and warns that the sampler is cold-starting.
+func (s *AdaptiveSampler) discardState(cause error) error {
+	aside := s.config.StateFile + corruptStateSuffix
+	if err := os.Rename(s.config.StateFile, aside); err != nil {
+		return fmt.Errorf("failed to move corrupt state file aside: %w", err)
+	}
+	s.diag.log(slog.Default(), "WARN", "Ignoring corrupt state file; starting without learned state",
+		"path", s.config.StateFile, "moved_to", aside, "error", cause)
+	return nil
+}
+
+// checkpointLoop persists sampler state periodically.
+func (s *AdaptiveSampler) checkpointLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.checkpointInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.checkpoint(); err != nil {
+				s.diag.log(slog.Default(), "ERROR", "Sampler checkpoint failed", "error", err)
+			}
+		}
+	}
+}
+
+// checkpointInterval returns the configured checkpoint interval.
+func (s *AdaptiveSampler) checkpointInterval() time.Duration {
+	if s.config.CheckpointInterval > 0 {
+		return s.config.CheckpointInterval
+	}
+	return defaultCheckpointInterval
+}
@@ -0,0 +1,58 @@
+package lipservice
+
+import (
+	"time"
+)
+
+// Attributes describing event times.
+const (
+	// EventTimeAttribute passes a record's event time to the logger, for
+	// records that happened before they were logged:
+	//
+	//	logger.Info("job finished", lipservice.EventTimeAttribute, finishedAt)
+	EventTimeAttribute = "lipservice.event_time"
+
+	// OriginalTimeAttribute holds the caller's event time when it was
+	// replaced by skew correction
+	OriginalTimeAttribute = "lipservice.original_time"
+
+	// TimeCorrectedAttribute marks records whose event time was replaced
+	TimeCorrectedAttribute = "lipservice.time_corrected"
+)
+
+// minPlausibleTime is the earliest event time accepted as genuine; earlier
+// times come from unset clocks or zero values.
+var minPlausibleTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// skewedTimestamp reports whether timestamp is implausible relative to the
+// observed time: unset, before minPlausibleTime, or further than maxSkew in
+// the future.
+func skewedTimestamp(timestamp, observed time.Time, maxSkew time.Duration) bool {
+	return timestamp.Before(minPlausibleTime) || timestamp.Sub(observed) > maxSkew
+}
+
+// correctTimestamp replaces an implausible event time with the observed
+// time when MaxClockSkew is set, annotating a copy of attributes with the
+// original.
+func (e *PostHogExporter) correctTimestamp(timestamp, observed time.Time, attributes map[string]interface{}) (time.Time, map[string]interface{}) {
+	if e.config.MaxClockSkew <= 0 || !skewedTimestamp(timestamp, observed, e.config.MaxClockSkew) {
+		return timestamp, attributes
+	}
+
+	annotated := make(map[string]interface{}, len(attributes)+2)
+	for key, value := range attributes {
+		annotated[key] = value
+	}
+	annotated[OriginalTimeAttribute] = timestamp.UTC().Format(time.RFC3339Nano)
+	annotated[TimeCorrectedAttribute] = true
+
+	return observed, annotated
+}
+
+// unixNano converts a time to OTLP nanoseconds, with 0 meaning unknown.
+func unixNano(t time.Time) uint64 {
+	if t.Before(time.Unix(0, 0)) {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
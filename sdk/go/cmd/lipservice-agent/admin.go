@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/srex-dev/lipservice-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// adminServiceName is the gRPC service managing a running agent.
+const adminServiceName = "lipservice.agent.v1.Admin"
+
+// adminProtoFile describes the admin service in terms of well-known types,
+// so it needs no generated code and is still listed by server reflection:
+//
+//	service Admin {
+//	  rpc GetPolicy(google.protobuf.Empty) returns (google.protobuf.Struct);
+//	  rpc GetStats(google.protobuf.Empty) returns (google.protobuf.Struct);
+//	  rpc SetIncidentMode(google.protobuf.BoolValue) returns (google.protobuf.BoolValue);
+//	}
+var adminProtoFile = &descriptorpb.FileDescriptorProto{
+	Name:    proto.String("lipservice/agent/v1/admin.proto"),
+	Package: proto.String("lipservice.agent.v1"),
+	Syntax:  proto.String("proto3"),
+	Dependency: []string{
+		"google/protobuf/empty.proto",
+		"google/protobuf/struct.proto",
+		"google/protobuf/wrappers.proto",
+	},
+	Service: []*descriptorpb.ServiceDescriptorProto{{
+		Name: proto.String("Admin"),
+		Method: []*descriptorpb.MethodDescriptorProto{
+			adminMethodProto("GetPolicy", "google.protobuf.Empty", "google.protobuf.Struct"),
+			adminMethodProto("GetStats", "google.protobuf.Empty", "google.protobuf.Struct"),
+			adminMethodProto("SetIncidentMode", "google.protobuf.BoolValue", "google.protobuf.BoolValue"),
+		},
+	}},
+}
+
+// adminMethodProto describes one unary admin method.
+func adminMethodProto(name, input, output string) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String(name),
+		InputType:  proto.String("." + input),
+		OutputType: proto.String("." + output),
+	}
+}
+
+func init() {
+	// Importing the well-known types above registers the dependencies
+	file, err := protodesc.NewFile(adminProtoFile, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(fmt.Sprintf("lipservice-agent: invalid admin service descriptor: %v", err))
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(fmt.Sprintf("lipservice-agent: failed to register admin service: %v", err))
+	}
+}
+
+// adminAPI is implemented by the admin service handlers.
+type adminAPI interface {
+	GetPolicy(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error)
+	GetStats(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error)
+	SetIncidentMode(ctx context.Context, req *wrapperspb.BoolValue) (*wrapperspb.BoolValue, error)
+}
+
+// adminServiceDesc registers adminAPI with a gRPC server.
+var adminServiceDesc = grpc.ServiceDesc{
+	ServiceName: adminServiceName,
+	HandlerType: (*adminAPI)(nil),
+	Methods: []grpc.MethodDesc{
+		adminMethod("GetPolicy", func() proto.Message { return &emptypb.Empty{} },
+			func(api adminAPI, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return api.GetPolicy(ctx, req.(*emptypb.Empty))
+			}),
+		adminMethod("GetStats", func() proto.Message { return &emptypb.Empty{} },
+			func(api adminAPI, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return api.GetStats(ctx, req.(*emptypb.Empty))
+			}),
+		adminMethod("SetIncidentMode", func() proto.Message { return &wrapperspb.BoolValue{} },
+			func(api adminAPI, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return api.SetIncidentMode(ctx, req.(*wrapperspb.BoolValue))
+			}),
+	},
+	Metadata: adminProtoFile.GetName(),
+}
+
+// adminMethod builds the unary handler for one admin method, as protoc
+// would generate it.
+func adminMethod(name string, newRequest func() proto.Message, call func(adminAPI, context.Context, proto.Message) (proto.Message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newRequest()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(adminAPI), ctx, req.(proto.Message))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + adminServiceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// adminServer serves the admin API for one agent.
+type adminServer struct {
+	ls *lipservice.LipService
+}
+
+// GetPolicy returns the sampling policy in force, or an empty struct when
+// none has been fetched.
+func (a *adminServer) GetPolicy(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error) {
+	policy := a.ls.Sampler().Policy()
+	if policy == nil {
+		return &structpb.Struct{}, nil
+	}
+	return jsonStruct(policy)
+}
+
+// GetStats returns the agent's delivery counters, as in a ShutdownReport,
+// with its incident mode and log level.
+func (a *adminServer) GetStats(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error) {
+	stats, err := jsonStruct(a.ls.Report())
+	if err != nil {
+		return nil, err
+	}
+	stats.Fields["incident_mode"] = structpb.NewBoolValue(a.ls.IncidentMode())
+	stats.Fields["log_level"] = structpb.NewStringValue(a.ls.LogLevel())
+	return stats, nil
+}
+
+// SetIncidentMode turns incident mode on or off and returns the new state.
+func (a *adminServer) SetIncidentMode(ctx context.Context, req *wrapperspb.BoolValue) (*wrapperspb.BoolValue, error) {
+	a.ls.SetIncidentMode(req.GetValue())
+	return wrapperspb.Bool(a.ls.IncidentMode()), nil
+}
+
+// jsonStruct converts v to a Struct through its JSON encoding.
+func jsonStruct(v interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %w", v, err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode %T: %w", v, err)
+	}
+	return structpb.NewStruct(fields)
+}
+
+// adminOptions secures the admin server.
+type adminOptions struct {
+	// token must be sent as "authorization: Bearer <token>" on every call
+	// but health checks, when set
+	token string
+
+	// tls serves the admin API over TLS, when set, requiring client
+	// certificates when it was given a client CA
+	tls *tls.Config
+
+	// reflection registers gRPC server reflection
+	reflection bool
+}
+
+// newAdminOptions loads the admin server's TLS certificate and the CA its
+// clients' certificates must be signed by, if any.
+func newAdminOptions(token, certFile, keyFile, clientCAFile string, reflection bool) (adminOptions, error) {
+	options := adminOptions{token: token, reflection: reflection}
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return options, fmt.Errorf("-admin-tls-client-ca needs -admin-tls-cert and -admin-tls-key")
+		}
+		return options, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return options, fmt.Errorf("-admin-tls-cert and -admin-tls-key must be given together")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return options, fmt.Errorf("failed to load admin TLS certificate: %w", err)
+	}
+	options.tls = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return options, fmt.Errorf("failed to read admin client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return options, fmt.Errorf("no certificates found in admin client CA %s", clientCAFile)
+		}
+		options.tls.ClientCAs = pool
+		options.tls.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return options, nil
+}
+
+// authenticated reports whether callers must prove who they are, with a
+// token or a client certificate. TLS alone only encrypts the connection.
+func (o adminOptions) authenticated() bool {
+	return o.token != "" || (o.tls != nil && o.tls.ClientAuth == tls.RequireAndVerifyClientCert)
+}
+
+// checkAdminListener refuses to expose the admin API beyond the host
+// without authenticating callers, since anyone who can reach it can change
+// how the agent samples.
+func checkAdminListener(listener net.Listener, options adminOptions) error {
+	if options.authenticated() {
+		return nil
+	}
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+		return fmt.Errorf("refusing to serve the admin API on %s without -admin-token or -admin-tls-client-ca", addr)
+	}
+	return nil
+}
+
+// authorize checks the bearer token on every call but health checks, which
+// orchestrators make without credentials.
+func (o adminOptions) authorize(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(o.token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid admin token")
+}
+
+// serverOptions returns the gRPC options enforcing o.
+func (o adminOptions) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if o.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(o.tls)))
+	}
+	if o.token != "" {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if err := o.authorize(ctx, info.FullMethod); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := o.authorize(stream.Context(), info.FullMethod); err != nil {
+					return err
+				}
+				return handler(srv, stream)
+			}),
+		)
+	}
+	return opts
+}
+
+// serveAdmin serves gRPC health checking, the admin API and, if enabled,
+// reflection on listener until ctx is done. Health reports NOT_SERVING once
+// shutdown starts, so orchestrators stop routing to the agent before it
+// exits.
+func serveAdmin(ctx context.Context, listener net.Listener, ls *lipservice.LipService, options adminOptions) error {
+	server := grpc.NewServer(options.serverOptions()...)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	server.RegisterService(&adminServiceDesc, &adminServer{ls: ls})
+	if options.reflection {
+		reflection.Register(server)
+	}
+
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(adminServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	go func() {
+		<-ctx.Done()
+		healthServer.Shutdown()
+		server.GracefulStop()
+	}()
+
+	log.Printf("lipservice-agent: admin gRPC listening on %s", listener.Addr())
+	if err := server.Serve(listener); err != nil {
+		return fmt.Errorf("admin server: %w", err)
+	}
+	return nil
+}
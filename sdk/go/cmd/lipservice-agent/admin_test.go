@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key to dir.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lipservice-agent"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "admin.crt"), filepath.Join(dir, "admin.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestCheckAdminListener(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+
+	tlsOnly, err := newAdminOptions("", certFile, keyFile, "", false)
+	if err != nil {
+		t.Fatalf("Failed to load TLS options: %v", err)
+	}
+	mutualTLS, err := newAdminOptions("", certFile, keyFile, certFile, false)
+	if err != nil {
+		t.Fatalf("Failed to load mTLS options: %v", err)
+	}
+	if mutualTLS.tls.ClientAuth != tls.RequireAndVerifyClientCert || mutualTLS.tls.ClientCAs == nil {
+		t.Errorf("Expected a client CA to require verified client certificates, got %v", mutualTLS.tls.ClientAuth)
+	}
+	if _, err := newAdminOptions("", "", "", certFile, false); err == nil {
+		t.Error("Expected a client CA without a server certificate to be rejected")
+	}
+
+	public, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer public.Close()
+	loopback, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer loopback.Close()
+
+	tests := []struct {
+		name     string
+		listener net.Listener
+		options  adminOptions
+		allowed  bool
+	}{
+		{"loopback without security", loopback, adminOptions{}, true},
+		{"public without security", public, adminOptions{}, false},
+		{"public with TLS only", public, tlsOnly, false},
+		{"public with a token", public, adminOptions{token: "s3cret"}, true},
+		{"public with TLS and a token", public, adminOptions{token: "s3cret", tls: tlsOnly.tls}, true},
+		{"public with client certificates", public, mutualTLS, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAdminListener(tt.listener, tt.options)
+			if allowed := err == nil; allowed != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.allowed, err)
+			}
+		})
+	}
+}
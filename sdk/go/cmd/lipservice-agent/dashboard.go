@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/srex-dev/lipservice-go"
+)
+
+// dashboardRefresh is how often the dashboard redraws.
+const dashboardRefresh = time.Second
+
+// dashboardTopPatterns is the number of patterns listed.
+const dashboardTopPatterns = 20
+
+// dashboardErrorLines is the number of recent errors kept for the errors
+// panel.
+const dashboardErrorLines = 8
+
+// runDashboard shows live delivery counters, throughput, sampler health,
+// recent errors and the top patterns on screen until ctx is done or the
+// user quits with q or Ctrl-C.
+func runDashboard(ctx context.Context, ls *lipservice.LipService, screen tcell.Screen, errs *dashboardErrors) error {
+	app := tview.NewApplication().SetScreen(screen)
+
+	summary := tview.NewTextView().SetDynamicColors(true)
+	summary.SetBorder(true).SetTitle(" Delivery ")
+
+	rates := tview.NewTextView().SetDynamicColors(true)
+	rates.SetBorder(true).SetTitle(" Throughput ")
+
+	health := tview.NewTextView().SetDynamicColors(true)
+	health.SetBorder(true).SetTitle(" Sampler ")
+
+	failures := tview.NewTextView().SetDynamicColors(true)
+	failures.SetBorder(true).SetTitle(" Errors ")
+
+	patterns := tview.NewTable().SetFixed(1, 0)
+	patterns.SetBorder(true).SetTitle(" Top patterns ")
+
+	top := tview.NewFlex().
+		AddItem(summary, 0, 1, false).
+		AddItem(rates, 0, 1, false).
+		AddItem(health, 0, 1, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(top, 9, 0, false).
+		AddItem(failures, dashboardErrorLines+2, 0, false).
+		AddItem(patterns, 0, 1, true)
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() == 'q' {
+			app.Stop()
+			return nil
+		}
+		return event
+	})
+
+	var meter throughput
+	update := func() {
+		report := ls.SamplingReport()
+		sampler := ls.Sampler()
+
+		summary.SetText(formatSummary(report.Summary))
+		rates.SetText(formatThroughput(meter.update(report.Summary, time.Now())))
+		health.SetText(formatHealth(sampler))
+		failures.SetText(formatErrors(sampler.PolicyFetchStats(), ls.ExporterStats(), errs.recent()))
+		fillPatterns(patterns, report.Patterns)
+	}
+	update()
+
+	go func() {
+		ticker := time.NewTicker(dashboardRefresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				app.Stop()
+				return
+			case <-ticker.C:
+				app.QueueUpdateDraw(update)
+			}
+		}
+	}()
+
+	return app.SetRoot(layout, true).Run()
+}
+
+// formatSummary renders the delivery counters.
+func formatSummary(r lipservice.ShutdownReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Accepted:  [white]%d[-]\n", r.Accepted)
+	fmt.Fprintf(&b, "Sampled:   [green]%d[-]\n", r.Sampled)
+	fmt.Fprintf(&b, "Exported:  [green]%d[-]\n", r.Exported)
+	fmt.Fprintf(&b, "Pending:   [yellow]%d[-]  Spooled: [yellow]%d[-]\n", r.Pending, r.SpoolRemaining)
+	reasons := make([]string, 0, len(r.Dropped))
+	for reason := range r.Dropped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "Dropped (%s): [red]%d[-]\n", reason, r.Dropped[reason])
+	}
+	return b.String()
+}
+
+// throughput turns successive delivery counters into per-second rates.
+type throughput struct {
+	last deliveryCounters
+	at   time.Time
+}
+
+// deliveryCounters are the cumulative counters throughput is measured on.
+type deliveryCounters struct {
+	Accepted, Sampled, Exported, Dropped int64
+}
+
+// throughputRates are records per second since the previous update.
+type throughputRates struct {
+	Accepted, Sampled, Exported, Dropped float64
+}
+
+// update records the counters in r at now and returns the rates since the
+// previous update, or zero rates on the first.
+func (t *throughput) update(r lipservice.ShutdownReport, now time.Time) throughputRates {
+	current := deliveryCounters{Accepted: r.Accepted, Sampled: r.Sampled, Exported: r.Exported}
+	for _, n := range r.Dropped {
+		current.Dropped += n
+	}
+
+	var rates throughputRates
+	if elapsed := now.Sub(t.at).Seconds(); !t.at.IsZero() && elapsed > 0 {
+		rates = throughputRates{
+			Accepted: float64(current.Accepted-t.last.Accepted) / elapsed,
+			Sampled:  float64(current.Sampled-t.last.Sampled) / elapsed,
+			Exported: float64(current.Exported-t.last.Exported) / elapsed,
+			Dropped:  float64(current.Dropped-t.last.Dropped) / elapsed,
+		}
+	}
+	t.last, t.at = current, now
+	return rates
+}
+
+// formatThroughput renders records per second.
+func formatThroughput(r throughputRates) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Accepted:  [white]%.1f/s[-]\n", r.Accepted)
+	fmt.Fprintf(&b, "Sampled:   [green]%.1f/s[-]\n", r.Sampled)
+	fmt.Fprintf(&b, "Exported:  [green]%.1f/s[-]\n", r.Exported)
+	fmt.Fprintf(&b, "Dropped:   [red]%.1f/s[-]\n", r.Dropped)
+	return b.String()
+}
+
+// formatHealth renders sampler health indicators.
+func formatHealth(s *lipservice.AdaptiveSampler) string {
+	var b strings.Builder
+
+	fetch := s.PolicyFetchStats()
+	if fetch.ConsecutiveFailures > 0 {
+		fmt.Fprintf(&b, "Policy:      [red]failing (%d)[-] %s\n", fetch.ConsecutiveFailures, fetch.LastError)
+	} else if !fetch.LastSuccess.IsZero() {
+		fmt.Fprintf(&b, "Policy:      [green]ok[-] (%s ago)\n", time.Since(fetch.LastSuccess).Round(time.Second))
+	} else {
+		fmt.Fprintf(&b, "Policy:      [yellow]pending[-]\n")
+	}
+
+	if s.Degraded() {
+		fmt.Fprintf(&b, "Latency:     [red]over budget, severity-only[-]\n")
+	} else {
+		fmt.Fprintf(&b, "Latency:     [green]ok[-]\n")
+	}
+	fmt.Fprintf(&b, "Rate factor: %.2f\n", s.RateMultiplier())
+
+	return b.String()
+}
+
+// formatErrors renders failing exporters and policy fetches, then the most
+// recent errors, newest last.
+func formatErrors(fetch lipservice.PolicyFetchStats, exporters []lipservice.ExporterStats, recent []dashboardError) string {
+	var b strings.Builder
+	if fetch.ConsecutiveFailures > 0 {
+		fmt.Fprintf(&b, "[red]policy fetch[-] failing (%d): %s\n", fetch.ConsecutiveFailures, tview.Escape(fetch.LastError))
+	}
+	for _, e := range exporters {
+		if e.Failed > 0 {
+			fmt.Fprintf(&b, "[red]%s[-] %d failed: %s\n", e.Exporter, e.Failed, tview.Escape(e.LastError))
+		}
+	}
+	for _, e := range recent {
+		fmt.Fprintf(&b, "%s [red]%s[-] %s\n", e.Time.Format("15:04:05"), e.Severity, tview.Escape(e.Message))
+	}
+	if b.Len() == 0 {
+		return "[green]none[-]"
+	}
+	return b.String()
+}
+
+// dashboardError is one line of the errors panel.
+type dashboardError struct {
+	Time     time.Time
+	Severity string
+	Message  string
+}
+
+// dashboardErrors collects the SDK's diagnostics, as its DiagnosticsSink,
+// and the agent's own log output while the dashboard owns the terminal, so
+// neither writes over the screen. It keeps the most recent warnings and
+// errors for the errors panel.
+type dashboardErrors struct {
+	mu      sync.Mutex
+	entries []dashboardError
+}
+
+// ExportLog records a diagnostic at WARN or above.
+func (e *dashboardErrors) ExportLog(message, severity string, timestamp time.Time, attributes map[string]interface{}) error {
+	if severity != "WARN" && severity != "ERROR" && severity != "CRITICAL" && severity != "FATAL" {
+		return nil
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		message += fmt.Sprintf(" %s=%v", key, attributes[key])
+	}
+
+	e.add(dashboardError{Time: timestamp, Severity: severity, Message: message})
+	return nil
+}
+
+// Write takes log output, one line per call as the log package writes it.
+// The agent's own messages and lines that look like warnings or errors are
+// kept; the echo of ordinary shipped records is discarded.
+func (e *dashboardErrors) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		severity := inferSeverity(line)
+		switch {
+		case severity == "WARN" || severity == "ERROR" || severity == "FATAL":
+		case strings.Contains(line, "lipservice-agent:"):
+			severity = "ERROR"
+		default:
+			continue
+		}
+		e.add(dashboardError{Time: time.Now(), Severity: severity, Message: line})
+	}
+	return len(p), nil
+}
+
+// add appends an entry, keeping the most recent dashboardErrorLines.
+func (e *dashboardErrors) add(entry dashboardError) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.entries = append(e.entries, entry)
+	if len(e.entries) > dashboardErrorLines {
+		e.entries = append(e.entries[:0], e.entries[len(e.entries)-dashboardErrorLines:]...)
+	}
+}
+
+// recent returns the kept entries, oldest first.
+func (e *dashboardErrors) recent() []dashboardError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]dashboardError(nil), e.entries...)
+}
+
+// fillPatterns renders the most frequent patterns into the table.
+func fillPatterns(table *tview.Table, rows []lipservice.PatternReport) {
+	table.Clear()
+
+	headers := []string{"Signature", "Count", "Rate", "Last seen"}
+	for col, header := range headers {
+		table.SetCell(0, col, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+	}
+
+	for i, row := range rows {
+		if i >= dashboardTopPatterns {
+			break
+		}
+		table.SetCell(i+1, 0, tview.NewTableCell(shortSignature(row.Signature)))
+		table.SetCell(i+1, 1, tview.NewTableCell(fmt.Sprintf("%d", row.Count)).SetAlign(tview.AlignRight))
+		table.SetCell(i+1, 2, tview.NewTableCell(fmt.Sprintf("%.3f", row.SamplingRate)).SetAlign(tview.AlignRight))
+		table.SetCell(i+1, 3, tview.NewTableCell(time.Since(row.LastSeen).Round(time.Second).String()+" ago"))
+	}
+}
+
+// shortSignature abbreviates a signature for display.
+func shortSignature(signature string) string {
+	if len(signature) > 12 {
+		return signature[:12]
+	}
+	return signature
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srex-dev/lipservice-go"
+)
+
+func TestThroughput(t *testing.T) {
+	var meter throughput
+	start := time.Now()
+
+	first := meter.update(lipservice.ShutdownReport{Accepted: 100, Sampled: 40, Exported: 30}, start)
+	if first != (throughputRates{}) {
+		t.Errorf("Expected zero rates on the first update, got %+v", first)
+	}
+
+	rates := meter.update(lipservice.ShutdownReport{
+		Accepted: 300,
+		Sampled:  80,
+		Exported: 70,
+		Dropped:  map[string]int64{"buffer_full": 6, "duplicate": 4},
+	}, start.Add(2*time.Second))
+	want := throughputRates{Accepted: 100, Sampled: 20, Exported: 20, Dropped: 5}
+	if rates != want {
+		t.Errorf("Expected rates %+v, got %+v", want, rates)
+	}
+
+	// A refresh at the same instant has no elapsed time to measure over
+	if same := meter.update(lipservice.ShutdownReport{Accepted: 400}, start.Add(2*time.Second)); same != (throughputRates{}) {
+		t.Errorf("Expected zero rates with no time elapsed, got %+v", same)
+	}
+
+	text := formatThroughput(want)
+	for _, line := range []string{"100.0/s", "20.0/s", "5.0/s"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("Expected %q in throughput panel, got %q", line, text)
+		}
+	}
+}
+
+func TestDashboardErrors(t *testing.T) {
+	errs := &dashboardErrors{}
+	now := time.Now()
+
+	tests := []struct {
+		severity string
+		kept     bool
+	}{
+		{"DEBUG", false},
+		{"INFO", false},
+		{"WARN", true},
+		{"ERROR", true},
+		{"CRITICAL", true},
+		{"FATAL", true},
+	}
+	for _, tt := range tests {
+		before := len(errs.recent())
+		errs.ExportLog("Failed to export", tt.severity, now, map[string]interface{}{"url": "http://a", "error": "timeout"})
+		if kept := len(errs.recent()) > before; kept != tt.kept {
+			t.Errorf("Severity %s: expected kept=%v, got %v", tt.severity, tt.kept, kept)
+		}
+	}
+	if got := errs.recent()[0].Message; got != "Failed to export error=timeout url=http://a" {
+		t.Errorf("Expected attributes appended in key order, got %q", got)
+	}
+
+	// Log output keeps the agent's own messages and error-looking lines,
+	// not the echo of ordinary records
+	errs = &dashboardErrors{}
+	fmt.Fprint(errs, "2024/01/01 12:00:00 user logged in\n")
+	fmt.Fprint(errs, "2024/01/01 12:00:00 lipservice-agent: drain: context deadline exceeded\n")
+	fmt.Fprint(errs, "2024/01/01 12:00:00 ERROR database unreachable\n2024/01/01 12:00:00 cache warmed\n")
+	recent := errs.recent()
+	if len(recent) != 2 {
+		t.Fatalf("Expected 2 lines kept from log output, got %+v", recent)
+	}
+	if recent[0].Severity != "ERROR" || !strings.Contains(recent[0].Message, "drain") {
+		t.Errorf("Expected the agent's message kept as an error, got %+v", recent[0])
+	}
+	if !strings.Contains(recent[1].Message, "database unreachable") {
+		t.Errorf("Expected the error line kept, got %+v", recent[1])
+	}
+
+	// Only the most recent entries are kept, oldest first
+	errs = &dashboardErrors{}
+	for i := 0; i < dashboardErrorLines+3; i++ {
+		errs.ExportLog(fmt.Sprintf("error %d", i), "ERROR", now, nil)
+	}
+	recent = errs.recent()
+	if len(recent) != dashboardErrorLines {
+		t.Fatalf("Expected %d entries kept, got %d", dashboardErrorLines, len(recent))
+	}
+	if recent[0].Message != "error 3" || recent[len(recent)-1].Message != fmt.Sprintf("error %d", dashboardErrorLines+2) {
+		t.Errorf("Expected the newest entries, oldest first, got %q..%q", recent[0].Message, recent[len(recent)-1].Message)
+	}
+
+	text := formatErrors(lipservice.PolicyFetchStats{}, nil, recent[:1])
+	if !strings.Contains(text, "error 3") {
+		t.Errorf("Expected recent errors in the errors panel, got %q", text)
+	}
+	if text := formatErrors(lipservice.PolicyFetchStats{}, nil, nil); text != "[green]none[-]" {
+		t.Errorf("Expected an empty errors panel to say none, got %q", text)
+	}
+}
@@ -0,0 +1,256 @@
+// Command lipservice-agent ships log lines from a file or stdin through
+// LipService's adaptive sampler to PostHog, for processes that can't embed
+// the SDK directly.
+//
+// Usage:
+//
+//	lipservice-agent -service checkout -file /var/log/checkout.log
+//	some-process 2>&1 | lipservice-agent -service some-process -dashboard
+//	lipservice-agent -service checkout -file /var/log/checkout.log -admin-addr localhost:9090
+//	lipservice-agent -install -service checkout -file C:\logs\checkout.log
+//
+// Under systemd the agent supports Type=notify, WatchdogSec and socket
+// activation of the admin server; on Windows it can run as a service.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/srex-dev/lipservice-go"
+)
+
+func main() {
+	var (
+		service      = flag.String("service", os.Getenv("LIPSERVICE_SERVICE_NAME"), "service name")
+		backend      = flag.String("lipservice-url", os.Getenv("LIPSERVICE_URL"), "LipService backend URL")
+		endpoint     = flag.String("posthog-endpoint", os.Getenv("POSTHOG_ENDPOINT"), "PostHog endpoint")
+		file         = flag.String("file", "", "log file to follow (defaults to stdin)")
+		dashboard    = flag.Bool("dashboard", false, "show an interactive terminal dashboard")
+		spoolDir     = flag.String("spool-dir", "", "directory for spooling batches that fail to export")
+		stateFile    = flag.String("state-file", "", "file for checkpointing learned sampler state")
+		multiline    = flag.Bool("multiline", true, "join stack traces and other multi-line records")
+		startExpr    = flag.String("multiline-start", "", "regexp matching the first line of each record (overrides the heuristics)")
+		adminAddr    = flag.String("admin-addr", "", "address for gRPC health and the admin API, on 127.0.0.1 unless a host is given (disabled when empty)")
+		adminToken   = flag.String("admin-token", os.Getenv("LIPSERVICE_ADMIN_TOKEN"), "bearer token required by the admin API")
+		adminCert    = flag.String("admin-tls-cert", "", "TLS certificate file for the admin API")
+		adminKey     = flag.String("admin-tls-key", "", "TLS key file for the admin API")
+		adminCA      = flag.String("admin-tls-client-ca", "", "CA file admin API clients' certificates must be signed by")
+		adminReflect = flag.Bool("admin-reflection", false, "serve gRPC reflection on the admin API")
+		drainTimeout = flag.Duration("drain-timeout", 30*time.Second, "how long to spend exporting buffered records on shutdown")
+		winService   = flag.String("windows-service", "lipservice-agent", "Windows service name for -install and -uninstall")
+		install      = flag.Bool("install", false, "register the agent, with the other flags given, as a Windows service and exit")
+		uninstall    = flag.Bool("uninstall", false, "remove the agent's Windows service and exit")
+	)
+	flag.Parse()
+
+	switch {
+	case *install:
+		if err := installService(*winService, serviceArgs(os.Args[1:])); err != nil {
+			log.Fatalf("lipservice-agent: %v", err)
+		}
+		return
+	case *uninstall:
+		if err := uninstallService(*winService); err != nil {
+			log.Fatalf("lipservice-agent: %v", err)
+		}
+		return
+	}
+
+	if *service == "" {
+		log.Fatal("lipservice-agent: -service is required")
+	}
+
+	config := lipservice.Config{
+		ServiceName:     *service,
+		LipServiceURL:   *backend,
+		PostHogAPIKey:   os.Getenv("POSTHOG_API_KEY"),
+		PostHogTeamID:   os.Getenv("POSTHOG_TEAM_ID"),
+		PostHogEndpoint: *endpoint,
+		SpoolDir:        *spoolDir,
+		StateFile:       *stateFile,
+	}
+
+	// The dashboard owns the terminal, so diagnostics go to its errors panel
+	var (
+		screen tcell.Screen
+		errs   *dashboardErrors
+	)
+	if *dashboard {
+		var err error
+		if screen, err = tcell.NewScreen(); err != nil {
+			log.Fatalf("lipservice-agent: dashboard: %v", err)
+		}
+		errs = &dashboardErrors{}
+		config.DiagnosticsSink = errs
+	}
+
+	ls, err := lipservice.New(config)
+	if err != nil {
+		log.Fatalf("lipservice-agent: %v", err)
+	}
+
+	var multilineConfig lipservice.MultilineConfig
+	if *startExpr != "" {
+		start, err := regexp.Compile(*startExpr)
+		if err != nil {
+			log.Fatalf("lipservice-agent: invalid -multiline-start: %v", err)
+		}
+		multilineConfig.StartPattern = start
+	}
+
+	// The admin server can use a socket passed by systemd socket activation
+	activated, err := activationListeners()
+	if err != nil {
+		log.Fatalf("lipservice-agent: %v", err)
+	}
+	listener, err := adminListener(activated, *adminAddr)
+	if err != nil {
+		log.Fatalf("lipservice-agent: %v", err)
+	}
+	adminOptions, err := newAdminOptions(*adminToken, *adminCert, *adminKey, *adminCA, *adminReflect)
+	if err != nil {
+		log.Fatalf("lipservice-agent: %v", err)
+	}
+	if listener != nil {
+		if err := checkAdminListener(listener, adminOptions); err != nil {
+			log.Fatalf("lipservice-agent: %v", err)
+		}
+	}
+
+	agent := func(ctx context.Context) {
+		ctx, stop := context.WithCancel(ctx)
+		defer stop()
+
+		if listener != nil {
+			go func() {
+				if err := serveAdmin(ctx, listener, ls, adminOptions); err != nil {
+					log.Printf("lipservice-agent: %v", err)
+				}
+			}()
+		}
+		if interval := watchdogInterval(); interval > 0 {
+			go runWatchdog(ctx, interval)
+		}
+
+		lines := make(chan string, 1024)
+		go func() {
+			defer close(lines)
+			if err := readLines(ctx, *file, lines); err != nil {
+				log.Printf("lipservice-agent: %v", err)
+			}
+		}()
+
+		records := lines
+		if *multiline {
+			assembled := make(chan string, 1024)
+			go assembleRecords(lines, assembled, multilineConfig)
+			records = assembled
+		}
+
+		notify("READY=1")
+		if *dashboard {
+			shipped := make(chan struct{})
+			go func() {
+				defer close(shipped)
+				ship(ls, records)
+			}()
+			output := log.Writer()
+			log.SetOutput(errs)
+			err := runDashboard(ctx, ls, screen, errs)
+			log.SetOutput(output)
+			if err != nil {
+				log.Printf("lipservice-agent: dashboard: %v", err)
+			}
+			// Stopping ends the reader; wait for what it read to be logged
+			stop()
+			<-shipped
+		} else {
+			ship(ls, records)
+		}
+
+		drain(ls, *drainTimeout)
+	}
+
+	// Under the Windows service control manager, stop requests end the agent
+	if ran, err := runAsService(*winService, agent); ran || err != nil {
+		if err != nil {
+			log.Fatalf("lipservice-agent: %v", err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Once draining starts, a second signal exits immediately
+		<-ctx.Done()
+		stop()
+	}()
+	agent(ctx)
+}
+
+// drain exports what the agent has buffered, giving up after timeout, then
+// shuts LipService down. Lines already read have been shipped by the time
+// it runs.
+func drain(ls *lipservice.LipService, timeout time.Duration) {
+	notify("STOPPING=1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := ls.FlushOnInvocationEnd(ctx); err != nil {
+		log.Printf("lipservice-agent: drain: %v", err)
+	}
+
+	if _, err := ls.CloseWithReport(); err != nil {
+		log.Printf("lipservice-agent: shutdown: %v", err)
+	}
+}
+
+// notify reports a state change to systemd, logging failures.
+func notify(state string) {
+	if err := sdNotify(state); err != nil {
+		log.Printf("lipservice-agent: %v", err)
+	}
+}
+
+// ship logs each record through LipService at its inferred severity.
+func ship(ls *lipservice.LipService, lines <-chan string) {
+	logger := ls.Logger().With("source", "lipservice-agent")
+	for line := range lines {
+		switch inferSeverity(line) {
+		case "FATAL":
+			logger.Fatal(line)
+		case "ERROR":
+			logger.Error(line)
+		case "WARN":
+			logger.Warn(line)
+		case "DEBUG":
+			logger.Debug(line)
+		default:
+			logger.Info(line)
+		}
+	}
+}
+
+// serviceArgs returns the command line for the installed service: args
+// without -install.
+func serviceArgs(args []string) []string {
+	kept := make([]string, 0, len(args))
+	for _, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == "install" || strings.HasPrefix(name, "install=") {
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errNotWindows is returned by service registration outside Windows, where
+// a systemd unit manages the agent instead.
+var errNotWindows = errors.New("service registration is only supported on Windows; use a systemd unit instead")
+
+// runAsService reports that the agent isn't running as a Windows service.
+func runAsService(name string, agent func(ctx context.Context)) (bool, error) {
+	return false, nil
+}
+
+// installService is only supported on Windows.
+func installService(name string, args []string) error {
+	return errNotWindows
+}
+
+// uninstallService is only supported on Windows.
+func uninstallService(name string) error {
+	return errNotWindows
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/srex-dev/lipservice-go"
+)
+
+// tailPollInterval is how often a followed file is checked for new data.
+const tailPollInterval = 250 * time.Millisecond
+
+// readLines sends lines from path, or stdin if path is empty, until ctx is
+// done. Files are followed like tail -f; stdin is read until EOF.
+func readLines(ctx context.Context, path string, lines chan<- string) error {
+	if path == "" {
+		return scanLines(ctx, os.Stdin, lines)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Start from the end, like tail -f
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	var partial strings.Builder
+	for {
+		chunk, err := reader.ReadString('\n')
+		partial.WriteString(chunk)
+
+		if err == nil {
+			select {
+			case lines <- strings.TrimRight(partial.String(), "\r\n"):
+			case <-ctx.Done():
+				return nil
+			}
+			partial.Reset()
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(tailPollInterval):
+		}
+	}
+}
+
+// scanLines sends each line read from r until EOF or ctx is done.
+func scanLines(ctx context.Context, r io.Reader, lines chan<- string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		select {
+		case lines <- scanner.Text():
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// assembleRecords joins multi-line records such as stack traces from lines
+// and sends them to records. A partial record is sent once no more lines
+// arrive within the assembler's flush timeout.
+func assembleRecords(lines <-chan string, records chan<- string, config lipservice.MultilineConfig) {
+	defer close(records)
+
+	assembler := lipservice.NewMultilineAssembler(config)
+	timeout := config.FlushTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if record, ok := assembler.Flush(); ok {
+					records <- record
+				}
+				return
+			}
+			if record, ok := assembler.Add(line); ok {
+				records <- record
+			}
+		case <-time.After(timeout):
+			if record, ok := assembler.Flush(); ok {
+				records <- record
+			}
+		}
+	}
+}
+
+// inferSeverity guesses a line's severity from common level markers.
+func inferSeverity(line string) string {
+	upper := strings.ToUpper(line)
+	switch {
+	case strings.Contains(upper, "FATAL") || strings.Contains(upper, "PANIC"):
+		return "FATAL"
+	case strings.Contains(upper, "ERROR") || strings.Contains(upper, "ERR "):
+		return "ERROR"
+	case strings.Contains(upper, "WARN"):
+		return "WARN"
+	case strings.Contains(upper, "DEBUG") || strings.Contains(upper, "TRACE"):
+		return "DEBUG"
+	default:
+		return "INFO"
+	}
+}
@@ -0,0 +1,73 @@
+// Command lipservice-explain shows how LipService would sample a record:
+// its pattern signature, the rule and rate that apply, the tier budget and
+// the decision. Learned patterns can be loaded from a state file or pattern
+// dictionary, so a record can be explained against production state.
+//
+// Usage:
+//
+//	lipservice-explain -message "cache miss for user 42" -tier standard
+//	lipservice-explain -message "payment failed" -severity WARN -state-file /var/lib/checkout/sampler.json
+//	lipservice-explain -message "request served" -tenant-attribute tenant_id -attr tenant_id=acme
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/srex-dev/lipservice-go"
+)
+
+func main() {
+	var (
+		message    = flag.String("message", "", "message to explain")
+		severity   = flag.String("severity", "INFO", "severity of -message")
+		tier       = flag.String("tier", "", "service tier (critical, standard or batch)")
+		stateFile  = flag.String("state-file", "", "sampler state file to load learned patterns from")
+		dictionary = flag.String("pattern-dictionary", "", "pattern dictionary to load known patterns from")
+		tenantAttr = flag.String("tenant-attribute", "", "attribute naming a record's tenant")
+		grouping   = flag.Bool("similarity-grouping", false, "group near-duplicate messages into shared patterns")
+		incident   = flag.Bool("incident", false, "explain the record as if incident mode were on")
+		attrs      = make(map[string]interface{})
+	)
+	flag.Func("attr", "attribute key=value for -message (repeatable)", func(value string) error {
+		key, v, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", value)
+		}
+		attrs[key] = v
+		return nil
+	})
+	flag.Parse()
+
+	if *message == "" {
+		log.Fatal("lipservice-explain: -message is required")
+	}
+
+	// Serverless keeps the sampler from starting background tasks that
+	// would contact the backend
+	sampler, err := lipservice.NewAdaptiveSampler(lipservice.Config{
+		ServiceName:        "lipservice-explain",
+		Serverless:         true,
+		Tier:               *tier,
+		StateFile:          *stateFile,
+		PatternDictionary:  *dictionary,
+		TenantAttribute:    *tenantAttr,
+		SimilarityGrouping: *grouping,
+	})
+	if err != nil {
+		log.Fatalf("lipservice-explain: %v", err)
+	}
+	// The sampler is deliberately not closed: closing would checkpoint over
+	// the state file being inspected
+	sampler.SetIncidentMode(*incident)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(sampler.Explain(*message, strings.ToUpper(*severity), attrs)); err != nil {
+		log.Fatalf("lipservice-explain: %v", err)
+	}
+}
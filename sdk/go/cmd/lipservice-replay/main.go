@@ -0,0 +1,125 @@
+// Command lipservice-replay resends records from a dead-letter file written
+// by FileDeadLetterQueue, once the cause of the failure has been fixed.
+//
+// Usage:
+//
+//	lipservice-replay -file /var/lib/app/dead.jsonl
+//	lipservice-replay -file dead.jsonl -reason export_failed -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/srex-dev/lipservice-go"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func main() {
+	var (
+		file      = flag.String("file", "", "dead-letter file to replay")
+		service   = flag.String("service", os.Getenv("LIPSERVICE_SERVICE_NAME"), "service name for letters that don't record one")
+		endpoint  = flag.String("posthog-endpoint", os.Getenv("POSTHOG_ENDPOINT"), "PostHog endpoint (defaults to each letter's endpoint)")
+		reason    = flag.String("reason", "", "only replay letters with this reason (rejected or export_failed)")
+		batchSize = flag.Int("batch-size", 100, "records per request")
+		dryRun    = flag.Bool("dry-run", false, "print what would be replayed without sending")
+	)
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("lipservice-replay: -file is required")
+	}
+	if *batchSize <= 0 {
+		log.Fatalf("lipservice-replay: -batch-size must be positive, got %d", *batchSize)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("lipservice-replay: %v", err)
+	}
+	letters, err := lipservice.ReadDeadLetters(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("lipservice-replay: %v", err)
+	}
+
+	// Group by endpoint and service so each letter goes back where it was
+	// headed, under the resource it came from
+	byTarget := make(map[replayTarget][]*logs.LogRecord)
+	for _, letter := range letters {
+		if *reason != "" && letter.Reason != *reason {
+			continue
+		}
+		target := replayTarget{endpoint: letter.Endpoint, service: letter.Service}
+		if *endpoint != "" {
+			target.endpoint = *endpoint
+		}
+		if target.service == "" {
+			target.service = *service
+		}
+		byTarget[target] = append(byTarget[target], letter.Record)
+	}
+
+	failed := false
+	for target, records := range byTarget {
+		if *dryRun {
+			fmt.Printf("would replay %d %s records to %s\n", len(records), target.service, target.endpoint)
+			continue
+		}
+		if err := replay(target, *batchSize, records); err != nil {
+			log.Printf("lipservice-replay: %s: %v", target.endpoint, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("replayed %d %s records to %s\n", len(records), target.service, target.endpoint)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// replayTarget is where a group of letters is resent.
+type replayTarget struct {
+	endpoint string
+	service  string
+}
+
+// replay sends records to one endpoint in batches.
+func replay(target replayTarget, batchSize int, records []*logs.LogRecord) error {
+	config := lipservice.DefaultConfig()
+	config.ServiceName = target.service
+	config.PostHogEndpoint = target.endpoint
+	config.PostHogAPIKey = os.Getenv("POSTHOG_API_KEY")
+	config.PostHogTeamID = os.Getenv("POSTHOG_TEAM_ID")
+	config.Serverless = true
+
+	exporter, err := lipservice.NewPostHogExporter(config)
+	if err != nil {
+		return err
+	}
+	defer exporter.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for start := 0; start < len(records); start += batchSize {
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		if err := exporter.ExportRecords(ctx, records[start:end]); err != nil {
+			return err
+		}
+	}
+
+	report := exporter.Report()
+	if n := report.Dropped[lipservice.DropReasonRejected]; n > 0 {
+		return fmt.Errorf("%d records were rejected again", n)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Command lipservice-scrub shows how a sample record will look once
+// LipService has redacted, transformed and truncated it for export, so a
+// configuration can be reviewed before it reaches production. Keys for
+// pseudonymized and encrypted attributes are read from
+// LIPSERVICE_PSEUDONYMIZATION_KEY and LIPSERVICE_ENCRYPTION_KEY.
+//
+// Usage:
+//
+//	lipservice-scrub -message "login token=abc123" -attr user_id=42 -pseudonymize user_id
+//	lipservice-scrub -record sample.json -secret-pattern 'card=\d+'
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/srex-dev/lipservice-go"
+)
+
+// listFlag collects a repeatable or comma-separated flag.
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+	return nil
+}
+
+func main() {
+	var (
+		recordFile      = flag.String("record", "", `JSON record {"message", "severity", "attributes"} to preview ("-" for stdin)`)
+		message         = flag.String("message", "", "message to preview, when -record isn't given")
+		severity        = flag.String("severity", "INFO", "severity of -message")
+		maxMessageBytes = flag.Int("max-message-bytes", 0, "longest exported message body (defaults to 16 KiB)")
+		maxKeys         = flag.Int("max-attribute-keys", 0, "distinct attribute keys before overflow bucketing (defaults to 256)")
+		noRedaction     = flag.Bool("no-redaction", false, "turn off the secret denylist")
+		attrs           = make(map[string]interface{})
+		secretPatterns  listFlag
+		pseudonymized   listFlag
+		encrypted       listFlag
+	)
+	flag.Func("attr", "attribute key=value for -message (repeatable)", func(value string) error {
+		key, v, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", value)
+		}
+		attrs[key] = v
+		return nil
+	})
+	flag.Var(&secretPatterns, "secret-pattern", "additional secret regexp (repeatable)")
+	flag.Var(&pseudonymized, "pseudonymize", "attribute keys to pseudonymize (comma-separated)")
+	flag.Var(&encrypted, "encrypt", "attribute keys to encrypt (comma-separated)")
+	flag.Parse()
+
+	record := lipservice.ScrubRecord{Message: *message, Severity: *severity, Attributes: attrs}
+	if *recordFile != "" {
+		var err error
+		if record, err = readRecord(*recordFile); err != nil {
+			log.Fatalf("lipservice-scrub: %v", err)
+		}
+	}
+
+	config := lipservice.Config{
+		MaxMessageBytes:         *maxMessageBytes,
+		MaxAttributeKeys:        *maxKeys,
+		SecretPatterns:          secretPatterns,
+		DisableSecretRedaction:  *noRedaction,
+		PseudonymizedAttributes: pseudonymized,
+		PseudonymizationKey:     []byte(os.Getenv("LIPSERVICE_PSEUDONYMIZATION_KEY")),
+		EncryptedAttributes:     encrypted,
+		AttributeEncryptionKey:  []byte(os.Getenv("LIPSERVICE_ENCRYPTION_KEY")),
+	}
+
+	preview, err := lipservice.PreviewScrub(config, record)
+	if err != nil {
+		log.Fatalf("lipservice-scrub: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(preview); err != nil {
+		log.Fatalf("lipservice-scrub: %v", err)
+	}
+}
+
+// readRecord reads a JSON record from path, or from stdin for "-".
+func readRecord(path string) (lipservice.ScrubRecord, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return lipservice.ScrubRecord{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var record lipservice.ScrubRecord
+	if err := json.NewDecoder(r).Decode(&record); err != nil {
+		return record, fmt.Errorf("failed to parse record: %w", err)
+	}
+	if record.Severity == "" {
+		record.Severity = "INFO"
+	}
+	return record, nil
+}
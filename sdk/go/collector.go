@@ -0,0 +1,98 @@
+package lipservice
+
+import (
+	"fmt"
+	"net/http"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// AdjustedCountAttribute is the number of original records an exported
+// record stands for (1/sampling rate), so a collector or backend can
+// re-weight counts after sampling.
+const AdjustedCountAttribute = "lipservice.adjusted_count"
+
+// Headers sent with exports when CollectorMetadata is set. A collector
+// processor recognizing them knows the records already carry LipService
+// sampling metadata and must not sample them again without adjusting it.
+const (
+	SamplingMetadataHeader = "X-LipService-Sampling-Metadata"
+	ServiceHeader          = "X-LipService-Service"
+)
+
+// samplingMetadataVersion identifies the layout of the sampling attributes.
+const samplingMetadataVersion = "v1"
+
+// exportAttributes returns the sampling metadata carried on exported
+// records for collectors to preserve end to end.
+func (o samplingOutcome) exportAttributes() map[string]interface{} {
+	attrs := map[string]interface{}{
+		SamplingRateAttribute:   o.rate,
+		SamplingReasonAttribute: o.reason,
+	}
+	if o.rate > 0 {
+		attrs[AdjustedCountAttribute] = 1 / o.rate
+	}
+	if o.policyID != "" {
+		attrs[PolicyIDAttribute] = o.policyID
+	}
+	return attrs
+}
+
+// exportKeyValue converts an attribute to OTLP for PostHog. Attributes are
+// exported as strings, except the sampling rate and adjusted count, which
+// stay doubles so collectors can re-weight counts without parsing them.
+func exportKeyValue(key string, value interface{}) *common.KeyValue {
+	if number, ok := value.(float64); ok && (key == AdjustedCountAttribute || key == SamplingRateAttribute) {
+		return &common.KeyValue{
+			Key:   key,
+			Value: &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: number}},
+		}
+	}
+	if count, ok := value.(int); ok && key == RepeatCountAttribute {
+		return &common.KeyValue{
+			Key:   key,
+			Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(count)}},
+		}
+	}
+	return stringKeyValue(key, fmt.Sprintf("%v", value))
+}
+
+// SamplingResult is one sampling decision with the metadata needed to
+// re-weight kept records downstream.
+type SamplingResult struct {
+	Kept      bool
+	Reason    string
+	Signature string
+	Rate      float64
+	PolicyID  string
+}
+
+// AdjustedCount returns the number of original records a kept record
+// stands for.
+func (r SamplingResult) AdjustedCount() float64 {
+	if r.Rate <= 0 {
+		return 0
+	}
+	return 1 / r.Rate
+}
+
+// Sample is ShouldSample returning the full decision, for callers such as
+// the collector processor that annotate kept records themselves.
+func (s *AdaptiveSampler) Sample(message, severity string) SamplingResult {
+	o := s.sample(message, severity)
+	return SamplingResult{
+		Kept:      o.kept,
+		Reason:    o.reason,
+		Signature: o.signature,
+		Rate:      o.rate,
+		PolicyID:  o.policyID,
+	}
+}
+
+// setCollectorHeaders marks an export request as carrying sampling metadata.
+func setCollectorHeaders(header http.Header, config Config) {
+	header.Set(SamplingMetadataHeader, samplingMetadataVersion)
+	header.Set(ServiceHeader, config.ServiceName)
+	header.Set("X-LipService-SDK", "go/"+Version)
+}
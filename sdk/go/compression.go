@@ -0,0 +1,224 @@
+package lipservice
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Batch compression codecs for Config.Compression.
+const (
+	CompressionAuto     = "auto"
+	CompressionIdentity = "identity"
+	CompressionGzip     = "gzip"
+	CompressionZstd     = "zstd"
+)
+
+// compressionCodecs are the codecs auto-selection chooses between. Every
+// OTLP/HTTP receiver must accept gzip, but not zstd, so zstd is only used
+// when configured explicitly.
+var compressionCodecs = []string{CompressionIdentity, CompressionGzip}
+
+// defaultCompressionCPUBudget is the default time allowed to compress one
+// MiB of batch data.
+const defaultCompressionCPUBudget = 20 * time.Millisecond
+
+// compressionMinBytes is the batch size below which auto-selection sends
+// batches uncompressed, since headers outweigh any savings.
+const compressionMinBytes = 1024
+
+// compressionWarmupBatches is the number of batches auto-selection
+// compresses with every codec before settling on one.
+const compressionWarmupBatches = 8
+
+// compressionRecalibrateBatches is how many batches auto-selection sends
+// with its chosen codec before calibrating again, so it follows changes in
+// batch shape.
+const compressionRecalibrateBatches = 1000
+
+// codecSample accumulates calibration measurements for one codec.
+type codecSample struct {
+	in      int64
+	out     int64
+	elapsed time.Duration
+}
+
+// ratio returns the achieved compression ratio.
+func (s codecSample) ratio() float64 {
+	if s.out == 0 {
+		return 1
+	}
+	return float64(s.in) / float64(s.out)
+}
+
+// perMiB returns the time spent compressing one MiB.
+func (s codecSample) perMiB() time.Duration {
+	if s.in == 0 {
+		return 0
+	}
+	return time.Duration(float64(s.elapsed) * (1 << 20) / float64(s.in))
+}
+
+// batchCompressor compresses serialized batches, either with a fixed codec
+// or by measuring each codec on real batches and picking the one with the
+// best ratio that fits the CPU budget.
+type batchCompressor struct {
+	mode   string
+	budget time.Duration
+	zstd   *zstd.Encoder
+
+	mu      sync.Mutex
+	samples map[string]*codecSample
+	batches int
+	chosen  string
+}
+
+// newBatchCompressor creates a compressor for config.Compression.
+func newBatchCompressor(config Config) (*batchCompressor, error) {
+	mode := config.Compression
+	if mode == "" {
+		mode = CompressionAuto
+	}
+
+	switch mode {
+	case CompressionAuto, CompressionIdentity, CompressionGzip, CompressionZstd:
+	default:
+		return nil, fmt.Errorf("unknown compression %q", mode)
+	}
+
+	budget := config.CompressionCPUBudget
+	if budget <= 0 {
+		budget = defaultCompressionCPUBudget
+	}
+
+	c := &batchCompressor{
+		mode:    mode,
+		budget:  budget,
+		samples: make(map[string]*codecSample),
+		chosen:  CompressionIdentity,
+	}
+
+	if mode == CompressionZstd {
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		c.zstd = enc
+	}
+
+	return c, nil
+}
+
+// compress returns data encoded for the wire and its Content-Encoding
+// (CompressionIdentity means no header is needed).
+func (c *batchCompressor) compress(data []byte) ([]byte, string, error) {
+	if c.mode != CompressionAuto {
+		body, err := c.encode(c.mode, data)
+		return body, c.mode, err
+	}
+
+	if len(data) < compressionMinBytes {
+		return data, CompressionIdentity, nil
+	}
+
+	c.mu.Lock()
+	calibrating := c.batches < compressionWarmupBatches
+	codec := c.chosen
+	c.batches++
+	if c.batches >= compressionWarmupBatches+compressionRecalibrateBatches {
+		c.batches = 0
+		c.samples = make(map[string]*codecSample)
+	}
+	c.mu.Unlock()
+
+	if !calibrating {
+		body, err := c.encode(codec, data)
+		return body, codec, err
+	}
+
+	return c.calibrate(data)
+}
+
+// calibrate compresses data with every codec, records the measurements,
+// and returns the output of the codec currently preferred.
+func (c *batchCompressor) calibrate(data []byte) ([]byte, string, error) {
+	outputs := make(map[string][]byte, len(compressionCodecs))
+
+	for _, codec := range compressionCodecs {
+		start := time.Now()
+		body, err := c.encode(codec, data)
+		if err != nil {
+			return nil, "", err
+		}
+		elapsed := time.Since(start)
+		outputs[codec] = body
+
+		c.mu.Lock()
+		sample := c.samples[codec]
+		if sample == nil {
+			sample = &codecSample{}
+			c.samples[codec] = sample
+		}
+		sample.in += int64(len(data))
+		sample.out += int64(len(body))
+		sample.elapsed += elapsed
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	c.chosen = c.selectCodec()
+	codec := c.chosen
+	c.mu.Unlock()
+
+	return outputs[codec], codec, nil
+}
+
+// selectCodec returns the codec with the best measured ratio whose cost per
+// MiB is within budget. The caller must hold c.mu.
+func (c *batchCompressor) selectCodec() string {
+	best, bestRatio := CompressionIdentity, 1.0
+	for _, codec := range compressionCodecs {
+		sample := c.samples[codec]
+		if sample == nil || sample.perMiB() > c.budget {
+			continue
+		}
+		if ratio := sample.ratio(); ratio > bestRatio {
+			best, bestRatio = codec, ratio
+		}
+	}
+	return best
+}
+
+// encode compresses data with the given codec.
+func (c *batchCompressor) encode(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to gzip batch: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to gzip batch: %w", err)
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return c.zstd.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	default:
+		return data, nil
+	}
+}
+
+// codec returns the codec currently in use.
+func (c *batchCompressor) codec() string {
+	if c.mode != CompressionAuto {
+		return c.mode
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chosen
+}
@@ -0,0 +1,225 @@
+package lipservice
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Attributes on dropped records exported as the lead-up to an error.
+const (
+	// ContextRecordAttribute marks a record exported only because it
+	// preceded a sampled error
+	ContextRecordAttribute = "lipservice.context"
+
+	// ContextForAttribute is the record ID of the error a context record
+	// leads up to
+	ContextForAttribute = "lipservice.context_for"
+)
+
+// contextBufferSize is how many recently dropped records are held for
+// bundling with a later error.
+const contextBufferSize = 1024
+
+// defaultContextKeys are the attributes linking dropped records to an
+// error, besides sharing a Middleware request.
+var defaultContextKeys = []string{"trace_id", "request_id"}
+
+// contextLink is a context key and the value a record has for it. Values
+// are only formatted when an error is matched against held records, never
+// when a record is dropped.
+type contextLink struct {
+	key   string
+	value interface{}
+}
+
+// contextLinks ties a record to others: its context keys, its Middleware
+// request and its pattern signature.
+type contextLinks struct {
+	keys      []contextLink
+	request   *requestTally
+	signature string
+}
+
+// empty reports whether nothing ties the record to another.
+func (c contextLinks) empty() bool {
+	return len(c.keys) == 0 && c.request == nil && c.signature == ""
+}
+
+// shares reports whether c and other have a link in common.
+func (c contextLinks) shares(other contextLinks) bool {
+	if c.request != nil && c.request == other.request {
+		return true
+	}
+	if c.signature != "" && c.signature == other.signature {
+		return true
+	}
+	for _, a := range c.keys {
+		for _, b := range other.keys {
+			if a.key == b.key && sameLinkValue(a.value, b.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameLinkValue compares two attribute values as they would be exported.
+func sameLinkValue(a, b interface{}) bool {
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return x == y
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// droppedRecord is a sampled-out record held for a possible bundle.
+type droppedRecord struct {
+	logger    *LipServiceLogger
+	msg       string
+	severity  string
+	timestamp time.Time
+	args      []interface{}
+	links     contextLinks
+}
+
+// contextBuffer is a ring of recently dropped records. When an error is
+// kept, the records sharing its trace, request, Middleware request or
+// pattern signature are exported with it, giving responders the lead-up to
+// the failure.
+type contextBuffer struct {
+	mu      sync.Mutex
+	records []*droppedRecord
+	next    int
+	limit   int
+	keys    []string
+}
+
+// newContextBuffer returns the buffer for config, or nil if ContextRecords
+// is not set.
+func newContextBuffer(config Config) *contextBuffer {
+	if config.ContextRecords <= 0 {
+		return nil
+	}
+	keys := config.ContextKeys
+	if len(keys) == 0 {
+		keys = defaultContextKeys
+	}
+	return &contextBuffer{
+		records: make([]*droppedRecord, contextBufferSize),
+		limit:   config.ContextRecords,
+		keys:    keys,
+	}
+}
+
+// links returns what ties a record to others: its context keys, looked up
+// in args and then in the logger's bound attributes, its Middleware
+// request and its signature.
+func (b *contextBuffer) links(l *LipServiceLogger, signature string, args []interface{}) contextLinks {
+	links := contextLinks{request: l.tally, signature: signature}
+	for _, key := range b.keys {
+		if value, ok := lookupAttribute(key, args, l.attrs); ok {
+			links.keys = append(links.keys, contextLink{key: key, value: value})
+		}
+	}
+	return links
+}
+
+// hold keeps a dropped record if it can be linked to a later error.
+func (b *contextBuffer) hold(l *LipServiceLogger, severity, msg, signature string, args []interface{}) {
+	if b == nil {
+		return
+	}
+	links := b.links(l, signature, args)
+	if links.empty() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.records[b.next] = &droppedRecord{
+		logger:    l,
+		msg:       msg,
+		severity:  severity,
+		timestamp: time.Now(),
+		args:      args,
+		links:     links,
+	}
+	b.next = (b.next + 1) % len(b.records)
+}
+
+// take removes and returns up to limit held records linked to an error,
+// oldest first.
+func (b *contextBuffer) take(l *LipServiceLogger, signature string, args []interface{}) []*droppedRecord {
+	if b == nil {
+		return nil
+	}
+	links := b.links(l, signature, args)
+	if links.empty() {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var taken []*droppedRecord
+	for i := 1; i <= len(b.records) && len(taken) < b.limit; i++ {
+		slot := (b.next - i + len(b.records)) % len(b.records)
+		record := b.records[slot]
+		if record == nil || !record.links.shares(links) {
+			continue
+		}
+		taken = append(taken, record)
+		b.records[slot] = nil
+	}
+
+	// Collected newest first; export in the order they were logged
+	for i, j := 0, len(taken)-1; i < j; i, j = i+1, j-1 {
+		taken[i], taken[j] = taken[j], taken[i]
+	}
+	return taken
+}
+
+// lookupAttribute finds key in args, then in bound.
+func lookupAttribute(key string, args, bound []interface{}) (interface{}, bool) {
+	var value interface{}
+	found := false
+	for _, kvs := range [][]interface{}{args, bound} {
+		eachAttribute(kvs, func(k string, v interface{}) bool {
+			if k == key {
+				value, found = v, true
+			}
+			return !found
+		})
+		if found {
+			break
+		}
+	}
+	return value, found
+}
+
+// exportContext exports the dropped records leading up to a kept error to
+// the same sinks, linked to it by record ID when one was assigned and to
+// their pattern by signature.
+func (l *LipServiceLogger) exportContext(sinks []string, signature string, args []interface{}, attributes recordFields) {
+	for _, record := range l.contexts.take(l, signature, args) {
+		attrs := make(recordFields, 0, len(record.args)/2+3)
+		attrs.add(record.args)
+		attrs.set(ContextRecordAttribute, true)
+		if id, ok := attributes.get(RecordIDAttribute); ok {
+			attrs.set(ContextForAttribute, id)
+		}
+		if record.links.signature != "" {
+			attrs.set(SignatureAttribute, record.links.signature)
+		}
+
+		timestamp := record.timestamp
+		if eventTime, ok := attrs.value(EventTimeAttribute).(time.Time); ok {
+			timestamp = eventTime
+			attrs.remove(EventTimeAttribute)
+		}
+		record.logger.exportRecord(sinks, record.msg, record.severity, timestamp, attrs)
+	}
+}
@@ -0,0 +1,171 @@
+package lipservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCoordinationInterval is how often an instance reports its volume
+// when coordination is enabled without an explicit interval.
+const defaultCoordinationInterval = time.Minute
+
+// coordinationReport is the volume report an instance sends to the backend.
+type coordinationReport struct {
+	ServiceName       string  `json:"service_name"`
+	InstanceID        string  `json:"instance_id"`
+	ObservedPerMinute float64 `json:"observed_per_minute"`
+}
+
+// coordinationResponse carries the instance-specific rate multiplier
+// returned by the backend.
+type coordinationResponse struct {
+	RateMultiplier float64 `json:"rate_multiplier"`
+}
+
+// coordinator tracks local log volume and the rate multiplier assigned to
+// this instance by the backend.
+type coordinator struct {
+	instanceID string
+	interval   time.Duration
+	observed   atomic.Int64
+	multiplier float64
+
+	// mu guards lastReport, which the coordination loop and serverless
+	// refreshes both read and write
+	mu         sync.Mutex
+	lastReport time.Time
+}
+
+// newCoordinator creates a coordinator, or returns nil if coordination is
+// disabled or the sampler is offline.
+func newCoordinator(config Config) *coordinator {
+	if !config.CoordinationEnabled || config.Offline {
+		return nil
+	}
+
+	instanceID := config.InstanceID
+	if instanceID == "" {
+		instanceID = defaultInstanceID()
+	}
+
+	interval := config.CoordinationInterval
+	if interval == 0 {
+		interval = defaultCoordinationInterval
+	}
+
+	return &coordinator{
+		instanceID: instanceID,
+		interval:   interval,
+		lastReport: time.Now(),
+		multiplier: 1.0,
+	}
+}
+
+// due reports whether a volume report is due at now.
+func (c *coordinator) due(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return now.Sub(c.lastReport) >= c.interval
+}
+
+// defaultInstanceID identifies this process as hostname-pid.
+func defaultInstanceID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// coordinationLoop periodically reports volume and refreshes the rate
+// multiplier.
+func (s *AdaptiveSampler) coordinationLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.coordinator.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.coordinate(ctx); err != nil {
+				s.diag.log(slog.Default(), "WARN", "Rate coordination failed", "error", err)
+			}
+		}
+	}
+}
+
+// coordinate sends this instance's observed volume to the backend and
+// applies the returned rate multiplier.
+func (s *AdaptiveSampler) coordinate(ctx context.Context) error {
+	if !s.BackendSupports(CapabilityRateCoordination) {
+		return nil
+	}
+
+	c := s.coordinator
+	now := time.Now()
+	c.mu.Lock()
+	elapsed := now.Sub(c.lastReport)
+	c.lastReport = now
+	c.mu.Unlock()
+
+	observed := c.observed.Swap(0)
+	perMinute := 0.0
+	if elapsed > 0 {
+		perMinute = float64(observed) / elapsed.Minutes()
+	}
+
+	body, err := json.Marshal(coordinationReport{
+		ServiceName:       s.config.ServiceName,
+		InstanceID:        c.instanceID,
+		ObservedPerMinute: perMinute,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal coordination report: %w", err)
+	}
+
+	req, err := s.newBackendRequest("POST", "/api/v1/coordination/"+url.PathEscape(s.config.ServiceName), body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("LipService returned status %d", resp.StatusCode)
+	}
+
+	var result coordinationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode coordination response: %w", err)
+	}
+	if result.RateMultiplier <= 0 {
+		return fmt.Errorf("invalid rate multiplier %v", result.RateMultiplier)
+	}
+
+	s.mu.Lock()
+	c.multiplier = result.RateMultiplier
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RateMultiplier returns the rate multiplier currently assigned to this
+// instance by the backend (1.0 when coordination is disabled).
+func (s *AdaptiveSampler) RateMultiplier() float64 {
+	if s.coordinator == nil {
+		return 1.0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.coordinator.multiplier
+}
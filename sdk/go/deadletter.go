@@ -0,0 +1,193 @@
+package lipservice
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// DeadLetter is a record that could not be delivered, with why.
+type DeadLetter struct {
+	// Time is when the record was dead-lettered
+	Time time.Time
+
+	// Reason is DropReasonRejected for records PostHog refused as invalid,
+	// or DropReasonExportFailed for records that exhausted their retries
+	Reason string
+
+	// Error is the last delivery error
+	Error string
+
+	// Attempts is the number of delivery attempts made
+	Attempts int
+
+	// Endpoint is the PostHog endpoint the record was sent to
+	Endpoint string
+
+	// Service is the service.name of the record's resource
+	Service string
+
+	// Record is the OTLP log record, ready to be resent
+	Record *logs.LogRecord
+}
+
+// DeadLetterQueue receives records that could not be delivered, so no data
+// silently evaporates. FileDeadLetterQueue and DeadLetterFunc are
+// DeadLetterQueues.
+type DeadLetterQueue interface {
+	DeadLetter(letter DeadLetter) error
+}
+
+// DeadLetterFunc adapts a function to a DeadLetterQueue.
+type DeadLetterFunc func(letter DeadLetter) error
+
+// DeadLetter calls f.
+func (f DeadLetterFunc) DeadLetter(letter DeadLetter) error {
+	return f(letter)
+}
+
+// deadLetterEntry is the JSON form of a DeadLetter.
+type deadLetterEntry struct {
+	Time     time.Time       `json:"time"`
+	Reason   string          `json:"reason"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	Endpoint string          `json:"endpoint"`
+	Service  string          `json:"service,omitempty"`
+	Record   json.RawMessage `json:"record"`
+}
+
+// FileDeadLetterQueue appends dead letters to a JSON-lines file, which
+// lipservice-replay can resend once the cause is fixed.
+type FileDeadLetterQueue struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileDeadLetterQueue opens path for appending, creating it if needed.
+func NewFileDeadLetterQueue(path string) (*FileDeadLetterQueue, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	return &FileDeadLetterQueue{file: file}, nil
+}
+
+// DeadLetter appends letter to the file.
+func (q *FileDeadLetterQueue) DeadLetter(letter DeadLetter) error {
+	record, err := protojson.Marshal(letter.Record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	line, err := json.Marshal(deadLetterEntry{
+		Time:     letter.Time,
+		Reason:   letter.Reason,
+		Error:    letter.Error,
+		Attempts: letter.Attempts,
+		Endpoint: letter.Endpoint,
+		Service:  letter.Service,
+		Record:   record,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, err = q.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (q *FileDeadLetterQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
+
+// ReadDeadLetters decodes dead letters written by FileDeadLetterQueue.
+func ReadDeadLetters(r io.Reader) ([]DeadLetter, error) {
+	var letters []DeadLetter
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry deadLetterEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid dead letter on line %d: %w", line, err)
+		}
+
+		record := &logs.LogRecord{}
+		if err := protojson.Unmarshal(entry.Record, record); err != nil {
+			return nil, fmt.Errorf("invalid dead letter record on line %d: %w", line, err)
+		}
+
+		letters = append(letters, DeadLetter{
+			Time:     entry.Time,
+			Reason:   entry.Reason,
+			Error:    entry.Error,
+			Attempts: entry.Attempts,
+			Endpoint: entry.Endpoint,
+			Service:  entry.Service,
+			Record:   record,
+		})
+	}
+
+	return letters, scanner.Err()
+}
+
+// openDeadLetterQueue returns config.DeadLetterQueue, or a file queue at
+// config.DeadLetterPath, or nil if neither is set. The returned closer is
+// non-nil only for a queue opened here.
+func openDeadLetterQueue(config Config) (DeadLetterQueue, io.Closer, error) {
+	if config.DeadLetterQueue != nil {
+		return config.DeadLetterQueue, nil, nil
+	}
+	if config.DeadLetterPath == "" {
+		return nil, nil, nil
+	}
+
+	queue, err := NewFileDeadLetterQueue(config.DeadLetterPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return queue, queue, nil
+}
+
+// deadLetter hands undeliverable records to the dead-letter queue, if one
+// is configured.
+func (e *PostHogExporter) deadLetter(reason string, cause error, records ...*logs.LogRecord) {
+	if e.deadLetters == nil {
+		return
+	}
+
+	attempts := 1
+	if reason == DropReasonExportFailed {
+		attempts = e.config.MaxRetries + 1
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		err := e.deadLetters.DeadLetter(DeadLetter{
+			Time:     now,
+			Reason:   reason,
+			Error:    cause.Error(),
+			Attempts: attempts,
+			Endpoint: e.config.PostHogEndpoint,
+			Service:  e.config.ServiceName,
+			Record:   record,
+		})
+		if err != nil {
+			e.diag.log(slog.Default(), "ERROR", "Failed to write dead letter", "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package lipservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// sendLatencyAlpha is the EWMA smoothing factor for export request latency.
+const sendLatencyAlpha = 0.2
+
+// flushByPriority sends records one priority class at a time, highest
+// first, while the deadline leaves time for another request. Records there's
+// no time for are spooled, or put back in the buffer for the next flush
+// without a spool.
+func (e *PostHogExporter) flushByPriority(ctx context.Context, records []*logs.LogRecord) error {
+	tiers := priorityTiers(records)
+
+	var err error
+	var leftover []*logs.LogRecord
+	for i, tier := range tiers {
+		// High and critical records, or else the most urgent class, are
+		// always attempted
+		urgent := i == 0 || recordPriority(tier[0]) >= PriorityHigh
+		if urgent || (err == nil && e.timeForSend(ctx)) {
+			err = e.sendRecords(ctx, tier)
+			continue
+		}
+
+		if serr := e.spoolRecords(tier); serr != nil {
+			// Nowhere safe to put them; keep them for the next flush
+			leftover = append(leftover, tier...)
+		}
+	}
+
+	if len(leftover) > 0 {
+		e.mu.Lock()
+		e.batch = append(leftover, e.batch...)
+		e.enforceBufferLimit()
+		e.recountBatchBytes()
+		e.mu.Unlock()
+	}
+
+	return err
+}
+
+// timeForSend reports whether ctx's deadline leaves time for one more
+// request, judged by recent request latency.
+func (e *PostHogExporter) timeForSend(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+	return time.Until(deadline) > time.Duration(e.sendLatency.Load())
+}
+
+// spoolRecords writes records to the disk spool without attempting to send
+// them.
+func (e *PostHogExporter) spoolRecords(records []*logs.LogRecord) error {
+	if e.spool == nil {
+		return fmt.Errorf("no spool configured")
+	}
+
+	data, err := proto.Marshal(e.createOTLPRequest(records))
+	if err != nil {
+		return fmt.Errorf("failed to marshal OTLP request: %w", err)
+	}
+	if err := e.spool.write(data, len(records)); err != nil {
+		return err
+	}
+
+	e.stats.spooled.Add(int64(len(records)))
+	return nil
+}
+
+// observeSendLatency folds a successful request's duration into the EWMA.
+func (e *PostHogExporter) observeSendLatency(d time.Duration) {
+	for {
+		old := e.sendLatency.Load()
+		next := old + int64(sendLatencyAlpha*float64(int64(d)-old))
+		if old == 0 {
+			next = int64(d)
+		}
+		if e.sendLatency.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
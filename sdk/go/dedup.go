@@ -0,0 +1,215 @@
+package lipservice
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// dedupMaxEntries bounds the number of distinct records tracked by the
+// deduplication window.
+const dedupMaxEntries = 10000
+
+// RepeatCountAttribute is attached to the record logged when a dedup
+// window closes, counting the identical records suppressed within it.
+const RepeatCountAttribute = "lipservice.repeat_count"
+
+// SamplingReasonRepeatSummary is the sampling reason of the records logged
+// when dedup windows close, which are kept without a sampling decision so
+// the count they carry is never lost.
+const SamplingReasonRepeatSummary = "repeat_summary"
+
+// dedupEntry tracks one distinct record within the deduplication window.
+type dedupEntry struct {
+	firstSeen time.Time
+	repeat    *dedupRepeat
+}
+
+// dedupRepeat is a record suppressed within a dedup window, kept so it can
+// be logged once with its count when the window closes.
+type dedupRepeat struct {
+	logger   *LipServiceLogger
+	severity string
+	msg      string
+	args     []interface{}
+	count    int
+	last     time.Time
+}
+
+// deduper suppresses identical records seen within a time window.
+type deduper struct {
+	window  time.Duration
+	mu      sync.Mutex
+	entries map[uint64]*dedupEntry
+}
+
+// newDeduper creates a deduper, or returns nil if window is zero.
+func newDeduper(window time.Duration) *deduper {
+	if window <= 0 {
+		return nil
+	}
+	return &deduper{
+		window:  window,
+		entries: make(map[uint64]*dedupEntry),
+	}
+}
+
+// check reports whether a record logged through l should be emitted. When
+// it should, any windows it closed that suppressed repeats are returned to
+// be logged.
+func (d *deduper) check(l *LipServiceLogger, severity, msg string, args []interface{}, now time.Time) (bool, []*dedupRepeat) {
+	key := dedupKey(severity, msg, l.category, l.attrs, args)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[key]
+	if ok && now.Sub(entry.firstSeen) < d.window {
+		if entry.repeat == nil {
+			// The caller may reuse args, so the repeat keeps its own copy
+			entry.repeat = &dedupRepeat{
+				logger:   l,
+				severity: severity,
+				msg:      msg,
+				args:     append([]interface{}(nil), args...),
+			}
+		}
+		entry.repeat.count++
+		entry.repeat.last = now
+		return false, nil
+	}
+
+	var closed []*dedupRepeat
+	if ok && entry.repeat != nil {
+		closed = append(closed, entry.repeat)
+	}
+	if len(d.entries) >= dedupMaxEntries {
+		closed = append(closed, d.sweep(now)...)
+	}
+	d.entries[key] = &dedupEntry{firstSeen: now}
+
+	return true, closed
+}
+
+// closed removes the windows that have closed by now, returning those
+// that suppressed repeats.
+func (d *deduper) closed(now time.Time) []*dedupRepeat {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sweep(now)
+}
+
+// drain returns every repeat suppressed so far, closed window or not. Open
+// windows keep suppressing, counting from zero again.
+func (d *deduper) drain() []*dedupRepeat {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var repeats []*dedupRepeat
+	for _, entry := range d.entries {
+		if entry.repeat != nil {
+			repeats = append(repeats, entry.repeat)
+			entry.repeat = nil
+		}
+	}
+	return repeats
+}
+
+// sweep removes entries whose window has expired, returning the repeats
+// they suppressed. Callers must hold d.mu.
+func (d *deduper) sweep(now time.Time) []*dedupRepeat {
+	var repeats []*dedupRepeat
+	for key, entry := range d.entries {
+		if now.Sub(entry.firstSeen) >= d.window {
+			if entry.repeat != nil {
+				repeats = append(repeats, entry.repeat)
+			}
+			delete(d.entries, key)
+		}
+	}
+
+	// Everything is still live; start over rather than grow without bound
+	if len(d.entries) >= dedupMaxEntries {
+		for _, entry := range d.entries {
+			if entry.repeat != nil {
+				repeats = append(repeats, entry.repeat)
+			}
+		}
+		d.entries = make(map[uint64]*dedupEntry)
+	}
+	return repeats
+}
+
+// dedupKey hashes the parts of a record that make it identical to another:
+// its severity, message, category, and bound and own attributes. Every
+// part is length-prefixed, so no two different records share a key by
+// concatenating alike.
+func dedupKey(severity, msg, category string, bound, args []interface{}) uint64 {
+	h := fnv.New64a()
+	writeDedupPart(h, severity)
+	writeDedupPart(h, msg)
+	writeDedupPart(h, category)
+	for _, part := range [][]interface{}{bound, args} {
+		writeDedupPart(h, fmt.Sprint(len(part)))
+		for _, value := range part {
+			writeDedupPart(h, fmt.Sprintf("%T", value))
+			writeDedupPart(h, fmt.Sprint(value))
+		}
+	}
+	return h.Sum64()
+}
+
+// writeDedupPart writes s to h after its length.
+func writeDedupPart(h hash.Hash64, s string) {
+	var prefix [binary.MaxVarintLen64]byte
+	h.Write(prefix[:binary.PutUvarint(prefix[:], uint64(len(s)))])
+	h.Write([]byte(s))
+}
+
+// logRepeats logs each repeat once, through the logger that suppressed
+// it, with RepeatCountAttribute set to how many it stands for. The summary
+// takes the place of one suppressed duplicate in the delivery counts and
+// is never sampled out. Live repeats are logged as of now, and imported
+// ones as of the last repeat.
+func logRepeats(repeats []*dedupRepeat) {
+	for _, repeat := range repeats {
+		logger := *repeat.logger
+		logger.deduper = nil
+		logger.unsampled = SamplingReasonRepeatSummary
+
+		now := time.Now()
+		if logger.imports != nil {
+			now = repeat.last
+		}
+		args := append(repeat.args[:len(repeat.args):len(repeat.args)], RepeatCountAttribute, repeat.count)
+		logger.process(repeat.severity, repeat.msg, now, args)
+	}
+}
+
+// dedupLoop logs the repeats of closed dedup windows until ls is closed,
+// so a burst that ends without another record still gets its count.
+func (ls *LipService) dedupLoop(ctx context.Context) {
+	ticker := time.NewTicker(ls.logger.deduper.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			logRepeats(ls.logger.deduper.closed(now))
+		}
+	}
+}
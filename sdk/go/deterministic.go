@@ -0,0 +1,41 @@
+package lipservice
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"time"
+)
+
+// defaultDeterministicWindow is the time bucket width used when
+// deterministic sampling is enabled without an explicit window.
+const defaultDeterministicWindow = 10 * time.Second
+
+// deterministicDecision returns a keep/drop decision that depends only on
+// the signature, the time bucket and the rate, so every replica of a service
+// reaches the same decision for the same pattern in the same window.
+func deterministicDecision(signature string, bucket int64, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(bucket))
+
+	h := fnv.New64a()
+	h.Write([]byte(signature))
+	h.Write(buf[:])
+
+	// Use the top 53 bits so the value maps exactly onto a float64 in [0, 1)
+	return float64(h.Sum64()>>11)/(1<<53) < rate
+}
+
+// timeBucket returns the index of the window containing t.
+func timeBucket(t time.Time, window time.Duration) int64 {
+	if window <= 0 {
+		window = defaultDeterministicWindow
+	}
+	return t.UnixNano() / int64(window)
+}
@@ -0,0 +1,137 @@
+package lipservice
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Diagnostics defaults.
+const (
+	defaultDiagnosticsLevel    = "WARN"
+	defaultDiagnosticsInterval = time.Minute
+)
+
+// diagnostics reports the SDK's own problems, such as failed exports,
+// throttled so an outage logs each problem once per interval rather than
+// once per record.
+type diagnostics struct {
+	level    string
+	interval time.Duration
+	sink     LogSink
+
+	mu   sync.Mutex
+	seen map[string]*diagnosticState
+}
+
+// diagnosticKeyAttributes name the attributes that tell apart the same
+// diagnostic about different things, such as two export endpoints, so each
+// is throttled on its own.
+var diagnosticKeyAttributes = map[string]bool{
+	"endpoint": true,
+	"exporter": true,
+	"path":     true,
+	"segment":  true,
+	"sink":     true,
+	"url":      true,
+}
+
+// diagnosticState tracks one diagnostic within its interval.
+type diagnosticState struct {
+	last       time.Time
+	suppressed int
+}
+
+// newDiagnostics creates the diagnostics logger for config.
+func newDiagnostics(config Config) *diagnostics {
+	level := config.DiagnosticsLevel
+	if level == "" {
+		level = defaultDiagnosticsLevel
+	}
+	interval := config.DiagnosticsInterval
+	if interval <= 0 {
+		interval = defaultDiagnosticsInterval
+	}
+	return &diagnostics{
+		level:    level,
+		interval: interval,
+		sink:     config.DiagnosticsSink,
+		seen:     make(map[string]*diagnosticState),
+	}
+}
+
+// checkDiagnosticsLevel validates Config.DiagnosticsLevel.
+func checkDiagnosticsLevel(config Config) error {
+	if config.DiagnosticsLevel != "" && !knownSeverity(config.DiagnosticsLevel) {
+		return fmt.Errorf("unknown diagnostics level %q", config.DiagnosticsLevel)
+	}
+	return nil
+}
+
+// log reports a diagnostic to the DiagnosticsSink, or to base without one.
+// Repeats of msg about the same endpoint, segment or other subject within
+// the interval are counted rather than logged, and the next report carries
+// how many were suppressed.
+func (d *diagnostics) log(base *slog.Logger, severity, msg string, args ...interface{}) {
+	if severityNumber(severity) < severityNumber(d.level) {
+		return
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	key := diagnosticKey(msg, args)
+	state, ok := d.seen[key]
+	if !ok {
+		state = &diagnosticState{}
+		d.seen[key] = state
+	}
+	if ok && now.Sub(state.last) < d.interval {
+		state.suppressed++
+		d.mu.Unlock()
+		return
+	}
+	suppressed := state.suppressed
+	state.last, state.suppressed = now, 0
+	d.mu.Unlock()
+
+	if suppressed > 0 {
+		args = append(args[:len(args):len(args)], "suppressed", suppressed)
+	}
+
+	if d.sink != nil {
+		attributes := make(map[string]interface{}, len(args)/2)
+		addAttributes(attributes, args)
+		d.sink.ExportLog(msg, severity, now, attributes)
+		return
+	}
+
+	// A SlogHandler installed as the default would export diagnostics
+	// about its own exports, so it names where they go instead
+	if h, ok := base.Handler().(*SlogHandler); ok {
+		base = h.diagnostics
+	}
+
+	switch severity {
+	case "ERROR", "CRITICAL", "FATAL":
+		base.Error(msg, args...)
+	case "WARN", "WARNING":
+		base.Warn(msg, args...)
+	case "INFO":
+		base.Info(msg, args...)
+	default:
+		base.Debug(msg, args...)
+	}
+}
+
+// diagnosticKey identifies a diagnostic for throttling: its message plus
+// the values of any diagnosticKeyAttributes in args.
+func diagnosticKey(msg string, args []interface{}) string {
+	key := msg
+	for i := 0; i+1 < len(args); i += 2 {
+		if name, ok := args[i].(string); ok && diagnosticKeyAttributes[name] {
+			key += fmt.Sprintf("\x00%s=%v", name, args[i+1])
+		}
+	}
+	return key
+}
@@ -0,0 +1,288 @@
+package lipservice
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// encryptedValuePrefix marks attribute values encrypted by the SDK with
+// AttributeEncryptionKey so they can be recognised and decrypted downstream.
+const encryptedValuePrefix = "enc:v1:"
+
+// envelopeValuePrefix marks attribute values encrypted with a data key
+// wrapped by a KeyProvider. The wrapped key travels with the value.
+const envelopeValuePrefix = "enc:v2:"
+
+// envelopeDataKeySize is the length of the AES-256 data keys generated for
+// envelope encryption.
+const envelopeDataKeySize = 32
+
+// KeyProvider wraps and unwraps the data keys used for envelope encryption
+// of EncryptedAttributes, typically by calling a KMS. The SDK generates one
+// data key per process and wraps it once; decrypting unwraps it once per
+// distinct wrapped key.
+type KeyProvider interface {
+	// WrapKey encrypts a data key under the provider's key encryption key
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+
+	// UnwrapKey reverses WrapKey
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// attributeEncryptor encrypts the values of designated attributes with
+// AES-GCM before they leave the process.
+type attributeEncryptor struct {
+	aead      cipher.AEAD
+	prefix    string
+	keys      map[string]struct{}
+	decrypter *Decrypter
+}
+
+// newAttributeEncryptor creates an encryptor for the configured attributes,
+// or returns nil if none are designated.
+func newAttributeEncryptor(config Config) (*attributeEncryptor, error) {
+	if len(config.EncryptedAttributes) == 0 {
+		return nil, nil
+	}
+	if config.AttributeKeyProvider != nil && len(config.AttributeEncryptionKey) > 0 {
+		return nil, fmt.Errorf("attribute encryption takes either a key or a key provider, not both")
+	}
+
+	keys := make(map[string]struct{}, len(config.EncryptedAttributes))
+	for _, key := range config.EncryptedAttributes {
+		keys[key] = struct{}{}
+	}
+
+	if config.AttributeKeyProvider == nil {
+		decrypter, err := NewDecrypter(config.AttributeEncryptionKey, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &attributeEncryptor{aead: decrypter.aead, prefix: encryptedValuePrefix, keys: keys, decrypter: decrypter}, nil
+	}
+
+	// Envelope encryption: a fresh data key, wrapped once by the provider
+	dataKey := make([]byte, envelopeDataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := config.AttributeKeyProvider.WrapKey(context.Background(), dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey := base64.StdEncoding.EncodeToString(wrapped)
+	decrypter, err := NewDecrypter(nil, config.AttributeKeyProvider)
+	if err != nil {
+		return nil, err
+	}
+	decrypter.unwrapped[wrappedKey] = aead
+
+	return &attributeEncryptor{
+		aead:      aead,
+		prefix:    envelopeValuePrefix + wrappedKey + ":",
+		keys:      keys,
+		decrypter: decrypter,
+	}, nil
+}
+
+// newAEAD creates an AES-GCM cipher for key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("attribute encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// apply returns attributes with designated values encrypted. The input map
+// is returned unchanged when it holds no designated attributes.
+func (e *attributeEncryptor) apply(attributes map[string]interface{}) (map[string]interface{}, error) {
+	var encrypted map[string]interface{}
+	for key, value := range attributes {
+		if _, ok := e.keys[key]; !ok {
+			continue
+		}
+
+		if encrypted == nil {
+			encrypted = make(map[string]interface{}, len(attributes))
+			for k, v := range attributes {
+				encrypted[k] = v
+			}
+		}
+
+		ciphertext, err := e.encrypt(key, fmt.Sprintf("%v", value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt attribute %q: %w", key, err)
+		}
+		encrypted[key] = ciphertext
+	}
+
+	if encrypted == nil {
+		return attributes, nil
+	}
+	return encrypted, nil
+}
+
+// encrypt seals the plaintext of attribute key and returns the prefixed,
+// base64-encoded nonce||ciphertext.
+func (e *attributeEncryptor) encrypt(key, plaintext string) (string, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := e.aead.Seal(nonce, nonce, []byte(plaintext), encryptionAAD(e.prefix, key))
+	return e.prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt reverses encrypt.
+func (e *attributeEncryptor) decrypt(key, value string) (string, error) {
+	return e.decrypter.Decrypt(context.Background(), key, value)
+}
+
+// encryptionAAD binds a ciphertext to its attribute key and to the key it
+// was sealed with, named by the value's prefix, so a value moved to
+// another attribute fails to decrypt.
+func encryptionAAD(prefix, key string) []byte {
+	return []byte(prefix + key)
+}
+
+// Decrypter recovers attribute values encrypted by the SDK, for consumers
+// authorized to read them. It is safe for concurrent use.
+type Decrypter struct {
+	aead     cipher.AEAD
+	provider KeyProvider
+	unwraps  singleflight.Group
+
+	mu        sync.Mutex
+	unwrapped map[string]cipher.AEAD
+}
+
+// NewDecrypter creates a Decrypter for values encrypted with key (the
+// AttributeEncryptionKey), provider (the AttributeKeyProvider), or both.
+func NewDecrypter(key []byte, provider KeyProvider) (*Decrypter, error) {
+	d := &Decrypter{provider: provider, unwrapped: make(map[string]cipher.AEAD)}
+	if key != nil || provider == nil {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		d.aead = aead
+	}
+	return d, nil
+}
+
+// Decrypt returns the plaintext of the encrypted value of attribute key, as
+// named in EncryptedAttributes. Values only decrypt under the attribute
+// they were encrypted for.
+func (d *Decrypter) Decrypt(ctx context.Context, key, value string) (string, error) {
+	var aead cipher.AEAD
+	var payload string
+	switch {
+	case strings.HasPrefix(value, encryptedValuePrefix):
+		if d.aead == nil {
+			return "", fmt.Errorf("value is encrypted with a static key, but none was given")
+		}
+		aead, payload = d.aead, value[len(encryptedValuePrefix):]
+	case strings.HasPrefix(value, envelopeValuePrefix):
+		wrappedKey, rest, ok := strings.Cut(value[len(envelopeValuePrefix):], ":")
+		if !ok {
+			return "", fmt.Errorf("envelope value has no wrapped key")
+		}
+		var err error
+		if aead, err = d.unwrap(ctx, wrappedKey); err != nil {
+			return "", err
+		}
+		payload = rest
+	default:
+		return "", fmt.Errorf("value is not encrypted")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	prefix := value[:len(value)-len(payload)]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, encryptionAAD(prefix, key))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// unwrap returns the cipher for a wrapped data key, asking the provider
+// the first time it is seen. The provider is called without holding d.mu,
+// and concurrent decrypts of a new key share one call.
+func (d *Decrypter) unwrap(ctx context.Context, wrappedKey string) (cipher.AEAD, error) {
+	if aead, ok := d.cached(wrappedKey); ok {
+		return aead, nil
+	}
+	if d.provider == nil {
+		return nil, fmt.Errorf("value is envelope encrypted, but no key provider was given")
+	}
+
+	aead, err, _ := d.unwraps.Do(wrappedKey, func() (interface{}, error) {
+		// An earlier call may have finished since the check above
+		if aead, ok := d.cached(wrappedKey); ok {
+			return aead, nil
+		}
+
+		wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode wrapped key: %w", err)
+		}
+		dataKey, err := d.provider.UnwrapKey(ctx, wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+		}
+		aead, err := newAEAD(dataKey)
+		if err != nil {
+			return nil, err
+		}
+
+		d.mu.Lock()
+		d.unwrapped[wrappedKey] = aead
+		d.mu.Unlock()
+		return aead, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aead.(cipher.AEAD), nil
+}
+
+// cached returns the cipher already unwrapped for wrappedKey, if any.
+func (d *Decrypter) cached(wrappedKey string) (cipher.AEAD, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	aead, ok := d.unwrapped[wrappedKey]
+	return aead, ok
+}
@@ -0,0 +1,83 @@
+package lipservice
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Decision is a Sampler's verdict on a record.
+type Decision struct {
+	// Keep reports whether the record is kept
+	Keep bool
+
+	// Rate is the keep probability the decision was made at, carried on
+	// kept records so they can be re-weighted downstream; zero means the
+	// record's own Rate
+	Rate float64
+}
+
+// Sampler makes the final keep/drop decision for a record. The record's
+// Signature, Rate and Seen are filled in by the adaptive sampler, Rate
+// being the keep probability it arrived at from policy, pattern statistics
+// and fleet coordination. Samplers are called concurrently, without the
+// adaptive sampler's lock held, and must be safe for concurrent use.
+// ERROR, CRITICAL and FATAL records are always kept and never reach the
+// sampler.
+type Sampler interface {
+	ShouldSample(r Record) Decision
+}
+
+// SamplerFunc adapts a function to the Sampler interface.
+type SamplerFunc func(r Record) Decision
+
+// ShouldSample calls f(r).
+func (f SamplerFunc) ShouldSample(r Record) Decision {
+	return f(r)
+}
+
+// RandomSampler keeps each record independently with probability Rate.
+func RandomSampler() Sampler {
+	return SamplerFunc(func(r Record) Decision {
+		return Decision{Keep: rand.Float64() < r.Rate}
+	})
+}
+
+// DeterministicSampler keeps records based on a hash of (signature, time
+// bucket, rate), so every replica makes the same decision for a pattern
+// within a window.
+func DeterministicSampler(window time.Duration) Sampler {
+	return SamplerFunc(func(r Record) Decision {
+		return Decision{Keep: deterministicDecision(r.Signature, timeBucket(r.Timestamp, window), r.Rate)}
+	})
+}
+
+// newSampler selects the sampler for a configuration.
+func newSampler(config Config) Sampler {
+	var sampler Sampler
+	switch {
+	case config.Sampler != nil:
+		sampler = config.Sampler
+	case config.DeterministicSampling:
+		sampler = DeterministicSampler(config.DeterministicWindow)
+	default:
+		sampler = RandomSampler()
+	}
+
+	if config.ImportanceScoring {
+		model := DefaultImportanceModel()
+		if config.ImportanceModel != nil {
+			model = *config.ImportanceModel
+		}
+		sampler = ImportanceSampler(sampler, model)
+	}
+
+	return sampler
+}
+
+// decisionRate returns the rate a decision was made at.
+func decisionRate(d Decision, rate float64) float64 {
+	if d.Rate > 0 {
+		return d.Rate
+	}
+	return rate
+}
@@ -0,0 +1,55 @@
+package lipservice
+
+import (
+	"sort"
+	"sync/atomic"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// resourceEnrichment holds the static resource attributes injected by the
+// sampling policy. It is shared by the home and regional exporters.
+type resourceEnrichment struct {
+	attributes atomic.Pointer[[]*common.KeyValue]
+}
+
+// apply replaces the enrichment with the policy's attributes. It runs with
+// the sampler lock held, so it must not block.
+func (r *resourceEnrichment) apply(policy *SamplingPolicy) {
+	keys := make([]string, 0, len(policy.Attributes))
+	for key := range policy.Attributes {
+		// The SDK's own resource attributes can't be overridden centrally
+		if key == "service.name" || key == "service.version" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]*common.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, stringKeyValue(key, policy.Attributes[key]))
+	}
+	r.attributes.Store(&kvs)
+}
+
+// get returns the current enrichment attributes.
+func (r *resourceEnrichment) get() []*common.KeyValue {
+	if kvs := r.attributes.Load(); kvs != nil {
+		return *kvs
+	}
+	return nil
+}
+
+// onPolicyChange registers fn to be called with every policy the sampler
+// applies, and immediately with the current one. fn runs with the sampler
+// lock held, so it must not block or call back into the sampler.
+func (s *AdaptiveSampler) onPolicyChange(fn func(*SamplingPolicy)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policyHooks = append(s.policyHooks, fn)
+	if s.policy != nil {
+		fn(s.policy)
+	}
+}
@@ -0,0 +1,321 @@
+package lipservice
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// DistinctIDAttribute is PostHog's user identifier attribute.
+const DistinctIDAttribute = "distinct_id"
+
+// ErasureReport counts the records purged by an erasure request.
+type ErasureReport struct {
+	// Buffered is the number of records purged from memory before export
+	Buffered int `json:"buffered"`
+
+	// Spooled is the number of records purged from the disk spool
+	Spooled int `json:"spooled"`
+
+	// Exporters is the number of records purged from Config.Exporters,
+	// from their buffers and from those implementing Eraser
+	Exporters int `json:"exporters"`
+
+	// Sinks is the number of records purged by sinks implementing Eraser
+	Sinks int `json:"sinks"`
+
+	// Unreadable is the number of spool segments that couldn't be read,
+	// and so may still hold matching records
+	Unreadable int `json:"unreadable,omitempty"`
+}
+
+// Total returns the number of unexported records purged.
+func (r ErasureReport) Total() int {
+	return r.Buffered + r.Spooled + r.Exporters + r.Sinks
+}
+
+// add accumulates another report into r.
+func (r *ErasureReport) add(other ErasureReport) {
+	r.Buffered += other.Buffered
+	r.Spooled += other.Spooled
+	r.Exporters += other.Exporters
+	r.Sinks += other.Sinks
+	r.Unreadable += other.Unreadable
+}
+
+// Eraser is implemented by sinks and Config.Exporters that hold records
+// themselves, so erasure requests reach them too. Erase purges records
+// whose attribute equals value and returns how many it purged.
+type Eraser interface {
+	Erase(attribute, value string) (int, error)
+}
+
+// erasureMatcher matches records carrying an identifier to be erased.
+type erasureMatcher struct {
+	attribute string
+	values    map[string]struct{}
+}
+
+// newErasureMatcher matches value, and its pseudonym if the attribute is
+// pseudonymized, since buffered records hold the pseudonym.
+func newErasureMatcher(privacy *attributePrivacy, attribute, value string) erasureMatcher {
+	values := map[string]struct{}{value: {}}
+	if pseudonymizer := privacy.pseudonymizer; pseudonymizer != nil {
+		if _, ok := pseudonymizer.keys[attribute]; ok {
+			values[pseudonymizer.pseudonym(value)] = struct{}{}
+		}
+	}
+	return erasureMatcher{attribute: attribute, values: values}
+}
+
+// matches reports whether an exported record carries the identifier.
+func (m erasureMatcher) matches(record *logs.LogRecord) bool {
+	for _, kv := range record.Attributes {
+		if kv.Key == m.attribute && m.matchesValue(anyValue(kv.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAttributes reports whether guarded attributes carry the
+// identifier.
+func (m erasureMatcher) matchesAttributes(attributes map[string]interface{}) bool {
+	value, ok := attributes[m.attribute]
+	return ok && m.matchesValue(value)
+}
+
+// matchesValue reports whether an attribute value is the identifier.
+// Values are compared in their %v form, so an exported int, bool or double
+// matches the same way as the attribute it was exported from.
+func (m erasureMatcher) matchesValue(value interface{}) bool {
+	_, ok := m.values[fmt.Sprintf("%v", value)]
+	return ok
+}
+
+// filter returns records without those matching, and how many matched.
+func (m erasureMatcher) filter(records []*logs.LogRecord) ([]*logs.LogRecord, int) {
+	kept := records[:0]
+	for _, record := range records {
+		if !m.matches(record) {
+			kept = append(kept, record)
+		}
+	}
+	return kept, len(records) - len(kept)
+}
+
+// erasureTombstones holds the identifiers erased so far, so records that
+// carry one are dropped on arrival rather than only purged once. Erasure
+// requests are rare, so the set is replaced on each one and read without
+// locking.
+type erasureTombstones struct {
+	mu       sync.Mutex
+	matchers atomic.Pointer[[]erasureMatcher]
+}
+
+// add registers an erased identifier.
+func (t *erasureTombstones) add(matcher erasureMatcher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var matchers []erasureMatcher
+	if current := t.matchers.Load(); current != nil {
+		matchers = append(matchers, *current...)
+	}
+	matchers = append(matchers, matcher)
+	t.matchers.Store(&matchers)
+}
+
+// matches reports whether a record carries an erased identifier.
+func (t *erasureTombstones) matches(record *logs.LogRecord) bool {
+	matchers := t.matchers.Load()
+	if matchers == nil {
+		return false
+	}
+	for _, matcher := range *matchers {
+		if matcher.matches(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAttributes reports whether guarded attributes carry an erased
+// identifier.
+func (t *erasureTombstones) matchesAttributes(attributes map[string]interface{}) bool {
+	matchers := t.matchers.Load()
+	if matchers == nil {
+		return false
+	}
+	for _, matcher := range *matchers {
+		if matcher.matchesAttributes(attributes) {
+			return true
+		}
+	}
+	return false
+}
+
+// Erase purges unexported records whose attribute equals value from the
+// buffer and the disk spool, for right-to-erasure requests, and drops
+// records carrying it that are exported afterwards. Records already
+// delivered to PostHog must be erased there. Values of encrypted attributes
+// can't be matched. Spool segments that can't be read are skipped, counted
+// in the report and returned as an error.
+func (e *PostHogExporter) Erase(attribute, value string) (ErasureReport, error) {
+	matcher := newErasureMatcher(e.privacy, attribute, value)
+
+	// Registered first, so nothing enqueued from here on needs purging
+	e.tombstones.add(matcher)
+
+	// flushMu keeps spool replay and in-flight sends out while segments are
+	// rewritten; e.mu is only held to filter the buffer, so logging carries
+	// on during the rewrite
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+
+	var report ErasureReport
+	e.mu.Lock()
+	e.batch, report.Buffered = matcher.filter(e.batch)
+	e.recountBatchBytes()
+	e.mu.Unlock()
+
+	if e.recent != nil {
+		e.recent.erase(matcher)
+	}
+
+	var err error
+	if e.spool != nil {
+		report.Spooled, report.Unreadable, err = e.eraseSpool(matcher)
+	}
+	if err == nil && report.Unreadable > 0 {
+		err = fmt.Errorf("failed to read %d spool segments, which may still hold matching records", report.Unreadable)
+	}
+
+	if n := report.Total(); n > 0 {
+		e.stats.drop(DropReasonErased, int64(n))
+	}
+	return report, err
+}
+
+// eraseSpool rewrites spooled segments without matching records, returning
+// how many records it purged and how many segments it couldn't read.
+// Callers must hold e.flushMu, which excludes spool replay.
+func (e *PostHogExporter) eraseSpool(matcher erasureMatcher) (int, int, error) {
+	names, err := e.spool.segments()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	purged, unreadable := 0, 0
+	for _, name := range names {
+		data, err := e.spool.read(name)
+		if err != nil {
+			unreadable++
+			continue
+		}
+		records, err := requestRecords(data)
+		if err != nil {
+			unreadable++
+			continue
+		}
+
+		kept, n := matcher.filter(records)
+		if n == 0 {
+			continue
+		}
+
+		if len(kept) > 0 {
+			rewritten, err := proto.Marshal(e.createOTLPRequest(kept))
+			if err != nil {
+				return purged, unreadable, fmt.Errorf("failed to marshal OTLP request: %w", err)
+			}
+			if err := e.spool.write(rewritten, len(kept)); err != nil {
+				return purged, unreadable, err
+			}
+		}
+		if err := e.spool.remove(name); err != nil {
+			return purged, unreadable, fmt.Errorf("failed to remove spool segment: %w", err)
+		}
+		purged += n
+	}
+
+	return purged, unreadable, nil
+}
+
+// erase purges buffered records carrying the identifier and, if the
+// exporter implements Eraser, asks it to purge its own.
+func (f *fanoutExporter) erase(matcher erasureMatcher, attribute, value string) (int, error) {
+	f.mu.Lock()
+	kept := f.batch[:0]
+	for _, record := range f.batch {
+		if !matcher.matchesAttributes(record.Attributes) {
+			kept = append(kept, record)
+		}
+	}
+	purged := len(f.batch) - len(kept)
+	f.batch = kept
+	f.mu.Unlock()
+
+	if eraser, ok := f.exporter.(Eraser); ok {
+		n, err := eraser.Erase(attribute, value)
+		purged += n
+		if err != nil {
+			return purged, fmt.Errorf("failed to erase records from %T: %w", f.exporter, err)
+		}
+	}
+	return purged, nil
+}
+
+// Erase purges unexported records whose attribute equals value from every
+// exporter's buffer and spool, from Config.Exporters and sinks implementing
+// Eraser, and from the recent-records index. Records carrying it that are
+// logged afterwards are dropped before any sink or exporter sees them.
+func (ls *LipService) Erase(attribute, value string) (ErasureReport, error) {
+	var total ErasureReport
+	var errs []error
+
+	matcher := newErasureMatcher(ls.logger.privacy, attribute, value)
+	ls.logger.tombstones.add(matcher)
+
+	for _, exporter := range ls.exporters() {
+		report, err := exporter.Erase(attribute, value)
+		total.add(report)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, f := range ls.fanout {
+		n, err := f.erase(matcher, attribute, value)
+		total.Exporters += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for name, sink := range ls.config.Sinks {
+		eraser, ok := sink.(Eraser)
+		if !ok {
+			continue
+		}
+		n, err := eraser.Erase(attribute, value)
+		total.Sinks += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to erase records from sink %q: %w", name, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return total, fmt.Errorf("failed to erase records: %w", err)
+	}
+	return total, nil
+}
+
+// EraseDistinctID purges unexported records for a PostHog distinct_id.
+func (ls *LipService) EraseDistinctID(distinctID string) (ErasureReport, error) {
+	return ls.Erase(DistinctIDAttribute, distinctID)
+}
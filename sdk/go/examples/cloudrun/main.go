@@ -0,0 +1,41 @@
+// Command cloudrun shows LipService running on Cloud Run, where CPU is
+// throttled between requests and background flushes can't be relied on.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/srex-dev/lipservice-go"
+)
+
+func main() {
+	ls, err := lipservice.New(lipservice.Config{
+		ServiceName:   "cloudrun-example",
+		LipServiceURL: os.Getenv("LIPSERVICE_URL"),
+		PostHogAPIKey: os.Getenv("POSTHOG_API_KEY"),
+		PostHogTeamID: os.Getenv("POSTHOG_TEAM_ID"),
+		Serverless:    true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ls.Close()
+
+	logger := ls.Logger()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Flush before the handler returns, while the instance still has CPU
+		defer ls.FlushOnInvocationEnd(r.Context())
+
+		logger.Info("Request handled", "path", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
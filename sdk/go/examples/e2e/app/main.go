@@ -0,0 +1,82 @@
+// Command app is a small HTTP service instrumented with LipService, used by
+// the end-to-end docker-compose harness in the parent directory.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/srex-dev/lipservice-go"
+)
+
+func main() {
+	ls, err := lipservice.New(lipservice.Config{
+		ServiceName:     getenv("SERVICE_NAME", "e2e-example"),
+		LipServiceURL:   getenv("LIPSERVICE_URL", "http://localhost:8000"),
+		PostHogAPIKey:   getenv("POSTHOG_API_KEY", "phc_e2e"),
+		PostHogTeamID:   getenv("POSTHOG_TEAM_ID", "1"),
+		PostHogEndpoint: getenv("POSTHOG_ENDPOINT", "http://localhost:4318"),
+		FlushInterval:   time.Second,
+		SpoolDir:        os.Getenv("SPOOL_DIR"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logger := ls.Logger()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/checkout", func(w http.ResponseWriter, r *http.Request) {
+		reqLogger := logger.With("path", r.URL.Path, "request_id", rand.Int63())
+		reqLogger.Info("Checkout started", "cart_items", rand.Intn(10))
+
+		// Fail a small fraction of requests so errors show up downstream
+		if rand.Intn(20) == 0 {
+			reqLogger.Error("Payment provider timeout", "provider", "stripe")
+			http.Error(w, "payment failed", http.StatusBadGateway)
+			return
+		}
+
+		reqLogger.Debug("Inventory reserved")
+		reqLogger.Info("Checkout completed")
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := &http.Server{Addr: ":" + getenv("PORT", "8080"), Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+
+	report, err := ls.CloseWithReport()
+	if err != nil {
+		log.Printf("LipService shutdown error: %v", err)
+	}
+	log.Printf("Telemetry complete: %v", report.Pending == 0 && report.SpoolRemaining == 0)
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,104 @@
+// Command posthog-mock accepts OTLP log exports the way PostHog does and
+// counts what it receives, so the end-to-end harness can check delivery
+// without a real PostHog project.
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type stats struct {
+	mu         sync.Mutex
+	Batches    int            `json:"batches"`
+	Records    int            `json:"records"`
+	Duplicates int            `json:"duplicates"`
+	Severities map[string]int `json:"severities"`
+	checksums  map[string]bool
+}
+
+func main() {
+	s := &stats{Severities: map[string]int{}, checksums: map[string]bool{}}
+
+	http.HandleFunc("/api/v1/otlp/v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req collectorlogs.ExportLogsServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// Mirror the backend's dedupe behaviour for retried batches
+		checksum := r.Header.Get("X-LipService-Batch-Checksum")
+		if checksum != "" && s.checksums[checksum] {
+			s.Duplicates++
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.checksums[checksum] = true
+
+		s.Batches++
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				for _, record := range sl.LogRecords {
+					s.Records++
+					s.Severities[record.SeverityText]++
+				}
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	http.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	})
+
+	addr := ":4318"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+	log.Printf("posthog-mock listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
+
+// readBody reads a request body, undoing any Content-Encoding the SDK chose.
+func readBody(r *http.Request) ([]byte, error) {
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return io.ReadAll(r.Body)
+	}
+}
@@ -0,0 +1,199 @@
+package lipservice
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Decisions reported by Explain.
+const (
+	// DecisionKeep means the record is always kept
+	DecisionKeep = "keep"
+
+	// DecisionSample means the record is kept with probability Rate
+	DecisionSample = "sample"
+
+	// DecisionDrop means the record is never kept
+	DecisionDrop = "drop"
+)
+
+// Explanation describes how the sampler would treat a record and why,
+// without the record being counted anywhere.
+type Explanation struct {
+	// Signature is the record's pattern signature, empty when the
+	// decision doesn't depend on it
+	Signature string `json:"signature,omitempty"`
+
+	// Template is the normalized message of a known pattern
+	Template string `json:"template,omitempty"`
+
+	// Seen is how many records of the pattern have been observed
+	Seen int `json:"seen"`
+
+	// Reason is the sampling reason, e.g. SamplingReasonPattern
+	Reason string `json:"reason"`
+
+	// Rule, BaseRate, Rate, PolicyID and Adjustments are as in
+	// EffectivePolicy
+	Rule        string   `json:"rule"`
+	BaseRate    float64  `json:"base_rate"`
+	Rate        float64  `json:"rate"`
+	PolicyID    string   `json:"policy_id,omitempty"`
+	Adjustments []string `json:"adjustments,omitempty"`
+
+	// Budget is the tier budget for the current minute
+	Budget BudgetState `json:"budget"`
+
+	// Tenant is the record's tenant under TenantAttribute, if any
+	Tenant string `json:"tenant,omitempty"`
+
+	// Decision is DecisionKeep, DecisionSample or DecisionDrop
+	Decision string `json:"decision"`
+
+	// DropReason is the ShutdownReport reason a dropped record counts
+	// under
+	DropReason string `json:"drop_reason,omitempty"`
+}
+
+// BudgetState is the tier budget of kept records per minute.
+type BudgetState struct {
+	// Limit is the budget, or 0 when unlimited
+	Limit int `json:"limit"`
+
+	// Used is the number of records kept against it this minute
+	Used int `json:"used"`
+
+	// Exhausted reports whether no more records fit this minute
+	Exhausted bool `json:"exhausted"`
+}
+
+// Explain reports how a record with this message, severity and attributes
+// would be sampled right now: its signature, the rule and rate that apply,
+// the budget state and the resulting decision. Unlike sampling, it
+// records no stats and creates no patterns.
+func (s *AdaptiveSampler) Explain(message, severity string, attrs map[string]interface{}) Explanation {
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var e Explanation
+	if s.policy != nil {
+		e.PolicyID = s.policy.PolicyID
+	}
+	if profile, ok := s.tier(); ok && profile.MaxLogsPerMinute > 0 {
+		e.Budget.Limit = profile.MaxLogsPerMinute
+		e.Budget.Used = s.budget.usedAt(now)
+		e.Budget.Exhausted = e.Budget.Used >= e.Budget.Limit
+	}
+
+	message = s.localize(message)
+
+	isError := severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL"
+	degraded := s.Degraded() || (s.shedder != nil && s.shedder.skipSignatures())
+	switch {
+	case !s.level.enabled(severity):
+		e.Rule = RuleLogLevel
+		e.Decision, e.DropReason = DecisionDrop, DropReasonLevel
+		return e
+	case isError:
+		e.Reason, e.Rule = SamplingReasonSeverity, RuleSeverity
+		e.BaseRate, e.Rate = 1, 1
+	case s.incident.Load() && !degraded:
+		e.Reason, e.Rule = SamplingReasonIncident, RuleIncident
+		e.BaseRate, e.Rate = 1, 1
+	default:
+		e.Reason = SamplingReasonDefault
+		if degraded {
+			e.Reason = SamplingReasonDegraded
+		}
+		if !degraded {
+			e.Signature = s.peekSignature(message)
+			if stats, ok := s.patternStats[e.Signature]; ok {
+				e.Reason = SamplingReasonPattern
+				e.Template, e.Seen = stats.Template, stats.Count
+			}
+		}
+		e.BaseRate, e.Rule = s.baseRate(e.Signature, severity)
+		e.Rate = e.BaseRate
+		if e.Rule == RulePin {
+			e.Reason = SamplingReasonPin
+		} else {
+			e.Rate = s.adjustRate(e.BaseRate, now, &e.Adjustments)
+		}
+	}
+
+	switch {
+	case e.Rate <= 0:
+		e.Decision, e.DropReason = DecisionDrop, DropReasonSampledOut
+	case e.Budget.Exhausted && !isError && e.Reason != SamplingReasonIncident && e.Reason != SamplingReasonDegraded:
+		e.Reason = SamplingReasonBudget
+		e.Decision, e.DropReason = DecisionDrop, DropReasonSampledOut
+	case e.Rate >= 1:
+		e.Decision = DecisionKeep
+	default:
+		e.Decision = DecisionSample
+	}
+
+	// Tenant fairness applies to records the sampler keeps
+	if s.fairness != nil && !isError {
+		args := make([]interface{}, 0, 2*len(attrs))
+		for key, value := range attrs {
+			args = append(args, key, value)
+		}
+		if tenant, ok := s.fairness.tenant(args, nil); ok {
+			e.Tenant = tenant
+			if e.Decision != DecisionDrop && !s.fairness.peek(tenant, e.Budget.Limit, now) {
+				e.Decision, e.DropReason = DecisionDrop, DropReasonTenantShare
+			}
+		}
+	}
+	return e
+}
+
+// peekSignature computes a message's signature like signature, but without
+// growing similarity groups. Callers must hold s.mu.
+func (s *AdaptiveSampler) peekSignature(message string) string {
+	if s.grouper != nil {
+		if _, ok := parsePanic(message); !ok {
+			return s.grouper.lookup(s.signer.Normalize(message))
+		}
+	}
+	return s.signer.Signature(message)
+}
+
+// Explain reports how a record would be sampled right now and why,
+// without counting it.
+func (ls *LipService) Explain(message, severity string, attrs map[string]interface{}) Explanation {
+	return ls.sampler.Explain(message, severity, attrs)
+}
+
+// ExplainHandler serves Explain as JSON for debug endpoints. The record is
+// taken from the query string: message, severity (default INFO) and
+// attr.<key>=<value> for attributes.
+func (ls *LipService) ExplainHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		message := query.Get("message")
+		if message == "" {
+			http.Error(w, "message is required", http.StatusBadRequest)
+			return
+		}
+		severity := strings.ToUpper(query.Get("severity"))
+		if severity == "" {
+			severity = "INFO"
+		}
+
+		attrs := make(map[string]interface{})
+		for key, values := range query {
+			if name, ok := strings.CutPrefix(key, "attr."); ok && name != "" {
+				attrs[name] = values[0]
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ls.Explain(message, severity, attrs))
+	})
+}
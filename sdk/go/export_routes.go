@@ -0,0 +1,147 @@
+package lipservice
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// PostHogSink names the built-in PostHog exporter in ExportRoute.Sinks.
+const PostHogSink = "posthog"
+
+// LogSink receives sampled records routed to it by an ExportRoute, after
+// the same attribute stages PostHog applies: value limits,
+// pseudonymization, encryption and key normalization. PostHogExporter and
+// FileSink are LogSinks.
+type LogSink interface {
+	ExportLog(message, severity string, timestamp time.Time, attributes map[string]interface{}) error
+}
+
+// LogSinkFunc adapts a function to a LogSink.
+type LogSinkFunc func(message, severity string, timestamp time.Time, attributes map[string]interface{}) error
+
+// ExportLog calls f.
+func (f LogSinkFunc) ExportLog(message, severity string, timestamp time.Time, attributes map[string]interface{}) error {
+	return f(message, severity, timestamp, attributes)
+}
+
+// ExportRoute sends records matching its severity range and pattern to a
+// set of named sinks. Routes are evaluated in order and the first match
+// wins unless Continue is set; records matching no route go to PostHog.
+type ExportRoute struct {
+	// MinSeverity is the lowest severity matched (empty matches from TRACE)
+	MinSeverity string
+
+	// MaxSeverity is the highest severity matched (empty matches up to FATAL)
+	MaxSeverity string
+
+	// Pattern is a regular expression the message must match (empty
+	// matches every message)
+	Pattern string
+
+	// Sinks are keys of Config.Sinks, or PostHogSink
+	Sinks []string
+
+	// Continue keeps evaluating later routes after this one matches
+	Continue bool
+}
+
+// exportRoute is a compiled ExportRoute.
+type exportRoute struct {
+	min     logs.SeverityNumber
+	max     logs.SeverityNumber
+	pattern *regexp.Regexp
+	sinks   []string
+	next    bool
+}
+
+// exportRouter picks the sinks each record is sent to.
+type exportRouter struct {
+	routes []exportRoute
+	sinks  map[string]LogSink
+}
+
+// newExportRouter compiles config.ExportRoutes, or returns nil if there are
+// none.
+func newExportRouter(config Config) (*exportRouter, error) {
+	if len(config.ExportRoutes) == 0 {
+		return nil, nil
+	}
+
+	router := &exportRouter{sinks: config.Sinks}
+	for i, route := range config.ExportRoutes {
+		compiled := exportRoute{
+			min:   logs.SeverityNumber_SEVERITY_NUMBER_TRACE,
+			max:   logs.SeverityNumber_SEVERITY_NUMBER_FATAL4,
+			sinks: route.Sinks,
+			next:  route.Continue,
+		}
+		if route.MinSeverity != "" {
+			compiled.min = severityNumber(route.MinSeverity)
+		}
+		if route.MaxSeverity != "" {
+			compiled.max = severityNumber(route.MaxSeverity)
+		}
+
+		if route.Pattern != "" {
+			re, err := regexp.Compile(route.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern in export route %d: %w", i, err)
+			}
+			compiled.pattern = re
+		}
+
+		for _, name := range route.Sinks {
+			if _, ok := config.Sinks[name]; !ok && name != PostHogSink {
+				return nil, fmt.Errorf("export route %d names unknown sink %q", i, name)
+			}
+		}
+
+		router.routes = append(router.routes, compiled)
+	}
+
+	return router, nil
+}
+
+// match returns the names of the sinks a record is sent to.
+func (r *exportRouter) match(severity, message string) []string {
+	number := severityNumber(severity)
+
+	var sinks []string
+	matched := false
+	for _, route := range r.routes {
+		if number < route.min || number > route.max {
+			continue
+		}
+		if route.pattern != nil && !route.pattern.MatchString(message) {
+			continue
+		}
+
+		matched = true
+		for _, name := range route.sinks {
+			if !containsString(sinks, name) {
+				sinks = append(sinks, name)
+			}
+		}
+		if !route.next {
+			break
+		}
+	}
+
+	if !matched {
+		return []string{PostHogSink}
+	}
+	return sinks
+}
+
+// containsString reports whether values contains s.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+package lipservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter is a destination for sampled records, such as a file, an OTLP
+// collector or another log backend. Exporters in Config.Exporters receive
+// every kept record routed to PostHog, in batches of up to BatchSize, with
+// the same attribute stages and data residency applied.
+type Exporter interface {
+	// Export delivers a batch of records. A returned error drops the batch
+	// for this exporter only. Records' attribute maps are shared between
+	// exporters and must not be modified
+	Export(ctx context.Context, records []Record) error
+
+	// Shutdown flushes anything the exporter buffers itself and releases
+	// its resources
+	Shutdown(ctx context.Context) error
+}
+
+var _ Exporter = (*PostHogExporter)(nil)
+
+// Export buffers records for PostHog, which sends them in its own batches.
+func (e *PostHogExporter) Export(ctx context.Context, records []Record) error {
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		timestamp := record.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		if err := e.ExportLog(record.Message, record.Severity, timestamp, record.Attributes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown closes the exporter, flushing buffered records, and gives up on
+// retries once ctx is done.
+func (e *PostHogExporter) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() { done <- e.Close() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		e.cancel()
+		return ctx.Err()
+	}
+}
+
+// ExporterStats reports one of Config.Exporters' deliveries.
+type ExporterStats struct {
+	// Exporter names the exporter's type, e.g. "*lipservice.PostHogExporter"
+	Exporter string `json:"exporter"`
+
+	// Exported is the number of records delivered
+	Exported int64 `json:"exported"`
+
+	// Failed is the number of records in batches Export rejected
+	Failed int64 `json:"failed"`
+
+	// Dropped is the number of records dropped because the exporter fell
+	// too far behind
+	Dropped int64 `json:"dropped"`
+
+	// LastError is the most recent Export error
+	LastError string `json:"last_error,omitempty"`
+}
+
+// fanoutExporter batches records for one of Config.Exporters, so each
+// destination flushes and fails independently of PostHog and the others.
+type fanoutExporter struct {
+	exporter Exporter
+	config   Config
+	diag     *diagnostics
+
+	mu      sync.Mutex
+	batch   []Record
+	closed  bool
+	lastErr string
+
+	// flushMu serializes Export calls so batches arrive in order
+	flushMu  sync.Mutex
+	flushNow chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	exported atomic.Int64
+	failed   atomic.Int64
+	dropped  atomic.Int64
+}
+
+// newFanout wraps each of Config.Exporters, starting their flush tasks
+// unless the configuration flushes per invocation or per record.
+func newFanout(config Config, diag *diagnostics) []*fanoutExporter {
+	fanout := make([]*fanoutExporter, 0, len(config.Exporters))
+	for _, exporter := range config.Exporters {
+		if exporter == nil {
+			continue
+		}
+		f := &fanoutExporter{
+			exporter: exporter,
+			config:   config,
+			diag:     diag,
+			batch:    make([]Record, 0, config.BatchSize),
+			flushNow: make(chan struct{}, 1),
+			done:     make(chan struct{}),
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		f.cancel = cancel
+		if config.Serverless || config.Synchronous {
+			close(f.done)
+		} else {
+			go f.flushLoop(ctx)
+		}
+		fanout = append(fanout, f)
+	}
+	return fanout
+}
+
+// enqueue buffers a record, flushing when the batch is full. A record is
+// dropped rather than buffered once the exporter is bufferLimitBatches
+// batches behind.
+func (f *fanoutExporter) enqueue(record Record) {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	if len(f.batch) >= bufferLimitBatches*f.config.BatchSize {
+		f.mu.Unlock()
+		f.dropped.Add(1)
+		return
+	}
+	f.batch = append(f.batch, record)
+	due := len(f.batch) >= f.config.BatchSize || f.config.Synchronous
+	f.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if f.config.Serverless || f.config.Synchronous {
+		f.flush(context.Background())
+		return
+	}
+	select {
+	case f.flushNow <- struct{}{}:
+	default:
+		// A flush is already pending
+	}
+}
+
+// flushLoop flushes every FlushInterval and whenever a batch fills.
+func (f *fanoutExporter) flushLoop(ctx context.Context) {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-f.flushNow:
+		}
+		if err := f.flush(ctx); err != nil && ctx.Err() == nil {
+			f.diag.log(slog.Default(), "ERROR", "Failed to flush exporter", "exporter", fmt.Sprintf("%T", f.exporter), "error", err)
+		}
+	}
+}
+
+// flush exports the buffered records.
+func (f *fanoutExporter) flush(ctx context.Context) error {
+	f.flushMu.Lock()
+	defer f.flushMu.Unlock()
+
+	f.mu.Lock()
+	records := f.batch
+	f.batch = make([]Record, 0, f.config.BatchSize)
+	f.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := f.exporter.Export(ctx, records); err != nil {
+		f.failed.Add(int64(len(records)))
+		f.mu.Lock()
+		f.lastErr = err.Error()
+		f.mu.Unlock()
+		return fmt.Errorf("failed to export to %T: %w", f.exporter, err)
+	}
+	f.exported.Add(int64(len(records)))
+	return nil
+}
+
+// shutdown stops the flush task, exports what is left and shuts the
+// exporter down.
+func (f *fanoutExporter) shutdown(ctx context.Context) error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+
+	f.cancel()
+	<-f.done
+
+	return errors.Join(f.flush(ctx), f.exporter.Shutdown(ctx))
+}
+
+// stats returns the exporter's delivery counts.
+func (f *fanoutExporter) stats() ExporterStats {
+	f.mu.Lock()
+	lastErr := f.lastErr
+	f.mu.Unlock()
+
+	return ExporterStats{
+		Exporter:  fmt.Sprintf("%T", f.exporter),
+		Exported:  f.exported.Load(),
+		Failed:    f.failed.Load(),
+		Dropped:   f.dropped.Load(),
+		LastError: lastErr,
+	}
+}
+
+// exportFanout hands a guarded record to every exporter in
+// Config.Exporters.
+func (l *LipServiceLogger) exportFanout(msg, severity string, timestamp time.Time, attributes map[string]interface{}) {
+	record := Record{Message: msg, Severity: severity, Timestamp: timestamp, Attributes: attributes}
+	for _, f := range l.fanout {
+		f.enqueue(record)
+	}
+}
+
+// flushFanout exports what Config.Exporters have buffered.
+func (ls *LipService) flushFanout(ctx context.Context) error {
+	var errs []error
+	for _, f := range ls.fanout {
+		errs = append(errs, f.flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// ExporterStats reports each of Config.Exporters' deliveries, in
+// configuration order.
+func (ls *LipService) ExporterStats() []ExporterStats {
+	stats := make([]ExporterStats, 0, len(ls.fanout))
+	for _, f := range ls.fanout {
+		stats = append(stats, f.stats())
+	}
+	return stats
+}
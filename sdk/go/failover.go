@@ -0,0 +1,152 @@
+package lipservice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Failover thresholds and timing.
+const (
+	// failoverThreshold is how many consecutive failures mark an endpoint
+	// unhealthy
+	failoverThreshold = 3
+
+	// failoverCooldown is how long an unhealthy endpoint is skipped before
+	// it is health-checked again
+	failoverCooldown = 30 * time.Second
+)
+
+// ExportEndpoint is an OTLP/HTTP logs endpoint to fail over to when
+// PostHog is unreachable, such as an OpenTelemetry collector.
+type ExportEndpoint struct {
+	// URL is the full logs URL, e.g. "http://collector:4318/v1/logs"
+	URL string
+
+	// Headers are sent with every request, e.g. the collector's
+	// authorization
+	Headers map[string]string
+}
+
+// exportEndpoint is an endpoint and its health.
+type exportEndpoint struct {
+	url     string
+	headers map[string]string
+	posthog bool
+	diag    *diagnostics
+
+	mu        sync.Mutex
+	failures  int
+	unhealthy bool
+	retryAt   time.Time
+}
+
+// newExportEndpoints returns PostHog followed by config.FailoverEndpoints,
+// in the order they are tried.
+func newExportEndpoints(config Config, diag *diagnostics) []*exportEndpoint {
+	endpoints := []*exportEndpoint{{
+		url:     fmt.Sprintf("%s/api/v1/otlp/v1/logs", config.PostHogEndpoint),
+		posthog: true,
+		diag:    diag,
+	}}
+	for _, endpoint := range config.FailoverEndpoints {
+		endpoints = append(endpoints, &exportEndpoint{url: endpoint.URL, headers: endpoint.Headers, diag: diag})
+	}
+	return endpoints
+}
+
+// usable reports whether the endpoint is healthy, and whether it is an
+// unhealthy one due a health check.
+func (ep *exportEndpoint) usable(now time.Time) (healthy, due bool) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return !ep.unhealthy, ep.unhealthy && !now.Before(ep.retryAt)
+}
+
+// succeeded marks the endpoint healthy.
+func (ep *exportEndpoint) succeeded() {
+	ep.mu.Lock()
+	recovered := ep.unhealthy
+	ep.failures, ep.unhealthy = 0, false
+	ep.mu.Unlock()
+
+	if recovered {
+		ep.diag.log(slog.Default(), "INFO", "Export endpoint is healthy again", "endpoint", ep.url)
+	}
+}
+
+// failed counts a failure, marking the endpoint unhealthy for
+// failoverCooldown once failoverThreshold are consecutive.
+func (ep *exportEndpoint) failed(now time.Time) {
+	ep.mu.Lock()
+	ep.failures++
+	tripped := !ep.unhealthy && ep.failures >= failoverThreshold
+	if ep.failures >= failoverThreshold {
+		ep.unhealthy = true
+		ep.retryAt = now.Add(failoverCooldown)
+	}
+	ep.mu.Unlock()
+
+	if tripped {
+		ep.diag.log(slog.Default(), "WARN", "Export endpoint failed repeatedly, failing over", "endpoint", ep.url, "failures", failoverThreshold)
+	}
+}
+
+// candidates returns the endpoints to try for one request, in order:
+// healthy ones, and unhealthy ones that pass a health check now that
+// their cooldown is over. If none qualify every endpoint is tried.
+func (e *PostHogExporter) candidates(ctx context.Context) []*exportEndpoint {
+	if len(e.endpoints) == 1 {
+		return e.endpoints
+	}
+
+	now := time.Now()
+	var candidates []*exportEndpoint
+	for _, endpoint := range e.endpoints {
+		healthy, due := endpoint.usable(now)
+		if due && e.healthCheck(ctx, endpoint) {
+			healthy = true
+		}
+		if healthy {
+			candidates = append(candidates, endpoint)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return e.endpoints
+	}
+	return candidates
+}
+
+// healthCheck sends an empty OTLP request to an unhealthy endpoint,
+// restoring it on success and restarting its cooldown on failure.
+func (e *PostHogExporter) healthCheck(ctx context.Context, endpoint *exportEndpoint) bool {
+	data, err := proto.Marshal(&collectorlogs.ExportLogsServiceRequest{})
+	if err == nil {
+		probe := wireBatch{data: data, body: data, encoding: CompressionIdentity, checksum: batchChecksum(data)}
+		_, err = e.post(ctx, endpoint, probe)
+	}
+	if err != nil {
+		endpoint.failed(time.Now())
+		return false
+	}
+	endpoint.succeeded()
+	return true
+}
+
+// setEndpointHeaders sets the authentication headers for endpoint.
+func (e *PostHogExporter) setEndpointHeaders(header http.Header, endpoint *exportEndpoint) {
+	if endpoint.posthog {
+		header.Set("Authorization", fmt.Sprintf("Bearer %s", e.config.PostHogAPIKey))
+		header.Set("X-PostHog-Team-Id", e.config.PostHogTeamID)
+	}
+	for key, value := range endpoint.headers {
+		header.Set(key, value)
+	}
+}
@@ -0,0 +1,131 @@
+package lipservice
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Fairness defaults and timing.
+const (
+	// fairnessWindow is the period tenant shares are measured over
+	fairnessWindow = time.Minute
+
+	// fairnessMinPerTenant is how many records every tenant may keep per
+	// window regardless of its share
+	fairnessMinPerTenant = 10
+
+	defaultTenantMaxShare = 0.25
+)
+
+// fairnessTracker caps each tenant's share of the records kept per window,
+// so one noisy customer can't crowd out logs about everyone else.
+type fairnessTracker struct {
+	attribute string
+	maxShare  float64
+
+	mu     sync.Mutex
+	window time.Time
+	total  int
+	kept   map[string]int
+}
+
+// newFairnessTracker creates a tracker, or returns nil if fairness is
+// disabled.
+func newFairnessTracker(config Config) *fairnessTracker {
+	if config.TenantAttribute == "" {
+		return nil
+	}
+
+	share := config.TenantMaxShare
+	if share <= 0 || share > 1 {
+		share = defaultTenantMaxShare
+	}
+
+	return &fairnessTracker{
+		attribute: config.TenantAttribute,
+		maxShare:  share,
+		kept:      make(map[string]int),
+	}
+}
+
+// admit reports whether a tenant's record may be kept, counting it if so.
+func (f *fairnessTracker) admit(tenant string, budget int, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	window := now.Truncate(fairnessWindow)
+	if !window.Equal(f.window) {
+		f.window = window
+		f.total = 0
+		f.kept = make(map[string]int)
+	}
+
+	allowed := f.allows(tenant, budget, now)
+	if allowed {
+		f.kept[tenant]++
+		f.total++
+	}
+	return allowed
+}
+
+// allows reports whether a tenant's record may be kept, without counting
+// it. With a budget the tenant may use at most its share of the budget;
+// without one, at most its share of what has been kept this window, and
+// only once other tenants are competing. Callers must hold f.mu.
+func (f *fairnessTracker) allows(tenant string, budget int, now time.Time) bool {
+	kept, total := f.kept[tenant], f.total
+	if !now.Truncate(fairnessWindow).Equal(f.window) {
+		kept, total = 0, 0
+	}
+
+	switch {
+	case kept < fairnessMinPerTenant:
+		return true
+	case budget > 0:
+		return float64(kept+1) <= f.maxShare*float64(budget)
+	default:
+		return total == kept || float64(kept+1) <= f.maxShare*float64(total+1)
+	}
+}
+
+// peek reports whether a tenant's record would be admitted now, without
+// counting it.
+func (f *fairnessTracker) peek(tenant string, budget int, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.allows(tenant, budget, now)
+}
+
+// tenant returns the tenant named in a record's attributes, looking at the
+// record's own args before those bound with With.
+func (f *fairnessTracker) tenant(args, bound []interface{}) (string, bool) {
+	value, ok := lookupAttribute(f.attribute, args, bound)
+	if !ok {
+		return "", false
+	}
+	tenant, ok := value.(string)
+	if !ok {
+		tenant = fmt.Sprint(value)
+	}
+	return tenant, true
+}
+
+// admitTenant applies tenant fairness to a record the sampler kept. ERROR
+// and above, and records without a tenant, are always admitted.
+func (s *AdaptiveSampler) admitTenant(severity string, args, bound []interface{}) bool {
+	if s.fairness == nil || severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL" {
+		return true
+	}
+
+	tenant, ok := s.fairness.tenant(args, bound)
+	if !ok {
+		return true
+	}
+
+	s.mu.RLock()
+	profile, _ := s.tier()
+	s.mu.RUnlock()
+
+	return s.fairness.admit(tenant, profile.MaxLogsPerMinute, time.Now())
+}
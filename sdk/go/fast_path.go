@@ -0,0 +1,27 @@
+package lipservice
+
+// needsSignatures reports whether the configuration has anything that reads
+// a record's pattern signature: a custom or signature-keyed sampler,
+// importance scoring, debug annotations, error context bundles, or pattern
+// reports to the backend.
+// Without any of these a sampler whose policy has only severity rules can
+// skip normalization entirely.
+func needsSignatures(config Config) bool {
+	return config.Sampler != nil ||
+		config.DeterministicSampling ||
+		config.ImportanceScoring ||
+		config.DebugSampling ||
+		config.ContextRecords > 0 ||
+		reportsPatterns(config)
+}
+
+// severityOnly reports whether the next decision depends on nothing but
+// severity, so sample can skip computing a signature. The answer matches
+// the slow path: with no pattern stats no signature could match one.
+// Callers must hold s.mu.
+func (s *AdaptiveSampler) severityOnly() bool {
+	if s.signatures || len(s.patternStats) > 0 || len(s.pins) > 0 || s.events.active() {
+		return false
+	}
+	return s.policy == nil || len(s.policy.Patterns) == 0
+}
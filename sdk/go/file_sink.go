@@ -0,0 +1,54 @@
+package lipservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileSink is a LogSink that appends records to a file as JSON lines, for
+// routing low-value logs to local storage or a log shipper.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// fileSinkRecord is the JSON form of a record written by FileSink.
+type fileSinkRecord struct {
+	Timestamp  time.Time              `json:"timestamp"`
+	Severity   string                 `json:"severity"`
+	Message    string                 `json:"message"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// NewFileSink opens path for appending, creating it if needed.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file sink: %w", err)
+	}
+	return &FileSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// ExportLog appends a record to the file.
+func (s *FileSink) ExportLog(message, severity string, timestamp time.Time, attributes map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.enc.Encode(fileSinkRecord{
+		Timestamp:  timestamp,
+		Severity:   severity,
+		Message:    message,
+		Attributes: attributes,
+	})
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
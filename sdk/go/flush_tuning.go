@@ -0,0 +1,142 @@
+package lipservice
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Flush auto-tuning parameters.
+const (
+	// tuneWindowSeconds is how much per-second ingress history is kept
+	tuneWindowSeconds = 60
+
+	// tuneInterval is how often the flush interval and batch size are
+	// recomputed
+	tuneInterval = 10 * time.Second
+
+	// tuneFillTime is how much traffic a tuned batch is sized to hold
+	tuneFillTime = 5 * time.Second
+
+	// tunePercentile of per-second ingress is the rate tuned for, so
+	// batches are sized for bursts rather than the average
+	tunePercentile = 0.9
+)
+
+// flushTuner picks a flush interval and batch size within the configured
+// bounds from a histogram of per-second ingress: short intervals and small
+// batches at low volume for freshness, long intervals and large batches at
+// high volume for efficiency.
+type flushTuner struct {
+	minInterval, maxInterval time.Duration
+	minBatch, maxBatch       int
+
+	mu      sync.Mutex
+	seconds [tuneWindowSeconds]int64
+	counts  [tuneWindowSeconds]int
+
+	interval  atomic.Int64
+	batchSize atomic.Int64
+}
+
+// newFlushTuner creates a tuner, or returns nil unless AutoTuneFlush is
+// set. Serverless and Synchronous exporters have no flush loop to tune.
+// Unset bounds default to a fifth and six times FlushInterval, and a tenth
+// and ten times BatchSize.
+func newFlushTuner(config Config) *flushTuner {
+	if !config.AutoTuneFlush || config.Serverless || config.Synchronous {
+		return nil
+	}
+
+	t := &flushTuner{
+		minInterval: config.MinFlushInterval,
+		maxInterval: config.MaxFlushInterval,
+		minBatch:    config.MinBatchSize,
+		maxBatch:    config.MaxBatchSize,
+	}
+	if t.minInterval <= 0 {
+		t.minInterval = config.FlushInterval / 5
+	}
+	if t.maxInterval < t.minInterval {
+		t.maxInterval = max(config.FlushInterval*6, t.minInterval)
+	}
+	if t.minBatch <= 0 {
+		t.minBatch = max(config.BatchSize/10, 1)
+	}
+	if t.maxBatch < t.minBatch {
+		t.maxBatch = max(config.BatchSize*10, t.minBatch)
+	}
+
+	t.interval.Store(int64(min(max(config.FlushInterval, t.minInterval), t.maxInterval)))
+	t.batchSize.Store(int64(min(max(config.BatchSize, t.minBatch), t.maxBatch)))
+	return t
+}
+
+// observe counts one record arriving at now.
+func (t *flushTuner) observe(now time.Time) {
+	second := now.Unix()
+	slot := int(second % tuneWindowSeconds)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seconds[slot] != second {
+		t.seconds[slot], t.counts[slot] = second, 0
+	}
+	t.counts[slot]++
+}
+
+// rate returns the tunePercentile of records per second over the window
+// ending at now. Seconds with no records count as zero.
+func (t *flushTuner) rate(now time.Time) float64 {
+	oldest := now.Unix() - tuneWindowSeconds
+
+	t.mu.Lock()
+	counts := make([]int, 0, tuneWindowSeconds)
+	for slot, second := range t.seconds {
+		if second > oldest {
+			counts = append(counts, t.counts[slot])
+		} else {
+			counts = append(counts, 0)
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Ints(counts)
+	return float64(counts[int(tunePercentile*float64(len(counts)-1))])
+}
+
+// tune recomputes the flush interval and batch size for the ingress rate
+// at now and returns the new interval. A batch is sized to hold
+// tuneFillTime of traffic, and the interval moves between its bounds in
+// step with where the batch size falls between its own.
+func (t *flushTuner) tune(now time.Time) time.Duration {
+	batch := int(t.rate(now) * tuneFillTime.Seconds())
+	batch = min(max(batch, t.minBatch), t.maxBatch)
+
+	fraction := 0.0
+	if t.maxBatch > t.minBatch {
+		fraction = float64(batch-t.minBatch) / float64(t.maxBatch-t.minBatch)
+	}
+	interval := t.minInterval + time.Duration(fraction*float64(t.maxInterval-t.minInterval))
+
+	t.batchSize.Store(int64(batch))
+	t.interval.Store(int64(interval))
+	return interval
+}
+
+// batchLimit returns the number of buffered records that triggers a flush.
+func (e *PostHogExporter) batchLimit() int {
+	if e.tuner != nil {
+		return int(e.tuner.batchSize.Load())
+	}
+	return e.config.BatchSize
+}
+
+// flushInterval returns the time between periodic flushes.
+func (e *PostHogExporter) flushInterval() time.Duration {
+	if e.tuner != nil {
+		return time.Duration(e.tuner.interval.Load())
+	}
+	return e.config.FlushInterval
+}
@@ -0,0 +1,63 @@
+package lipservice
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// latencyGuardAlpha is the EWMA smoothing factor for sampler latency.
+const latencyGuardAlpha = 0.1
+
+// latencyGuardCooldown is how long the sampler stays degraded before
+// retrying full pattern-based decisions.
+const latencyGuardCooldown = 30 * time.Second
+
+// latencyGuard tracks ShouldSample latency with a lightweight EWMA and trips
+// when it exceeds the configured budget.
+type latencyGuard struct {
+	budget        time.Duration
+	diag          *diagnostics
+	ewma          atomic.Int64
+	degradedUntil atomic.Int64
+}
+
+// newLatencyGuard creates a latency guard, or returns nil if budget is zero.
+func newLatencyGuard(budget time.Duration, diag *diagnostics) *latencyGuard {
+	if budget <= 0 {
+		return nil
+	}
+	return &latencyGuard{budget: budget, diag: diag}
+}
+
+// observe folds a latency sample into the EWMA and trips the guard if the
+// budget is exceeded.
+func (g *latencyGuard) observe(d time.Duration) {
+	for {
+		old := g.ewma.Load()
+		next := old + int64(latencyGuardAlpha*float64(int64(d)-old))
+		if old == 0 {
+			next = int64(d)
+		}
+		if g.ewma.CompareAndSwap(old, next) {
+			if time.Duration(next) > g.budget && !g.degraded() {
+				g.trip(time.Duration(next))
+			}
+			return
+		}
+	}
+}
+
+// trip switches the guard into degraded mode and reports the condition.
+func (g *latencyGuard) trip(latency time.Duration) {
+	until := time.Now().Add(latencyGuardCooldown).UnixNano()
+	g.degradedUntil.Store(until)
+	g.ewma.Store(0)
+	g.diag.log(slog.Default(), "WARN", "Sampler latency exceeded its budget, using severity-only sampling",
+		"latency", latency, "budget", g.budget, "cooldown", latencyGuardCooldown)
+}
+
+// degraded reports whether the guard is currently tripped.
+func (g *latencyGuard) degraded() bool {
+	return time.Now().UnixNano() < g.degradedUntil.Load()
+}
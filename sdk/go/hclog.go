@@ -0,0 +1,180 @@
+package lipservice
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// HCLogger adapts a LipServiceLogger to hclog.Logger, so Consul, Vault and
+// other hclog-based applications can adopt sampling without interface
+// rewrites.
+type HCLogger struct {
+	logger *LipServiceLogger
+	name   string
+	level  hclog.Level
+	args   []interface{}
+}
+
+// NewHCLogger creates an hclog.Logger that writes through logger at Info
+// and above.
+func NewHCLogger(logger *LipServiceLogger) *HCLogger {
+	return &HCLogger{logger: logger, level: hclog.Info}
+}
+
+var _ hclog.Logger = (*HCLogger)(nil)
+
+// Log logs msg at level.
+func (h *HCLogger) Log(level hclog.Level, msg string, args ...interface{}) {
+	if level == hclog.Off || level < h.level {
+		return
+	}
+	if h.name != "" {
+		// The caller's args may have spare capacity, so append to a copy
+		args = append(args[:len(args):len(args)], "logger", h.name)
+	}
+	h.logger.log(hclogSeverity(level), msg, args...)
+}
+
+// Trace logs msg at trace level.
+func (h *HCLogger) Trace(msg string, args ...interface{}) { h.Log(hclog.Trace, msg, args...) }
+
+// Debug logs msg at debug level.
+func (h *HCLogger) Debug(msg string, args ...interface{}) { h.Log(hclog.Debug, msg, args...) }
+
+// Info logs msg at info level.
+func (h *HCLogger) Info(msg string, args ...interface{}) { h.Log(hclog.Info, msg, args...) }
+
+// Warn logs msg at warn level.
+func (h *HCLogger) Warn(msg string, args ...interface{}) { h.Log(hclog.Warn, msg, args...) }
+
+// Error logs msg at error level.
+func (h *HCLogger) Error(msg string, args ...interface{}) { h.Log(hclog.Error, msg, args...) }
+
+// IsTrace reports whether trace messages are logged.
+func (h *HCLogger) IsTrace() bool { return h.level <= hclog.Trace }
+
+// IsDebug reports whether debug messages are logged.
+func (h *HCLogger) IsDebug() bool { return h.level <= hclog.Debug }
+
+// IsInfo reports whether info messages are logged.
+func (h *HCLogger) IsInfo() bool { return h.level <= hclog.Info }
+
+// IsWarn reports whether warn messages are logged.
+func (h *HCLogger) IsWarn() bool { return h.level <= hclog.Warn }
+
+// IsError reports whether error messages are logged.
+func (h *HCLogger) IsError() bool { return h.level <= hclog.Error }
+
+// ImpliedArgs returns the args bound by With.
+func (h *HCLogger) ImpliedArgs() []interface{} { return h.args }
+
+// With returns a logger with args bound to every message.
+func (h *HCLogger) With(args ...interface{}) hclog.Logger {
+	clone := *h
+	clone.logger = h.logger.With(args...)
+	clone.args = append(append([]interface{}{}, h.args...), args...)
+	return &clone
+}
+
+// Name returns the logger's name.
+func (h *HCLogger) Name() string { return h.name }
+
+// Named returns a logger with name appended to the current name.
+func (h *HCLogger) Named(name string) hclog.Logger {
+	clone := *h
+	if h.name != "" {
+		clone.name = h.name + "." + name
+	} else {
+		clone.name = name
+	}
+	return &clone
+}
+
+// ResetNamed returns a logger with the given name, replacing the current one.
+func (h *HCLogger) ResetNamed(name string) hclog.Logger {
+	clone := *h
+	clone.name = name
+	return &clone
+}
+
+// SetLevel sets the lowest level logged.
+func (h *HCLogger) SetLevel(level hclog.Level) { h.level = level }
+
+// GetLevel returns the lowest level logged.
+func (h *HCLogger) GetLevel() hclog.Level { return h.level }
+
+// StandardLogger returns a standard library logger writing through h.
+func (h *HCLogger) StandardLogger(opts *hclog.StandardLoggerOptions) *log.Logger {
+	return log.New(h.StandardWriter(opts), "", 0)
+}
+
+// StandardWriter returns a writer that logs each write through h.
+func (h *HCLogger) StandardWriter(opts *hclog.StandardLoggerOptions) io.Writer {
+	if opts == nil {
+		opts = &hclog.StandardLoggerOptions{}
+	}
+	return &hclogWriter{logger: h, opts: opts}
+}
+
+// hclogWriter logs writes from a standard library logger.
+type hclogWriter struct {
+	logger *HCLogger
+	opts   *hclog.StandardLoggerOptions
+}
+
+// Write logs p as one message, inferring the level from a "[LEVEL]" prefix
+// when InferLevels is set.
+func (w *hclogWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, " \t\n"))
+
+	level := hclog.Info
+	if w.opts.ForceLevel != hclog.NoLevel {
+		level = w.opts.ForceLevel
+	} else if w.opts.InferLevels {
+		level, msg = hclogInferLevel(msg)
+	}
+
+	w.logger.Log(level, msg)
+	return len(p), nil
+}
+
+// hclogInferLevel strips a leading "[LEVEL]" marker and returns its level.
+func hclogInferLevel(msg string) (hclog.Level, string) {
+	prefixes := []struct {
+		prefix string
+		level  hclog.Level
+	}{
+		{"[TRACE]", hclog.Trace},
+		{"[DEBUG]", hclog.Debug},
+		{"[INFO]", hclog.Info},
+		{"[WARN]", hclog.Warn},
+		{"[ERR]", hclog.Error},
+		{"[ERROR]", hclog.Error},
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(msg, p.prefix) {
+			return p.level, strings.TrimSpace(msg[len(p.prefix):])
+		}
+	}
+	return hclog.Info, msg
+}
+
+// hclogSeverity maps an hclog level to a LipService severity.
+func hclogSeverity(level hclog.Level) string {
+	switch level {
+	case hclog.Trace:
+		return "TRACE"
+	case hclog.Debug:
+		return "DEBUG"
+	case hclog.Warn:
+		return "WARN"
+	case hclog.Error:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
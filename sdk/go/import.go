@@ -0,0 +1,200 @@
+package lipservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ImportedAttribute marks records that arrived through ImportBatch rather
+// than a logger.
+const ImportedAttribute = "lipservice.imported"
+
+// Record is a log record as ImportBatch takes it, e.g. from a migration or
+// another logging system, and as a Sampler sees it.
+type Record struct {
+	// Message is the record's body
+	Message string `json:"message"`
+
+	// Severity is the record's level (defaults to INFO)
+	Severity string `json:"severity"`
+
+	// Timestamp is when the record was originally emitted (defaults to
+	// now); it is exported as the record's event time
+	Timestamp time.Time `json:"timestamp"`
+
+	// Attributes are the record's key/value pairs; they are not passed to
+	// Samplers
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// Signature is the record's pattern signature, filled in for Samplers
+	Signature string `json:"-"`
+
+	// Rate is the keep probability the adaptive sampler arrived at, filled
+	// in for Samplers
+	Rate float64 `json:"-"`
+
+	// Seen is how many times the record's pattern has been seen before,
+	// filled in for Samplers
+	Seen int `json:"-"`
+}
+
+// ImportBatch pushes pre-existing records through sampling and export,
+// keeping their original timestamps, and returns how many were kept. The
+// records take the logger's path, with redaction, dedup, category budgets
+// and sinks, retention, owners and export routes, but are sampled by the
+// current policy without feeding live pattern stats, budgets or reports.
+// Dedup, SLO boosts and category budgets are tracked by the import's own
+// clock, and its counts go to ImportReport rather than Report. Records are
+// never logged locally, and an import flushes after every BatchSize kept
+// records so large imports are paced by delivery rather than dropped as
+// overflow.
+func (ls *LipService) ImportBatch(ctx context.Context, records []Record) (int, error) {
+	// Imports are held to the SLO target in force now
+	ls.importer.imports.slo.setTarget(ls.sampler.slo.currentTarget())
+
+	kept := 0
+	for i, record := range records {
+		if err := ctx.Err(); err != nil {
+			return kept, fmt.Errorf("import stopped after %d of %d records: %w", i, len(records), err)
+		}
+		if !ls.importer.importRecord(record) {
+			continue
+		}
+
+		kept++
+		if kept%ls.config.BatchSize == 0 {
+			if err := ls.flushImport(ctx); err != nil {
+				return kept, err
+			}
+		}
+	}
+	logRepeats(ls.importer.deduper.drain())
+	return kept, ls.flushImport(ctx)
+}
+
+// ImportReport returns the counts of records pushed through ImportBatch,
+// kept apart from those of live logging.
+func (ls *LipService) ImportReport() ShutdownReport {
+	return ls.importer.stats.report(0, 0)
+}
+
+// flushImport exports what an import has buffered so far.
+func (ls *LipService) flushImport(ctx context.Context) error {
+	for _, exporter := range ls.exporters() {
+		if err := exporter.FlushContext(ctx); err != nil {
+			return fmt.Errorf("failed to export imported records: %w", err)
+		}
+	}
+	if err := ls.flushFanout(ctx); err != nil {
+		return fmt.Errorf("failed to export imported records: %w", err)
+	}
+	return nil
+}
+
+// importState is what an importer tracks apart from live logging, so
+// historical records can't trip a live SLO boost.
+type importState struct {
+	slo *sloTracker
+}
+
+// importer returns a copy of the logger for ImportBatch. It has its own
+// counts, dedup window, SLO tracker and category budgets, and nothing that
+// only makes sense for live records: request tallies, error context and
+// span events.
+func (l *LipServiceLogger) importer() *LipServiceLogger {
+	clone := *l
+	clone.stats = newDeliveryStats()
+	clone.deduper = newDeduper(l.sampler.config.DedupWindow)
+	clone.tally = nil
+	clone.contexts = nil
+	clone.spanEvents = nil
+	clone.span = nil
+
+	clone.imports = &importState{slo: newSLOTracker(l.sampler.config, l.diag)}
+
+	if l.categories != nil {
+		clone.categories = make(map[string]*logCategory, len(l.categories))
+		for name, category := range l.categories {
+			clone.categories[name] = &logCategory{config: category.config}
+		}
+	}
+	return &clone
+}
+
+// importRecord runs one imported record through the importer's pipeline,
+// reporting whether it was kept.
+func (l *LipServiceLogger) importRecord(record Record) bool {
+	severity := strings.ToUpper(record.Severity)
+	if severity == "" {
+		severity = "INFO"
+	}
+	timestamp := record.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	args := make([]interface{}, 0, 2*len(record.Attributes))
+	for key, value := range record.Attributes {
+		args = append(args, key, value)
+	}
+	return l.process(severity, record.Message, timestamp, args)
+}
+
+// sampleImported decides whether to keep an imported record from at. The
+// current policy's rates apply, but live state is left alone: pattern
+// stats, pattern reports and budgets aren't updated, and live adjustments
+// such as warmup and load shedding don't apply. Only slo, the import's own
+// tracker, may boost the rate.
+func (s *AdaptiveSampler) sampleImported(message, severity string, at time.Time, slo *sloTracker) samplingOutcome {
+	// The grouper learns from what it sees, so this takes the write lock;
+	// the Sampler decides without it
+	s.mu.Lock()
+	if severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL" {
+		defer s.mu.Unlock()
+		return s.importOutcome(true, SamplingReasonSeverity, "", 1)
+	}
+
+	message = s.localize(message)
+	signature := ""
+	if !s.severityOnly() {
+		signature = s.signature(message)
+	}
+
+	rate, rule := s.baseRate(signature, severity)
+	reason, seen := SamplingReasonDefault, 0
+	switch rule {
+	case RuleIncident:
+		reason = SamplingReasonIncident
+	case RulePin:
+		reason = SamplingReasonPin
+	case RulePattern:
+		reason, seen = SamplingReasonPattern, s.patternStats[signature].Count
+	}
+	if rule != RulePin && rule != RuleIncident {
+		rate = slo.boost(rate, at)
+	}
+	s.mu.Unlock()
+
+	decision := s.engine.ShouldSample(Record{
+		Message:   message,
+		Severity:  severity,
+		Timestamp: at,
+		Signature: signature,
+		Rate:      rate,
+		Seen:      seen,
+	})
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.importOutcome(decision.Keep, reason, signature, decisionRate(decision, rate))
+}
+
+// importOutcome is outcome for an imported record, which is never part of
+// the live warmup. Callers must hold s.mu.
+func (s *AdaptiveSampler) importOutcome(kept bool, reason, signature string, rate float64) samplingOutcome {
+	o := s.outcome(kept, reason, signature, rate)
+	o.warmup = false
+	return o
+}
@@ -0,0 +1,106 @@
+package lipservice
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Importance model feature names that aren't message words.
+const (
+	featureRare  = "rare"
+	featureStack = "stack_trace"
+	featureLong  = "long_message"
+)
+
+// ImportanceModel is a small logistic regression model that scores how
+// important a log record is, from 0 to 1. Weights are keyed by feature:
+// "word:<token>" for lowercase message words, "severity:<SEVERITY>", and
+// the rare, stack_trace and long_message features.
+type ImportanceModel struct {
+	Bias    float64            `json:"bias"`
+	Weights map[string]float64 `json:"weights"`
+}
+
+// DefaultImportanceModel returns hand-tuned weights that favour failures,
+// security events and rarely seen patterns.
+func DefaultImportanceModel() ImportanceModel {
+	return ImportanceModel{
+		Bias: -3.0,
+		Weights: map[string]float64{
+			"word:fail":         2.0,
+			"word:failed":       2.0,
+			"word:failure":      2.0,
+			"word:error":        1.5,
+			"word:exception":    2.0,
+			"word:panic":        3.0,
+			"word:timeout":      1.5,
+			"word:timed":        1.0,
+			"word:refused":      1.5,
+			"word:denied":       2.0,
+			"word:unauthorized": 2.0,
+			"word:forbidden":    1.5,
+			"word:retry":        0.8,
+			"word:retrying":     0.8,
+			"word:degraded":     1.5,
+			"word:unavailable":  1.5,
+			"word:corrupt":      2.5,
+			"word:deprecated":   0.5,
+			"word:slow":         0.8,
+			"word:success":      -1.0,
+			"word:completed":    -1.0,
+			"word:ok":           -1.0,
+			"word:healthy":      -1.5,
+			"severity:WARN":     1.0,
+			"severity:WARNING":  1.0,
+			"severity:DEBUG":    -1.0,
+			"severity:TRACE":    -1.5,
+			featureRare:         2.5,
+			featureStack:        2.0,
+			featureLong:         0.5,
+		},
+	}
+}
+
+// Score returns the importance of a record between 0 and 1.
+func (m ImportanceModel) Score(r Record) float64 {
+	z := m.Bias
+	z += m.Weights["severity:"+r.Severity]
+
+	seenWords := make(map[string]struct{})
+	for _, word := range strings.FieldsFunc(strings.ToLower(r.Message), isWordSeparator) {
+		if _, ok := seenWords[word]; ok {
+			continue
+		}
+		seenWords[word] = struct{}{}
+		z += m.Weights["word:"+word]
+	}
+
+	// Rarity decays with how often the pattern has been seen
+	z += m.Weights[featureRare] / (1 + math.Log1p(float64(r.Seen)))
+
+	if strings.Contains(r.Message, "goroutine ") || strings.Contains(r.Message, "\n\tat ") {
+		z += m.Weights[featureStack]
+	}
+	z += m.Weights[featureLong] * math.Min(float64(len(r.Message))/500, 1)
+
+	return 1 / (1 + math.Exp(-z))
+}
+
+// isWordSeparator splits messages into word tokens.
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r)
+}
+
+// ImportanceSampler wraps base so records the model scores as important are
+// kept with at least their importance score as the rate.
+func ImportanceSampler(base Sampler, model ImportanceModel) Sampler {
+	return SamplerFunc(func(r Record) Decision {
+		if score := model.Score(r); score > r.Rate {
+			r.Rate = score
+		}
+		decision := base.ShouldSample(r)
+		decision.Rate = decisionRate(decision, r.Rate)
+		return decision
+	})
+}
@@ -0,0 +1,44 @@
+package lipservice
+
+import "log/slog"
+
+// SetIncidentMode turns incident mode on or off. While it is on, every
+// record that passes the log level is kept, whatever its pattern, policy
+// or adjustments would otherwise allow.
+func (s *AdaptiveSampler) SetIncidentMode(on bool) {
+	s.incident.Store(on)
+}
+
+// IncidentMode reports whether incident mode is on.
+func (s *AdaptiveSampler) IncidentMode() bool {
+	return s.incident.Load()
+}
+
+// Policy returns a copy of the sampling policy in force, or nil when none
+// has been fetched.
+func (s *AdaptiveSampler) Policy() *SamplingPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.policy == nil {
+		return nil
+	}
+	policy := *s.policy
+	return &policy
+}
+
+// SetIncidentMode keeps every record that passes the log level while on,
+// e.g. for the duration of an outage investigation.
+func (ls *LipService) SetIncidentMode(on bool) {
+	ls.sampler.SetIncidentMode(on)
+	if on {
+		ls.sampler.diag.log(slog.Default(), "WARN", "Incident mode on, keeping every record")
+	} else {
+		ls.sampler.diag.log(slog.Default(), "INFO", "Incident mode off")
+	}
+}
+
+// IncidentMode reports whether incident mode is on.
+func (ls *LipService) IncidentMode() bool {
+	return ls.sampler.IncidentMode()
+}
@@ -0,0 +1,68 @@
+package lipservice
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KitLogger adapts a LipServiceLogger to go-kit's log.Logger interface, so
+// go-kit services can adopt sampling without rewriting their logging.
+//
+//	var logger log.Logger = lipservice.NewKitLogger(ls.Logger())
+//	level.Error(logger).Log("msg", "payment failed", "order", id)
+type KitLogger struct {
+	logger *LipServiceLogger
+}
+
+// NewKitLogger creates a go-kit logger that writes through logger.
+func NewKitLogger(logger *LipServiceLogger) *KitLogger {
+	return &KitLogger{logger: logger}
+}
+
+// Log logs keyvals. The "level" key (as set by go-kit's level package)
+// selects the severity and the "msg" or "message" key the message; the
+// remaining pairs become attributes.
+func (k *KitLogger) Log(keyvals ...interface{}) error {
+	severity, msg, args := kitKeyvals(keyvals)
+	k.logger.log(severity, msg, args...)
+	return nil
+}
+
+// kitKeyvals splits go-kit keyvals into a severity, message and attributes.
+func kitKeyvals(keyvals []interface{}) (string, string, []interface{}) {
+	if len(keyvals)%2 == 1 {
+		keyvals = append(keyvals[:len(keyvals):len(keyvals)], "(MISSING)")
+	}
+
+	severity, msg := "INFO", ""
+	args := make([]interface{}, 0, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		switch key {
+		case "level":
+			severity = kitSeverity(fmt.Sprint(keyvals[i+1]))
+		case "msg", "message":
+			msg = fmt.Sprint(keyvals[i+1])
+		default:
+			args = append(args, key, keyvals[i+1])
+		}
+	}
+
+	return severity, msg, args
+}
+
+// kitSeverity maps a go-kit level value to a LipService severity.
+func kitSeverity(level string) string {
+	switch strings.ToLower(level) {
+	case "debug":
+		return "DEBUG"
+	case "warn", "warning":
+		return "WARN"
+	case "error":
+		return "ERROR"
+	case "fatal", "crit", "critical":
+		return "FATAL"
+	default:
+		return "INFO"
+	}
+}
@@ -0,0 +1,227 @@
+package lipservice
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// LanguageAttribute carries the detected language of a record's message
+// when MultiLanguage is enabled.
+const LanguageAttribute = "lipservice.language"
+
+// languageUndetermined is the ISO 639 code for an undetermined language.
+const languageUndetermined = "und"
+
+// Modes for Config.FreeText.
+const (
+	// FreeTextStrip replaces every free-text segment with one placeholder
+	FreeTextStrip = "strip"
+
+	// FreeTextBucket replaces free-text segments with a placeholder naming
+	// their language, so each language gets its own pattern
+	FreeTextBucket = "bucket"
+)
+
+// freeTextQuotes maps the quotation marks that open a free-text segment
+// to the marks that close it.
+var freeTextQuotes = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'„':  '“',
+	'«':  '»',
+	'「':  '」',
+	'『':  '』',
+}
+
+// scriptLanguages maps writing systems that mostly identify a single
+// language (or language family) to a language code.
+var scriptLanguages = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// latinStopwords are frequent short words that distinguish common
+// Latin-script languages.
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "is", "was", "for", "with", "from", "not", "failed", "user"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "von", "fehlgeschlagen", "benutzer"},
+	"fr": {"le", "la", "les", "et", "est", "pas", "avec", "pour", "échec", "utilisateur"},
+	"es": {"el", "los", "las", "y", "es", "no", "con", "para", "falló", "usuario"},
+	"pt": {"o", "os", "e", "não", "com", "para", "falhou", "usuário", "foi", "um"},
+	"it": {"il", "gli", "e", "è", "non", "con", "per", "fallito", "utente", "di"},
+}
+
+// latinStopwordIndex inverts latinStopwords for lookup by word.
+var latinStopwordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for language, words := range latinStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], language)
+		}
+	}
+	return index
+}()
+
+// detectLanguage makes a cheap guess at a message's language: by script
+// for non-Latin text, and by stopword votes for Latin text.
+func detectLanguage(message string) string {
+	for _, r := range message {
+		if r <= unicode.MaxASCII {
+			continue
+		}
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				// Kana appears alongside Han in Japanese, so keep looking
+				if script.language == "zh" && containsKana(message) {
+					return "ja"
+				}
+				return script.language
+			}
+		}
+	}
+
+	votes := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(message), isWordSeparator) {
+		for _, language := range latinStopwordIndex[word] {
+			votes[language]++
+		}
+	}
+
+	best, bestVotes := languageUndetermined, 0
+	for _, language := range []string{"en", "de", "fr", "es", "pt", "it"} {
+		if votes[language] > bestVotes {
+			best, bestVotes = language, votes[language]
+		}
+	}
+	return best
+}
+
+// containsKana reports whether message contains Japanese kana.
+func containsKana(message string) bool {
+	for _, r := range message {
+		if unicode.In(r, unicode.Hiragana, unicode.Katakana) {
+			return true
+		}
+	}
+	return false
+}
+
+// localize prepares a message for signature computation under the
+// multi-language settings: free-text segments are stripped or bucketed,
+// then accents and digit scripts are folded.
+func (s *AdaptiveSampler) localize(message string) string {
+	if s.config.FreeText != "" {
+		message = replaceFreeText(message, s.config.FreeText)
+	}
+	if s.config.MultiLanguage {
+		message = normalizeUnicode(message)
+	}
+	return message
+}
+
+// replaceFreeText replaces the free-text segments of a message, quoted
+// strings and runs of words in non-Latin scripts, with placeholders so
+// the structural part of the message drives its signature.
+func replaceFreeText(message, mode string) string {
+	text := []rune(message)
+	var b strings.Builder
+	b.Grow(len(message))
+
+	for i := 0; i < len(text); {
+		// A quoted segment opens at a word boundary and closes at one
+		if closing, ok := freeTextQuotes[text[i]]; ok && (i == 0 || !isWordRune(text[i-1])) {
+			if end := closingQuote(text, i+1, closing); end >= 0 {
+				writeFreeText(&b, string(text[i+1:end]), mode)
+				i = end + 1
+				continue
+			}
+		}
+
+		// Words in a non-Latin script, with the spaces between them
+		if isForeignLetter(text[i]) {
+			end := i + 1
+			for j := end; j < len(text) && (isForeignLetter(text[j]) || text[j] == ' '); j++ {
+				if text[j] != ' ' {
+					end = j + 1
+				}
+			}
+			writeFreeText(&b, string(text[i:end]), mode)
+			i = end
+			continue
+		}
+
+		b.WriteRune(text[i])
+		i++
+	}
+	return b.String()
+}
+
+// closingQuote returns the index of the first closing mark at or after
+// start that ends a word, or -1.
+func closingQuote(text []rune, start int, closing rune) int {
+	for j := start; j < len(text); j++ {
+		if text[j] == closing && (j+1 == len(text) || !isWordRune(text[j+1])) {
+			return j
+		}
+	}
+	return -1
+}
+
+// writeFreeText writes the placeholder for a free-text segment.
+func writeFreeText(b *strings.Builder, segment, mode string) {
+	if mode == FreeTextBucket {
+		b.WriteString("<text:" + detectLanguage(segment) + ">")
+		return
+	}
+	b.WriteString("<text>")
+}
+
+// isWordRune reports whether r is part of a word, so a quote next to it is
+// an apostrophe rather than the start or end of a quotation.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isForeignLetter reports whether r is a letter or mark of a non-Latin
+// script.
+func isForeignLetter(r rune) bool {
+	return r > unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsMark(r)) && !unicode.Is(unicode.Latin, r)
+}
+
+// unicodeFolder applies compatibility decomposition, strips combining marks
+// and recomposes, so "Fehlgeschlägen" and "Fehlgeschlagen", or full-width
+// and ASCII digits, normalize to the same text.
+var unicodeFolder = transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// normalizeUnicode folds a message into a script-neutral form for
+// signature computation. Non-ASCII decimal digits become '0' so the
+// numeric placeholder rules apply to them too.
+func normalizeUnicode(message string) string {
+	folded, _, err := transform.String(unicodeFolder, message)
+	if err != nil {
+		folded = message
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII && unicode.IsDigit(r) {
+			return '0'
+		}
+		return r
+	}, folded)
+}
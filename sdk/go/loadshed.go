@@ -0,0 +1,173 @@
+package lipservice
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// Load shedding thresholds and timing.
+const (
+	// loadShedInterval is how often runtime metrics are sampled
+	loadShedInterval = time.Second
+
+	// loadShedGCFraction is the share of CPU time spent in GC above which
+	// the runtime is considered to be thrashing
+	loadShedGCFraction = 0.25
+
+	// loadShedSchedLatency is the p90 goroutine scheduling latency above
+	// which the host is considered CPU-saturated
+	loadShedSchedLatency = 10 * time.Millisecond
+
+	// loadShedMinFactor is the lowest rate factor applied under pressure
+	loadShedMinFactor = 1.0 / 16
+
+	// loadShedSkipFactor is the rate factor below which signature work is
+	// skipped in favour of severity-only decisions
+	loadShedSkipFactor = 0.25
+)
+
+// Runtime metrics read by the load shedder.
+const (
+	metricGCCPU        = "/cpu/classes/gc/total:cpu-seconds"
+	metricTotalCPU     = "/cpu/classes/total:cpu-seconds"
+	metricSchedLatency = "/sched/latencies:seconds"
+)
+
+// loadShedder lowers sampling rates while runtime metrics show CPU
+// saturation or GC thrashing, so telemetry yields to application work.
+type loadShedder struct {
+	samples []metrics.Sample
+	diag    *diagnostics
+	lastGC  float64
+	lastCPU float64
+	lastSch []uint64
+
+	// factor is the current rate factor as float64 bits
+	factor atomic.Uint64
+}
+
+// newLoadShedder creates a load shedder, or returns nil if load shedding is
+// disabled.
+func newLoadShedder(config Config, diag *diagnostics) *loadShedder {
+	if !config.LoadShedding {
+		return nil
+	}
+
+	l := &loadShedder{
+		diag: diag,
+		samples: []metrics.Sample{
+			{Name: metricGCCPU},
+			{Name: metricTotalCPU},
+			{Name: metricSchedLatency},
+		},
+	}
+	l.factor.Store(math.Float64bits(1))
+	l.read()
+
+	return l
+}
+
+// rateFactor returns the multiplier currently applied to sampling rates.
+func (l *loadShedder) rateFactor() float64 {
+	return math.Float64frombits(l.factor.Load())
+}
+
+// skipSignatures reports whether pressure is high enough that signature
+// work should be skipped.
+func (l *loadShedder) skipSignatures() bool {
+	return l.rateFactor() < loadShedSkipFactor
+}
+
+// update samples runtime metrics and halves the rate factor under pressure,
+// or doubles it back towards 1 once pressure subsides.
+func (l *loadShedder) update() {
+	gcFraction, schedP90 := l.read()
+	overloaded := gcFraction > loadShedGCFraction || schedP90 > loadShedSchedLatency
+
+	old := l.rateFactor()
+	next := old
+	if overloaded {
+		next = math.Max(old/2, loadShedMinFactor)
+	} else {
+		next = math.Min(old*2, 1)
+	}
+	l.factor.Store(math.Float64bits(next))
+
+	if overloaded && old == 1 {
+		l.diag.log(slog.Default(), "WARN", "Host under pressure, shedding telemetry load",
+			"gc_percent", math.Round(gcFraction*100), "sched_p90", schedP90)
+	} else if !overloaded && next == 1 && old < 1 {
+		l.diag.log(slog.Default(), "INFO", "Host pressure subsided, restoring sampling rates")
+	}
+}
+
+// read samples the runtime metrics and returns the GC CPU fraction and p90
+// scheduling latency since the previous read.
+func (l *loadShedder) read() (float64, time.Duration) {
+	metrics.Read(l.samples)
+
+	var gcFraction float64
+	if l.samples[0].Value.Kind() == metrics.KindFloat64 && l.samples[1].Value.Kind() == metrics.KindFloat64 {
+		gc, total := l.samples[0].Value.Float64(), l.samples[1].Value.Float64()
+		if delta := total - l.lastCPU; delta > 0 {
+			gcFraction = (gc - l.lastGC) / delta
+		}
+		l.lastGC, l.lastCPU = gc, total
+	}
+
+	var schedP90 time.Duration
+	if l.samples[2].Value.Kind() == metrics.KindFloat64Histogram {
+		hist := l.samples[2].Value.Float64Histogram()
+		if len(l.lastSch) == len(hist.Counts) {
+			schedP90 = histogramQuantile(hist, l.lastSch, 0.9)
+		}
+		l.lastSch = append(l.lastSch[:0], hist.Counts...)
+	}
+
+	return gcFraction, schedP90
+}
+
+// histogramQuantile returns quantile q of the counts added to hist since
+// previous, taking each bucket's upper bound.
+func histogramQuantile(hist *metrics.Float64Histogram, previous []uint64, q float64) time.Duration {
+	var total uint64
+	for i, count := range hist.Counts {
+		total += count - previous[i]
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := uint64(math.Ceil(float64(total) * q))
+	var seen uint64
+	for i, count := range hist.Counts {
+		seen += count - previous[i]
+		if seen >= target {
+			upper := hist.Buckets[i+1]
+			if math.IsInf(upper, 1) {
+				upper = hist.Buckets[i]
+			}
+			return time.Duration(upper * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// loadShedLoop samples runtime metrics periodically.
+func (s *AdaptiveSampler) loadShedLoop(ctx context.Context) {
+	ticker := time.NewTicker(loadShedInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.shedder.update()
+		}
+	}
+}
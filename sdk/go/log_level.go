@@ -0,0 +1,102 @@
+package lipservice
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevelEnv names the environment variable setting the initial log level
+// when Config.LogLevel is empty.
+const LogLevelEnv = "LIPSERVICE_LOG_LEVEL"
+
+// DropReasonLevel counts records below the current log level.
+const DropReasonLevel = "below_level"
+
+// logLevels are the settable levels, most verbose first. ERROR is the
+// least verbose, so errors are never filtered out.
+var logLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR"}
+
+// logLevel is the minimum severity emitted locally and considered for
+// sampling. It can be changed at runtime from any goroutine.
+type logLevel struct {
+	index atomic.Int32 // position in logLevels
+}
+
+// newLogLevel returns the level from config.LogLevel, falling back to
+// LIPSERVICE_LOG_LEVEL and then to TRACE (everything).
+func newLogLevel(config Config) (*logLevel, error) {
+	name := config.LogLevel
+	if name == "" {
+		name = os.Getenv(LogLevelEnv)
+	}
+
+	level := &logLevel{}
+	if name == "" {
+		return level, nil
+	}
+	if err := level.set(name); err != nil {
+		return nil, err
+	}
+	return level, nil
+}
+
+// set changes the level by name.
+func (l *logLevel) set(name string) error {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "WARNING" {
+		name = "WARN"
+	}
+	for i, level := range logLevels {
+		if level == name {
+			l.index.Store(int32(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q (expected one of %s)", name, strings.Join(logLevels, ", "))
+}
+
+// get returns the current level's name.
+func (l *logLevel) get() string {
+	return logLevels[l.index.Load()]
+}
+
+// shift moves the level by delta steps, negative for more verbose, staying
+// within logLevels. It returns the new level's name.
+func (l *logLevel) shift(delta int) string {
+	for {
+		current := l.index.Load()
+		next := current + int32(delta)
+		if next < 0 {
+			next = 0
+		}
+		if next >= int32(len(logLevels)) {
+			next = int32(len(logLevels) - 1)
+		}
+		if l.index.CompareAndSwap(current, next) {
+			return logLevels[next]
+		}
+	}
+}
+
+// enabled reports whether records of severity pass the level.
+func (l *logLevel) enabled(severity string) bool {
+	return severityNumber(severity) >= severityNumber(l.get())
+}
+
+// SetLogLevel changes the minimum severity emitted and sampled, e.g. to
+// "DEBUG" while investigating an incident. ERROR and above always pass.
+func (ls *LipService) SetLogLevel(level string) error {
+	if err := ls.sampler.level.set(level); err != nil {
+		return err
+	}
+	ls.sampler.diag.log(slog.Default(), "INFO", "Log level set", "level", ls.sampler.level.get())
+	return nil
+}
+
+// LogLevel returns the current minimum severity emitted and sampled.
+func (ls *LipService) LogLevel() string {
+	return ls.sampler.level.get()
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package lipservice
+
+import "log/slog"
+
+// watchLevelSignals is not supported on this platform, which has no
+// SIGUSR1 or SIGUSR2; use SetLogLevel instead.
+func (ls *LipService) watchLevelSignals() {
+	ls.sampler.diag.log(slog.Default(), "WARN", "Log level signals are not supported on this platform")
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package lipservice
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchLevelSignals makes SIGUSR1 one level more verbose and SIGUSR2 one
+// level less, until ls is closed.
+func (ls *LipService) watchLevelSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	ls.wg.Add(1)
+	go func() {
+		defer ls.wg.Done()
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ls.ctx.Done():
+				return
+			case sig := <-signals:
+				delta := 1
+				if sig == syscall.SIGUSR1 {
+					delta = -1
+				}
+				ls.sampler.diag.log(slog.Default(), "INFO", "Log level set", "level", ls.sampler.level.shift(delta))
+			}
+		}
+	}()
+}
@@ -2,26 +2,70 @@ package lipservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
 )
 
 // LipServiceLogger provides intelligent logging with sampling and PostHog integration.
 type LipServiceLogger struct {
-	sampler       *AdaptiveSampler
+	sampler         *AdaptiveSampler
 	posthogExporter *PostHogExporter
-	baseLogger    *slog.Logger
+	baseLogger      *slog.Logger
+	stats           *deliveryStats
+	deduper         *deduper
+	redactor        *secretRedactor
+	router          *residencyRouter
+	routes          *exportRouter
+	ids             IDGenerator
+	attrs           []interface{}
+	bound           []*common.KeyValue
+	tally           *requestTally
+	contexts        *contextBuffer
+	categories      map[string]*logCategory
+	category        string
+	diag            *diagnostics
+	trails          *debugTrails
+	fanout          []*fanoutExporter
+	spanEvents      *spanEvents
+	span            trace.Span
+	privacy         *attributePrivacy
+	tombstones      *erasureTombstones
+	imports         *importState
+	unsampled       string
 }
 
 // NewLipServiceLogger creates a new LipService logger.
 func NewLipServiceLogger(sampler *AdaptiveSampler, posthogExporter *PostHogExporter) *LipServiceLogger {
 	baseLogger := slog.Default()
 
+	stats := newDeliveryStats()
+	trails := newDebugTrails(sampler.config)
+	var privacy *attributePrivacy
+	if posthogExporter != nil {
+		stats = posthogExporter.stats
+		trails = posthogExporter.trails
+		privacy = posthogExporter.privacy
+	}
+
 	return &LipServiceLogger{
-		sampler:       sampler,
+		sampler:         sampler,
 		posthogExporter: posthogExporter,
-		baseLogger:    baseLogger,
+		baseLogger:      baseLogger,
+		stats:           stats,
+		deduper:         newDeduper(sampler.config.DedupWindow),
+		ids:             newIDGenerator(sampler.config),
+		contexts:        newContextBuffer(sampler.config),
+		diag:            sampler.diag,
+		trails:          trails,
+		spanEvents:      newSpanEvents(sampler.config),
+		privacy:         privacy,
+		tombstones:      &erasureTombstones{},
 	}
 }
 
@@ -52,56 +96,315 @@ func (l *LipServiceLogger) Fatal(msg string, args ...interface{}) {
 
 // log handles the core logging logic with sampling and PostHog export.
 func (l *LipServiceLogger) log(severity, msg string, args ...interface{}) {
-	// Check if we should sample this log
-	if !l.sampler.ShouldSample(msg, severity) {
-		return
+	l.process(severity, msg, time.Now(), args)
+}
+
+// process runs a record through sampling and export as of now, reporting
+// whether it was kept. On an importer, now is the record's original time
+// and the import's own dedup, SLO and category state stand in for the live
+// ones.
+func (l *LipServiceLogger) process(severity, msg string, now time.Time, args []interface{}) bool {
+	// A repeat summary stands in for a duplicate that was already accepted
+	// and counted, so it leaves the duplicate count rather than being
+	// accepted again, and is kept whatever the level, sampling or budgets
+	repeat := l.unsampled == SamplingReasonRepeatSummary
+	if repeat {
+		l.stats.drop(DropReasonDuplicate, -1)
+	} else if !l.accept(severity) {
+		return false
+	}
+
+	// Strip secrets before the message is fingerprinted, logged or exported
+	if l.redactor != nil {
+		msg = l.redactor.redact(msg)
+	}
+
+	// Records tagged for tracing leave a trail under their record ID
+	trace := l.trails.start(l.ids, msg, severity, args, l.attrs)
+
+	// Collapse identical records within the dedup window; windows this
+	// record closed are logged first, with their repeat counts
+	if l.deduper != nil {
+		emit, closed := l.deduper.check(l, severity, msg, args, now)
+		if !emit {
+			l.stats.drop(DropReasonDuplicate, 1)
+			l.tally.drop()
+			l.trails.step(trace, TrailDropped, "%s", DropReasonDuplicate)
+			return false
+		}
+		logRepeats(closed)
+	}
+
+	// Measure the SLO on every record, kept or not
+	slo := l.sampler.slo
+	if l.imports != nil {
+		slo = l.imports.slo
+	}
+	slo.observe(severity, args, now)
+
+	// Check if we should sample this log; imports leave live pattern
+	// stats and budgets alone, and SDK summaries are always kept
+	var outcome samplingOutcome
+	switch {
+	case l.unsampled != "":
+		outcome = l.sampler.keepUnsampled(l.unsampled)
+	case l.imports != nil:
+		outcome = l.sampler.sampleImported(msg, severity, now, slo)
+	default:
+		outcome = l.sampler.sample(msg, severity)
+	}
+	l.trails.step(trace, TrailSampled, "kept=%t reason=%s rate=%g", outcome.kept, outcome.reason, outcome.rate)
+
+	// In debug mode every record is logged locally with why it was kept or
+	// dropped; imported records are never logged locally
+	local := l.imports == nil
+	debug := local && l.sampler.config.DebugSampling
+	if debug {
+		annotated := append(args[:len(args):len(args)], outcome.attributes()...)
+		l.baseLogger.Info(msg, annotated...)
+	}
+
+	if !outcome.kept {
+		l.stats.drop(DropReasonSampledOut, 1)
+		l.tally.drop()
+		l.trails.step(trace, TrailDropped, "%s", DropReasonSampledOut)
+		l.contexts.hold(l, severity, msg, outcome.signature, args)
+		l.spanEvents.record(l.span, severity, msg, args, l.attrs, l.privacy)
+		return false
+	}
+
+	// Keep one noisy tenant from crowding out the others
+	if !repeat && !l.sampler.admitTenant(severity, args, l.attrs) {
+		l.stats.drop(DropReasonTenantShare, 1)
+		l.tally.drop()
+		l.trails.step(trace, TrailDropped, "%s", DropReasonTenantShare)
+		return false
+	}
+	// Each category keeps to its own budget
+	category := l.categories[l.category]
+	if !repeat && !category.admit(now) {
+		l.stats.drop(DropReasonCategoryBudget, 1)
+		l.tally.drop()
+		l.trails.step(trace, TrailDropped, "%s", DropReasonCategoryBudget)
+		return false
 	}
+	l.stats.sampled.Add(1)
+	l.tally.emit()
 
 	// Log to base logger
-	l.baseLogger.Info(msg, args...)
+	if local && !debug {
+		l.baseLogger.Info(msg, args...)
+	}
 
-	// Export to PostHog if configured
-	if l.posthogExporter != nil {
-		// Convert args to attributes map
-		attributes := make(map[string]interface{})
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				key, ok := args[i].(string)
-				if ok {
-					attributes[key] = args[i+1]
-				}
-			}
+	// Pick the sinks for this record; a category's sinks override routes,
+	// and without either everything goes to PostHog
+	sinks := []string{PostHogSink}
+	switch {
+	case category != nil && len(category.config.Sinks) > 0:
+		sinks = category.config.Sinks
+	case l.routes != nil:
+		sinks = l.routes.match(severity, msg)
+	}
+	if l.posthogExporter == nil && len(l.fanout) == 0 && len(sinks) == 1 && sinks[0] == PostHogSink {
+		l.trails.step(trace, TrailDropped, "no exporter configured")
+		return true
+	}
+
+	// Collect the record's attributes in fields backed by the stack rather
+	// than a map; attrs bound by With are pre-converted
+	var buf [fieldsInline]field
+	attributes := newRecordFields(&buf)
+	attributes.add(args)
+	if l.sampler.config.MultiLanguage {
+		attributes.set(LanguageAttribute, detectLanguage(msg))
+	}
+	if stack, ok := parsePanic(msg); ok {
+		for key, value := range stack.attributes(l.sampler.signer.config.Hasher) {
+			attributes.set(key, value)
 		}
+	}
+	if outcome.warmup {
+		attributes.set(WarmupAttribute, true)
+	}
+	if l.category != "" {
+		attributes.set(CategoryAttribute, l.category)
+	}
+	if l.imports != nil {
+		attributes.set(ImportedAttribute, true)
+	}
+	if retention := l.retention(severity, category, args); retention != "" {
+		attributes.set(RetentionAttribute, retention)
+	}
+	if owner := l.sampler.owners.owner(msg, args, l.attrs); owner != "" {
+		attributes.set(OwnerAttribute, owner)
+	}
+	if l.sampler.config.CollectorMetadata {
+		for key, value := range outcome.exportAttributes() {
+			attributes.set(key, value)
+		}
+	}
+	if trace != "" {
+		attributes.set(RecordIDAttribute, trace)
+	} else if l.ids != nil {
+		// Assigned here so every sink sees the same ID
+		attributes.set(RecordIDAttribute, l.ids.NewID())
+	}
+
+	// The event time defaults to now unless the caller supplied one
+	timestamp := now
+	if eventTime, ok := attributes.value(EventTimeAttribute).(time.Time); ok {
+		timestamp = eventTime
+		attributes.remove(EventTimeAttribute)
+	}
+
+	l.trails.step(trace, TrailEnqueued, "sinks=%v", sinks)
+	l.exportRecord(sinks, msg, severity, timestamp, attributes)
+
+	// Give responders the records that led up to the failure
+	if local && outcome.reason == SamplingReasonSeverity {
+		l.exportContext(sinks, outcome.signature, args, attributes)
+	}
+	return true
+}
 
-		// Export to PostHog
-		err := l.posthogExporter.ExportLog(msg, severity, time.Now(), attributes)
+// accept counts a record as accepted and reports whether it passes the
+// runtime log level; records below it are neither emitted nor sampled.
+func (l *LipServiceLogger) accept(severity string) bool {
+	l.stats.accepted.Add(1)
+
+	if !l.sampler.level.enabled(severity) {
+		l.stats.drop(DropReasonLevel, 1)
+		l.tally.drop()
+		return false
+	}
+	if severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL" {
+		l.stats.errors.Add(1)
+	}
+	return true
+}
+
+// exportRecord sends a record to each of sinks and, along with PostHog,
+// to Config.Exporters. A record refused by data residency goes nowhere.
+func (l *LipServiceLogger) exportRecord(sinks []string, msg, severity string, timestamp time.Time, attributes recordFields) {
+	// Route to the exporter for the record's data region
+	exporter := l.posthogExporter
+	if l.router != nil {
+		routed, err := l.router.route(attributes, l.attrs)
 		if err != nil {
-			// Log error but don't fail
-			l.baseLogger.Error("Failed to export log to PostHog", "error", err)
+			l.stats.drop(DropReasonResidency, 1)
+			l.diag.log(l.baseLogger, "ERROR", "Refusing to export log outside its data region", "error", err)
+			return
+		}
+		exporter = routed
+	}
+
+	posthog := containsString(sinks, PostHogSink)
+	if len(sinks) > 1 || !posthog || len(l.fanout) > 0 {
+		// Sinks and Config.Exporters see the record only once it has been
+		// through the same attribute stages as PostHog
+		guardedMsg, guarded, err := l.guard(msg, attributes)
+		switch {
+		case err != nil:
+			l.diag.log(l.baseLogger, "ERROR", "Failed to prepare log for export", "error", err)
+		case l.tombstones.matchesAttributes(guarded):
+			// Erased identifiers go nowhere, PostHog included
+			l.stats.drop(DropReasonErased, 1)
+			return
+		default:
+			for _, name := range sinks {
+				if name != PostHogSink {
+					l.exportSink(name, guardedMsg, severity, timestamp, guarded)
+				}
+			}
+			if posthog {
+				l.exportFanout(guardedMsg, severity, timestamp, guarded)
+			}
 		}
 	}
+
+	if posthog && exporter != nil {
+		l.exportPostHog(exporter, msg, severity, timestamp, attributes)
+	}
+}
+
+// guard merges the attributes bound by With into a record's own and runs
+// them through the export attribute stages, truncating the message unless
+// Config.KeepFullMessages is set.
+func (l *LipServiceLogger) guard(msg string, attributes recordFields) (string, map[string]interface{}, error) {
+	merged := make(map[string]interface{}, len(l.attrs)/2+len(attributes))
+	addAttributes(merged, l.attrs)
+	for _, field := range attributes {
+		merged[field.key] = field.value
+	}
+
+	if !l.sampler.config.KeepFullMessages {
+		msg, merged = truncateForExport(msg, merged, maxMessageBytes(l.sampler.config))
+	}
+	guarded, err := l.privacy.apply(merged)
+	return msg, guarded, err
+}
+
+// exportPostHog sends a record to the PostHog exporter for its data region.
+func (l *LipServiceLogger) exportPostHog(exporter *PostHogExporter, msg, severity string, timestamp time.Time, attributes recordFields) {
+	err := exporter.exportFields(msg, severity, timestamp, l.bound, attributes)
+	if errors.Is(err, ErrExporterClosed) {
+		l.stats.drop(DropReasonClosed, 1)
+		return
+	}
+	if err != nil {
+		// Log error but don't fail
+		l.diag.log(l.baseLogger, "ERROR", "Failed to export log to PostHog", "error", err)
+	}
+}
+
+// exportSink sends a guarded record to a named sink.
+func (l *LipServiceLogger) exportSink(name, msg, severity string, timestamp time.Time, attributes map[string]interface{}) {
+	if err := l.sampler.config.Sinks[name].ExportLog(msg, severity, timestamp, attributes); err != nil {
+		l.diag.log(l.baseLogger, "ERROR", "Failed to export log to sink", "sink", name, "error", err)
+	}
+}
+
+// addAttributes copies key/value pairs and Attrs from args into attributes.
+func addAttributes(attributes map[string]interface{}, args []interface{}) {
+	eachAttribute(args, func(key string, value interface{}) bool {
+		attributes[key] = value
+		return true
+	})
 }
 
-// With returns a new logger with additional context.
+// With returns a new logger with additional context. The bound attributes
+// are copied into a fresh slice, so loggers can be cloned concurrently
+// from any goroutine without locking or sharing backing arrays.
 func (l *LipServiceLogger) With(args ...interface{}) *LipServiceLogger {
-	newLogger := l.baseLogger.With(args...)
-	
-	return &LipServiceLogger{
-		sampler:       l.sampler,
-		posthogExporter: l.posthogExporter,
-		baseLogger:    newLogger,
+	clone := *l
+	clone.baseLogger = l.baseLogger.With(args...)
+
+	attrs := make([]interface{}, 0, len(l.attrs)+len(args))
+	attrs = append(attrs, l.attrs...)
+	clone.attrs = append(attrs, args...)
+
+	// Convert to OTLP once here rather than on every record
+	if l.posthogExporter != nil {
+		kvs, err := l.posthogExporter.prebind(args)
+		if err != nil {
+			l.diag.log(l.baseLogger, "WARN", "Failed to bind attributes for export", "error", err)
+		} else {
+			clone.bound = mergeBound(l.bound, kvs)
+		}
 	}
+
+	return &clone
 }
 
-// WithContext returns a new logger with the given context.
+// WithContext returns a new logger with the given context. Within a
+// request handled by Middleware, its logs count towards the request summary.
 func (l *LipServiceLogger) WithContext(ctx context.Context) *LipServiceLogger {
-	newLogger := l.baseLogger.With("context", ctx)
-	
-	return &LipServiceLogger{
-		sampler:       l.sampler,
-		posthogExporter: l.posthogExporter,
-		baseLogger:    newLogger,
+	clone := *l
+	clone.baseLogger = l.baseLogger.With("context", ctx)
+	if tally := requestTallyFrom(ctx); tally != nil {
+		clone.tally = tally
 	}
+	return &clone
 }
 
 // Example usage and integration patterns
@@ -109,19 +412,19 @@ func (l *LipServiceLogger) WithContext(ctx context.Context) *LipServiceLogger {
 // ExampleHTTPHandler shows how to integrate LipService with HTTP handlers.
 func ExampleHTTPHandler(ls *LipService) http.HandlerFunc {
 	logger := ls.Logger()
-	
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Log request
-		logger.Info("HTTP request received", 
+		logger.Info("HTTP request received",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"user_agent", r.UserAgent(),
 		)
-		
+
 		// Process request
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
-		
+
 		// Log response
 		logger.Info("HTTP request completed",
 			"method", r.Method,
@@ -134,35 +437,35 @@ func ExampleHTTPHandler(ls *LipService) http.HandlerFunc {
 // ExampleDatabaseOperation shows how to integrate LipService with database operations.
 func ExampleDatabaseOperation(ls *LipService) error {
 	logger := ls.Logger()
-	
+
 	logger.Info("Starting database operation", "operation", "user_lookup")
-	
+
 	// Simulate database operation
 	time.Sleep(100 * time.Millisecond)
-	
-	logger.Info("Database operation completed", 
+
+	logger.Info("Database operation completed",
 		"operation", "user_lookup",
 		"duration_ms", 100,
 		"rows_affected", 1,
 	)
-	
+
 	return nil
 }
 
 // ExampleErrorHandling shows how to handle errors with LipService.
 func ExampleErrorHandling(ls *LipService) {
 	logger := ls.Logger()
-	
+
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Error("Panic recovered", "panic", r)
 		}
 	}()
-	
+
 	// Simulate an error
 	err := fmt.Errorf("database connection failed")
 	if err != nil {
-		logger.Error("Database operation failed", 
+		logger.Error("Database operation failed",
 			"error", err.Error(),
 			"operation", "user_creation",
 		)
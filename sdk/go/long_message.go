@@ -0,0 +1,62 @@
+package lipservice
+
+import "unicode/utf8"
+
+// Long message limits.
+const (
+	// defaultMaxMessageBytes is the longest message body exported before
+	// it is truncated
+	defaultMaxMessageBytes = 16 << 10
+
+	// signatureMaxBytes is how much of a message is normalized for its
+	// signature. The tail of a huge message is usually payload that
+	// doesn't distinguish its pattern, and the regex passes over it are
+	// the costliest part of sampling.
+	signatureMaxBytes = 4 << 10
+)
+
+// Attributes added to records whose message was truncated for export.
+const (
+	TruncatedAttribute      = "lipservice.truncated"
+	OriginalLengthAttribute = "lipservice.original_length"
+)
+
+// maxMessageBytes returns the export limit for message bodies.
+func maxMessageBytes(config Config) int {
+	if config.MaxMessageBytes <= 0 {
+		return defaultMaxMessageBytes
+	}
+	return config.MaxMessageBytes
+}
+
+// truncateMessage shortens message to at most max bytes without splitting
+// a UTF-8 sequence, reporting whether it was cut.
+func truncateMessage(message string, max int) (string, bool) {
+	if len(message) <= max {
+		return message, false
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut], true
+}
+
+// truncateForExport truncates an oversized message, returning a copy of
+// attributes annotated with its original length.
+func truncateForExport(message string, attributes map[string]interface{}, max int) (string, map[string]interface{}) {
+	truncated, cut := truncateMessage(message, max)
+	if !cut {
+		return message, attributes
+	}
+
+	annotated := make(map[string]interface{}, len(attributes)+2)
+	for key, value := range attributes {
+		annotated[key] = value
+	}
+	annotated[TruncatedAttribute] = true
+	annotated[OriginalLengthAttribute] = len(message)
+
+	return truncated, annotated
+}
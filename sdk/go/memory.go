@@ -0,0 +1,82 @@
+package lipservice
+
+import (
+	"sort"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Policies for Config.MemoryOverflowPolicy.
+const (
+	// MemoryPolicySpill writes the buffered batch to the disk spool to make
+	// room, falling back to MemoryPolicyDrop without a spool
+	MemoryPolicySpill = "spill"
+
+	// MemoryPolicyDrop drops the lowest-priority records to make room
+	MemoryPolicyDrop = "drop"
+)
+
+// recordBytes returns the memory accounted to a buffered record.
+func recordBytes(record *logs.LogRecord) int64 {
+	return int64(proto.Size(record))
+}
+
+// makeRoom frees buffer space for an incoming record of size bytes and
+// priority p under MaxMemoryBytes, and reports whether the record can be
+// buffered. Callers must hold e.mu.
+func (e *PostHogExporter) makeRoom(size int64, p Priority) bool {
+	limit := e.config.MaxMemoryBytes
+	if limit <= 0 || e.batchBytes+size <= limit {
+		return true
+	}
+	if size > limit {
+		return false
+	}
+
+	if e.config.MemoryOverflowPolicy != MemoryPolicyDrop && e.spool != nil {
+		if err := e.spoolRecords(e.batch); err == nil {
+			e.batch = e.batch[:0]
+			e.batchBytes = 0
+			return true
+		}
+	}
+
+	// Drop from the lowest priority up, never evicting records that
+	// outrank the incoming one
+	sort.SliceStable(e.batch, func(i, j int) bool {
+		return recordPriority(e.batch[i]) > recordPriority(e.batch[j])
+	})
+
+	dropped := int64(0)
+	for e.batchBytes+size > limit && len(e.batch) > 0 {
+		last := e.batch[len(e.batch)-1]
+		if recordPriority(last) > p {
+			break
+		}
+		e.batch = e.batch[:len(e.batch)-1]
+		e.batchBytes -= recordBytes(last)
+		dropped++
+	}
+	if dropped > 0 {
+		e.stats.drop(DropReasonMemory, dropped)
+	}
+
+	return e.batchBytes+size <= limit
+}
+
+// recountBatchBytes recomputes the bytes held by the batch after it has
+// been rebuilt. Callers must hold e.mu.
+func (e *PostHogExporter) recountBatchBytes() {
+	e.batchBytes = 0
+	for _, record := range e.batch {
+		e.batchBytes += recordBytes(record)
+	}
+}
+
+// PendingBytes returns the memory held by buffered logs.
+func (e *PostHogExporter) PendingBytes() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.batchBytes
+}
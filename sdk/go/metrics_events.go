@@ -0,0 +1,234 @@
+package lipservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Events sent to PostHog when MetricsEvents is set.
+const (
+	// MetricsEvent summarizes volume, savings and errors for an interval
+	MetricsEvent = "lipservice_metrics"
+
+	// PatternVolumeEvent reports one busy pattern's volume for an interval
+	PatternVolumeEvent = "lipservice_pattern_volume"
+)
+
+// Metrics event timing and size.
+const (
+	// defaultMetricsInterval is how often metrics events are sent
+	defaultMetricsInterval = 5 * time.Minute
+
+	// metricsTopPatterns is the number of busiest patterns reported
+	metricsTopPatterns = 20
+
+	// metricsFinalTimeout bounds the last send at Close
+	metricsFinalTimeout = 5 * time.Second
+)
+
+// posthogEvent is one event in a PostHog /batch/ request.
+type posthogEvent struct {
+	Event      string                 `json:"event"`
+	DistinctID string                 `json:"distinct_id"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// metricsReporter periodically sends aggregate SDK metrics to PostHog as
+// events, so LipService's impact shows up in product analytics dashboards
+// without a Prometheus setup.
+type metricsReporter struct {
+	ls       *LipService
+	client   *http.Client
+	interval time.Duration
+	previous ShutdownReport
+	last     time.Time
+}
+
+// newMetricsReporter returns the reporter for ls, or nil if MetricsEvents
+// is not set, there is no PostHog project to send to, or ls is offline.
+func newMetricsReporter(ls *LipService) *metricsReporter {
+	if !ls.config.MetricsEvents || ls.config.PostHogAPIKey == "" || ls.config.Offline {
+		return nil
+	}
+	interval := ls.config.MetricsInterval
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+	return &metricsReporter{
+		ls:       ls,
+		client:   newExportClient(ls.config),
+		interval: interval,
+		last:     time.Now(),
+	}
+}
+
+// start sends metrics every interval until ls is closed. Close sends the
+// final interval's itself, once the exporters have drained.
+func (r *metricsReporter) start() {
+	r.ls.wg.Add(1)
+	go func() {
+		defer r.ls.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.ls.ctx.Done():
+				return
+			case now := <-ticker.C:
+				r.report(r.ls.ctx, now)
+			}
+		}
+	}()
+}
+
+// final sends the metrics for the last interval at Close.
+func (r *metricsReporter) final() {
+	if r == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metricsFinalTimeout)
+	defer cancel()
+	r.report(ctx, time.Now())
+}
+
+// due reports whether an interval has passed since the last report.
+func (r *metricsReporter) due(now time.Time) bool {
+	return r != nil && now.Sub(r.last) >= r.interval
+}
+
+// report sends the metrics for the interval ending now, logging failures.
+func (r *metricsReporter) report(ctx context.Context, now time.Time) {
+	if err := r.send(ctx, r.events(now)); err != nil {
+		r.ls.sampler.diag.log(slog.Default(), "WARN", "Failed to send metrics events", "error", err)
+	}
+}
+
+// events builds the events for the interval ending now and starts the
+// next interval.
+func (r *metricsReporter) events(now time.Time) []posthogEvent {
+	current := r.ls.Report()
+	window := now.Sub(r.last)
+
+	accepted := current.Accepted - r.previous.Accepted
+	sampledOut := current.Dropped[DropReasonSampledOut] - r.previous.Dropped[DropReasonSampledOut]
+	dropped := make(map[string]int64, len(current.Dropped))
+	for reason, n := range current.Dropped {
+		if delta := n - r.previous.Dropped[reason]; delta > 0 {
+			dropped[reason] = delta
+		}
+	}
+	savings := 0.0
+	if accepted > 0 {
+		savings = float64(sampledOut) / float64(accepted)
+	}
+
+	distinctID := "lipservice:" + r.ls.config.ServiceName
+	base := func(properties map[string]interface{}) map[string]interface{} {
+		properties["service"] = r.ls.config.ServiceName
+		properties["sdk_version"] = Version
+		properties["interval_seconds"] = window.Seconds()
+		// Metrics describe the service, not a person
+		properties["$process_person_profile"] = false
+		return properties
+	}
+
+	events := []posthogEvent{{
+		Event:      MetricsEvent,
+		DistinctID: distinctID,
+		Timestamp:  now.UTC(),
+		Properties: base(map[string]interface{}{
+			"accepted":          accepted,
+			"sampled":           current.Sampled - r.previous.Sampled,
+			"exported":          current.Exported - r.previous.Exported,
+			"errors":            current.Errors - r.previous.Errors,
+			"dropped":           dropped,
+			"savings_ratio":     savings,
+			"patterns":          current.Patterns,
+			"pattern_evictions": current.PatternEvictions - r.previous.PatternEvictions,
+		}),
+	}}
+	for _, volume := range r.ls.sampler.patternVolumes(now, window, metricsTopPatterns) {
+		events = append(events, posthogEvent{
+			Event:      PatternVolumeEvent,
+			DistinctID: distinctID,
+			Timestamp:  now.UTC(),
+			Properties: base(map[string]interface{}{
+				"signature":     volume.Signature,
+				"count":         volume.Count,
+				"sampling_rate": volume.SamplingRate,
+			}),
+		})
+	}
+
+	r.previous = current
+	r.last = now
+	return events
+}
+
+// send posts events to PostHog's batch capture endpoint.
+func (r *metricsReporter) send(ctx context.Context, events []posthogEvent) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"api_key": r.ls.config.PostHogAPIKey,
+		"batch":   events,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/batch/", r.ls.config.PostHogEndpoint)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-LipService-SDK", "go/"+Version)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return &statusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// patternVolumes returns the busiest patterns over window, at most limit.
+func (s *AdaptiveSampler) patternVolumes(now time.Time, window time.Duration, limit int) []PatternReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var volumes []PatternReport
+	for signature, stats := range s.patternStats {
+		if count := stats.buckets.count(now, window); count > 0 {
+			volumes = append(volumes, PatternReport{
+				Signature:    signature,
+				Count:        count,
+				SamplingRate: stats.SamplingRate,
+				LastSeen:     stats.LastSeen,
+			})
+		}
+	}
+
+	sort.Slice(volumes, func(i, j int) bool {
+		if volumes[i].Count != volumes[j].Count {
+			return volumes[i].Count > volumes[j].Count
+		}
+		return volumes[i].Signature < volumes[j].Signature
+	})
+	if len(volumes) > limit {
+		volumes = volumes[:limit]
+	}
+	return volumes
+}
@@ -0,0 +1,155 @@
+package lipservice
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Request summary attributes added to the request-completion log, so a
+// reader of that one record knows whether they are seeing every log the
+// request produced.
+const (
+	RequestLogsEmittedAttribute = "lipservice.request_logs_emitted"
+	RequestLogsDroppedAttribute = "lipservice.request_logs_dropped"
+)
+
+// SamplingReasonRequestSummary is the sampling reason of request-completion
+// logs, which are kept without a sampling decision so the summary they
+// carry is never itself sampled out.
+const SamplingReasonRequestSummary = "request_summary"
+
+// requestTally counts the logs emitted and dropped within one request.
+type requestTally struct {
+	emitted atomic.Int64
+	dropped atomic.Int64
+}
+
+// emit records a kept log. It is a no-op outside a request.
+func (t *requestTally) emit() {
+	if t != nil {
+		t.emitted.Add(1)
+	}
+}
+
+// drop records a sampled-out or suppressed log. It is a no-op outside a
+// request.
+func (t *requestTally) drop() {
+	if t != nil {
+		t.dropped.Add(1)
+	}
+}
+
+// attributes returns the summary attributes for the completion log.
+func (t *requestTally) attributes() []interface{} {
+	return []interface{}{
+		RequestLogsEmittedAttribute, t.emitted.Load(),
+		RequestLogsDroppedAttribute, t.dropped.Load(),
+	}
+}
+
+type requestTallyKey struct{}
+type requestLoggerKey struct{}
+
+// requestTallyFrom returns the tally of the request ctx belongs to, if any.
+func requestTallyFrom(ctx context.Context) *requestTally {
+	tally, _ := ctx.Value(requestTallyKey{}).(*requestTally)
+	return tally
+}
+
+// LoggerFromContext returns the request-scoped logger installed by
+// Middleware or UnaryServerInterceptor, or nil outside a request. Logs
+// written through it are counted in the request summary.
+func LoggerFromContext(ctx context.Context) *LipServiceLogger {
+	logger, _ := ctx.Value(requestLoggerKey{}).(*LipServiceLogger)
+	return logger
+}
+
+// withRequest starts a request tally and returns the context carrying it
+// along with a request-scoped logger, and the logger for the unsampled
+// completion log.
+func (ls *LipService) withRequest(ctx context.Context) (context.Context, *LipServiceLogger, *requestTally) {
+	tally := &requestTally{}
+	ctx = context.WithValue(ctx, requestTallyKey{}, tally)
+
+	logger := ls.Logger().WithContext(ctx)
+	ctx = context.WithValue(ctx, requestLoggerKey{}, logger)
+
+	summary := *logger
+	summary.unsampled = SamplingReasonRequestSummary
+	return ctx, &summary, tally
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware wraps an HTTP handler so that it logs one completion record
+// per request, summarising how many of the request's logs were emitted and
+// dropped. The completion record is never sampled out. Handlers log
+// through LoggerFromContext(r.Context()).
+func (ls *LipService) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, summary, tally := ls.withRequest(r.Context())
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		args := []interface{}{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		}
+		args = append(args, tally.attributes()...)
+
+		if recorder.status >= http.StatusInternalServerError {
+			summary.Error("HTTP request completed", args...)
+		} else {
+			summary.Info("HTTP request completed", args...)
+		}
+	})
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that logs one
+// completion record per call with the same summary as Middleware.
+func (ls *LipService) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, summary, tally := ls.withRequest(ctx)
+
+		resp, err := handler(ctx, req)
+
+		args := []interface{}{
+			"method", info.FullMethod,
+			"code", status.Code(err).String(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		}
+		args = append(args, tally.attributes()...)
+
+		if err != nil {
+			summary.Error("gRPC request completed", args...)
+		} else {
+			summary.Info("gRPC request completed", args...)
+		}
+
+		return resp, err
+	}
+}
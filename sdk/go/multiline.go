@@ -0,0 +1,178 @@
+package lipservice
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Multi-line assembly defaults.
+const (
+	defaultMultilineMaxLines     = 500
+	defaultMultilineFlushTimeout = time.Second
+)
+
+var (
+	// traceStart matches the first line of a stack trace or panic
+	traceStart = regexp.MustCompile(`^(panic: |fatal error: |Traceback \(most recent call last\):|Exception in thread |goroutine \d+ \[)`)
+
+	// traceContinuation matches lines that continue a record wherever
+	// they appear
+	traceContinuation = regexp.MustCompile(`^(\s|Caused by: |\.\.\. \d+ more|goroutine \d+ \[|created by )`)
+
+	// traceBody matches lines that only continue a record already known
+	// to be a trace: Go frames, blank separators and exception summaries
+	traceBody = regexp.MustCompile(`^($|[\w./*()\[\]-]+\(.*\)$|[\w.]+(Error|Exception)\b.*)`)
+)
+
+// MultilineConfig configures how lines are assembled into records.
+type MultilineConfig struct {
+	// StartPattern matches the first line of each record; any other line
+	// continues the previous record. When nil, continuation heuristics
+	// for Go panics and Java and Python stack traces are used.
+	StartPattern *regexp.Regexp
+
+	// MaxLines caps the lines in one record (default: 500)
+	MaxLines int
+
+	// FlushTimeout is how long a partial record waits for more lines
+	// before it is emitted by Writer (default: 1s)
+	FlushTimeout time.Duration
+}
+
+// MultilineAssembler joins continuation lines, such as stack frames, onto
+// the line that started them, so an exception becomes one record instead of
+// dozens of fragments. It is not safe for concurrent use.
+type MultilineAssembler struct {
+	config  MultilineConfig
+	pending []string
+	trace   bool
+}
+
+// NewMultilineAssembler creates an assembler.
+func NewMultilineAssembler(config MultilineConfig) *MultilineAssembler {
+	if config.MaxLines <= 0 {
+		config.MaxLines = defaultMultilineMaxLines
+	}
+	if config.FlushTimeout <= 0 {
+		config.FlushTimeout = defaultMultilineFlushTimeout
+	}
+	return &MultilineAssembler{config: config}
+}
+
+// Add feeds one line. If the line starts a new record, the previous record
+// is returned complete.
+func (a *MultilineAssembler) Add(line string) (string, bool) {
+	if len(a.pending) > 0 && len(a.pending) < a.config.MaxLines && a.continues(line) {
+		a.pending = append(a.pending, line)
+		return "", false
+	}
+
+	record, ok := a.Flush()
+	a.pending = append(a.pending, line)
+	a.trace = traceStart.MatchString(line)
+	return record, ok
+}
+
+// Flush returns the partially assembled record, if any.
+func (a *MultilineAssembler) Flush() (string, bool) {
+	if len(a.pending) == 0 {
+		return "", false
+	}
+
+	record := strings.TrimRight(strings.Join(a.pending, "\n"), "\n")
+	a.pending = a.pending[:0]
+	a.trace = false
+	return record, true
+}
+
+// continues reports whether line belongs to the pending record.
+func (a *MultilineAssembler) continues(line string) bool {
+	if a.config.StartPattern != nil {
+		return !a.config.StartPattern.MatchString(line)
+	}
+	if traceStart.MatchString(line) && !strings.HasPrefix(line, "goroutine ") {
+		return false
+	}
+	return traceContinuation.MatchString(line) || (a.trace && traceBody.MatchString(line))
+}
+
+// Writer returns a writer that logs each record written to it at severity,
+// assembling multi-line records such as panics written to stderr. A partial
+// record is logged once no more lines arrive within the flush timeout, or
+// on Close.
+func (l *LipServiceLogger) Writer(severity string, config MultilineConfig) io.WriteCloser {
+	return &logWriter{
+		logger:    l,
+		severity:  severity,
+		assembler: NewMultilineAssembler(config),
+	}
+}
+
+// logWriter logs assembled records written to it.
+type logWriter struct {
+	logger    *LipServiceLogger
+	severity  string
+	mu        sync.Mutex
+	assembler *MultilineAssembler
+	partial   strings.Builder
+	timer     *time.Timer
+}
+
+// Write splits p into lines and logs each completed record.
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial.Write(p)
+	buffered := w.partial.String()
+
+	lines := strings.Split(buffered, "\n")
+	w.partial.Reset()
+	w.partial.WriteString(lines[len(lines)-1])
+
+	for _, line := range lines[:len(lines)-1] {
+		if record, ok := w.assembler.Add(strings.TrimRight(line, "\r")); ok {
+			w.logger.log(w.severity, record)
+		}
+	}
+
+	// Emit the pending record if nothing follows it soon
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.assembler.config.FlushTimeout, w.flush)
+	} else {
+		w.timer.Reset(w.assembler.config.FlushTimeout)
+	}
+
+	return len(p), nil
+}
+
+// flush logs the pending record.
+func (w *logWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.partial.Len() > 0 {
+		if record, ok := w.assembler.Add(w.partial.String()); ok {
+			w.logger.log(w.severity, record)
+		}
+		w.partial.Reset()
+	}
+	if record, ok := w.assembler.Flush(); ok {
+		w.logger.log(w.severity, record)
+	}
+}
+
+// Close logs any pending record.
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+
+	w.flush()
+	return nil
+}
@@ -0,0 +1,31 @@
+package lipservice
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOffline is returned in place of any network call when Config.Offline
+// is set.
+var ErrOffline = errors.New("lipservice: offline, network calls are disabled")
+
+// checkOffline rejects sinks and exporters that would reach the network
+// from an offline configuration, so Offline is a guarantee rather than a
+// best effort.
+func checkOffline(config Config) error {
+	if !config.Offline {
+		return nil
+	}
+	for name, sink := range config.Sinks {
+		if _, ok := sink.(*WebhookAlertSink); ok {
+			return fmt.Errorf("sink %q sends webhooks, which offline mode forbids", name)
+		}
+	}
+	for i, exporter := range config.Exporters {
+		switch exporter.(type) {
+		case *OTLPGRPCExporter, *PostHogExporter:
+			return fmt.Errorf("exporter %d (%T) sends over the network, which offline mode forbids", i, exporter)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package lipservice
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// OTLPGRPCConfig configures an OTLPGRPCExporter.
+type OTLPGRPCConfig struct {
+	// Endpoint is the collector's host:port, e.g. "otel-collector:4317"
+	Endpoint string
+
+	// ServiceName is the service.name resource attribute for records that
+	// don't set their own with Service
+	ServiceName string
+
+	// Insecure connects without TLS, e.g. to a collector sidecar
+	Insecure bool
+
+	// TLSConfig customizes TLS, e.g. for a private CA or client
+	// certificates (defaults to the system roots)
+	TLSConfig *tls.Config
+
+	// Headers are sent as gRPC metadata with every export, e.g. for
+	// collector authentication
+	Headers map[string]string
+
+	// Compression is "gzip" or "" for none (default: "")
+	Compression string
+
+	// Timeout bounds each export attempt (default: 10s)
+	Timeout time.Duration
+
+	// MaxRetries is how many times a batch is retried after a retryable
+	// failure (default: 3)
+	MaxRetries int
+
+	// InitialBackoff is the delay before the first retry, doubling for
+	// each one after (default: 1s)
+	InitialBackoff time.Duration
+}
+
+// OTLPGRPCExporter exports records as OTLP logs over gRPC to any
+// OpenTelemetry collector. Add it to Config.Exporters to route sampled
+// logs through a collector alongside PostHog.
+type OTLPGRPCExporter struct {
+	config OTLPGRPCConfig
+	conn   *grpc.ClientConn
+	client collectorlogs.LogsServiceClient
+}
+
+var _ Exporter = (*OTLPGRPCExporter)(nil)
+
+// NewOTLPGRPCExporter creates an exporter for config.Endpoint. The
+// connection is established lazily, so a collector that is down at
+// startup doesn't fail construction.
+func NewOTLPGRPCExporter(config OTLPGRPCConfig) (*OTLPGRPCExporter, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("OTLP gRPC endpoint is required")
+	}
+	if config.Compression != "" && config.Compression != gzip.Name {
+		return nil, fmt.Errorf("unsupported OTLP gRPC compression %q", config.Compression)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+	if config.InitialBackoff == 0 {
+		config.InitialBackoff = time.Second
+	}
+
+	creds := insecure.NewCredentials()
+	if !config.Insecure {
+		creds = credentials.NewTLS(config.TLSConfig)
+	}
+	conn, err := grpc.NewClient(config.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP collector client: %w", err)
+	}
+
+	return &OTLPGRPCExporter{
+		config: config,
+		conn:   conn,
+		client: collectorlogs.NewLogsServiceClient(conn),
+	}, nil
+}
+
+// Export sends records as one OTLP request, retrying retryable failures
+// with exponential backoff until retries run out or ctx is done.
+func (e *OTLPGRPCExporter) Export(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	request := e.request(records)
+
+	if len(e.config.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.config.Headers))
+	}
+	var opts []grpc.CallOption
+	if e.config.Compression != "" {
+		opts = append(opts, grpc.UseCompressor(e.config.Compression))
+	}
+
+	backoff := e.config.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := e.send(ctx, request, opts)
+		if err == nil || !retryableCode(status.Code(err)) || attempt == e.config.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one export attempt.
+func (e *OTLPGRPCExporter) send(ctx context.Context, request *collectorlogs.ExportLogsServiceRequest, opts []grpc.CallOption) error {
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	resp, err := e.client.Export(ctx, request, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to export to OTLP collector: %w", err)
+	}
+	if partial := resp.GetPartialSuccess(); partial.GetRejectedLogRecords() > 0 {
+		return fmt.Errorf("OTLP collector rejected %d records: %s", partial.GetRejectedLogRecords(), partial.GetErrorMessage())
+	}
+	return nil
+}
+
+// Shutdown closes the connection to the collector.
+func (e *OTLPGRPCExporter) Shutdown(ctx context.Context) error {
+	return e.conn.Close()
+}
+
+// request builds an OTLP request with one ResourceLogs per service.
+func (e *OTLPGRPCExporter) request(records []Record) *collectorlogs.ExportLogsServiceRequest {
+	observed := uint64(time.Now().UnixNano())
+	logRecords := make([]*logs.LogRecord, 0, len(records))
+	for _, record := range records {
+		timestamp := record.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+
+		logRecord := &logs.LogRecord{
+			TimeUnixNano:         uint64(timestamp.UnixNano()),
+			ObservedTimeUnixNano: observed,
+			SeverityText:         record.Severity,
+			SeverityNumber:       severityNumber(record.Severity),
+			Body:                 &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: record.Message}},
+			Attributes:           make([]*common.KeyValue, 0, len(record.Attributes)),
+		}
+		traceID, spanID, flags, correlated := spanFields(record.Attributes[TraceIDAttribute], record.Attributes[SpanIDAttribute], record.Attributes[TraceFlagsAttribute])
+		if correlated {
+			logRecord.TraceId, logRecord.SpanId, logRecord.Flags = traceID, spanID, flags
+		}
+		for key, value := range record.Attributes {
+			if correlated && isSpanAttribute(key) {
+				continue
+			}
+			logRecord.Attributes = append(logRecord.Attributes, &common.KeyValue{Key: key, Value: otlpValue(value)})
+		}
+		logRecords = append(logRecords, logRecord)
+	}
+
+	scope := &common.InstrumentationScope{Name: "lipservice-go", Version: Version}
+	request := &collectorlogs.ExportLogsServiceRequest{}
+	for _, group := range groupByService(logRecords, e.config.ServiceName) {
+		request.ResourceLogs = append(request.ResourceLogs, &logs.ResourceLogs{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{
+				stringKeyValue(ServiceAttribute, group.service),
+				stringKeyValue("service.version", Version),
+			}},
+			ScopeLogs: []*logs.ScopeLogs{{Scope: scope, LogRecords: group.records}},
+		})
+	}
+	return request
+}
+
+// otlpValue converts an attribute value to an OTLP value, keeping strings,
+// bools and numbers typed and formatting anything else.
+func otlpValue(value interface{}) *common.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(v)}}
+	case int32:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(v)}}
+	case int64:
+		return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: v}}
+	case float32:
+		return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: float64(v)}}
+	case float64:
+		return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: v}}
+	default:
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: fmt.Sprintf("%v", v)}}
+	}
+}
+
+// retryableCode reports whether an export failing with code may succeed
+// if retried, per the OTLP specification.
+func retryableCode(code codes.Code) bool {
+	switch code {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
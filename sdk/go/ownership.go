@@ -0,0 +1,153 @@
+package lipservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Attributes used for pattern ownership.
+const (
+	// OwnerAttribute is the team owning a record's pattern, added to kept
+	// records when a PatternOwner matches
+	OwnerAttribute = "owner"
+
+	// ModuleAttribute names the module a record comes from, e.g.
+	// "payments/refunds", for PatternOwner.Module to match
+	ModuleAttribute = "module"
+)
+
+// PatternOwner assigns the records matching a module or message prefix to
+// a team. When several owners match, a Module match beats a Prefix match
+// and the longest of either wins.
+type PatternOwner struct {
+	// Owner is the owning team, e.g. "team-payments"
+	Owner string `json:"owner"`
+
+	// Module matches records whose ModuleAttribute starts with it
+	Module string `json:"module,omitempty"`
+
+	// Prefix matches records whose normalized message (the template their
+	// signature is computed from) starts with it, e.g. "payment failed".
+	// It is normalized the same way, so it may be written as logged.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// patternOwners maps records to their owning teams. Owners from the
+// backend policy replace the configured ones while it is in force.
+type patternOwners struct {
+	configured []PatternOwner
+	diag       *diagnostics
+
+	mu     sync.RWMutex
+	owners []PatternOwner
+}
+
+// newPatternOwners returns the owners from Config.PatternOwners and
+// Config.PatternOwnersFile, or nil when neither is set.
+func newPatternOwners(config Config, diag *diagnostics) (*patternOwners, error) {
+	owners := append([]PatternOwner(nil), config.PatternOwners...)
+	if config.PatternOwnersFile != "" {
+		data, err := os.ReadFile(config.PatternOwnersFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pattern owners: %w", err)
+		}
+		var loaded []PatternOwner
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			return nil, fmt.Errorf("failed to decode pattern owners: %w", err)
+		}
+		owners = append(owners, loaded...)
+	}
+	if len(owners) == 0 {
+		return nil, nil
+	}
+
+	owners, err := normalizeOwners(owners)
+	if err != nil {
+		return nil, err
+	}
+	return &patternOwners{configured: owners, owners: owners, diag: diag}, nil
+}
+
+// normalizeOwners checks owners and normalizes their prefixes.
+func normalizeOwners(owners []PatternOwner) ([]PatternOwner, error) {
+	normalized := make([]PatternOwner, 0, len(owners))
+	for _, owner := range owners {
+		if owner.Owner == "" {
+			return nil, fmt.Errorf("pattern owner for module %q prefix %q has no owner", owner.Module, owner.Prefix)
+		}
+		if owner.Module == "" && owner.Prefix == "" {
+			return nil, fmt.Errorf("pattern owner %q needs a module or prefix", owner.Owner)
+		}
+		if owner.Prefix != "" {
+			owner.Prefix = normalizeMessage(owner.Prefix)
+		}
+		normalized = append(normalized, owner)
+	}
+	return normalized, nil
+}
+
+// setPolicy applies a policy's owners, or restores the configured owners
+// if it has none.
+func (p *patternOwners) setPolicy(owners []PatternOwner) {
+	if p == nil {
+		return
+	}
+
+	if len(owners) == 0 {
+		owners = p.configured
+	} else {
+		var err error
+		if owners, err = normalizeOwners(owners); err != nil {
+			p.diag.log(slog.Default(), "WARN", "Ignoring invalid policy pattern owners", "error", err)
+			owners = p.configured
+		}
+	}
+
+	p.mu.Lock()
+	p.owners = owners
+	p.mu.Unlock()
+}
+
+// owner returns the team owning a record, or "" if no owner matches. A
+// record that already carries OwnerAttribute keeps it.
+func (p *patternOwners) owner(msg string, args, bound []interface{}) string {
+	if p == nil {
+		return ""
+	}
+	if owner, ok := lookupAttribute(OwnerAttribute, args, bound); ok {
+		return fmt.Sprintf("%v", owner)
+	}
+
+	p.mu.RLock()
+	owners := p.owners
+	p.mu.RUnlock()
+
+	module, _ := lookupAttribute(ModuleAttribute, args, bound)
+	moduleName, _ := module.(string)
+
+	var template string
+	best, bestModule, bestLen := "", false, -1
+	for _, owner := range owners {
+		if owner.Module != "" {
+			if strings.HasPrefix(moduleName, owner.Module) && (!bestModule || len(owner.Module) > bestLen) {
+				best, bestModule, bestLen = owner.Owner, true, len(owner.Module)
+			}
+			continue
+		}
+		if bestModule {
+			continue
+		}
+		// The message is only normalized once a prefix rule needs it
+		if template == "" {
+			template = normalizeMessage(msg)
+		}
+		if strings.HasPrefix(template, owner.Prefix) && len(owner.Prefix) > bestLen {
+			best, bestLen = owner.Owner, len(owner.Prefix)
+		}
+	}
+	return best
+}
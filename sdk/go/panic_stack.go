@@ -0,0 +1,138 @@
+package lipservice
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Attributes added to records carrying a Go panic stack. The exception.*
+// and code.* keys follow the OpenTelemetry semantic conventions.
+const (
+	ExceptionTypeAttribute    = "exception.type"
+	ExceptionMessageAttribute = "exception.message"
+	CodeFunctionAttribute     = "code.function"
+	CodeFilepathAttribute     = "code.filepath"
+	CodeLinenoAttribute       = "code.lineno"
+	StackFramesAttribute      = "lipservice.stack_frames"
+	FingerprintAttribute      = "lipservice.fingerprint"
+)
+
+// fingerprintFrames is how many of the top application frames identify a
+// panic.
+const fingerprintFrames = 3
+
+// StackFrame is one frame of a parsed Go stack trace.
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// panicStack is a Go panic parsed from a message.
+type panicStack struct {
+	value  string
+	frames []StackFrame
+}
+
+// parsePanic parses the panic value and the panicking goroutine's frames
+// from a message containing a Go panic stack.
+func parsePanic(message string) (*panicStack, bool) {
+	start := strings.Index(message, "panic: ")
+	if start < 0 || !strings.Contains(message[start:], "\ngoroutine ") {
+		return nil, false
+	}
+
+	lines := strings.Split(message[start:], "\n")
+	stack := &panicStack{value: strings.TrimPrefix(lines[0], "panic: ")}
+
+	i := 1
+	for i < len(lines) && !strings.HasPrefix(lines[i], "goroutine ") {
+		i++
+	}
+
+	// Frames come in pairs: the function, then its tab-indented location
+	for i++; i+1 < len(lines); i += 2 {
+		function, location := lines[i], lines[i+1]
+		if function == "" || !strings.HasPrefix(location, "\t") {
+			break
+		}
+		stack.frames = append(stack.frames, parseFrame(function, location))
+	}
+
+	if len(stack.frames) == 0 {
+		return nil, false
+	}
+	return stack, true
+}
+
+// parseFrame parses a frame's function line and location line.
+func parseFrame(function, location string) StackFrame {
+	var frame StackFrame
+
+	// "created by main.main in goroutine 1" names the spawning function
+	function = strings.TrimPrefix(function, "created by ")
+	if i := strings.Index(function, " in goroutine "); i >= 0 {
+		function = function[:i]
+	}
+	if i := strings.LastIndex(function, "("); i > 0 {
+		function = function[:i]
+	}
+	frame.Function = function
+
+	location = strings.TrimPrefix(location, "\t")
+	if i := strings.Index(location, " +0x"); i >= 0 {
+		location = location[:i]
+	}
+	if i := strings.LastIndex(location, ":"); i >= 0 {
+		frame.File = location[:i]
+		frame.Line, _ = strconv.Atoi(location[i+1:])
+	} else {
+		frame.File = location
+	}
+
+	return frame
+}
+
+// appFrames returns the frames below the runtime's own panic machinery.
+func (p *panicStack) appFrames() []StackFrame {
+	for i, frame := range p.frames {
+		if !strings.HasPrefix(frame.Function, "runtime.") && frame.Function != "panic" {
+			return p.frames[i:]
+		}
+	}
+	return p.frames
+}
+
+// fingerprint identifies the panic by its top application frames, so the
+// same crash groups together whatever its panic value or line numbers.
+// A nil hasher uses FNVHasher.
+func (p *panicStack) fingerprint(hasher SignatureHasher) string {
+	frames := p.appFrames()
+	if len(frames) > fingerprintFrames {
+		frames = frames[:fingerprintFrames]
+	}
+
+	functions := make([]string, len(frames))
+	for i, frame := range frames {
+		functions[i] = frame.Function
+	}
+	return hashSignature(hasher, "panic\n"+strings.Join(functions, "\n"))
+}
+
+// attributes returns the structured form of the panic for export, its
+// fingerprint hashed with hasher.
+func (p *panicStack) attributes(hasher SignatureHasher) map[string]interface{} {
+	top := p.appFrames()[0]
+	frames, _ := json.Marshal(p.frames)
+
+	return map[string]interface{}{
+		ExceptionTypeAttribute:    "panic",
+		ExceptionMessageAttribute: p.value,
+		CodeFunctionAttribute:     top.Function,
+		CodeFilepathAttribute:     top.File,
+		CodeLinenoAttribute:       top.Line,
+		StackFramesAttribute:      string(frames),
+		FingerprintAttribute:      p.fingerprint(hasher),
+	}
+}
@@ -0,0 +1,32 @@
+// Package lipserviceparquet writes LipService sampling reports as Parquet
+// files. It is a separate module so the Parquet dependency isn't pulled
+// into every application using the SDK. Importing it registers the
+// lipservice.ReportFormatParquet format for ExportPatternReport.
+package lipserviceparquet
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+	lipservice "github.com/srex-dev/lipservice-go"
+)
+
+func init() {
+	lipservice.RegisterReportFormat(lipservice.ReportFormatParquet, WriteReport)
+}
+
+// WriteReport writes a sampling report's per-pattern rows as a Parquet
+// file.
+func WriteReport(w io.Writer, report lipservice.SamplingReport) error {
+	writer := parquet.NewGenericWriter[lipservice.PatternReport](w)
+
+	if _, err := writer.Write(report.Patterns); err != nil {
+		return fmt.Errorf("failed to write Parquet rows: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close Parquet writer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package lipservice
+
+import "time"
+
+// Pattern stats keep an hour of per-minute counts in a ring. A bucket is
+// cleared when the ring comes back around to it, so old counts age out
+// without any global reset.
+const (
+	// patternBucketCount is the number of per-minute buckets kept
+	patternBucketCount = 60
+
+	// patternRateWindow is the window PatternReport.PerMinute averages over
+	patternRateWindow = 5 * time.Minute
+)
+
+// minuteBuckets counts occurrences per wall-clock minute over the last
+// patternBucketCount minutes.
+type minuteBuckets struct {
+	counts  [patternBucketCount]int
+	minutes [patternBucketCount]int64 // unix minute each bucket counts
+}
+
+// add counts one occurrence at now.
+func (b *minuteBuckets) add(now time.Time) {
+	minute := now.Unix() / 60
+	i := minute % patternBucketCount
+	if b.minutes[i] != minute {
+		b.minutes[i] = minute
+		b.counts[i] = 0
+	}
+	b.counts[i]++
+}
+
+// count returns the occurrences in the minute containing now and the
+// minutes before it, back to window (capped at patternBucketCount minutes).
+func (b *minuteBuckets) count(now time.Time, window time.Duration) int {
+	minutes := int64(window / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	if minutes > patternBucketCount {
+		minutes = patternBucketCount
+	}
+
+	current := now.Unix() / 60
+	total := 0
+	for minute := current - minutes + 1; minute <= current; minute++ {
+		i := minute % patternBucketCount
+		if b.minutes[i] == minute {
+			total += b.counts[i]
+		}
+	}
+	return total
+}
+
+// perMinute returns the average occurrences per minute over window.
+func (b *minuteBuckets) perMinute(now time.Time, window time.Duration) float64 {
+	minutes := window / time.Minute
+	if minutes < 1 {
+		minutes = 1
+	}
+	if minutes > patternBucketCount {
+		minutes = patternBucketCount
+	}
+	return float64(b.count(now, minutes*time.Minute)) / float64(minutes)
+}
+
+// observe counts one occurrence of the pattern at now.
+func (p *PatternStats) observe(now time.Time) {
+	p.Count++
+	p.LastSeen = now
+	p.buckets.add(now)
+}
+
+// spiking reports whether the current minute's count is well above the
+// pattern's average over the rest of the hour, using the same thresholds
+// as PatternSpike events.
+func (p *PatternStats) spiking(now time.Time) bool {
+	current := p.buckets.count(now, time.Minute)
+	if current < patternSpikeMinCount {
+		return false
+	}
+	previous := p.buckets.count(now, patternBucketCount*time.Minute) - current
+	baseline := float64(previous) / (patternBucketCount - 1)
+	return float64(current) >= patternSpikeFactor*baseline
+}
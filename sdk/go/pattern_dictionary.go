@@ -0,0 +1,164 @@
+package lipservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// PatternDictionary is a portable snapshot of learned log patterns, used to
+// seed another instance or environment with their sampling rates.
+type PatternDictionary struct {
+	ExportedAt  time.Time      `json:"exported_at"`
+	ServiceName string         `json:"service_name"`
+	Patterns    []PatternEntry `json:"patterns"`
+}
+
+// PatternEntry is one learned pattern in a PatternDictionary.
+type PatternEntry struct {
+	Signature string `json:"signature"`
+
+	// Template is the normalized message the signature is computed from,
+	// such as "user n logged in from ip" (empty for panic fingerprints)
+	Template string `json:"template,omitempty"`
+
+	// Example is a redacted message with this signature
+	Example string `json:"example,omitempty"`
+
+	SamplingRate float64 `json:"sampling_rate"`
+	Count        int     `json:"count"`
+}
+
+// ExportPatternDictionary writes the learned pattern dictionary to path as
+// JSON. The file is replaced atomically.
+func (ls *LipService) ExportPatternDictionary(path string) error {
+	dictionary := ls.sampler.patternDictionary()
+	dictionary.ServiceName = ls.config.ServiceName
+
+	data, err := json.MarshalIndent(dictionary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pattern dictionary: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".lipservice-patterns-*")
+	if err != nil {
+		return fmt.Errorf("failed to create pattern dictionary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write pattern dictionary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pattern dictionary: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace pattern dictionary: %w", err)
+	}
+	return nil
+}
+
+// ImportPatternDictionary seeds the sampler with patterns from a file
+// written by ExportPatternDictionary, returning how many were added.
+// Patterns this instance already knows keep their own rates.
+func (ls *LipService) ImportPatternDictionary(path string) (int, error) {
+	return ls.sampler.importPatternFile(path)
+}
+
+// patternDictionary copies the pattern stats into dictionary entries, most
+// frequent first.
+func (s *AdaptiveSampler) patternDictionary() PatternDictionary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]PatternEntry, 0, len(s.patternStats))
+	for signature, stats := range s.patternStats {
+		entries = append(entries, PatternEntry{
+			Signature:    signature,
+			Template:     stats.Template,
+			Example:      stats.Example,
+			SamplingRate: stats.SamplingRate,
+			Count:        stats.Count,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Signature < entries[j].Signature
+	})
+
+	return PatternDictionary{ExportedAt: time.Now().UTC(), Patterns: entries}
+}
+
+// describe records message as the pattern's example and its normalized
+// form as the template. Callers must hold the sampler's lock.
+func (p *PatternStats) describe(message string) {
+	p.Example = message
+	if _, ok := parsePanic(message); !ok {
+		p.Template = normalizeMessage(message)
+	}
+}
+
+// importPatternFile reads a pattern dictionary file and imports it.
+func (s *AdaptiveSampler) importPatternFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read pattern dictionary: %w", err)
+	}
+
+	var dictionary PatternDictionary
+	if err := json.Unmarshal(data, &dictionary); err != nil {
+		return 0, fmt.Errorf("failed to decode pattern dictionary: %w", err)
+	}
+
+	return s.importPatterns(dictionary), nil
+}
+
+// importPatterns adds the dictionary's patterns that aren't already known.
+// Signatures are recomputed from templates, so a dictionary stays valid
+// across builds that hash signatures differently. Counts start from zero,
+// since they describe the exporting instance's traffic.
+func (s *AdaptiveSampler) importPatterns(dictionary PatternDictionary) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := 0
+	for _, entry := range dictionary.Patterns {
+		signature := entry.Signature
+		if entry.Template != "" {
+			signature = s.templateSignature(entry.Template)
+		}
+		if signature == "" || entry.SamplingRate < 0 || entry.SamplingRate > 1 {
+			continue
+		}
+		if _, ok := s.patternStats[signature]; ok {
+			continue
+		}
+
+		s.addPattern(signature, &PatternStats{
+			Signature:    signature,
+			SamplingRate: entry.SamplingRate,
+			Template:     entry.Template,
+			Example:      entry.Example,
+		}, time.Now())
+		added++
+	}
+
+	return added
+}
+
+// templateSignature computes the signature of a normalized message, as
+// signature does for the messages it matches. Callers must hold s.mu.
+func (s *AdaptiveSampler) templateSignature(template string) string {
+	if s.grouper != nil {
+		return s.grouper.signature(template)
+	}
+	return s.signer.hash(template)
+}
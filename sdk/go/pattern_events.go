@@ -0,0 +1,255 @@
+package lipservice
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PatternEventType is the kind of change in a pattern's lifecycle.
+type PatternEventType string
+
+// Pattern lifecycle events.
+const (
+	// PatternFirstSeen is emitted the first time a signature is seen
+	PatternFirstSeen PatternEventType = "first_seen"
+
+	// PatternDormant is emitted when a signature hasn't been seen for
+	// patternDormantAfter
+	PatternDormant PatternEventType = "dormant"
+
+	// PatternSpike is emitted when a signature's per-minute count jumps
+	// well above its usual rate
+	PatternSpike PatternEventType = "spike"
+
+	// PatternEvicted is emitted when a signature stops being tracked,
+	// either after a long dormancy or to make room for new ones
+	PatternEvicted PatternEventType = "evicted"
+)
+
+// Pattern lifecycle thresholds and timing.
+const (
+	// patternEventInterval is how often per-minute counts are rolled up
+	patternEventInterval = time.Minute
+
+	// patternDormantAfter is how long a signature must be silent to be
+	// reported dormant
+	patternDormantAfter = 30 * time.Minute
+
+	// patternEvictAfter is how long a signature may be silent before it
+	// stops being tracked
+	patternEvictAfter = 24 * time.Hour
+
+	// patternMaxTracked caps the number of tracked signatures
+	patternMaxTracked = 10000
+
+	// patternSpikeFactor is how many times its baseline a minute's count
+	// must reach to be a spike
+	patternSpikeFactor = 5.0
+
+	// patternSpikeMinCount is the fewest records in a minute for a spike
+	patternSpikeMinCount = 50
+
+	// patternBaselineAlpha weights each minute in the baseline EWMA
+	patternBaselineAlpha = 0.3
+)
+
+// PatternEvent describes a change in a log pattern's lifecycle.
+type PatternEvent struct {
+	Type      PatternEventType
+	Signature string
+
+	// Message is an example message with this signature
+	Message string
+
+	Time time.Time
+
+	// Count is the number of records in the last minute, and Baseline the
+	// usual per-minute count (set for spikes)
+	Count    int
+	Baseline float64
+}
+
+// patternActivity tracks one signature's recent activity.
+type patternActivity struct {
+	message  string
+	lastSeen time.Time
+	count    int
+	baseline float64
+	measured bool
+	dormant  bool
+}
+
+// patternEvents tracks pattern lifecycles and delivers events to
+// subscribers. Nothing is tracked until someone subscribes.
+type patternEvents struct {
+	subscribed atomic.Bool
+
+	mu          sync.Mutex
+	patterns    map[string]*patternActivity
+	subscribers map[int]chan<- PatternEvent
+	nextID      int
+	lastRoll    time.Time
+}
+
+func newPatternEvents() *patternEvents {
+	return &patternEvents{
+		patterns:    make(map[string]*patternActivity),
+		subscribers: make(map[int]chan<- PatternEvent),
+	}
+}
+
+// active reports whether anyone is subscribed.
+func (p *patternEvents) active() bool {
+	return p.subscribed.Load()
+}
+
+// subscribe registers ch and returns a function that unregisters it.
+func (p *patternEvents) subscribe(ch chan<- PatternEvent) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := p.nextID
+	p.nextID++
+	p.subscribers[id] = ch
+	p.subscribed.Store(true)
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		delete(p.subscribers, id)
+		p.subscribed.Store(len(p.subscribers) > 0)
+	}
+}
+
+// observe records one occurrence of a signature.
+func (p *patternEvents) observe(signature, message string, now time.Time) {
+	if !p.active() {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	activity, ok := p.patterns[signature]
+	if !ok {
+		if len(p.patterns) >= patternMaxTracked {
+			p.evictOldest(now)
+		}
+		activity = &patternActivity{message: message}
+		p.patterns[signature] = activity
+		p.emit(PatternEvent{Type: PatternFirstSeen, Signature: signature, Message: message, Time: now})
+	}
+
+	activity.lastSeen = now
+	activity.count++
+	activity.dormant = false
+}
+
+// roll closes the current minute for every pattern, emitting spikes,
+// dormancy and evictions.
+func (p *patternEvents) roll(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastRoll = now
+
+	for signature, activity := range p.patterns {
+		silent := now.Sub(activity.lastSeen)
+		switch {
+		case silent >= patternEvictAfter:
+			delete(p.patterns, signature)
+			p.emit(PatternEvent{Type: PatternEvicted, Signature: signature, Message: activity.message, Time: now})
+			continue
+		case silent >= patternDormantAfter && !activity.dormant:
+			activity.dormant = true
+			p.emit(PatternEvent{Type: PatternDormant, Signature: signature, Message: activity.message, Time: now})
+		}
+
+		count := float64(activity.count)
+		if activity.measured && activity.count >= patternSpikeMinCount && count > patternSpikeFactor*activity.baseline {
+			p.emit(PatternEvent{
+				Type:      PatternSpike,
+				Signature: signature,
+				Message:   activity.message,
+				Time:      now,
+				Count:     activity.count,
+				Baseline:  activity.baseline,
+			})
+		}
+
+		if activity.measured {
+			activity.baseline += patternBaselineAlpha * (count - activity.baseline)
+		} else {
+			activity.baseline = count
+			activity.measured = true
+		}
+		activity.count = 0
+	}
+}
+
+// due reports whether a roll-up has fallen due, for serverless refreshes.
+func (p *patternEvents) due(now time.Time) bool {
+	if !p.active() {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return now.Sub(p.lastRoll) >= patternEventInterval
+}
+
+// evictOldest drops the least recently seen signature. Callers must hold
+// p.mu.
+func (p *patternEvents) evictOldest(now time.Time) {
+	var oldest string
+	var oldestSeen time.Time
+	for signature, activity := range p.patterns {
+		if oldest == "" || activity.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = signature, activity.lastSeen
+		}
+	}
+
+	message := p.patterns[oldest].message
+	delete(p.patterns, oldest)
+	p.emit(PatternEvent{Type: PatternEvicted, Signature: oldest, Message: message, Time: now})
+}
+
+// emit delivers an event to every subscriber without blocking; events
+// for a subscriber whose channel is full are dropped. Callers must hold
+// p.mu.
+func (p *patternEvents) emit(event PatternEvent) {
+	for _, ch := range p.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// patternEventLoop rolls up pattern activity every minute.
+func (s *AdaptiveSampler) patternEventLoop(ctx context.Context) {
+	ticker := time.NewTicker(patternEventInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if s.events.active() {
+				s.events.roll(now)
+			}
+		}
+	}
+}
+
+// Subscribe delivers pattern lifecycle events (first seen, dormant, spike,
+// evicted) to ch until the returned function is called. Sends never block
+// logging: events are dropped while ch is full, so give it a buffer.
+func (ls *LipService) Subscribe(ch chan<- PatternEvent) (unsubscribe func()) {
+	return ls.sampler.events.subscribe(ch)
+}
@@ -0,0 +1,172 @@
+package lipservice
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Pattern stats limits.
+const (
+	// defaultMaxPatterns is the default cap on tracked pattern stats
+	defaultMaxPatterns = 10000
+
+	// defaultPatternTTL is how long a pattern may go unseen before its
+	// stats are dropped, by default
+	defaultPatternTTL = 24 * time.Hour
+
+	// patternSweepInterval is how often expired pattern stats are swept
+	patternSweepInterval = 5 * time.Minute
+)
+
+// maxPatterns returns the cap on pattern stats, or 0 for no cap.
+func (s *AdaptiveSampler) maxPatterns() int {
+	switch {
+	case s.config.MaxPatterns < 0:
+		return 0
+	case s.config.MaxPatterns == 0:
+		return defaultMaxPatterns
+	default:
+		return s.config.MaxPatterns
+	}
+}
+
+// patternTTL returns how long pattern stats outlive their last record, or
+// 0 if they never expire.
+func (s *AdaptiveSampler) patternTTL() time.Duration {
+	switch {
+	case s.config.PatternTTL < 0:
+		return 0
+	case s.config.PatternTTL == 0:
+		return defaultPatternTTL
+	default:
+		return s.config.PatternTTL
+	}
+}
+
+// addPattern tracks stats for a new signature, evicting the least recently
+// seen patterns to stay within MaxPatterns. Stats that were never seen
+// count as seen now, so they get a full TTL. Callers must hold s.mu.
+func (s *AdaptiveSampler) addPattern(signature string, stats *PatternStats, now time.Time) {
+	if stats.LastSeen.IsZero() {
+		stats.LastSeen = now
+	}
+	if _, ok := s.patternStats[signature]; !ok {
+		s.trimPatterns(1)
+	}
+	s.patternStats[signature] = stats
+	s.touchPattern(signature, stats)
+}
+
+// touchPattern marks a pattern as the most recently seen. Callers must hold
+// s.mu.
+func (s *AdaptiveSampler) touchPattern(signature string, stats *PatternStats) {
+	if stats.element == nil {
+		stats.element = s.patternOrder.PushFront(signature)
+		return
+	}
+	s.patternOrder.MoveToFront(stats.element)
+}
+
+// indexPatterns rebuilds the recency order from the patterns' LastSeen
+// times, after their stats were replaced wholesale. Callers must hold s.mu.
+func (s *AdaptiveSampler) indexPatterns() {
+	signatures := make([]string, 0, len(s.patternStats))
+	for signature := range s.patternStats {
+		signatures = append(signatures, signature)
+	}
+	sort.Slice(signatures, func(i, j int) bool {
+		return s.patternStats[signatures[i]].LastSeen.Before(s.patternStats[signatures[j]].LastSeen)
+	})
+
+	s.patternOrder.Init()
+	for _, signature := range signatures {
+		stats := s.patternStats[signature]
+		stats.element = nil
+		s.touchPattern(signature, stats)
+	}
+}
+
+// policyPattern reports whether the policy in force sets the pattern's
+// rate. Such patterns are never evicted: the backend answers an unchanged
+// policy with a 304, so an evicted rate would not come back. Callers must
+// hold s.mu.
+func (s *AdaptiveSampler) policyPattern(signature string) bool {
+	if s.policy == nil {
+		return false
+	}
+	_, ok := s.policy.PatternRates[signature]
+	return ok
+}
+
+// dropPattern forgets a pattern's stats. Callers must hold s.mu.
+func (s *AdaptiveSampler) dropPattern(signature string) {
+	if stats, ok := s.patternStats[signature]; ok && stats.element != nil {
+		s.patternOrder.Remove(stats.element)
+	}
+	delete(s.patternStats, signature)
+	s.patternEvictions.Add(1)
+}
+
+// trimPatterns evicts the least recently seen patterns until room more
+// patterns fit within MaxPatterns, sparing the policy's own. Callers must
+// hold s.mu.
+func (s *AdaptiveSampler) trimPatterns(room int) {
+	limit := s.maxPatterns()
+	if limit == 0 {
+		return
+	}
+	for element := s.patternOrder.Back(); element != nil && len(s.patternStats)+room > limit; {
+		previous := element.Prev()
+		signature := element.Value.(string)
+		if !s.policyPattern(signature) {
+			s.dropPattern(signature)
+		}
+		element = previous
+	}
+}
+
+// sweepPatterns drops pattern stats unseen for longer than PatternTTL,
+// other than the policy's own, returning how many were dropped.
+func (s *AdaptiveSampler) sweepPatterns(now time.Time) int {
+	ttl := s.patternTTL()
+	if ttl == 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	swept := 0
+	for signature, stats := range s.patternStats {
+		if now.Sub(stats.LastSeen) > ttl && !s.policyPattern(signature) {
+			s.dropPattern(signature)
+			swept++
+		}
+	}
+	s.lastPatternSweep = now
+	return swept
+}
+
+// patternSweepLoop sweeps expired pattern stats periodically.
+func (s *AdaptiveSampler) patternSweepLoop(ctx context.Context) {
+	ticker := time.NewTicker(patternSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.sweepPatterns(now)
+		}
+	}
+}
+
+// patternCounts returns the number of tracked patterns and how many have
+// been evicted by MaxPatterns or PatternTTL.
+func (s *AdaptiveSampler) patternCounts() (int, int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.patternStats), s.patternEvictions.Load()
+}
@@ -0,0 +1,250 @@
+package lipservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Pattern report limits. Tallies for signatures beyond maxPatternTallies
+// are dropped until the next successful report, and a failed report is
+// retried patternReportAttempts times, doubling the delay from
+// patternReportBackoff.
+const (
+	maxPatternTallies     = 10000
+	patternReportAttempts = 3
+	patternReportBackoff  = 500 * time.Millisecond
+)
+
+// errPatternReportRejected marks a report the backend refused for good,
+// such as a bad request or API key, which resending can't fix.
+var errPatternReportRejected = errors.New("pattern report rejected")
+
+// patternTally counts a pattern's records since the last successful
+// report.
+type patternTally struct {
+	count      int
+	sampled    int
+	severities map[string]int
+	firstSeen  time.Time
+	lastSeen   time.Time
+	example    string
+}
+
+// PatternReportEntry is one pattern in a report to the backend.
+type PatternReportEntry struct {
+	Signature            string         `json:"signature"`
+	MessageSample        string         `json:"message_sample,omitempty"`
+	Count                int            `json:"count"`
+	SampledCount         int            `json:"sampled_count"`
+	SeverityDistribution map[string]int `json:"severity_distribution"`
+	FirstSeen            float64        `json:"first_seen"`
+	LastSeen             float64        `json:"last_seen"`
+	SamplingRate         float64        `json:"sampling_rate,omitempty"`
+	Spiking              bool           `json:"spiking,omitempty"`
+}
+
+// PatternStatsReport is the payload POSTed to /api/v1/patterns/stats.
+// Times are Unix seconds, as the backend expects.
+type PatternStatsReport struct {
+	ServiceName    string               `json:"service_name"`
+	TeamID         int                  `json:"team_id"`
+	Timestamp      float64              `json:"timestamp"`
+	Patterns       []PatternReportEntry `json:"patterns"`
+	TotalLogs      int                  `json:"total_logs"`
+	UniquePatterns int                  `json:"unique_patterns"`
+
+	// SignatureHash names the hash signatures were computed with, e.g.
+	// "fnv1a64" or "md5"
+	SignatureHash string `json:"signature_hash"`
+}
+
+// reportsPatterns reports whether the sampler sends pattern reports to the
+// backend.
+func reportsPatterns(config Config) bool {
+	return config.LipServiceURL != "" && !config.Offline
+}
+
+// tally counts a record toward its pattern's next report. Callers must
+// hold s.mu.
+func (s *AdaptiveSampler) tally(signature, message, severity string, kept bool, now time.Time) {
+	if s.tallies == nil || signature == "" {
+		return
+	}
+
+	t, ok := s.tallies[signature]
+	if !ok {
+		if len(s.tallies) >= maxPatternTallies {
+			return
+		}
+		t = &patternTally{severities: make(map[string]int), firstSeen: now, example: message}
+		s.tallies[signature] = t
+	}
+	t.count++
+	if kept {
+		t.sampled++
+	}
+	t.severities[severity]++
+	t.lastSeen = now
+}
+
+// dominantSeverity returns the severity the pattern was logged at most.
+func (t *patternTally) dominantSeverity() string {
+	dominant, most := "", 0
+	for severity, n := range t.severities {
+		if n > most || (n == most && severity < dominant) {
+			dominant, most = severity, n
+		}
+	}
+	return dominant
+}
+
+// reportPatterns sends the tallies gathered since the last report to the
+// backend, bounded by ctx. They are reset once the backend accepts them,
+// merged back for the next report if it fails to, and dropped if it
+// rejects them.
+func (s *AdaptiveSampler) reportPatterns(ctx context.Context) {
+	now := time.Now()
+
+	s.mu.Lock()
+	s.lastPatternReport = now
+	tallies := s.tallies
+	if len(tallies) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.tallies = make(map[string]*patternTally, len(tallies))
+	report := s.patternReport(tallies, now)
+	s.mu.Unlock()
+
+	if err := s.submitPatterns(ctx, report); err != nil {
+		if errors.Is(err, errPatternReportRejected) {
+			s.diag.log(slog.Default(), "ERROR", "Pattern report rejected, dropping it", "patterns_dropped", len(tallies), "error", err)
+			return
+		}
+		s.mu.Lock()
+		s.mergeTallies(tallies)
+		s.mu.Unlock()
+		if ctx.Err() == nil {
+			s.diag.log(slog.Default(), "WARN", "Pattern report failed", "patterns_kept", len(tallies), "error", err)
+		}
+	}
+}
+
+// patternReport builds a report from tallies, adding each pattern's
+// current rate and spike state. Callers must hold s.mu.
+func (s *AdaptiveSampler) patternReport(tallies map[string]*patternTally, now time.Time) PatternStatsReport {
+	// The backend keys teams by number; anything else is left for it to
+	// resolve from the API key
+	teamID, _ := strconv.Atoi(s.config.PostHogTeamID)
+
+	report := PatternStatsReport{
+		ServiceName:    s.config.ServiceName,
+		TeamID:         teamID,
+		Timestamp:      float64(now.UnixNano()) / 1e9,
+		Patterns:       make([]PatternReportEntry, 0, len(tallies)),
+		UniquePatterns: len(tallies),
+		SignatureHash:  hasherName(s.signer.config.Hasher),
+	}
+	for signature, t := range tallies {
+		entry := PatternReportEntry{
+			Signature:            signature,
+			MessageSample:        t.example,
+			Count:                t.count,
+			SampledCount:         t.sampled,
+			SeverityDistribution: t.severities,
+			FirstSeen:            float64(t.firstSeen.UnixNano()) / 1e9,
+			LastSeen:             float64(t.lastSeen.UnixNano()) / 1e9,
+		}
+		if stats, ok := s.patternStats[signature]; ok {
+			entry.SamplingRate = stats.SamplingRate
+			entry.Spiking = stats.spiking(now)
+		}
+		report.Patterns = append(report.Patterns, entry)
+		report.TotalLogs += t.count
+	}
+
+	sort.Slice(report.Patterns, func(i, j int) bool {
+		return report.Patterns[i].Count > report.Patterns[j].Count
+	})
+	return report
+}
+
+// mergeTallies folds tallies from a failed report back into the current
+// ones. Callers must hold s.mu.
+func (s *AdaptiveSampler) mergeTallies(tallies map[string]*patternTally) {
+	for signature, old := range tallies {
+		t, ok := s.tallies[signature]
+		if !ok {
+			if len(s.tallies) < maxPatternTallies {
+				s.tallies[signature] = old
+			}
+			continue
+		}
+		t.count += old.count
+		t.sampled += old.sampled
+		for severity, n := range old.severities {
+			t.severities[severity] += n
+		}
+		t.firstSeen = old.firstSeen
+		t.example = old.example
+	}
+}
+
+// submitPatterns POSTs a report, retrying with exponential backoff unless
+// the backend rejected it. It gives up rather than wait for a retry that
+// ctx's deadline wouldn't leave time for.
+func (s *AdaptiveSampler) submitPatterns(ctx context.Context, report PatternStatsReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode pattern report: %w", err)
+	}
+
+	delay := patternReportBackoff
+	for attempt := 1; ; attempt++ {
+		err = s.postPatterns(ctx, body)
+		if err == nil || errors.Is(err, errPatternReportRejected) || attempt == patternReportAttempts {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// postPatterns makes one attempt to deliver an encoded report. Network
+// errors, 429 and 5xx responses are worth retrying; other client errors
+// are rejections.
+func (s *AdaptiveSampler) postPatterns(ctx context.Context, body []byte) error {
+	req, err := s.newBackendRequest("POST", "/api/v1/patterns/stats", body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to send pattern report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("LipService returned status %d", resp.StatusCode)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("%w: LipService returned status %d", errPatternReportRejected, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package lipservice
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// SamplingReasonPin is the sampling reason for records of a pinned pattern.
+const SamplingReasonPin = "pin"
+
+// PatternPin is a temporary rate forced on one pattern from code.
+type PatternPin struct {
+	Signature string    `json:"signature"`
+	Rate      float64   `json:"rate"`
+	Expires   time.Time `json:"expires"`
+}
+
+// PinPatternRate forces the pattern with this signature to be sampled at
+// rate until ttl elapses, overriding learned, policy and adjusted rates.
+// Pinning again replaces the previous pin. Incident mode and budgets still
+// apply.
+func (s *AdaptiveSampler) PinPatternRate(signature string, rate float64, ttl time.Duration) error {
+	if signature == "" {
+		return fmt.Errorf("pattern signature is required")
+	}
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("pinned rate %v is outside [0, 1]", rate)
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("pin TTL must be positive, got %v", ttl)
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prunePins(now)
+	if s.pins == nil {
+		s.pins = make(map[string]PatternPin)
+	}
+	s.pins[signature] = PatternPin{Signature: signature, Rate: rate, Expires: now.Add(ttl)}
+	return nil
+}
+
+// UnpinPattern removes a pattern's pin, if any, before it expires.
+func (s *AdaptiveSampler) UnpinPattern(signature string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pins, signature)
+}
+
+// PinnedPatterns returns the pins in force, soonest to expire first.
+func (s *AdaptiveSampler) PinnedPatterns() []PatternPin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prunePins(time.Now())
+	pins := make([]PatternPin, 0, len(s.pins))
+	for _, pin := range s.pins {
+		pins = append(pins, pin)
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].Expires.Before(pins[j].Expires) })
+	return pins
+}
+
+// pinnedRate returns the rate pinned for signature, if a pin is in force.
+// Callers must hold s.mu.
+func (s *AdaptiveSampler) pinnedRate(signature string, now time.Time) (float64, bool) {
+	pin, ok := s.pins[signature]
+	if !ok || !now.Before(pin.Expires) {
+		return 0, false
+	}
+	return pin.Rate, true
+}
+
+// prunePins drops expired pins. Callers must hold s.mu for writing.
+func (s *AdaptiveSampler) prunePins(now time.Time) {
+	for signature, pin := range s.pins {
+		if !now.Before(pin.Expires) {
+			delete(s.pins, signature)
+		}
+	}
+}
+
+// PinPatternRate forces a pattern to be sampled at rate for ttl, e.g. 1.0
+// for an hour while debugging it, without a round-trip to the backend.
+func (ls *LipService) PinPatternRate(signature string, rate float64, ttl time.Duration) error {
+	if err := ls.sampler.PinPatternRate(signature, rate, ttl); err != nil {
+		return err
+	}
+	ls.sampler.diag.log(slog.Default(), "INFO", "Pattern pinned", "signature", signature, "rate", rate, "ttl", ttl)
+	return nil
+}
+
+// UnpinPattern removes a pattern's pin before it expires.
+func (ls *LipService) UnpinPattern(signature string) {
+	ls.sampler.UnpinPattern(signature)
+}
+
+// PinnedPatterns returns the pattern pins in force.
+func (ls *LipService) PinnedPatterns() []PatternPin {
+	return ls.sampler.PinnedPatterns()
+}
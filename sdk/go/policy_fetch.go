@@ -0,0 +1,385 @@
+package lipservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Policy fetch backoff bounds. Failed fetches are retried starting at
+// policyFetchBaseBackoff, doubling up to the normal refresh interval.
+const (
+	policyFetchBaseBackoff = 5 * time.Second
+	policyFetchMaxBackoff  = policyRefreshInterval
+)
+
+// defaultPolicyTTL is how long a policy is trusted without a successful
+// fetch, by default: three missed refreshes.
+const defaultPolicyTTL = 3 * policyRefreshInterval
+
+// PolicyFetchStats reports the health of sampling policy fetches.
+type PolicyFetchStats struct {
+	// Attempts is the total number of fetch attempts
+	Attempts int64 `json:"attempts"`
+
+	// Failures is the total number of failed attempts
+	Failures int64 `json:"failures"`
+
+	// ConsecutiveFailures is the number of failures since the last success
+	ConsecutiveFailures int `json:"consecutive_failures"`
+
+	// LastError is the error from the most recent failed attempt
+	LastError string `json:"last_error,omitempty"`
+
+	// LastSuccess is when a policy was last fetched successfully
+	LastSuccess time.Time `json:"last_success"`
+
+	// NextAttempt is when the next fetch is scheduled
+	NextAttempt time.Time `json:"next_attempt"`
+
+	// PolicyAge is how long ago a policy was last fetched successfully,
+	// or since startup if none has been
+	PolicyAge time.Duration `json:"policy_age"`
+
+	// PolicyStale is set once PolicyAge exceeds Config.PolicyTTL
+	PolicyStale bool `json:"policy_stale"`
+}
+
+// policyFetchState tracks fetch outcomes and schedules the next attempt.
+type policyFetchState struct {
+	mu    sync.Mutex
+	stats PolicyFetchStats
+
+	// ttl is how long a policy stays fresh, or 0 when there is no backend
+	// to fetch from; started stands in for the last success until one
+	ttl     time.Duration
+	started time.Time
+	// stale is the staleness OnPolicyStale last reported
+	stale bool
+}
+
+// policyTTL returns Config.PolicyTTL or its default.
+func policyTTL(config Config) time.Duration {
+	if config.PolicyTTL > 0 {
+		return config.PolicyTTL
+	}
+	return defaultPolicyTTL
+}
+
+// record notes the outcome of a fetch and returns the delay until the next
+// attempt: the refresh interval after a success, or an exponential backoff
+// with jitter after a failure.
+func (p *policyFetchState) record(err error, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Attempts++
+
+	delay := policyRefreshInterval
+	if err == nil {
+		p.stats.ConsecutiveFailures = 0
+		p.stats.LastError = ""
+		p.stats.LastSuccess = now
+	} else {
+		p.stats.Failures++
+		p.stats.ConsecutiveFailures++
+		p.stats.LastError = err.Error()
+		delay = policyFetchBackoff(p.stats.ConsecutiveFailures)
+	}
+
+	p.stats.NextAttempt = now.Add(delay)
+	return delay
+}
+
+// due reports whether the next fetch is due.
+func (p *policyFetchState) due(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !now.Before(p.stats.NextAttempt)
+}
+
+// snapshot returns a copy of the current stats.
+func (p *policyFetchState) snapshot() PolicyFetchStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.statsAt(time.Now())
+}
+
+// statsAt returns the stats with the policy's age as of now. Callers must
+// hold p.mu.
+func (p *policyFetchState) statsAt(now time.Time) PolicyFetchStats {
+	stats := p.stats
+	if p.ttl <= 0 {
+		return stats
+	}
+	since := stats.LastSuccess
+	if since.IsZero() {
+		since = p.started
+	}
+	stats.PolicyAge = now.Sub(since)
+	stats.PolicyStale = stats.PolicyAge > p.ttl
+	return stats
+}
+
+// staleChange reports whether the policy went stale or fresh again since
+// the last call that reported a change, returning the stats as of now.
+func (p *policyFetchState) staleChange(now time.Time) (PolicyFetchStats, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.statsAt(now)
+	if stats.PolicyStale == p.stale {
+		return stats, false
+	}
+	p.stale = stats.PolicyStale
+	return stats, true
+}
+
+// policyFetchBackoff returns the delay after n consecutive failures, with
+// up to 20% jitter so a fleet doesn't retry in lockstep.
+func policyFetchBackoff(n int) time.Duration {
+	delay := policyFetchBaseBackoff
+	for i := 1; i < n && delay < policyFetchMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > policyFetchMaxBackoff {
+		delay = policyFetchMaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+	return delay - jitter
+}
+
+// PolicyFetchStats returns the health of sampling policy fetches.
+func (s *AdaptiveSampler) PolicyFetchStats() PolicyFetchStats {
+	return s.policyFetch.snapshot()
+}
+
+// notifyStale reports the policy going stale or fresh again to the
+// diagnostics and Config.OnPolicyStale.
+func (s *AdaptiveSampler) notifyStale() {
+	stats, changed := s.policyFetch.staleChange(time.Now())
+	if !changed {
+		return
+	}
+
+	if stats.PolicyStale {
+		s.diag.log(slog.Default(), "WARN", "Sampling policy is stale",
+			"age", stats.PolicyAge.Round(time.Second), "last_error", stats.LastError)
+	} else {
+		s.diag.log(slog.Default(), "INFO", "Sampling policy is fresh again")
+	}
+	if s.config.OnPolicyStale != nil {
+		s.config.OnPolicyStale(stats.PolicyAge, stats.PolicyStale)
+	}
+}
+
+// refreshPolicy fetches the latest sampling policy, bounded by ctx, and
+// installs it, returning the delay until the next fetch. An unchanged
+// policy counts as a successful fetch.
+func (s *AdaptiveSampler) refreshPolicy(ctx context.Context) time.Duration {
+	policy, etag, err := s.fetchPolicy(ctx)
+	delay := s.policyFetch.record(err, time.Now())
+	s.notifyStale()
+
+	if err != nil {
+		// Throttled, so a long backend outage is reported once per interval
+		s.diag.log(slog.Default(), "WARN", "Policy fetch failed",
+			"consecutive_failures", s.policyFetch.snapshot().ConsecutiveFailures,
+			"retry_in", delay.Round(time.Second), "error", err)
+		return delay
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastPolicyUpdate = time.Now()
+	if policy == nil {
+		return delay
+	}
+
+	s.checkSignatureHash(policy)
+
+	// Install the policy and its pattern rates together so no decision
+	// sees one without the other
+	previous := s.policy
+	s.applyPolicy(policy, PolicySourceBackend)
+	s.replacePatternRates(previous, policy)
+	s.policyETag = etag
+
+	return delay
+}
+
+// replacePatternRates swaps the previous policy's pattern rates for the
+// new policy's. Patterns only the previous policy rated are forgotten, so
+// they fall back to the rest of the policy. Callers must hold s.mu.
+func (s *AdaptiveSampler) replacePatternRates(previous, policy *SamplingPolicy) {
+	if previous != nil {
+		for signature := range previous.PatternRates {
+			if _, ok := policy.PatternRates[signature]; ok {
+				continue
+			}
+			if stats, ok := s.patternStats[signature]; ok && stats.element != nil {
+				s.patternOrder.Remove(stats.element)
+			}
+			delete(s.patternStats, signature)
+		}
+	}
+
+	for signature, rate := range policy.PatternRates {
+		if stats, ok := s.patternStats[signature]; ok {
+			stats.SamplingRate = rate
+			continue
+		}
+		s.addPattern(signature, &PatternStats{Signature: signature, SamplingRate: rate}, time.Now())
+	}
+}
+
+// checkSignatureHash drops a policy's pattern rates when they are keyed by
+// a different hash than this instance's signatures, since none of them
+// could match, and warns once per mismatch. Callers must hold s.mu.
+func (s *AdaptiveSampler) checkSignatureHash(policy *SamplingPolicy) {
+	local := hasherName(s.signer.config.Hasher)
+	if policy.SignatureHash == "" || policy.SignatureHash == local || len(policy.PatternRates) == 0 {
+		s.hashMismatch = ""
+		return
+	}
+
+	if s.hashMismatch != policy.SignatureHash {
+		s.hashMismatch = policy.SignatureHash
+		s.diag.log(slog.Default(), "WARN", "Ignoring policy pattern rates keyed by another signature hash; set SignatureHasher to match",
+			"policy_hash", policy.SignatureHash, "local_hash", local)
+	}
+	policy.PatternRates = nil
+}
+
+// policyResponse is the backend's policy document. It carries the
+// SamplingPolicy fields plus the backend's own names for the version and
+// flat rate. The rates are pointers so an explicit 0 can be told from an
+// absent rate.
+type policyResponse struct {
+	SamplingPolicy
+	Version      int      `json:"version"`
+	SamplingRate *float64 `json:"sampling_rate"`
+	GlobalRate   *float64 `json:"global_rate"`
+}
+
+// fetchPolicy retrieves the sampling policy for this service, returning it
+// with its ETag. The policy is nil when there is nothing new to apply: no
+// backend is configured, the backend has no policy for the service, or the
+// policy hasn't changed since the last fetch.
+func (s *AdaptiveSampler) fetchPolicy(ctx context.Context) (*SamplingPolicy, string, error) {
+	if s.config.LipServiceURL == "" {
+		return nil, "", nil
+	}
+
+	// The signature hash tells the backend how to key pattern rates
+	query := url.Values{"signature_hash": {hasherName(s.signer.config.Hasher)}}
+	if s.config.PostHogTeamID != "" {
+		query.Set("team_id", s.config.PostHogTeamID)
+	}
+	path := "/api/v1/policies/" + url.PathEscape(s.config.ServiceName) + "?" + query.Encode()
+	req, err := s.newBackendRequest("GET", path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.RLock()
+	etag := s.policyETag
+	s.mu.RUnlock()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified, http.StatusNotFound:
+		return nil, "", nil
+	default:
+		return nil, "", fmt.Errorf("LipService returned status %d", resp.StatusCode)
+	}
+
+	var document policyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, "", fmt.Errorf("failed to decode policy: %w", err)
+	}
+
+	policy := document.SamplingPolicy
+	if policy.PolicyID == "" && document.Version > 0 {
+		policy.PolicyID = fmt.Sprintf("v%d", document.Version)
+	}
+	rate := document.SamplingRate
+	if rate == nil {
+		rate = document.GlobalRate
+	}
+	if rate != nil {
+		policy.SamplingRate, policy.ZeroRate = *rate, *rate == 0
+	}
+	if err := validatePolicy(&policy); err != nil {
+		return nil, "", fmt.Errorf("invalid policy: %w", err)
+	}
+
+	return &policy, resp.Header.Get("ETag"), nil
+}
+
+// validatePolicy rejects a policy whose rates or limits are out of range,
+// so a bad document leaves the current policy in force.
+func validatePolicy(policy *SamplingPolicy) error {
+	checkRate := func(name string, rate float64) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %g", name, rate)
+		}
+		return nil
+	}
+
+	if err := checkRate("sampling_rate", policy.SamplingRate); err != nil {
+		return err
+	}
+	if err := checkRate("canary_rate", policy.CanaryRate); err != nil {
+		return err
+	}
+	for severity, rate := range policy.SeverityRates {
+		if err := checkRate("severity_rates."+severity, rate); err != nil {
+			return err
+		}
+	}
+	for signature, rate := range policy.PatternRates {
+		if err := checkRate("pattern_rates."+signature, rate); err != nil {
+			return err
+		}
+	}
+	if policy.MaxLogsPerMinute < 0 {
+		return fmt.Errorf("max_logs_per_minute must not be negative, got %d", policy.MaxLogsPerMinute)
+	}
+	if _, ok := tierProfile(policy.Tier); policy.Tier != "" && !ok {
+		return fmt.Errorf("unknown service tier %q", policy.Tier)
+	}
+	return nil
+}
+
+// policyRefreshLoop refreshes the sampling policy, backing off after
+// failures.
+func (s *AdaptiveSampler) policyRefreshLoop(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			timer.Reset(s.refreshPolicy(ctx))
+		}
+	}
+}
@@ -3,77 +3,268 @@ package lipservice
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"go.opentelemetry.io/otel/log"
-	"go.opentelemetry.io/otel/sdk/log"
-	"go.opentelemetry.io/proto/otlp/collector/logs/v1"
-	"go.opentelemetry.io/proto/otlp/common/v1"
-	"go.opentelemetry.io/proto/otlp/logs/v1"
-	"go.opentelemetry.io/proto/otlp/resource/v1"
+	collector "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/proto"
 )
 
+// ErrExporterClosed is returned when a log is exported after Close.
+var ErrExporterClosed = errors.New("lipservice: exporter closed")
+
 // PostHogExporter handles OTLP export to PostHog.
 type PostHogExporter struct {
 	config     Config
 	client     *http.Client
+	diag       *diagnostics
+	endpoints  []*exportEndpoint
 	batch      []*logs.LogRecord
+	batchBytes int64
 	mu         sync.Mutex
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
+	// flushMu serializes sends so that e.mu is never held during I/O
+	flushMu       sync.Mutex
+	flushNow      chan struct{}
+	tuner         *flushTuner
+	ctx           context.Context
+	cancel        context.CancelFunc
+	group         *errgroup.Group
+	closed        bool
+	closeOnce     sync.Once
+	closeErr      error
+	stats         *deliveryStats
+	spool         *diskSpool
+	privacy       *attributePrivacy
+	tombstones    erasureTombstones
+	compressor    *batchCompressor
+	deadLetters   DeadLetterQueue
+	ids           IDGenerator
+	enrichment    *resourceEnrichment
+	priorityFloor *atomic.Int32
+	recent        *recordIndex
+	trails        *debugTrails
+	canary        bool
+	dlqCloser     io.Closer
+
+	// sendLatency is an EWMA of successful request durations in nanoseconds
+	sendLatency atomic.Int64
 }
 
 // NewPostHogExporter creates a new PostHog exporter.
 func NewPostHogExporter(config Config) (*PostHogExporter, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+	if config.Offline {
+		return nil, ErrOffline
+	}
+	if config.FlushOnSeverity != "" && !knownSeverity(config.FlushOnSeverity) {
+		return nil, fmt.Errorf("unknown flush severity %q", config.FlushOnSeverity)
+	}
 
+	// A tuned exporter buffers up to the largest batch it may pick
+	tuner := newFlushTuner(config)
+	if tuner != nil {
+		config.BatchSize = tuner.maxBatch
+	}
+
+	parent, cancel := context.WithCancel(context.Background())
+	group, ctx := errgroup.WithContext(parent)
+
+	diag := newDiagnostics(config)
 	exporter := &PostHogExporter{
-		config: config,
-		client: &http.Client{
-			Timeout: config.Timeout,
-		},
-		batch:  make([]*logs.LogRecord, 0, config.BatchSize),
-		ctx:    ctx,
-		cancel: cancel,
+		group:         group,
+		config:        config,
+		tuner:         tuner,
+		client:        newExportClient(config),
+		diag:          diag,
+		endpoints:     newExportEndpoints(config, diag),
+		batch:         make([]*logs.LogRecord, 0, config.BatchSize),
+		flushNow:      make(chan struct{}, 1),
+		ctx:           ctx,
+		cancel:        cancel,
+		stats:         newDeliveryStats(),
+		ids:           newIDGenerator(config),
+		enrichment:    &resourceEnrichment{},
+		priorityFloor: newPriorityFloor(config),
+		recent:        newRecordIndex(config),
+		trails:        newDebugTrails(config),
+		canary:        isCanary(config),
 	}
 
-	// Start background flush task
-	exporter.wg.Add(1)
-	go exporter.flushLoop()
+	privacy, err := newAttributePrivacy(config, diag)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	exporter.privacy = privacy
+
+	compressor, err := newBatchCompressor(config)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create batch compressor: %w", err)
+	}
+	exporter.compressor = compressor
+
+	deadLetters, dlqCloser, err := openDeadLetterQueue(config)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open dead-letter queue: %w", err)
+	}
+	exporter.deadLetters = deadLetters
+	exporter.dlqCloser = dlqCloser
+
+	if config.SpoolDir != "" {
+		spool, err := openDiskSpool(config.SpoolDir, diag)
+		if err != nil {
+			cancel()
+			if dlqCloser != nil {
+				dlqCloser.Close()
+			}
+			return nil, fmt.Errorf("failed to open spool: %w", err)
+		}
+		exporter.spool = spool
+		exporter.countLostSpool()
+	}
+
+	// Start background flush task (serverless callers flush per invocation,
+	// synchronous exports flush every record)
+	if !config.Serverless && !config.Synchronous {
+		exporter.group.Go(func() error {
+			return runTask(exporter.flushLoop)
+		})
+	}
 
 	return exporter, nil
 }
 
 // ExportLog exports a log to PostHog.
 func (e *PostHogExporter) ExportLog(message, severity string, timestamp time.Time, attributes map[string]interface{}) error {
+	return e.exportLog(message, severity, timestamp, nil, attributes)
+}
+
+// exportLog exports a log with attributes pre-bound by prebind in addition
+// to its own attributes.
+func (e *PostHogExporter) exportLog(message, severity string, timestamp time.Time, bound []*common.KeyValue, attributes map[string]interface{}) error {
+	timestamp, attributes = e.correctTimestamp(timestamp, time.Now(), attributes)
+	message, attributes, err := scrubForExport(e.config, e.privacy, message, attributes)
+	if err != nil {
+		return err
+	}
+
+	return e.enqueue(e.createLogRecord(message, severity, timestamp, bound, attributes))
+}
+
+// scrubForExport caps an oversized message and runs attributes through the
+// attribute stages. Every PostHog export and PreviewScrub go through it.
+func scrubForExport(config Config, privacy *attributePrivacy, message string, attributes map[string]interface{}) (string, map[string]interface{}, error) {
+	message, attributes = truncateForExport(message, attributes, maxMessageBytes(config))
+	attributes, err := privacy.apply(attributes)
+	return message, attributes, err
+}
+
+// exportFields exports a record from the logger's fields. Unless an
+// attribute transform applies to the record, it is built straight from the
+// fields, without the map exportLog works on.
+func (e *PostHogExporter) exportFields(message, severity string, timestamp time.Time, bound []*common.KeyValue, attributes recordFields) error {
+	_, truncated := truncateMessage(message, maxMessageBytes(e.config))
+	skewed := e.config.MaxClockSkew > 0 && skewedTimestamp(timestamp, time.Now(), e.config.MaxClockSkew)
+	if truncated || skewed || e.privacy.rewrites() {
+		return e.exportLog(message, severity, timestamp, bound, attributes.toMap())
+	}
+
+	attributes = e.privacy.keyGuard.applyFields(attributes)
+	return e.enqueue(e.newLogRecord(message, severity, timestamp, bound, attributes))
+}
+
+// enqueue buffers a record for the next batch, flushing if it is due.
+func (e *PostHogExporter) enqueue(logRecord *logs.LogRecord) error {
+	e.raisePriority(logRecord)
+
+	size := recordBytes(logRecord)
+
 	e.mu.Lock()
-	defer e.mu.Unlock()
+	if e.closed {
+		e.mu.Unlock()
+		return ErrExporterClosed
+	}
+	if e.tombstones.matches(logRecord) {
+		e.mu.Unlock()
+		e.stats.drop(DropReasonErased, 1)
+		return nil
+	}
+
+	// Stay under the memory cap, spilling or dropping per policy
+	if !e.makeRoom(size, recordPriority(logRecord)) {
+		e.mu.Unlock()
+		e.stats.drop(DropReasonMemory, 1)
+		e.trails.stepAll(e.trails.tracedIDs([]*logs.LogRecord{logRecord}), TrailDropped, "%s", DropReasonMemory)
+		return nil
+	}
 
-	// Create log record
-	logRecord := e.createLogRecord(message, severity, timestamp, attributes)
 	e.batch = append(e.batch, logRecord)
+	e.batchBytes += size
+	if e.tuner != nil {
+		e.tuner.observe(time.Now())
+	}
+
+	// While a slow flush is in flight the batch keeps growing; shed the
+	// lowest priority records rather than buffer without bound
+	if len(e.batch) > bufferLimitBatches*e.config.BatchSize {
+		e.enforceBufferLimit()
+		e.recountBatchBytes()
+	}
+
+	// Flush if batch is full, or at once in Synchronous mode and for
+	// records severe enough that they shouldn't wait for FlushInterval
+	due := len(e.batch) >= e.batchLimit() || e.config.Synchronous || e.flushesOn(logRecord)
+	e.mu.Unlock()
+
+	if !due {
+		return nil
+	}
 
-	// Flush if batch is full
-	if len(e.batch) >= e.config.BatchSize {
+	// Without a flush task (Serverless and Synchronous) the caller sends
+	if e.config.Serverless || e.config.Synchronous {
 		return e.flushBatch()
 	}
 
+	// Otherwise wake the flush task, so callers never wait on the network
+	select {
+	case e.flushNow <- struct{}{}:
+	default:
+		// A flush is already pending
+	}
 	return nil
 }
 
+// flushesOn reports whether record is at or above FlushOnSeverity.
+func (e *PostHogExporter) flushesOn(record *logs.LogRecord) bool {
+	return e.config.FlushOnSeverity != "" && record.SeverityNumber >= severityNumber(e.config.FlushOnSeverity)
+}
+
 // createLogRecord creates an OTLP LogRecord.
-func (e *PostHogExporter) createLogRecord(message, severity string, timestamp time.Time, attributes map[string]interface{}) *logs.LogRecord {
-	// Convert timestamp to nanoseconds
-	timestampNs := uint64(timestamp.UnixNano())
+func (e *PostHogExporter) createLogRecord(message, severity string, timestamp time.Time, bound []*common.KeyValue, attributes map[string]interface{}) *logs.LogRecord {
+	return e.newLogRecord(message, severity, timestamp, bound, fieldsFromMap(attributes))
+}
+
+// newLogRecord creates an OTLP LogRecord from a record's fields.
+func (e *PostHogExporter) newLogRecord(message, severity string, timestamp time.Time, bound []*common.KeyValue, attributes recordFields) *logs.LogRecord {
+	// Convert timestamp to nanoseconds; the SDK's own clock is the observed time
+	timestampNs := unixNano(timestamp)
+	observedNs := unixNano(time.Now())
 
 	// Create attributes
-	otlpAttributes := make([]*common.KeyValue, 0, len(attributes)+2)
+	otlpAttributes := make([]*common.KeyValue, 0, len(bound)+len(attributes)+2)
 
 	// Add severity
 	otlpAttributes = append(otlpAttributes, &common.KeyValue{
@@ -96,33 +287,53 @@ func (e *PostHogExporter) createLogRecord(message, severity string, timestamp ti
 		},
 	})
 
+	// Span IDs go on the record itself, so logs join to traces
+	traceID, spanID, flags, correlated := spanFields(attributes.value(TraceIDAttribute), attributes.value(SpanIDAttribute), attributes.value(TraceFlagsAttribute))
+
 	// Add custom attributes
-	for key, value := range attributes {
-		otlpAttributes = append(otlpAttributes, &common.KeyValue{
-			Key: key,
-			Value: &common.AnyValue{
-				Value: &common.AnyValue_StringValue{
-					StringValue: fmt.Sprintf("%v", value),
-				},
-			},
-		})
+	for _, field := range attributes {
+		if correlated && isSpanAttribute(field.key) {
+			continue
+		}
+		otlpAttributes = append(otlpAttributes, exportKeyValue(field.key, field.value))
+	}
+
+	// Identify records that weren't assigned an ID by the logger
+	if _, ok := attributes.get(RecordIDAttribute); !ok && e.ids != nil {
+		otlpAttributes = append(otlpAttributes, stringKeyValue(RecordIDAttribute, e.ids.NewID()))
+	}
+
+	// Add pre-bound attributes not overridden by this record
+	for _, kv := range bound {
+		if _, ok := attributes.get(kv.Key); !ok {
+			otlpAttributes = append(otlpAttributes, kv)
+		}
 	}
 
 	return &logs.LogRecord{
-		TimeUnixNano:   timestampNs,
-		SeverityText:   severity,
-		SeverityNumber: logs.SeverityNumber(severityNumber),
+		TimeUnixNano:         timestampNs,
+		ObservedTimeUnixNano: observedNs,
+		SeverityText:         severity,
+		SeverityNumber:       logs.SeverityNumber(severityNumber),
 		Body: &common.AnyValue{
 			Value: &common.AnyValue_StringValue{
 				StringValue: message,
 			},
 		},
 		Attributes: otlpAttributes,
+		TraceId:    traceID,
+		SpanId:     spanID,
+		Flags:      flags,
 	}
 }
 
 // getSeverityNumber converts severity string to OTLP severity number.
 func (e *PostHogExporter) getSeverityNumber(severity string) logs.SeverityNumber {
+	return severityNumber(severity)
+}
+
+// severityNumber converts severity string to OTLP severity number.
+func severityNumber(severity string) logs.SeverityNumber {
 	switch severity {
 	case "TRACE":
 		return logs.SeverityNumber_SEVERITY_NUMBER_TRACE
@@ -141,41 +352,97 @@ func (e *PostHogExporter) getSeverityNumber(severity string) logs.SeverityNumber
 	}
 }
 
-// flushLoop periodically flushes the batch.
-func (e *PostHogExporter) flushLoop() {
-	defer e.wg.Done()
+// knownSeverity reports whether severityNumber recognizes severity rather
+// than defaulting it to INFO.
+func knownSeverity(severity string) bool {
+	switch severity {
+	case "TRACE", "DEBUG", "INFO", "WARN", "WARNING", "ERROR", "FATAL", "CRITICAL":
+		return true
+	}
+	return false
+}
 
-	ticker := time.NewTicker(e.config.FlushInterval)
+// flushLoop flushes the batch every flush interval, and whenever exportLog
+// reports a full batch, until the exporter is closed. Close sends the final
+// batch itself, before cancelling the context.
+func (e *PostHogExporter) flushLoop() {
+	interval := e.flushInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// With auto-tuning the interval is recomputed periodically
+	var retune <-chan time.Time
+	if e.tuner != nil {
+		tuneTicker := time.NewTicker(tuneInterval)
+		defer tuneTicker.Stop()
+		retune = tuneTicker.C
+	}
+
 	for {
 		select {
 		case <-e.ctx.Done():
-			// Flush remaining logs before exiting
-			e.mu.Lock()
-			if len(e.batch) > 0 {
-				e.flushBatch()
-			}
-			e.mu.Unlock()
 			return
 		case <-ticker.C:
-			e.mu.Lock()
-			if len(e.batch) > 0 {
-				e.flushBatch()
+			e.flushBatch()
+		case <-e.flushNow:
+			e.flushBatch()
+		case now := <-retune:
+			if next := e.tuner.tune(now); next != interval {
+				interval = next
+				ticker.Reset(interval)
 			}
-			e.mu.Unlock()
 		}
 	}
 }
 
 // flushBatch flushes the current batch to PostHog.
 func (e *PostHogExporter) flushBatch() error {
-	if len(e.batch) == 0 {
+	return e.flushBatchContext(e.ctx)
+}
+
+// flushBatchContext flushes the current batch to PostHog, giving up on
+// retries once ctx is done. When ctx has a deadline, urgent records are
+// sent first and the rest are spooled if there isn't time to send them.
+// The batch is swapped out under e.mu and sent under flushMu, so ExportLog
+// keeps buffering while the request is in flight.
+func (e *PostHogExporter) flushBatchContext(ctx context.Context) error {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+
+	records := e.takeBatch()
+	if len(records) == 0 {
 		return nil
 	}
 
+	if _, ok := ctx.Deadline(); ok {
+		return e.flushByPriority(ctx, records)
+	}
+
+	err := e.sendRecords(ctx, records)
+
+	if err == nil && e.spool != nil {
+		e.replaySpool(ctx)
+	}
+
+	return err
+}
+
+// takeBatch hands the buffered records to the caller and starts a new batch.
+func (e *PostHogExporter) takeBatch() []*logs.LogRecord {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	records := e.batch
+	e.batch = make([]*logs.LogRecord, 0, e.config.BatchSize)
+	e.batchBytes = 0
+	return records
+}
+
+// sendRecords serializes and sends records, spooling or dropping them if
+// every attempt fails.
+func (e *PostHogExporter) sendRecords(ctx context.Context, records []*logs.LogRecord) error {
 	// Create OTLP request
-	request := e.createOTLPRequest(e.batch)
+	request := e.createOTLPRequest(records)
 
 	// Serialize request
 	data, err := proto.Marshal(request)
@@ -183,36 +450,211 @@ func (e *PostHogExporter) flushBatch() error {
 		return fmt.Errorf("failed to marshal OTLP request: %w", err)
 	}
 
-	// Send request with retries
+	// Checksum lets the backend recognise a batch resent after an ambiguous failure
+	checksum := batchChecksum(data)
+
+	traced := e.trails.tracedIDs(records)
+	e.trails.stepAll(traced, TrailBatched, "batch=%s records=%d", checksum, len(records))
+
+	batch, err := e.encodeBatch(data, checksum)
+	if err == nil {
+		err = e.sendWithRetries(withTrail(ctx, traced), batch)
+	}
+	n := int64(len(records))
+
+	// Isolate the records PostHog rejected and deliver the rest
+	if isRejection(err) {
+		return e.splitRejected(ctx, records, err)
+	}
+
+	switch {
+	case err == nil:
+		e.stats.exported.Add(n)
+		e.recent.add(records)
+		e.trails.stepAll(traced, TrailDelivered, "batch=%s", checksum)
+	case e.spool != nil:
+		// Keep the batch on disk and retry it on a later flush
+		if serr := e.spool.write(data, len(records)); serr != nil {
+			e.deadLetter(DropReasonExportFailed, err, records...)
+			e.stats.drop(DropReasonExportFailed, n)
+			e.trails.stepAll(traced, TrailDeadLettered, "%s: %v", DropReasonExportFailed, err)
+		} else {
+			e.stats.spooled.Add(n)
+			e.trails.stepAll(traced, TrailSpooled, "%v", err)
+		}
+	default:
+		e.deadLetter(DropReasonExportFailed, err, records...)
+		e.stats.drop(DropReasonExportFailed, n)
+		e.trails.stepAll(traced, TrailDeadLettered, "%s: %v", DropReasonExportFailed, err)
+	}
+
+	return err
+}
+
+// wireBatch is a serialized batch as it goes on the wire. It is compressed
+// once, however many attempts and endpoints it takes to deliver.
+type wireBatch struct {
+	// data is the uncompressed request, and body the same encoded
+	data     []byte
+	body     []byte
+	encoding string
+	checksum string
+}
+
+// encodeBatch compresses a serialized batch for sending.
+func (e *PostHogExporter) encodeBatch(data []byte, checksum string) (wireBatch, error) {
+	body, encoding, err := e.compressor.compress(data)
+	if err != nil {
+		return wireBatch{}, err
+	}
+	return wireBatch{data: data, body: body, encoding: encoding, checksum: checksum}, nil
+}
+
+// sendWithRetries sends an encoded batch, retrying with exponential
+// backoff until it succeeds, retries run out or ctx is done.
+func (e *PostHogExporter) sendWithRetries(ctx context.Context, batch wireBatch) error {
+	var err error
 	for attempt := 0; attempt <= e.config.MaxRetries; attempt++ {
-		err := e.sendRequest(data)
-		if err == nil {
+		err = e.sendRequest(ctx, batch)
+		if err == nil || isRejection(err) {
 			break
 		}
 
 		if attempt < e.config.MaxRetries {
 			// Exponential backoff
 			waitTime := time.Duration(1<<uint(attempt)) * time.Second
-			time.Sleep(waitTime)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(waitTime):
+			}
 		}
 	}
 
-	// Clear batch
-	e.batch = e.batch[:0]
-
 	return err
 }
 
-// createOTLPRequest creates an OTLP ExportLogsServiceRequest.
+// ExportRecords sends OTLP log records immediately, bypassing the batch,
+// with the same retries, batch splitting and spooling as a flush. It is
+// used to resend dead letters. Records carrying erased identifiers are
+// dropped.
+func (e *PostHogExporter) ExportRecords(ctx context.Context, records []*logs.LogRecord) error {
+	kept := make([]*logs.LogRecord, 0, len(records))
+	for _, record := range records {
+		if e.tombstones.matches(record) {
+			e.stats.drop(DropReasonErased, 1)
+			continue
+		}
+		kept = append(kept, record)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return e.sendRecords(ctx, kept)
+}
+
+// countLostSpool records spooled records that recovery had to discard.
+func (e *PostHogExporter) countLostSpool() {
+	if n := e.spool.takeLost(); n > 0 {
+		e.stats.drop(DropReasonSpoolCorrupt, n)
+	}
+}
+
+// replaySpool sends spooled segments oldest first, stopping at the first
+// failure so ordering is preserved. Segments that can't be decoded are
+// quarantined rather than retried on every replay.
+func (e *PostHogExporter) replaySpool(ctx context.Context) {
+	if _, err := e.spool.reap(); err != nil {
+		e.diag.log(slog.Default(), "ERROR", "Failed to reap orphaned spool segments", "error", err)
+	}
+	e.countLostSpool()
+
+	names, err := e.spool.segments()
+	if err != nil {
+		return
+	}
+
+	defer e.countLostSpool()
+	for _, name := range names {
+		data, err := e.spool.read(name)
+		if errors.Is(err, errSpoolTorn) || errors.Is(err, errSpoolCorrupt) {
+			e.quarantineSegment(name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		// The checksum is recomputed from the same bytes, so the backend
+		// can still dedupe a segment that was partially delivered before
+		batch, err := e.encodeBatch(data, batchChecksum(data))
+		if err == nil {
+			err = e.sendRequest(ctx, batch)
+		}
+		if isRejection(err) {
+			// Split the segment so one bad record doesn't block the spool;
+			// anything that still fails is spooled again as a new segment
+			records, rerr := requestRecords(data)
+			if rerr != nil {
+				e.quarantineSegment(name, rerr)
+				continue
+			}
+			e.splitRejected(ctx, records, err)
+			e.spool.remove(name)
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		e.spool.remove(name)
+		e.stats.exported.Add(int64(segmentRecords(name)))
+	}
+}
+
+// quarantineSegment moves a spool segment that can't be decoded aside.
+func (e *PostHogExporter) quarantineSegment(name string, cause error) {
+	e.diag.log(slog.Default(), "WARN", "Quarantining unreadable spool segment", "segment", name, "error", cause)
+	if err := e.spool.quarantine(name); err != nil {
+		e.diag.log(slog.Default(), "ERROR", "Failed to quarantine spool segment", "segment", name, "error", err)
+	}
+}
+
+// createOTLPRequest creates an OTLP ExportLogsServiceRequest with one
+// ResourceLogs per service identity among logRecords.
 func (e *PostHogExporter) createOTLPRequest(logRecords []*logs.LogRecord) *collector.ExportLogsServiceRequest {
-	// Create resource
+	// Create scope
+	scope := &common.InstrumentationScope{
+		Name:    "lipservice-go",
+		Version: Version,
+	}
+
+	request := &collector.ExportLogsServiceRequest{}
+	for _, group := range groupByService(logRecords, e.config.ServiceName) {
+		// Create scope logs
+		scopeLogs := &logs.ScopeLogs{
+			Scope:      scope,
+			LogRecords: group.records,
+		}
+
+		// Create resource logs
+		request.ResourceLogs = append(request.ResourceLogs, &logs.ResourceLogs{
+			Resource:  e.resource(group.service),
+			ScopeLogs: []*logs.ScopeLogs{scopeLogs},
+		})
+	}
+	return request
+}
+
+// resource creates the OTLP resource for the named service.
+func (e *PostHogExporter) resource(service string) *resource.Resource {
 	resource := &resource.Resource{
 		Attributes: []*common.KeyValue{
 			{
 				Key: "service.name",
 				Value: &common.AnyValue{
 					Value: &common.AnyValue_StringValue{
-						StringValue: e.config.ServiceName,
+						StringValue: service,
 					},
 				},
 			},
@@ -220,67 +662,161 @@ func (e *PostHogExporter) createOTLPRequest(logRecords []*logs.LogRecord) *colle
 				Key: "service.version",
 				Value: &common.AnyValue{
 					Value: &common.AnyValue_StringValue{
-						StringValue: "0.2.0",
+						StringValue: Version,
 					},
 				},
 			},
 		},
 	}
 
-	// Create scope
-	scope := &common.InstrumentationScope{
-		Name:    "lipservice-go",
-		Version: "0.2.0",
-	}
-
-	// Create scope logs
-	scopeLogs := &logs.ScopeLogs{
-		Scope:      scope,
-		LogRecords: logRecords,
+	// Add attributes injected centrally by the sampling policy
+	resource.Attributes = append(resource.Attributes, e.enrichment.get()...)
+	if e.canary {
+		resource.Attributes = append(resource.Attributes, &common.KeyValue{
+			Key:   CanaryResourceAttribute,
+			Value: &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: true}},
+		})
 	}
+	return resource
+}
 
-	// Create resource logs
-	resourceLogs := &logs.ResourceLogs{
-		Resource:   resource,
-		ScopeLogs:  []*logs.ScopeLogs{scopeLogs},
-	}
+// batchChecksum returns the hex-encoded SHA-256 of a serialized batch.
+func batchChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
 
-	return &collector.ExportLogsServiceRequest{
-		ResourceLogs: []*logs.ResourceLogs{resourceLogs},
+// sendRequest sends the OTLP request to PostHog, failing over to
+// Config.FailoverEndpoints while PostHog is unreachable. Rejections are
+// about the batch rather than the endpoint, so they aren't retried
+// elsewhere.
+func (e *PostHogExporter) sendRequest(ctx context.Context, batch wireBatch) error {
+	var err error
+	for _, endpoint := range e.candidates(ctx) {
+		var delivered bool
+		start := time.Now()
+		delivered, err = e.post(ctx, endpoint, batch)
+		if err == nil {
+			endpoint.succeeded()
+			if delivered {
+				e.observeSendLatency(time.Since(start))
+				e.stats.uncompressedBytes.Add(int64(len(batch.data)))
+				e.stats.compressedBytes.Add(int64(len(batch.body)))
+			}
+			return nil
+		}
+		if isRejection(err) {
+			return err
+		}
+		if len(e.endpoints) > 1 {
+			endpoint.failed(time.Now())
+		}
 	}
+	return err
 }
 
-// sendRequest sends the OTLP request to PostHog.
-func (e *PostHogExporter) sendRequest(data []byte) error {
-	url := fmt.Sprintf("%s/api/v1/otlp/v1/logs", e.config.PostHogEndpoint)
-
-	req, err := http.NewRequestWithContext(e.ctx, "POST", url, bytes.NewReader(data))
+// post sends an encoded batch to one endpoint, reporting whether it was
+// delivered: false with no error means the endpoint had already ingested
+// it.
+func (e *PostHogExporter) post(ctx context.Context, endpoint *exportEndpoint, batch wireBatch) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.url, bytes.NewReader(batch.body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return false, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/x-protobuf")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", e.config.PostHogAPIKey))
-	req.Header.Set("X-PostHog-Team-Id", e.config.PostHogTeamID)
+	if batch.encoding != CompressionIdentity {
+		req.Header.Set("Content-Encoding", batch.encoding)
+	}
+	e.setEndpointHeaders(req.Header, endpoint)
+	req.Header.Set("X-LipService-Batch-Checksum", batch.checksum)
+	req.Header.Set("Idempotency-Key", batch.checksum)
+	if e.config.CollectorMetadata {
+		setCollectorHeaders(req.Header, e.config)
+	}
 
 	// Send request
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		e.trails.stepAll(trailFrom(ctx), TrailHTTP, "endpoint=%s error=%v", endpoint.url, err)
+		return false, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
+	e.trails.stepAll(trailFrom(ctx), TrailHTTP, "endpoint=%s status=%d", endpoint.url, resp.StatusCode)
+
+	// 409 means the backend already ingested this batch on an earlier attempt
+	if resp.StatusCode == http.StatusConflict {
+		return false, nil
+	}
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("PostHog returned status %d", resp.StatusCode)
+		return false, &statusError{code: resp.StatusCode}
 	}
 
-	return nil
+	return true, nil
+}
+
+// Flush immediately sends any buffered logs to PostHog.
+func (e *PostHogExporter) Flush() error {
+	return e.flushBatch()
+}
+
+// FlushContext sends any buffered logs to PostHog, bounded by ctx.
+func (e *PostHogExporter) FlushContext(ctx context.Context) error {
+	return e.flushBatchContext(ctx)
+}
+
+// Pending returns the number of logs buffered but not yet exported.
+func (e *PostHogExporter) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.batch)
+}
+
+// Report returns a summary of the records this exporter has handled.
+func (e *PostHogExporter) Report() ShutdownReport {
+	report := e.stats.report(e.Pending(), e.Spooled())
+	report.PendingBytes = e.PendingBytes()
+	return report
+}
+
+// Spooled returns the number of logs held in the disk spool awaiting retry.
+func (e *PostHogExporter) Spooled() int {
+	if e.spool == nil {
+		return 0
+	}
+	return e.spool.records()
 }
 
-// Close shuts down the exporter.
+// Close flushes buffered logs and shuts down the exporter. Logs exported
+// after Close are rejected with ErrExporterClosed. It is safe to call more
+// than once and concurrently with ExportLog and Flush.
 func (e *PostHogExporter) Close() error {
-	e.cancel()
-	e.wg.Wait()
-	return nil
+	e.closeOnce.Do(func() {
+		// Flush while the context is still live so the final batch can be
+		// sent, and refuse new records from here on
+		e.mu.Lock()
+		e.closed = true
+		e.mu.Unlock()
+		err := e.flushBatch()
+
+		e.cancel()
+		if gerr := e.group.Wait(); gerr != nil && err == nil {
+			err = gerr
+		}
+
+		if e.spool != nil {
+			if serr := e.spool.close(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+		if e.dlqCloser != nil {
+			if derr := e.dlqCloser.Close(); derr != nil && err == nil {
+				err = derr
+			}
+		}
+		e.closeErr = err
+	})
+	return e.closeErr
 }
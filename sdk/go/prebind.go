@@ -0,0 +1,56 @@
+package lipservice
+
+import (
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// prebind converts attributes bound with With into OTLP key/values once, so
+// records logged through the bound logger only convert their own args. Value
+// limits, pseudonymization, encryption and key normalization are applied here
+// exactly as in ExportLog.
+func (e *PostHogExporter) prebind(args []interface{}) ([]*common.KeyValue, error) {
+	attributes := make(map[string]interface{}, len(args)/2)
+	addAttributes(attributes, args)
+
+	attributes, err := e.privacy.apply(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	kvs := make([]*common.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		kvs = append(kvs, exportKeyValue(key, value))
+	}
+	return kvs, nil
+}
+
+// mergeBound returns parent bound key/values followed by child, dropping
+// parent entries that child overrides. The result never aliases parent.
+func mergeBound(parent, child []*common.KeyValue) []*common.KeyValue {
+	merged := make([]*common.KeyValue, 0, len(parent)+len(child))
+	for _, kv := range parent {
+		overridden := false
+		for _, c := range child {
+			if c.Key == kv.Key {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, kv)
+		}
+	}
+	return append(merged, child...)
+}
+
+// stringKeyValue builds an OTLP string attribute.
+func stringKeyValue(key, value string) *common.KeyValue {
+	return &common.KeyValue{
+		Key: key,
+		Value: &common.AnyValue{
+			Value: &common.AnyValue_StringValue{
+				StringValue: value,
+			},
+		},
+	}
+}
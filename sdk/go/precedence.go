@@ -0,0 +1,168 @@
+package lipservice
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rules that can set a record's base sampling rate, from highest to lowest
+// precedence. Backend state beats local configuration, and within a source
+// the more specific rule wins.
+const (
+	// RuleLogLevel drops records below the runtime log level
+	RuleLogLevel = "log_level"
+
+	// RuleSeverity keeps ERROR, CRITICAL and FATAL records unconditionally
+	RuleSeverity = "severity"
+
+	// RuleIncident keeps every record while incident mode is on
+	RuleIncident = "incident"
+
+	// RulePin is a rate pinned from code with PinPatternRate
+	RulePin = "pin"
+
+	// RulePattern is the rate learned for the record's pattern
+	RulePattern = "pattern"
+
+	// RulePolicySeverity is the policy's rate for the record's severity
+	RulePolicySeverity = "policy.severity_rates"
+
+	// RulePolicyTier is the profile of the tier the policy selects
+	RulePolicyTier = "policy.tier"
+
+	// RuleCanary is the canary rate, which takes the place of the flat
+	// rate on canary instances
+	RuleCanary = "canary"
+
+	// RulePolicyRate is the policy's flat sampling rate
+	RulePolicyRate = "policy.sampling_rate"
+
+	// RuleConfigTier is the profile of Config.Tier
+	RuleConfigTier = "config.tier"
+
+	// RuleDefault is the built-in 10% rate
+	RuleDefault = "default"
+)
+
+// defaultSamplingRate applies when no rule sets a rate.
+const defaultSamplingRate = 0.1
+
+// EffectivePolicy explains the sampling rate a record would get and why.
+type EffectivePolicy struct {
+	// Rate is the probability of keeping the record after adjustments
+	Rate float64 `json:"rate"`
+
+	// BaseRate is the rate set by Rule, before adjustments
+	BaseRate float64 `json:"base_rate"`
+
+	// Rule is the highest-precedence rule that applied, e.g. RulePattern
+	Rule string `json:"rule"`
+
+	// PolicyID is the backend policy in force, if any
+	PolicyID string `json:"policy_id,omitempty"`
+
+	// Adjustments describe each modifier applied to BaseRate, in order,
+	// such as warmup, SLO boosts or load shedding
+	Adjustments []string `json:"adjustments,omitempty"`
+}
+
+// baseRate resolves the rate for a record with no adjustments, returning
+// the rule that set it. Pattern stats are only consulted when signature is
+// set. Callers must hold s.mu.
+func (s *AdaptiveSampler) baseRate(signature, severity string) (float64, string) {
+	if s.incident.Load() {
+		return 1, RuleIncident
+	}
+	if signature != "" {
+		if rate, ok := s.pinnedRate(signature, time.Now()); ok {
+			return rate, RulePin
+		}
+		if stats, ok := s.patternStats[signature]; ok {
+			return stats.SamplingRate, RulePattern
+		}
+	}
+	if s.policy != nil {
+		if rate, ok := s.policy.SeverityRates[severity]; ok {
+			return rate, RulePolicySeverity
+		}
+		if profile, ok := tierProfile(s.policy.Tier); ok {
+			return overrideTier(profile, s.policy).rate(severity), RulePolicyTier
+		}
+	}
+	if rate, ok := s.canaryRate(); ok {
+		return rate, RuleCanary
+	}
+	if s.policy != nil && (s.policy.SamplingRate > 0 || s.policy.ZeroRate) {
+		return s.policy.SamplingRate, RulePolicyRate
+	}
+	if profile, ok := tierProfile(s.config.Tier); ok {
+		return profile.rate(severity), RuleConfigTier
+	}
+	return defaultSamplingRate, RuleDefault
+}
+
+// adjustRate applies fleet coordination, warmup and SLO boosts,
+// then load shedding to rate. Each change is described in explain when it
+// is non-nil. Callers must hold s.mu.
+func (s *AdaptiveSampler) adjustRate(rate float64, now time.Time, explain *[]string) float64 {
+	// Nothing is adjusted away during an incident
+	if s.incident.Load() {
+		return rate
+	}
+
+	adjust := func(name string, next float64) {
+		if explain != nil && next != rate {
+			*explain = append(*explain, fmt.Sprintf("%s: %g -> %g", name, rate, next))
+		}
+		rate = next
+	}
+
+	if s.coordinator != nil {
+		adjust("coordination", rate*s.coordinator.multiplier)
+	}
+	// Raise sampling during warmup and while the SLO burns, but still
+	// yield to load shedding
+	adjust("warmup", s.warmup.boost(rate, now))
+	adjust("slo", s.slo.boost(rate, now))
+	if s.shedder != nil {
+		adjust("load_shedding", rate*s.shedder.rateFactor())
+	}
+	return rate
+}
+
+// EffectivePolicyFor explains the sampling rate a record with this
+// signature and severity would get right now: which rule won and how it
+// was adjusted. Pass an empty signature to ignore pattern stats.
+func (s *AdaptiveSampler) EffectivePolicyFor(signature, severity string) EffectivePolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var effective EffectivePolicy
+	if s.policy != nil {
+		effective.PolicyID = s.policy.PolicyID
+	}
+
+	switch {
+	case !s.level.enabled(severity):
+		effective.Rule = RuleLogLevel
+		return effective
+	case severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL":
+		effective.Rule = RuleSeverity
+		effective.BaseRate, effective.Rate = 1, 1
+		return effective
+	}
+
+	effective.BaseRate, effective.Rule = s.baseRate(signature, severity)
+	effective.Rate = effective.BaseRate
+	if effective.Rule != RulePin {
+		effective.Rate = s.adjustRate(effective.BaseRate, time.Now(), &effective.Adjustments)
+	}
+	return effective
+}
+
+// EffectivePolicyFor explains the sampling rate a record with this
+// signature and severity would get right now, for debugging conflicting
+// policies.
+func (ls *LipService) EffectivePolicyFor(signature, severity string) EffectivePolicy {
+	return ls.sampler.EffectivePolicyFor(signature, severity)
+}
@@ -0,0 +1,123 @@
+package lipservice
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Back-pressure defaults and timing.
+const (
+	defaultPressureThreshold = 0.8
+
+	// pressureCheckInterval is how often OnPressure's threshold is checked
+	pressureCheckInterval = time.Second
+
+	// pressureRecovery is the fraction of the threshold pressure must fall
+	// below before it is reported relieved, so it doesn't flap
+	pressureRecovery = 0.75
+
+	// spoolPressureBatches is how many batches' worth of spooled records
+	// count as a saturated spool
+	spoolPressureBatches = 100
+)
+
+// Pressure returns how saturated log delivery is, from 0 (idle) to 1
+// (saturated). It is the worst, across exporters, of buffer fill, spool
+// backlog and export latency relative to Timeout, so applications can
+// voluntarily cut their own debug logging while it is high.
+func (ls *LipService) Pressure() float64 {
+	pressure := 0.0
+	for _, exporter := range ls.exporters() {
+		pressure = math.Max(pressure, exporter.pressure())
+	}
+	return pressure
+}
+
+// pressure scores this exporter's saturation from 0 to 1.
+func (e *PostHogExporter) pressure() float64 {
+	e.mu.Lock()
+	pending, pendingBytes := len(e.batch), e.batchBytes
+	e.mu.Unlock()
+
+	pressure := float64(pending) / float64(bufferLimitBatches*e.config.BatchSize)
+	if limit := e.config.MaxMemoryBytes; limit > 0 {
+		pressure = math.Max(pressure, float64(pendingBytes)/float64(limit))
+	}
+	if e.spool != nil {
+		spooled := float64(e.spool.records())
+		pressure = math.Max(pressure, spooled/float64(spoolPressureBatches*e.config.BatchSize))
+	}
+	if timeout := e.config.Timeout; timeout > 0 {
+		pressure = math.Max(pressure, float64(e.sendLatency.Load())/float64(timeout))
+	}
+	return math.Min(pressure, 1)
+}
+
+// pressureMonitor calls Config.OnPressure when pressure crosses
+// PressureThreshold, and again once it has eased.
+type pressureMonitor struct {
+	ls        *LipService
+	threshold float64
+	notify    func(pressure float64, high bool)
+
+	mu   sync.Mutex
+	high bool
+}
+
+// newPressureMonitor creates a monitor for Config.OnPressure, or returns
+// nil if it isn't set.
+func newPressureMonitor(ls *LipService) *pressureMonitor {
+	if ls.config.OnPressure == nil {
+		return nil
+	}
+	threshold := ls.config.PressureThreshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultPressureThreshold
+	}
+	return &pressureMonitor{ls: ls, threshold: threshold, notify: ls.config.OnPressure}
+}
+
+// start checks pressure every pressureCheckInterval until ls is closed.
+func (m *pressureMonitor) start() {
+	m.ls.wg.Add(1)
+	go func() {
+		defer m.ls.wg.Done()
+
+		ticker := time.NewTicker(pressureCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.ls.ctx.Done():
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+// check measures pressure and calls the callback if it crossed the
+// threshold since the last check.
+func (m *pressureMonitor) check() {
+	if m == nil {
+		return
+	}
+	pressure := m.ls.Pressure()
+
+	m.mu.Lock()
+	crossed := false
+	switch {
+	case !m.high && pressure >= m.threshold:
+		m.high, crossed = true, true
+	case m.high && pressure < m.threshold*pressureRecovery:
+		m.high, crossed = false, true
+	}
+	high := m.high
+	m.mu.Unlock()
+
+	if crossed {
+		m.notify(pressure, high)
+	}
+}
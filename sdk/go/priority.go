@@ -0,0 +1,121 @@
+package lipservice
+
+import (
+	"sort"
+	"strings"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// Priority orders records in the exporter: higher priorities are flushed
+// first under a deadline and dropped last when the buffer overflows.
+type Priority int
+
+// Priority classes, lowest first.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+// PriorityAttribute tags a record with a Priority, overriding the one
+// derived from its severity:
+//
+//	logger.Info("checkout completed", lipservice.PriorityAttribute, lipservice.PriorityHigh)
+const PriorityAttribute = "lipservice.priority"
+
+// bufferLimitBatches is how many batches' worth of records the exporter
+// buffers before dropping the lowest priorities.
+const bufferLimitBatches = 10
+
+// String returns the priority's name.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses a priority name.
+func ParsePriority(name string) (Priority, bool) {
+	switch strings.ToLower(name) {
+	case "low":
+		return PriorityLow, true
+	case "normal":
+		return PriorityNormal, true
+	case "high":
+		return PriorityHigh, true
+	case "critical":
+		return PriorityCritical, true
+	default:
+		return PriorityNormal, false
+	}
+}
+
+// severityPriority derives a priority from an OTLP severity number.
+func severityPriority(severity logs.SeverityNumber) Priority {
+	switch {
+	case severity >= logs.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return PriorityCritical
+	case severity >= logs.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return PriorityHigh
+	case severity >= logs.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return PriorityNormal
+	default:
+		return PriorityLow
+	}
+}
+
+// recordPriority returns a record's priority: its PriorityAttribute if
+// tagged, otherwise derived from its severity.
+func recordPriority(record *logs.LogRecord) Priority {
+	for _, kv := range record.Attributes {
+		if kv.Key == PriorityAttribute {
+			if p, ok := ParsePriority(kv.Value.GetStringValue()); ok {
+				return p
+			}
+		}
+	}
+	return severityPriority(record.SeverityNumber)
+}
+
+// priorityTiers splits records into groups of equal priority, highest
+// first, preserving order within each group.
+func priorityTiers(records []*logs.LogRecord) [][]*logs.LogRecord {
+	var tiers [][]*logs.LogRecord
+	for p := PriorityCritical; p >= PriorityLow; p-- {
+		var tier []*logs.LogRecord
+		for _, record := range records {
+			if recordPriority(record) == p {
+				tier = append(tier, record)
+			}
+		}
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// enforceBufferLimit drops the lowest-priority, newest records once more
+// than bufferLimitBatches batches are buffered. Callers must hold e.mu.
+func (e *PostHogExporter) enforceBufferLimit() {
+	limit := bufferLimitBatches * e.config.BatchSize
+	if len(e.batch) <= limit {
+		return
+	}
+
+	sort.SliceStable(e.batch, func(i, j int) bool {
+		return recordPriority(e.batch[i]) > recordPriority(e.batch[j])
+	})
+
+	e.stats.drop(DropReasonOverflow, int64(len(e.batch)-limit))
+	e.batch = e.batch[:limit]
+}
@@ -0,0 +1,61 @@
+package lipservice
+
+import "fmt"
+
+// attributePrivacy is the set of attribute stages every exported record
+// goes through, whichever sink or exporter it is sent to: value limits,
+// pseudonymization, encryption and key normalization.
+type attributePrivacy struct {
+	keyGuard      *attributeKeyGuard
+	values        *attributeValueSampler
+	pseudonymizer *attributePseudonymizer
+	encryptor     *attributeEncryptor
+}
+
+// newAttributePrivacy creates the attribute stages for config, reporting
+// attribute key overflow to diag when it is set.
+func newAttributePrivacy(config Config, diag *diagnostics) (*attributePrivacy, error) {
+	encryptor, err := newAttributeEncryptor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attribute encryptor: %w", err)
+	}
+	pseudonymizer, err := newAttributePseudonymizer(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attribute pseudonymizer: %w", err)
+	}
+
+	return &attributePrivacy{
+		keyGuard:      newAttributeKeyGuard(config.MaxAttributeKeys, config.AttributeKeyWindow, diag),
+		values:        newAttributeValueSampler(config),
+		pseudonymizer: pseudonymizer,
+		encryptor:     encryptor,
+	}, nil
+}
+
+// rewrites reports whether any stage besides key normalization may change
+// a record's attribute values.
+func (p *attributePrivacy) rewrites() bool {
+	return p.values != nil || p.pseudonymizer != nil || p.encryptor != nil
+}
+
+// apply runs every stage over attributes.
+func (p *attributePrivacy) apply(attributes map[string]interface{}) (map[string]interface{}, error) {
+	return p.protect(p.values.apply(attributes))
+}
+
+// protect pseudonymizes and encrypts designated attributes under their raw
+// keys, then normalizes the keys. Guarding the keys first could rename a
+// designated key, or fold it into an overflow key, and ship it in cleartext.
+func (p *attributePrivacy) protect(attributes map[string]interface{}) (map[string]interface{}, error) {
+	if p.pseudonymizer != nil {
+		attributes = p.pseudonymizer.apply(attributes)
+	}
+	if p.encryptor != nil {
+		encrypted, err := p.encryptor.apply(attributes)
+		if err != nil {
+			return nil, err
+		}
+		attributes = encrypted
+	}
+	return p.keyGuard.apply(attributes), nil
+}
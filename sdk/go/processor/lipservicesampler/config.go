@@ -0,0 +1,49 @@
+package lipservicesampler
+
+import (
+	"errors"
+	"time"
+)
+
+// Config configures the lipservicesampler processor.
+type Config struct {
+	// LipServiceURL is the URL of the LipService backend policies are
+	// fetched from
+	LipServiceURL string `mapstructure:"lipservice_url"`
+
+	// APIKey is the API key for LipService (optional)
+	APIKey string `mapstructure:"api_key"`
+
+	// DefaultService is the policy used for records whose resource has no
+	// service.name
+	DefaultService string `mapstructure:"default_service"`
+
+	// Timeout is the timeout for requests to the LipService backend
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// ResampleAnnotated samples records that already carry LipService
+	// sampling metadata from an SDK, compounding their adjusted count.
+	// By default they were sampled at the source and are passed through.
+	ResampleAnnotated bool `mapstructure:"resample_annotated"`
+
+	// MaxServices is how many services keep a sampler at once; the least
+	// recently seen is closed to make room for a new one
+	MaxServices int `mapstructure:"max_services"`
+}
+
+// Validate checks the processor configuration.
+func (c *Config) Validate() error {
+	if c.LipServiceURL == "" {
+		return errors.New("lipservice_url must be set")
+	}
+	if c.DefaultService == "" {
+		return errors.New("default_service must be set")
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if c.MaxServices <= 0 {
+		return errors.New("max_services must be positive")
+	}
+	return nil
+}
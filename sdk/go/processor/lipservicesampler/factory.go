@@ -0,0 +1,58 @@
+// Package lipservicesampler is an OpenTelemetry Collector processor that
+// applies LipService adaptive sampling to log pipelines, using the same
+// sampler core and backend policies as the SDK.
+//
+// Example collector configuration:
+//
+//	processors:
+//	  lipservicesampler:
+//	    lipservice_url: https://lipservice.company.com
+//	    default_service: unknown_service
+//	    max_services: 1000
+package lipservicesampler
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/processorhelper"
+)
+
+// typeStr is the processor's key in collector configuration.
+const typeStr = "lipservicesampler"
+
+// NewFactory returns a factory for the lipservicesampler processor.
+func NewFactory() processor.Factory {
+	return processor.NewFactory(
+		typeStr,
+		createDefaultConfig,
+		processor.WithLogs(createLogsProcessor, component.StabilityLevelAlpha),
+	)
+}
+
+func createDefaultConfig() component.Config {
+	return &Config{
+		DefaultService: "unknown_service",
+		Timeout:        10 * time.Second,
+		MaxServices:    1000,
+	}
+}
+
+func createLogsProcessor(
+	ctx context.Context,
+	set processor.CreateSettings,
+	cfg component.Config,
+	next consumer.Logs,
+) (processor.Logs, error) {
+	p := newSamplerProcessor(cfg.(*Config), set.Logger)
+
+	return processorhelper.NewLogsProcessor(
+		ctx, set, cfg, next,
+		p.processLogs,
+		processorhelper.WithCapabilities(consumer.Capabilities{MutatesData: true}),
+		processorhelper.WithShutdown(p.shutdown),
+	)
+}
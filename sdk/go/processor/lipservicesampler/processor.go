@@ -0,0 +1,301 @@
+package lipservicesampler
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/srex-dev/lipservice-go"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/processor/processorhelper"
+	"go.uber.org/zap"
+)
+
+// samplerProcessor samples log records with one LipService sampler per
+// service, so each service is governed by its own backend policy. At most
+// MaxServices samplers are kept, least recently used evicted first, so a
+// stream of distinct service names can't grow it without bound.
+type samplerProcessor struct {
+	config *Config
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	samplers map[string]*list.Element
+	order    *list.List
+}
+
+// serviceSampler is one service's sampler. It is closed once it has been
+// evicted and no caller still holds it.
+type serviceSampler struct {
+	service string
+	sampler *lipservice.AdaptiveSampler
+
+	// Guarded by samplerProcessor.mu
+	refs    int
+	evicted bool
+	closed  bool
+}
+
+func newSamplerProcessor(config *Config, logger *zap.Logger) *samplerProcessor {
+	return &samplerProcessor{
+		config:   config,
+		logger:   logger,
+		samplers: make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// samplerFor returns the sampler for a service, creating it on first use
+// and evicting the least recently used one if that makes too many. The
+// caller holds the sampler until it calls release.
+func (p *samplerProcessor) samplerFor(service string) (*serviceSampler, error) {
+	if held := p.hold(service); held != nil {
+		return held, nil
+	}
+
+	// Creating a sampler can take a while, so it happens outside the lock
+	// and the map is checked again before the new one is added
+	config := lipservice.DefaultConfig()
+	config.ServiceName = service
+	config.LipServiceURL = p.config.LipServiceURL
+	config.APIKey = p.config.APIKey
+	config.Timeout = p.config.Timeout
+
+	sampler, err := lipservice.NewAdaptiveSampler(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sampler for service %q: %w", service, err)
+	}
+	created := &serviceSampler{service: service, sampler: sampler, refs: 1}
+
+	p.mu.Lock()
+	if element, ok := p.samplers[service]; ok {
+		// Another caller created one first; use theirs and close ours
+		held := element.Value.(*serviceSampler)
+		held.refs++
+		p.order.MoveToFront(element)
+		p.mu.Unlock()
+		p.closeSampler(created)
+		return held, nil
+	}
+	p.samplers[service] = p.order.PushFront(created)
+
+	var closing *serviceSampler
+	if p.config.MaxServices > 0 && p.order.Len() > p.config.MaxServices {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		evicted := oldest.Value.(*serviceSampler)
+		delete(p.samplers, evicted.service)
+		closing = p.evict(evicted)
+	}
+	p.mu.Unlock()
+
+	if closing != nil {
+		p.closeSampler(closing)
+	}
+	return created, nil
+}
+
+// hold returns the existing sampler for service, held for the caller, or
+// nil if there is none.
+func (p *samplerProcessor) hold(service string) *serviceSampler {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.samplers[service]
+	if !ok {
+		return nil
+	}
+	p.order.MoveToFront(element)
+	held := element.Value.(*serviceSampler)
+	held.refs++
+	return held
+}
+
+// release gives back a sampler from samplerFor, closing it if it was
+// evicted while held.
+func (p *samplerProcessor) release(held *serviceSampler) {
+	p.mu.Lock()
+	held.refs--
+	var closing *serviceSampler
+	if held.evicted {
+		closing = p.evict(held)
+	}
+	p.mu.Unlock()
+
+	if closing != nil {
+		p.closeSampler(closing)
+	}
+}
+
+// evict marks a sampler removed from the processor and returns it if no
+// caller holds it, so it can be closed. Callers must hold p.mu.
+func (p *samplerProcessor) evict(s *serviceSampler) *serviceSampler {
+	s.evicted = true
+	if s.refs > 0 || s.closed {
+		return nil
+	}
+	s.closed = true
+	return s
+}
+
+// closeSampler closes an evicted sampler, logging failures. Closing waits
+// for the sampler's background tasks, so callers must not hold p.mu.
+func (p *samplerProcessor) closeSampler(s *serviceSampler) {
+	if err := s.sampler.Close(); err != nil {
+		p.logger.Warn("Failed to close evicted LipService sampler", zap.String("service", s.service), zap.Error(err))
+	}
+}
+
+// processLogs drops sampled-out records and annotates kept ones with the
+// same sampling metadata the SDK exports.
+func (p *samplerProcessor) processLogs(_ context.Context, ld plog.Logs) (plog.Logs, error) {
+	var errs []error
+
+	ld.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		service := p.config.DefaultService
+		if value, ok := rl.Resource().Attributes().Get("service.name"); ok && value.Str() != "" {
+			service = value.Str()
+		}
+
+		held, err := p.samplerFor(service)
+		if err != nil {
+			// Keep the records rather than lose them to a setup failure
+			errs = append(errs, err)
+			return false
+		}
+		defer p.release(held)
+
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(record plog.LogRecord) bool {
+				return !p.sample(held.sampler, record)
+			})
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		p.logger.Warn("LipService sampling skipped", zap.Error(err))
+	}
+	if ld.ResourceLogs().Len() == 0 {
+		return ld, processorhelper.ErrSkipProcessingData
+	}
+	return ld, nil
+}
+
+// sample decides whether to keep a record, annotating it if kept.
+func (p *samplerProcessor) sample(sampler *lipservice.AdaptiveSampler, record plog.LogRecord) bool {
+	attributes := record.Attributes()
+
+	// Records sampled by an SDK already carry their adjusted count
+	upstream := 1.0
+	if value, ok := attributes.Get(lipservice.AdjustedCountAttribute); ok {
+		if !p.config.ResampleAnnotated {
+			return true
+		}
+		if count, ok := adjustedCount(value); ok {
+			upstream = count
+		}
+	}
+
+	result := sampler.Sample(record.Body().AsString(), severityText(record))
+	if !result.Kept {
+		return false
+	}
+
+	attributes.PutDouble(lipservice.SamplingRateAttribute, result.Rate)
+	attributes.PutStr(lipservice.SamplingReasonAttribute, result.Reason)
+	attributes.PutDouble(lipservice.AdjustedCountAttribute, upstream*result.AdjustedCount())
+	if result.PolicyID != "" {
+		attributes.PutStr(lipservice.PolicyIDAttribute, result.PolicyID)
+	}
+	return true
+}
+
+// adjustedCount reads an upstream adjusted count, which SDKs send as a
+// double but older ones, and some pipelines, send as a string or an int.
+func adjustedCount(value pcommon.Value) (float64, bool) {
+	switch value.Type() {
+	case pcommon.ValueTypeDouble:
+		return value.Double(), true
+	case pcommon.ValueTypeInt:
+		return float64(value.Int()), true
+	case pcommon.ValueTypeStr:
+		count, err := strconv.ParseFloat(value.Str(), 64)
+		return count, err == nil
+	}
+	return 0, false
+}
+
+// severityAliases maps severity text other SDKs and frameworks use to the
+// SDK's vocabulary.
+var severityAliases = map[string]string{
+	"TRACE":       "DEBUG",
+	"FINE":        "DEBUG",
+	"FINER":       "DEBUG",
+	"FINEST":      "DEBUG",
+	"INFORMATION": "INFO",
+	"NOTICE":      "INFO",
+	"WARNING":     "WARN",
+	"ERR":         "ERROR",
+	"SEVERE":      "ERROR",
+	"CRIT":        "CRITICAL",
+	"ALERT":       "FATAL",
+	"EMERG":       "FATAL",
+	"EMERGENCY":   "FATAL",
+	"PANIC":       "FATAL",
+}
+
+// severityText returns the record's severity in the SDK's vocabulary. The
+// severity number is used when set, since it means the same to every
+// producer; otherwise the text is upper-cased and aliases such as "error"
+// or "SEVERE" are mapped, so errors are never sampled at INFO rates.
+func severityText(record plog.LogRecord) string {
+	switch n := record.SeverityNumber(); {
+	case n >= plog.SeverityNumberFatal:
+		return "FATAL"
+	case n >= plog.SeverityNumberError:
+		return "ERROR"
+	case n >= plog.SeverityNumberWarn:
+		return "WARN"
+	case n >= plog.SeverityNumberInfo:
+		return "INFO"
+	case n > plog.SeverityNumberUnspecified:
+		return "DEBUG"
+	}
+
+	text := strings.ToUpper(strings.TrimSpace(record.SeverityText()))
+	if alias, ok := severityAliases[text]; ok {
+		return alias
+	}
+	if text == "" {
+		return "DEBUG"
+	}
+	return text
+}
+
+// shutdown closes every sampler; those still held are closed when they
+// are released.
+func (p *samplerProcessor) shutdown(context.Context) error {
+	p.mu.Lock()
+	var closing []*serviceSampler
+	for _, element := range p.samplers {
+		if s := p.evict(element.Value.(*serviceSampler)); s != nil {
+			closing = append(closing, s)
+		}
+	}
+	p.samplers = make(map[string]*list.Element)
+	p.order.Init()
+	p.mu.Unlock()
+
+	var errs []error
+	for _, s := range closing {
+		errs = append(errs, s.sampler.Close())
+	}
+	return errors.Join(errs...)
+}
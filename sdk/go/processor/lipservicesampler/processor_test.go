@@ -0,0 +1,270 @@
+package lipservicesampler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/srex-dev/lipservice-go"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.uber.org/zap"
+)
+
+func TestProcessLogs(t *testing.T) {
+	config := createDefaultConfig().(*Config)
+	config.LipServiceURL = "http://localhost:8000"
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected a valid config, got %v", err)
+	}
+
+	p := newSamplerProcessor(config, zap.NewNop())
+	defer p.shutdown(context.Background())
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+
+	failed := records.AppendEmpty()
+	failed.Body().SetStr("payment failed")
+	failed.SetSeverityNumber(plog.SeverityNumberError)
+
+	upstream := records.AppendEmpty()
+	upstream.Body().SetStr("cache miss")
+	upstream.SetSeverityText("DEBUG")
+	upstream.Attributes().PutDouble(lipservice.AdjustedCountAttribute, 10)
+
+	out, err := p.processLogs(context.Background(), ld)
+	if err != nil {
+		t.Fatalf("Failed to process logs: %v", err)
+	}
+
+	// Errors are always kept, and SDK-sampled records pass through
+	kept := out.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	if kept.Len() != 2 {
+		t.Fatalf("Expected both records kept, got %d", kept.Len())
+	}
+	if count, _ := kept.At(0).Attributes().Get(lipservice.AdjustedCountAttribute); count.Double() != 1 {
+		t.Errorf("Expected an adjusted count of 1 on the error, got %v", count.Double())
+	}
+	if _, ok := p.samplers["checkout"]; !ok {
+		t.Error("Expected a sampler for the record's service")
+	}
+}
+
+func TestSamplerEviction(t *testing.T) {
+	config := createDefaultConfig().(*Config)
+	config.LipServiceURL = "http://localhost:8000"
+	config.MaxServices = 2
+
+	p := newSamplerProcessor(config, zap.NewNop())
+	defer p.shutdown(context.Background())
+
+	for _, service := range []string{"checkout", "payments", "checkout", "search"} {
+		held, err := p.samplerFor(service)
+		if err != nil {
+			t.Fatalf("Failed to create sampler for %s: %v", service, err)
+		}
+		p.release(held)
+	}
+
+	if len(p.samplers) != 2 || p.order.Len() != 2 {
+		t.Fatalf("Expected 2 samplers kept, got %d", len(p.samplers))
+	}
+	if _, ok := p.samplers["payments"]; ok {
+		t.Error("Expected the least recently used service evicted")
+	}
+	if _, ok := p.samplers["checkout"]; !ok {
+		t.Error("Expected the recently used service kept")
+	}
+
+	if err := p.shutdown(context.Background()); err != nil || len(p.samplers) != 0 {
+		t.Errorf("Expected shutdown to close every sampler, got %v with %d left", err, len(p.samplers))
+	}
+}
+
+func TestEvictHeldSampler(t *testing.T) {
+	config := createDefaultConfig().(*Config)
+	config.LipServiceURL = "http://localhost:8000"
+	config.MaxServices = 1
+
+	p := newSamplerProcessor(config, zap.NewNop())
+	defer p.shutdown(context.Background())
+
+	checkout, err := p.samplerFor("checkout")
+	if err != nil {
+		t.Fatalf("Failed to create sampler: %v", err)
+	}
+	again, err := p.samplerFor("checkout")
+	if err != nil || again != checkout {
+		t.Fatalf("Expected the same sampler for the same service, got %v", err)
+	}
+	p.release(again)
+
+	// Evicting a sampler still in use leaves it open until it's released
+	search, err := p.samplerFor("search")
+	if err != nil {
+		t.Fatalf("Failed to create sampler: %v", err)
+	}
+	defer p.release(search)
+	if _, ok := p.samplers["checkout"]; ok || checkout.closed {
+		t.Fatalf("Expected checkout evicted but left open while held")
+	}
+	checkout.sampler.Sample("payment failed", "ERROR")
+
+	p.release(checkout)
+	if !checkout.closed {
+		t.Error("Expected checkout closed once released")
+	}
+}
+
+func TestProcessSDKRecord(t *testing.T) {
+	// Capture a record as the SDK exports it, sampled at 1 in 2
+	bodies := make(chan []byte, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sdkConfig := lipservice.DefaultConfig()
+	sdkConfig.ServiceName = "checkout"
+	sdkConfig.PostHogAPIKey = "phc_test"
+	sdkConfig.PostHogTeamID = "1"
+	sdkConfig.PostHogEndpoint = server.URL
+	sdkConfig.Compression = lipservice.CompressionIdentity
+	sdkConfig.Synchronous = true
+	sdkConfig.CollectorMetadata = true
+
+	ls, err := lipservice.New(sdkConfig)
+	if err != nil {
+		t.Fatalf("Failed to create LipService: %v", err)
+	}
+	defer ls.Close()
+	signature := lipservice.NewSignatureEngine(lipservice.SignatureEngineConfig{}).Signature("cache miss")
+	if err := ls.Sampler().PinPatternRate(signature, 0.5, time.Hour); err != nil {
+		t.Fatalf("Failed to pin the pattern: %v", err)
+	}
+	for i := 0; i < 100 && len(bodies) == 0; i++ {
+		ls.Logger().Info("cache miss")
+	}
+	if len(bodies) == 0 {
+		t.Fatal("Expected the SDK to export a record")
+	}
+
+	request := plogotlp.NewExportRequest()
+	if err := request.UnmarshalProto(<-bodies); err != nil {
+		t.Fatalf("Failed to decode the SDK's export: %v", err)
+	}
+	ld := request.Logs()
+	exported, _ := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get(lipservice.AdjustedCountAttribute)
+	if exported.Type() != pcommon.ValueTypeDouble {
+		t.Errorf("Expected the SDK to export the adjusted count as a double, got %v", exported.Type())
+	}
+
+	config := createDefaultConfig().(*Config)
+	config.LipServiceURL = "http://localhost:8000"
+	config.ResampleAnnotated = true
+	p := newSamplerProcessor(config, zap.NewNop())
+	defer p.shutdown(context.Background())
+	held, err := p.samplerFor("checkout")
+	if err != nil {
+		t.Fatalf("Failed to create sampler: %v", err)
+	}
+	defer p.release(held)
+	if err := held.sampler.PinPatternRate(signature, 1, time.Hour); err != nil {
+		t.Fatalf("Failed to pin the pattern: %v", err)
+	}
+
+	out, err := p.processLogs(context.Background(), ld)
+	if err != nil {
+		t.Fatalf("Failed to process logs: %v", err)
+	}
+	count, ok := out.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get(lipservice.AdjustedCountAttribute)
+	if !ok || count.Type() != pcommon.ValueTypeDouble || count.Double() != 2 {
+		t.Errorf("Expected the SDK's adjusted count of 2 carried through, got %v", count.AsRaw())
+	}
+}
+
+func TestAdjustedCountForms(t *testing.T) {
+	for _, value := range []pcommon.Value{
+		pcommon.NewValueDouble(4),
+		pcommon.NewValueInt(4),
+		pcommon.NewValueStr("4"),
+	} {
+		if count, ok := adjustedCount(value); !ok || count != 4 {
+			t.Errorf("adjustedCount(%v) = %v, %v, expected 4", value.AsRaw(), count, ok)
+		}
+	}
+	if _, ok := adjustedCount(pcommon.NewValueStr("many")); ok {
+		t.Error("Expected an unparseable count to be ignored")
+	}
+}
+
+func TestSeverityText(t *testing.T) {
+	tests := []struct {
+		text   string
+		number plog.SeverityNumber
+		want   string
+	}{
+		{"ERROR", 0, "ERROR"},
+		{"error", 0, "ERROR"},
+		{"Error", 0, "ERROR"},
+		{"SEVERE", 0, "ERROR"},
+		{"warning", 0, "WARN"},
+		{"crit", 0, "CRITICAL"},
+		{"panic", 0, "FATAL"},
+		{"info", 0, "INFO"},
+		{"trace", 0, "DEBUG"},
+		{"", 0, "DEBUG"},
+		// The severity number wins over text
+		{"info", plog.SeverityNumberError, "ERROR"},
+		{"", plog.SeverityNumberWarn2, "WARN"},
+	}
+	for _, tt := range tests {
+		record := plog.NewLogRecord()
+		record.SetSeverityText(tt.text)
+		record.SetSeverityNumber(tt.number)
+		if got := severityText(record); got != tt.want {
+			t.Errorf("severityText(%q, %v) = %q, expected %q", tt.text, tt.number, got, tt.want)
+		}
+	}
+
+	// Lower-case errors are kept however low the pattern's rate
+	config := createDefaultConfig().(*Config)
+	config.LipServiceURL = "http://localhost:8000"
+	p := newSamplerProcessor(config, zap.NewNop())
+	defer p.shutdown(context.Background())
+	held, err := p.samplerFor("checkout")
+	if err != nil {
+		t.Fatalf("Failed to create sampler: %v", err)
+	}
+	signature := lipservice.NewSignatureEngine(lipservice.SignatureEngineConfig{}).Signature("payment failed")
+	if err := held.sampler.PinPatternRate(signature, 0, time.Hour); err != nil {
+		t.Fatalf("Failed to pin the pattern: %v", err)
+	}
+	p.release(held)
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	for i := 0; i < 10; i++ {
+		record := records.AppendEmpty()
+		record.Body().SetStr("payment failed")
+		record.SetSeverityText("error")
+	}
+	out, err := p.processLogs(context.Background(), ld)
+	if err != nil {
+		t.Fatalf("Failed to process logs: %v", err)
+	}
+	if kept := out.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().Len(); kept != 10 {
+		t.Errorf("Expected every lower-case error kept, got %d of 10", kept)
+	}
+}
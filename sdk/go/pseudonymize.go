@@ -0,0 +1,76 @@
+package lipservice
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// pseudonymPrefix marks attribute values replaced by the SDK with a keyed
+// hash.
+const pseudonymPrefix = "psd:v1:"
+
+// minPseudonymizationKeyBytes is the shortest HMAC key accepted.
+const minPseudonymizationKeyBytes = 16
+
+// attributePseudonymizer replaces the values of designated attributes with
+// an HMAC-SHA256 under a secret key. The same value always maps to the same
+// pseudonym, so records can be correlated by user without exposing the raw
+// identifier, and without the key the pseudonym can't be reversed or
+// recomputed from a guess.
+type attributePseudonymizer struct {
+	key  []byte
+	keys map[string]struct{}
+}
+
+// newAttributePseudonymizer creates a pseudonymizer for the configured
+// attributes, or returns nil if none are designated.
+func newAttributePseudonymizer(config Config) (*attributePseudonymizer, error) {
+	if len(config.PseudonymizedAttributes) == 0 {
+		return nil, nil
+	}
+
+	if len(config.PseudonymizationKey) < minPseudonymizationKeyBytes {
+		return nil, fmt.Errorf("pseudonymization key must be at least %d bytes, got %d",
+			minPseudonymizationKeyBytes, len(config.PseudonymizationKey))
+	}
+
+	keys := make(map[string]struct{}, len(config.PseudonymizedAttributes))
+	for _, key := range config.PseudonymizedAttributes {
+		keys[key] = struct{}{}
+	}
+
+	return &attributePseudonymizer{key: config.PseudonymizationKey, keys: keys}, nil
+}
+
+// apply returns attributes with designated values pseudonymized. The input
+// map is returned unchanged when it holds no designated attributes.
+func (p *attributePseudonymizer) apply(attributes map[string]interface{}) map[string]interface{} {
+	var pseudonymized map[string]interface{}
+	for key, value := range attributes {
+		if _, ok := p.keys[key]; !ok {
+			continue
+		}
+
+		if pseudonymized == nil {
+			pseudonymized = make(map[string]interface{}, len(attributes))
+			for k, v := range attributes {
+				pseudonymized[k] = v
+			}
+		}
+		pseudonymized[key] = p.pseudonym(fmt.Sprintf("%v", value))
+	}
+
+	if pseudonymized == nil {
+		return attributes
+	}
+	return pseudonymized
+}
+
+// pseudonym returns the prefixed, hex-encoded HMAC of value.
+func (p *attributePseudonymizer) pseudonym(value string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(value))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))
+}
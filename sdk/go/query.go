@@ -0,0 +1,257 @@
+package lipservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// RecordFilter selects records from the recent-records index. Zero fields
+// match everything.
+type RecordFilter struct {
+	// Severity matches the record's severity text exactly
+	Severity string
+
+	// Signature matches the record's message signature
+	Signature string
+
+	// Attributes must all be present with these values, compared as text
+	Attributes map[string]string
+
+	// Since and Until bound the record's event time
+	Since time.Time
+	Until time.Time
+
+	// Limit caps the number of records returned (0 means no cap)
+	Limit int
+}
+
+// RecentRecord is an exported record held in the recent-records index.
+type RecentRecord struct {
+	Time       time.Time              `json:"time"`
+	Severity   string                 `json:"severity"`
+	Message    string                 `json:"message"`
+	Signature  string                 `json:"signature"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// erased marks a slot whose record was purged by Erase
+	erased bool
+}
+
+// recordIndex is a bounded ring of the most recently exported records.
+type recordIndex struct {
+	signatures *SignatureEngine
+
+	mu      sync.RWMutex
+	records []RecentRecord
+	next    int
+	full    bool
+}
+
+// newRecordIndex creates an index, or returns nil if it is disabled.
+func newRecordIndex(config Config) *recordIndex {
+	if config.RecentRecords <= 0 {
+		return nil
+	}
+	return &recordIndex{
+		signatures: signatureEngine(config),
+		records:    make([]RecentRecord, config.RecentRecords),
+	}
+}
+
+// add indexes exported records, evicting the oldest.
+func (x *recordIndex) add(records []*logs.LogRecord) {
+	if x == nil {
+		return
+	}
+
+	entries := make([]RecentRecord, len(records))
+	for i, record := range records {
+		entries[i] = recentRecord(record, x.signatures)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, entry := range entries {
+		x.records[x.next] = entry
+		x.next = (x.next + 1) % len(x.records)
+		if x.next == 0 {
+			x.full = true
+		}
+	}
+}
+
+// erase removes indexed records carrying an erased identifier.
+func (x *recordIndex) erase(matcher erasureMatcher) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for i, record := range x.records {
+		value, ok := record.Attributes[matcher.attribute].(string)
+		if !ok {
+			continue
+		}
+		if _, erased := matcher.values[value]; erased {
+			x.records[i] = RecentRecord{erased: true}
+		}
+	}
+}
+
+// query returns the records matching filter, newest first.
+func (x *recordIndex) query(filter RecordFilter) []RecentRecord {
+	if x == nil {
+		return nil
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	n := x.next
+	if x.full {
+		n = len(x.records)
+	}
+
+	var matched []RecentRecord
+	for i := 1; i <= n; i++ {
+		record := x.records[(x.next-i+len(x.records))%len(x.records)]
+		if record.erased || !filter.matches(record) {
+			continue
+		}
+		matched = append(matched, record)
+		if filter.Limit > 0 && len(matched) == filter.Limit {
+			break
+		}
+	}
+	return matched
+}
+
+// matches reports whether a record passes the filter.
+func (f RecordFilter) matches(record RecentRecord) bool {
+	if f.Severity != "" && f.Severity != record.Severity {
+		return false
+	}
+	if f.Signature != "" && f.Signature != record.Signature {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.Time.After(f.Until) {
+		return false
+	}
+	for key, want := range f.Attributes {
+		value, ok := record.Attributes[key]
+		if !ok || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// recentRecord converts an OTLP record for the index, computing its
+// signature with signatures.
+func recentRecord(record *logs.LogRecord, signatures *SignatureEngine) RecentRecord {
+	message := record.Body.GetStringValue()
+
+	attributes := make(map[string]interface{}, len(record.Attributes))
+	for _, kv := range record.Attributes {
+		attributes[kv.Key] = anyValue(kv.Value)
+	}
+
+	return RecentRecord{
+		Time:       time.Unix(0, int64(record.TimeUnixNano)),
+		Severity:   record.SeverityText,
+		Message:    message,
+		Signature:  signatures.Signature(message),
+		Attributes: attributes,
+	}
+}
+
+// anyValue converts an OTLP value to its Go equivalent.
+func anyValue(value *common.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_BoolValue:
+		return v.BoolValue
+	case *common.AnyValue_IntValue:
+		return v.IntValue
+	case *common.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *common.AnyValue_BytesValue:
+		return v.BytesValue
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Query returns recently exported records matching filter, newest first.
+// It returns nil unless Config.RecentRecords is set.
+func (ls *LipService) Query(filter RecordFilter) []RecentRecord {
+	if ls.posthogExporter == nil {
+		return nil
+	}
+	return ls.posthogExporter.recent.query(filter)
+}
+
+// QueryHandler serves Query as JSON for debug endpoints. Filters are taken
+// from the query string: severity, signature, since and until (RFC 3339),
+// limit, and attr.<key>=<value> for attribute matches.
+func (ls *LipService) QueryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseRecordFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ls.Query(filter))
+	})
+}
+
+// parseRecordFilter reads a RecordFilter from a request's query string.
+func parseRecordFilter(r *http.Request) (RecordFilter, error) {
+	query := r.URL.Query()
+	filter := RecordFilter{
+		Severity:  query.Get("severity"),
+		Signature: query.Get("signature"),
+	}
+
+	for name, field := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*field = t
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid limit: %w", err)
+		}
+		filter.Limit = limit
+	}
+
+	for key, values := range query {
+		if name, ok := strings.CutPrefix(key, "attr."); ok && name != "" {
+			if filter.Attributes == nil {
+				filter.Attributes = make(map[string]string)
+			}
+			filter.Attributes[name] = values[0]
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,197 @@
+package lipservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Kinds of drift between the local and backend views of a pattern.
+const (
+	// DriftMissing is a pattern the backend knows but this instance has
+	// no stats for, e.g. because reports never arrived or state was lost
+	DriftMissing = "missing"
+
+	// DriftNew is a pattern this instance has seen that the backend
+	// doesn't know, e.g. because it hasn't been reported yet
+	DriftNew = "new"
+
+	// DriftRateMismatch is a pattern sampled at a different rate locally
+	// than the backend assigned, e.g. because a policy wasn't applied
+	DriftRateMismatch = "rate_mismatch"
+)
+
+// reconcileRateTolerance is how far local and backend rates may differ
+// before they count as a mismatch.
+const reconcileRateTolerance = 0.001
+
+// PatternDrift is one pattern whose local and backend views disagree.
+type PatternDrift struct {
+	Signature string `json:"signature"`
+	Kind      string `json:"kind"`
+	Template  string `json:"template,omitempty"`
+
+	LocalRate    float64 `json:"local_rate"`
+	BackendRate  float64 `json:"backend_rate"`
+	LocalCount   int     `json:"local_count"`
+	BackendCount int     `json:"backend_count"`
+}
+
+// ReconciliationReport compares the patterns this instance has learned
+// with those the backend knows for the service.
+type ReconciliationReport struct {
+	GeneratedAt     time.Time `json:"generated_at"`
+	LocalPatterns   int       `json:"local_patterns"`
+	BackendPatterns int       `json:"backend_patterns"`
+
+	// Matched is the number of patterns both views agree on
+	Matched int `json:"matched"`
+
+	// Drift lists disagreements, missing first, then new, then rate
+	// mismatches, each by signature
+	Drift []PatternDrift `json:"drift,omitempty"`
+}
+
+// String formats the report as a single log line.
+func (r ReconciliationReport) String() string {
+	kinds := map[string]int{}
+	for _, drift := range r.Drift {
+		kinds[drift.Kind]++
+	}
+	return fmt.Sprintf("local=%d backend=%d matched=%d missing=%d new=%d rate_mismatch=%d",
+		r.LocalPatterns, r.BackendPatterns, r.Matched, kinds[DriftMissing], kinds[DriftNew], kinds[DriftRateMismatch])
+}
+
+// ReconcilePatterns fetches the patterns the backend knows for this
+// service and diffs them against the patterns this instance has stats for
+// or has logged since its last pattern report. Drift points at reporting or
+// policy problems: patterns that never reached the backend, stats lost
+// locally, or rates that weren't applied.
+func (ls *LipService) ReconcilePatterns(ctx context.Context) (ReconciliationReport, error) {
+	return ls.sampler.reconcilePatterns(ctx)
+}
+
+// reconcilePatterns fetches the backend's patterns and diffs them against
+// the local ones.
+func (s *AdaptiveSampler) reconcilePatterns(ctx context.Context) (ReconciliationReport, error) {
+	backend, err := s.fetchPatterns(ctx)
+	if err != nil {
+		return ReconciliationReport{}, err
+	}
+	return diffPatterns(s.localPatterns(), backend.Patterns), nil
+}
+
+// localPatterns returns the patterns with stats, plus those observed since
+// the last pattern report that have none, at the rate a record of their
+// most frequent severity would get.
+func (s *AdaptiveSampler) localPatterns() []PatternEntry {
+	entries := s.patternDictionary().Patterns
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for signature, t := range s.tallies {
+		if _, ok := s.patternStats[signature]; ok {
+			continue
+		}
+		rate, _ := s.baseRate(signature, t.dominantSeverity())
+
+		stats := &PatternStats{}
+		stats.describe(t.example)
+		entries = append(entries, PatternEntry{
+			Signature:    signature,
+			Template:     stats.Template,
+			Example:      t.example,
+			SamplingRate: rate,
+			Count:        t.count,
+		})
+	}
+	return entries
+}
+
+// fetchPatterns retrieves the backend's pattern dictionary for this
+// service.
+func (s *AdaptiveSampler) fetchPatterns(ctx context.Context) (PatternDictionary, error) {
+	var dictionary PatternDictionary
+
+	req, err := s.newBackendRequest("GET", "/api/v1/patterns/"+url.PathEscape(s.config.ServiceName), nil)
+	if err != nil {
+		return dictionary, err
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return dictionary, fmt.Errorf("failed to fetch backend patterns: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return dictionary, fmt.Errorf("LipService returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&dictionary); err != nil {
+		return dictionary, fmt.Errorf("failed to decode backend patterns: %w", err)
+	}
+	return dictionary, nil
+}
+
+// diffPatterns compares local and backend pattern entries.
+func diffPatterns(local, backend []PatternEntry) ReconciliationReport {
+	report := ReconciliationReport{
+		GeneratedAt:     time.Now().UTC(),
+		LocalPatterns:   len(local),
+		BackendPatterns: len(backend),
+	}
+
+	known := make(map[string]PatternEntry, len(local))
+	for _, entry := range local {
+		known[entry.Signature] = entry
+	}
+
+	for _, remote := range backend {
+		entry, ok := known[remote.Signature]
+		delete(known, remote.Signature)
+
+		drift := PatternDrift{
+			Signature:    remote.Signature,
+			Template:     remote.Template,
+			BackendRate:  remote.SamplingRate,
+			BackendCount: remote.Count,
+		}
+		switch {
+		case !ok:
+			drift.Kind = DriftMissing
+		case math.Abs(entry.SamplingRate-remote.SamplingRate) > reconcileRateTolerance:
+			drift.Kind = DriftRateMismatch
+			drift.LocalRate, drift.LocalCount = entry.SamplingRate, entry.Count
+		default:
+			report.Matched++
+			continue
+		}
+		report.Drift = append(report.Drift, drift)
+	}
+
+	for _, entry := range known {
+		report.Drift = append(report.Drift, PatternDrift{
+			Signature:  entry.Signature,
+			Kind:       DriftNew,
+			Template:   entry.Template,
+			LocalRate:  entry.SamplingRate,
+			LocalCount: entry.Count,
+		})
+	}
+
+	order := map[string]int{DriftMissing: 0, DriftNew: 1, DriftRateMismatch: 2}
+	sort.Slice(report.Drift, func(i, j int) bool {
+		a, b := report.Drift[i], report.Drift[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		return a.Signature < b.Signature
+	})
+	return report
+}
@@ -0,0 +1,93 @@
+package lipservice
+
+// fieldsInline is how many attributes a record holds before its fields
+// spill to the heap. Like slog.Record's inline attributes, it covers most
+// calls.
+const fieldsInline = 8
+
+// field is one attribute of a record.
+type field struct {
+	key   string
+	value interface{}
+}
+
+// recordFields holds a record's attributes on the logging path in place of
+// a map, which would allocate on every call. Keys are unique: setting a
+// key again replaces its value. Records carry few attributes, so the
+// linear scans are cheaper than hashing.
+type recordFields []field
+
+// newRecordFields returns empty fields backed by buf, so a caller that
+// declares buf on its stack allocates nothing for up to fieldsInline
+// attributes.
+func newRecordFields(buf *[fieldsInline]field) recordFields {
+	return buf[:0]
+}
+
+// fieldsFromMap copies attributes into fields.
+func fieldsFromMap(attributes map[string]interface{}) recordFields {
+	if len(attributes) == 0 {
+		return nil
+	}
+	fields := make(recordFields, 0, len(attributes))
+	for key, value := range attributes {
+		fields = append(fields, field{key: key, value: value})
+	}
+	return fields
+}
+
+// set sets key to value.
+func (f *recordFields) set(key string, value interface{}) {
+	for i := range *f {
+		if (*f)[i].key == key {
+			(*f)[i].value = value
+			return
+		}
+	}
+	*f = append(*f, field{key: key, value: value})
+}
+
+// add sets each key/value in args, which holds Attrs and alternating keys
+// and values.
+func (f *recordFields) add(args []interface{}) {
+	eachAttribute(args, func(key string, value interface{}) bool {
+		f.set(key, value)
+		return true
+	})
+}
+
+// get returns key's value.
+func (f recordFields) get(key string) (interface{}, bool) {
+	for _, field := range f {
+		if field.key == key {
+			return field.value, true
+		}
+	}
+	return nil, false
+}
+
+// value returns key's value, or nil.
+func (f recordFields) value(key string) interface{} {
+	value, _ := f.get(key)
+	return value
+}
+
+// remove deletes key, keeping the order of the rest.
+func (f *recordFields) remove(key string) {
+	for i := range *f {
+		if (*f)[i].key == key {
+			*f = append((*f)[:i], (*f)[i+1:]...)
+			return
+		}
+	}
+}
+
+// toMap copies the fields into a new map, for sinks and exporters that
+// take one.
+func (f recordFields) toMap() map[string]interface{} {
+	attributes := make(map[string]interface{}, len(f))
+	for _, field := range f {
+		attributes[field.key] = field.value
+	}
+	return attributes
+}
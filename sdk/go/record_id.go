@@ -0,0 +1,47 @@
+package lipservice
+
+import (
+	"github.com/google/uuid"
+)
+
+// RecordIDAttribute carries each record's unique identifier, for dedupe,
+// dead-letter correlation and tracing a record through the backend.
+const RecordIDAttribute = "lipservice.record_id"
+
+// IDGenerator creates record identifiers. The default generates
+// time-ordered UUIDv7s; supply your own for ULIDs or snowflakes.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to an IDGenerator.
+type IDGeneratorFunc func() string
+
+// NewID calls f.
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// UUIDv7Generator returns a generator of time-ordered UUIDv7 identifiers.
+func UUIDv7Generator() IDGenerator {
+	return IDGeneratorFunc(func() string {
+		id, err := uuid.NewV7()
+		if err != nil {
+			// Only fails if the random source does; fall back to v4
+			return uuid.NewString()
+		}
+		return id.String()
+	})
+}
+
+// newIDGenerator returns config.IDGenerator, or UUIDv7Generator, or nil if
+// record IDs are disabled.
+func newIDGenerator(config Config) IDGenerator {
+	if config.DisableRecordIDs {
+		return nil
+	}
+	if config.IDGenerator != nil {
+		return config.IDGenerator
+	}
+	return UUIDv7Generator()
+}
@@ -0,0 +1,145 @@
+package lipservice
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactedPlaceholder replaces secrets matched by the denylist.
+const redactedPlaceholder = "[REDACTED]"
+
+// secretGroupName names the capture group of a custom secret pattern that
+// holds the secret itself; without one the whole match is redacted.
+const secretGroupName = "secret"
+
+// secretPattern is a denylist entry. Only the text of its secret group is
+// replaced, and valid, when set, vetoes matches that only look like
+// secrets.
+type secretPattern struct {
+	re    *regexp.Regexp
+	group int
+	valid func(submatches []string) bool
+}
+
+// defaultSecretPatterns matches common credential formats in log messages.
+var defaultSecretPatterns = []secretPattern{
+	// Key/value secrets such as password=hunter2; the key is kept for readability
+	{re: regexp.MustCompile(`(?i)\b(password|passwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret)(["']?\s*[:=]\s*["']?)([^\s"',;&]+)`), group: 3},
+	// Authorization credentials; outside a header they must look like one
+	{re: regexp.MustCompile(`(?i)\b(authorization:\s*)?(bearer|basic)\s+([A-Za-z0-9\-._~+/]+=*)`), group: 3, valid: validAuthCredential},
+	// AWS access key IDs
+	{re: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	// PostHog, Stripe, GitHub and Slack style tokens
+	{re: regexp.MustCompile(`\b(phc|phx|sk_live|sk_test|rk_live|ghp|gho|ghs|xox[abpr])_[A-Za-z0-9_-]{10,}\b`)},
+	// JSON Web Tokens
+	{re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	// PEM private keys
+	{re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+}
+
+// validAuthCredential reports whether a bearer or basic match is a
+// credential. Anything after an Authorization header is; otherwise basic
+// credentials must decode to user:password and bearer tokens must be long
+// and mix letters and digits, so "basic plan" and "bearer 42" are kept.
+func validAuthCredential(submatches []string) bool {
+	if submatches[1] != "" {
+		return true
+	}
+
+	credential := submatches[3]
+	if strings.EqualFold(submatches[2], "basic") {
+		decoded, err := base64.StdEncoding.DecodeString(credential)
+		return err == nil && strings.Contains(string(decoded), ":")
+	}
+	return len(credential) >= 16 &&
+		strings.ContainsAny(credential, "0123456789") &&
+		strings.IndexFunc(credential, func(r rune) bool { return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' }) >= 0
+}
+
+// secretRedactor removes secrets from message bodies using a regex denylist.
+type secretRedactor struct {
+	patterns []secretPattern
+	allow    []*regexp.Regexp
+}
+
+// newSecretRedactor compiles the configured secret patterns on top of the
+// defaults, or returns nil if redaction is disabled.
+func newSecretRedactor(config Config) (*secretRedactor, error) {
+	if config.DisableSecretRedaction {
+		return nil, nil
+	}
+
+	patterns := append([]secretPattern{}, defaultSecretPatterns...)
+	for _, source := range config.SecretPatterns {
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %w", source, err)
+		}
+		group := 0
+		if index := re.SubexpIndex(secretGroupName); index > 0 {
+			group = index
+		}
+		patterns = append(patterns, secretPattern{re: re, group: group})
+	}
+
+	var allow []*regexp.Regexp
+	for _, source := range config.SecretAllowlist {
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret allowlist pattern %q: %w", source, err)
+		}
+		allow = append(allow, re)
+	}
+
+	return &secretRedactor{patterns: patterns, allow: allow}, nil
+}
+
+// redact returns msg with every denylisted secret replaced, except those
+// the allowlist matches.
+func (r *secretRedactor) redact(msg string) string {
+	for _, p := range r.patterns {
+		matches := p.re.FindAllStringSubmatchIndex(msg, -1)
+		if matches == nil {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, match := range matches {
+			start, end := match[2*p.group], match[2*p.group+1]
+			if start < 0 || !r.secret(msg, p, match, msg[start:end]) {
+				continue
+			}
+			b.WriteString(msg[last:start])
+			b.WriteString(redactedPlaceholder)
+			last = end
+		}
+		if b.Len() > 0 {
+			b.WriteString(msg[last:])
+			msg = b.String()
+		}
+	}
+	return msg
+}
+
+// secret reports whether a denylist match is to be redacted.
+func (r *secretRedactor) secret(msg string, p secretPattern, match []int, secret string) bool {
+	for _, allow := range r.allow {
+		if allow.MatchString(secret) {
+			return false
+		}
+	}
+	if p.valid == nil {
+		return true
+	}
+
+	submatches := make([]string, len(match)/2)
+	for i := range submatches {
+		if match[2*i] >= 0 {
+			submatches[i] = msg[match[2*i]:match[2*i+1]]
+		}
+	}
+	return p.valid(submatches)
+}
@@ -0,0 +1,140 @@
+package lipservice
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Drop reasons recorded in a ShutdownReport.
+const (
+	DropReasonSampledOut   = "sampled_out"
+	DropReasonExportFailed = "export_failed"
+	DropReasonDuplicate    = "duplicate"
+	DropReasonResidency    = "residency"
+	DropReasonOverflow     = "overflow"
+	DropReasonMemory       = "memory_limit"
+	DropReasonClosed       = "closed"
+	DropReasonRejected     = "rejected"
+	DropReasonTenantShare  = "tenant_share"
+	DropReasonErased       = "erased"
+	DropReasonSpoolCorrupt = "spool_corrupt"
+)
+
+// ShutdownReport summarizes what happened to the records handled by a
+// LipService instance over its lifetime.
+type ShutdownReport struct {
+	// Accepted is the number of records passed to the logger
+	Accepted int64 `json:"accepted"`
+
+	// Sampled is the number of records kept by the sampler
+	Sampled int64 `json:"sampled"`
+
+	// Errors is the number of ERROR, CRITICAL and FATAL records passed to
+	// the logger
+	Errors int64 `json:"errors"`
+
+	// Exported is the number of records successfully sent to PostHog
+	Exported int64 `json:"exported"`
+
+	// Dropped counts records that were not exported, keyed by reason
+	Dropped map[string]int64 `json:"dropped"`
+
+	// Pending is the number of records still buffered and not yet exported
+	Pending int `json:"pending"`
+
+	// SpoolRemaining is the number of records left in the disk spool
+	SpoolRemaining int `json:"spool_remaining"`
+
+	// PendingBytes is the memory held by records still buffered
+	PendingBytes int64 `json:"pending_bytes"`
+
+	// CompressionRatio is uncompressed over on-the-wire bytes for delivered
+	// batches (1 when nothing was compressed)
+	CompressionRatio float64 `json:"compression_ratio"`
+
+	// BatchSize and FlushInterval are the batching in force, as chosen by
+	// AutoTuneFlush or else as configured
+	BatchSize     int           `json:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval"`
+
+	// Patterns is the number of patterns whose stats are tracked, and
+	// PatternEvictions how many were dropped by MaxPatterns or PatternTTL
+	Patterns         int   `json:"patterns"`
+	PatternEvictions int64 `json:"pattern_evictions"`
+}
+
+// String formats the report as a single log line.
+func (r ShutdownReport) String() string {
+	reasons := make([]string, 0, len(r.Dropped))
+	for reason := range r.Dropped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	dropped := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		dropped = append(dropped, fmt.Sprintf("%s=%d", reason, r.Dropped[reason]))
+	}
+
+	return fmt.Sprintf("accepted=%d sampled=%d exported=%d dropped=[%s] pending=%d spool_remaining=%d compression_ratio=%.2f",
+		r.Accepted, r.Sampled, r.Exported, strings.Join(dropped, " "), r.Pending, r.SpoolRemaining, r.CompressionRatio)
+}
+
+// deliveryStats holds the counters behind a ShutdownReport.
+type deliveryStats struct {
+	accepted atomic.Int64
+	sampled  atomic.Int64
+	exported atomic.Int64
+	errors   atomic.Int64
+	spooled  atomic.Int64
+	mu       sync.Mutex
+	dropped  map[string]int64
+
+	// Bytes of delivered batches before and after compression
+	uncompressedBytes atomic.Int64
+	compressedBytes   atomic.Int64
+}
+
+// newDeliveryStats creates an empty set of delivery counters.
+func newDeliveryStats() *deliveryStats {
+	return &deliveryStats{dropped: make(map[string]int64)}
+}
+
+// drop records n records dropped for the given reason.
+func (d *deliveryStats) drop(reason string, n int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dropped[reason] += n
+}
+
+// report builds a ShutdownReport from the current counters.
+func (d *deliveryStats) report(pending, spoolRemaining int) ShutdownReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dropped := make(map[string]int64, len(d.dropped))
+	for reason, n := range d.dropped {
+		dropped[reason] = n
+	}
+
+	ratio := 1.0
+	if compressed := d.compressedBytes.Load(); compressed > 0 {
+		ratio = float64(d.uncompressedBytes.Load()) / float64(compressed)
+	}
+
+	return ShutdownReport{
+		Accepted: d.accepted.Load(),
+		Sampled:  d.sampled.Load(),
+		Exported: d.exported.Load(),
+		Errors:   d.errors.Load(),
+		Dropped:  dropped,
+		Pending:  pending,
+
+		SpoolRemaining:   spoolRemaining,
+		CompressionRatio: ratio,
+	}
+}
@@ -0,0 +1,136 @@
+package lipservice
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// posthogRegionEndpoints maps PostHog Cloud regions to their ingestion
+// endpoints.
+var posthogRegionEndpoints = map[string]string{
+	"us": "https://us.i.posthog.com",
+	"eu": "https://eu.i.posthog.com",
+}
+
+// regionEndpoint resolves the endpoint for a region, preferring
+// configured RegionEndpoints over the PostHog Cloud defaults.
+func regionEndpoint(config Config, region string) (string, bool) {
+	if endpoint, ok := config.RegionEndpoints[region]; ok {
+		return endpoint, true
+	}
+	endpoint, ok := posthogRegionEndpoints[region]
+	return endpoint, ok
+}
+
+// regionConfig returns a copy of config that exports to the given region.
+// Each region gets its own spool so retried batches never cross regions,
+// and fails over only to the endpoints RegionFailoverEndpoints gives it;
+// FailoverEndpoints serve the home region alone.
+func regionConfig(config Config, region string) (Config, error) {
+	endpoint, ok := regionEndpoint(config, region)
+	if !ok {
+		return config, fmt.Errorf("unknown data region %q", region)
+	}
+
+	config.PostHogEndpoint = endpoint
+	if failover, ok := config.RegionFailoverEndpoints[region]; ok || region != config.DataRegion {
+		config.FailoverEndpoints = failover
+	}
+	if config.SpoolDir != "" {
+		config.SpoolDir = filepath.Join(config.SpoolDir, region)
+	}
+	return config, nil
+}
+
+// residencyRouter picks the exporter for a record based on the region named
+// in its ResidencyAttribute. Records without the attribute go to the home
+// exporter; records naming an unknown region are refused rather than sent
+// somewhere they may not be stored.
+type residencyRouter struct {
+	config    Config
+	attribute string
+	home      *PostHogExporter
+	mu        sync.Mutex
+	exporters map[string]*PostHogExporter
+}
+
+// newResidencyRouter creates a router, or returns nil if no residency
+// attribute is configured.
+func newResidencyRouter(config Config, home *PostHogExporter) *residencyRouter {
+	if config.ResidencyAttribute == "" || home == nil {
+		return nil
+	}
+
+	router := &residencyRouter{
+		config:    config,
+		attribute: config.ResidencyAttribute,
+		home:      home,
+		exporters: make(map[string]*PostHogExporter),
+	}
+	if config.DataRegion != "" {
+		router.exporters[config.DataRegion] = home
+	}
+	return router
+}
+
+// route returns the exporter for a record, given its own attributes and
+// the key/value pairs bound to its logger with With.
+func (r *residencyRouter) route(attributes recordFields, bound []interface{}) (*PostHogExporter, error) {
+	value, ok := attributes.get(r.attribute)
+	if !ok {
+		// The most recently bound value wins
+		eachAttribute(bound, func(key string, v interface{}) bool {
+			if key == r.attribute {
+				value, ok = v, true
+			}
+			return true
+		})
+	}
+	if !ok {
+		return r.home, nil
+	}
+	region := fmt.Sprintf("%v", value)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if exporter, ok := r.exporters[region]; ok {
+		return exporter, nil
+	}
+
+	config, err := regionConfig(r.config, region)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := NewPostHogExporter(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter for region %q: %w", region, err)
+	}
+
+	// Regional exporters share counters so the shutdown report covers all
+	// regions, policy enrichment and tier so every region is treated alike,
+	// and the recent-records index so Query sees every region
+	exporter.stats = r.home.stats
+	exporter.enrichment = r.home.enrichment
+	exporter.priorityFloor = r.home.priorityFloor
+	exporter.recent = r.home.recent
+	exporter.trails = r.home.trails
+	r.exporters[region] = exporter
+
+	return exporter, nil
+}
+
+// regional returns the exporters created for non-home regions.
+func (r *residencyRouter) regional() []*PostHogExporter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exporters := make([]*PostHogExporter, 0, len(r.exporters))
+	for _, exporter := range r.exporters {
+		if exporter != r.home {
+			exporters = append(exporters, exporter)
+		}
+	}
+	return exporters
+}
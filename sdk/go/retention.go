@@ -0,0 +1,62 @@
+package lipservice
+
+import "strings"
+
+// Common retention classes exported as RetentionAttribute. Any string may
+// be used, as long as downstream storage understands it.
+const (
+	Retention7Days   = "7d"
+	Retention30Days  = "30d"
+	Retention365Days = "365d"
+)
+
+// retention returns the retention class for a kept record, or "" for
+// none. An explicit RetentionAttribute on the record wins, then its
+// category's Retention, then the policy's and finally the configured
+// class for its severity, falling back to DefaultRetention.
+func (l *LipServiceLogger) retention(severity string, category *logCategory, args []interface{}) string {
+	if value, ok := lookupAttribute(RetentionAttribute, args, l.attrs); ok {
+		if class, ok := value.(string); ok && class != "" {
+			return class
+		}
+	}
+	if category != nil && category.config.Retention != "" {
+		return category.config.Retention
+	}
+	return l.sampler.retention(severity)
+}
+
+// retention returns the retention class for severity from the policy in
+// force or the configuration.
+func (s *AdaptiveSampler) retention(severity string) string {
+	s.mu.RLock()
+	policy := s.policy
+	s.mu.RUnlock()
+
+	if policy != nil {
+		if class, ok := severityRetention(policy.Retention, severity); ok {
+			return class
+		}
+	}
+	if class, ok := severityRetention(s.config.RetentionBySeverity, severity); ok {
+		return class
+	}
+	return s.config.DefaultRetention
+}
+
+// severityRetention looks severity up in classes, whose keys may be in any
+// case.
+func severityRetention(classes map[string]string, severity string) (string, bool) {
+	if len(classes) == 0 {
+		return "", false
+	}
+	if class, ok := classes[severity]; ok {
+		return class, true
+	}
+	for key, class := range classes {
+		if strings.EqualFold(key, severity) {
+			return class, true
+		}
+	}
+	return "", false
+}
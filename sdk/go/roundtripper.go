@@ -0,0 +1,154 @@
+package lipservice
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Outbound call timings added by RoundTripper when Config.HTTPTrace is set,
+// in milliseconds. Phases that didn't happen, such as DNS on a reused
+// connection, are omitted.
+const (
+	HTTPDNSAttribute        = "http.dns_ms"
+	HTTPConnectAttribute    = "http.connect_ms"
+	HTTPTLSAttribute        = "http.tls_ms"
+	HTTPFirstByteAttribute  = "http.ttfb_ms"
+	HTTPConnReusedAttribute = "http.conn_reused"
+)
+
+// RoundTripper wraps an HTTP transport so that it logs one record per
+// outbound call, failed calls and 5xx responses as errors. A nil next uses
+// http.DefaultTransport. Calls made with a request context from Middleware
+// are counted in that request's summary.
+func (ls *LipService) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &loggingRoundTripper{ls: ls, next: next}
+}
+
+// loggingRoundTripper logs outbound calls made through next.
+type loggingRoundTripper struct {
+	ls   *LipService
+	next http.RoundTripper
+}
+
+// RoundTrip sends the request and logs its outcome.
+func (t *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	// Timings are cheap to collect, and only reach PostHog on the call
+	// logs the sampler keeps
+	var timings *callTimings
+	if t.ls.config.HTTPTrace {
+		timings = &callTimings{start: start}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), timings.trace()))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+
+	logger := LoggerFromContext(req.Context())
+	if logger == nil {
+		logger = t.ls.Logger()
+	}
+
+	args := []interface{}{
+		"method", req.Method,
+		"host", req.URL.Host,
+		"path", req.URL.Path,
+		"duration_ms", time.Since(start).Milliseconds(),
+	}
+	args = append(args, timings.attributes()...)
+
+	if err != nil {
+		logger.Error("HTTP call failed", append(args, "error", err.Error())...)
+		return resp, err
+	}
+
+	args = append(args, "status", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		logger.Error("HTTP call completed", args...)
+	} else {
+		logger.Info("HTTP call completed", args...)
+	}
+	return resp, nil
+}
+
+// callTimings records when each phase of an outbound call first happened.
+// Trace hooks can run on dialing goroutines, hence the lock.
+type callTimings struct {
+	start time.Time
+
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+// trace returns the hooks that fill in the timings.
+func (c *callTimings) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { c.mark(&c.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { c.mark(&c.dnsDone) },
+		ConnectStart: func(network, addr string) {
+			c.mark(&c.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				c.mark(&c.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { c.mark(&c.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				c.mark(&c.tlsDone)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.mu.Lock()
+			c.reused = info.Reused
+			c.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { c.mark(&c.firstByte) },
+	}
+}
+
+// mark sets *at to now unless it is already set. Dialers racing several
+// addresses report a phase more than once; the first one counts.
+func (c *callTimings) mark(at *time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// attributes returns the timings as log arguments. A nil c has none.
+func (c *callTimings) attributes() []interface{} {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	args := []interface{}{HTTPConnReusedAttribute, c.reused}
+	phase := func(key string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			args = append(args, key, float64(to.Sub(from).Microseconds())/1000)
+		}
+	}
+	phase(HTTPDNSAttribute, c.dnsStart, c.dnsDone)
+	phase(HTTPConnectAttribute, c.connectStart, c.connectDone)
+	phase(HTTPTLSAttribute, c.tlsStart, c.tlsDone)
+	phase(HTTPFirstByteAttribute, c.start, c.firstByte)
+	return args
+}
@@ -1,18 +1,17 @@
 package lipservice
 
 import (
+	"container/list"
 	"context"
-	"crypto/md5"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
-	"regexp"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
-	"go.opentelemetry.io/otel/log"
-	"go.opentelemetry.io/otel/sdk/log"
+	"golang.org/x/sync/errgroup"
 )
 
 // Config holds the configuration for LipService.
@@ -41,11 +40,468 @@ type Config struct {
 	// FlushInterval is the interval between batch flushes
 	FlushInterval time.Duration
 
+	// AutoTuneFlush adjusts FlushInterval and BatchSize to the observed
+	// ingress rate: short intervals and small batches at low volume, long
+	// intervals and large batches at high volume. The values in force are
+	// reported by Report
+	AutoTuneFlush bool
+
+	// MinFlushInterval and MaxFlushInterval bound the tuned flush interval
+	// (default to a fifth and six times FlushInterval)
+	MinFlushInterval time.Duration
+	MaxFlushInterval time.Duration
+
+	// MinBatchSize and MaxBatchSize bound the tuned batch size (default to
+	// a tenth and ten times BatchSize)
+	MinBatchSize int
+	MaxBatchSize int
+
+	// FlushOnSeverity flushes the batch as soon as a record at or above
+	// this severity is added, e.g. "ERROR", instead of waiting up to
+	// FlushInterval (default: off)
+	FlushOnSeverity string
+
 	// MaxRetries is the maximum number of retry attempts
 	MaxRetries int
 
 	// Timeout is the timeout for HTTP requests
 	Timeout time.Duration
+
+	// DebugSampling logs every record locally, kept or not, annotated with
+	// its sampling decision, signature, applied rate and policy ID
+	DebugSampling bool
+
+	// DebugTrail records a breadcrumb trail (sampling decision, sinks,
+	// batch ID, HTTP status) for each record tagged with TraceAttribute,
+	// retrievable with Trail and TrailHandler
+	DebugTrail bool
+
+	// Tier selects a built-in profile (critical, standard or batch) setting
+	// severity rates, a per-minute budget and export priorities. A backend
+	// policy naming a tier takes precedence
+	Tier string
+
+	// CollectorMetadata adds the sampling rate, adjusted count and policy ID
+	// to every exported record, and marks exports with LipService headers,
+	// so sampling information survives an intermediate OTel Collector
+	CollectorMetadata bool
+
+	// SLO is a service level objective measured from the log stream; while
+	// its error budget burns too fast, sampling is raised for debugging
+	SLO SLOTarget
+
+	// WarmupDuration raises sampling for this long after startup, when
+	// deploys tend to break things, tagging records with warmup=true
+	// (0 disables warmup)
+	WarmupDuration time.Duration
+
+	// WarmupMultiplier is how much warmup raises sampling at first,
+	// decaying to 1 by the end of WarmupDuration (defaults to 5)
+	WarmupMultiplier float64
+
+	// Canary marks this instance as a canary, sampled at CanaryRate. It is
+	// also detected from LIPSERVICE_CANARY, DEPLOYMENT_TRACK=canary or a
+	// deployment.track=canary entry in OTEL_RESOURCE_ATTRIBUTES.
+	Canary bool
+
+	// CanaryRate replaces the flat sampling rate on canary instances, so
+	// new releases get richer telemetry than the stable fleet. Pattern,
+	// severity and tier rates still apply (defaults to 0.5; a policy's
+	// canary_rate overrides it)
+	CanaryRate float64
+
+	// TenantAttribute names the attribute identifying a tenant or customer.
+	// When set, no tenant may take more than TenantMaxShare of the records
+	// kept each minute, so one noisy customer can't hide issues for others
+	TenantAttribute string
+
+	// TenantMaxShare is the largest share of kept records one tenant may
+	// take (default: 0.25)
+	TenantMaxShare float64
+
+	// ContextRecords exports up to this many dropped records along with
+	// each sampled error, when they share one of ContextKeys, the same
+	// Middleware request or the same pattern signature, so responders see
+	// the lead-up (0 disables)
+	ContextRecords int
+
+	// ContextKeys are the attributes linking dropped records to an error
+	// (defaults to trace_id and request_id)
+	ContextKeys []string
+
+	// RecentRecords keeps the last N exported records in memory for Query
+	// (0 disables the index)
+	RecentRecords int
+
+	// SamplerLatencyBudget is the maximum average ShouldSample latency before
+	// the sampler degrades to severity-only decisions (0 disables the guard)
+	SamplerLatencyBudget time.Duration
+
+	// LoadShedding lowers sampling rates, and under heavy pressure skips
+	// signature work, while runtime metrics show CPU saturation or GC
+	// thrashing (not available in Serverless mode)
+	LoadShedding bool
+
+	// DeterministicSampling makes keep/drop a function of (signature, time
+	// bucket, rate) so all replicas keep the same subset of a pattern
+	DeterministicSampling bool
+
+	// DeterministicWindow is the time bucket width for deterministic
+	// sampling (defaults to 10s)
+	DeterministicWindow time.Duration
+
+	// Sampler makes the final keep/drop decision for each record
+	// (defaults to RandomSampler, or DeterministicSampler when
+	// DeterministicSampling is set)
+	Sampler Sampler
+
+	// ImportanceScoring boosts the keep rate of records an on-device
+	// model scores as important (failures, security events, rare patterns)
+	ImportanceScoring bool
+
+	// ImportanceModel overrides the default importance model weights
+	ImportanceModel *ImportanceModel
+
+	// SimilarityGrouping folds near-duplicate messages that differ in a
+	// few tokens (such as user names) into one pattern
+	SimilarityGrouping bool
+
+	// SimilarityThreshold is the fraction of tokens two messages must share
+	// position-for-position to be grouped (defaults to 0.7)
+	SimilarityThreshold float64
+
+	// SignatureEngine computes pattern signatures, so its tokenizer and
+	// cache can be tuned (defaults to precompiled regexes without a cache)
+	SignatureEngine *SignatureEngine
+
+	// SignatureHasher hashes normalized messages into pattern signatures
+	// (defaults to FNVHasher; MD5Hasher matches the backend's per-pattern
+	// rates). With SignatureEngine set, its own Hasher is used instead
+	SignatureHasher SignatureHasher
+
+	// MultiLanguage folds accents and non-ASCII digits before computing
+	// signatures and tags exported records with their detected language
+	MultiLanguage bool
+
+	// FreeText replaces free-text segments of messages, quoted strings and
+	// runs of non-Latin words, before computing signatures: FreeTextStrip
+	// uses one placeholder for all, FreeTextBucket one per language
+	// (defaults to off)
+	FreeText string
+
+	// CoordinationEnabled reports local volume to the backend and applies
+	// the instance-specific rate multiplier it returns
+	CoordinationEnabled bool
+
+	// InstanceID identifies this instance for coordination (defaults to
+	// hostname-pid)
+	InstanceID string
+
+	// CoordinationInterval is the interval between volume reports
+	// (defaults to 1m)
+	CoordinationInterval time.Duration
+
+	// LogLevel is the minimum severity emitted locally and sampled, from
+	// TRACE to ERROR (defaults to LIPSERVICE_LOG_LEVEL, or everything).
+	// It can be changed at runtime with SetLogLevel.
+	LogLevel string
+
+	// LevelSignals lets operators adjust LogLevel on a running process:
+	// SIGUSR1 makes it one step more verbose, SIGUSR2 one step less (not
+	// available on Windows)
+	LevelSignals bool
+
+	// MetricsEvents periodically sends aggregate SDK metrics (volume per
+	// pattern, savings, error counts) to PostHog as events, for teams
+	// without Prometheus
+	MetricsEvents bool
+
+	// MetricsInterval is the interval between metrics events (defaults to
+	// 5m)
+	MetricsInterval time.Duration
+
+	// PolicyTTL is how long the sampling policy is trusted without a
+	// successful fetch before it counts as stale (defaults to 15m)
+	PolicyTTL time.Duration
+
+	// OnPolicyStale is called with the policy's age when it outlives
+	// PolicyTTL (stale is true), and again once a fetch succeeds, so
+	// operators know when the SDK is sampling on an outdated policy
+	OnPolicyStale func(age time.Duration, stale bool)
+
+	// OnPressure is called with the current Pressure when it reaches
+	// PressureThreshold (high is true), and again once it has fallen back
+	// well below it, so applications can shed their own debug logging
+	OnPressure func(pressure float64, high bool)
+
+	// PressureThreshold is the Pressure at which OnPressure fires
+	// (defaults to 0.8)
+	PressureThreshold float64
+
+	// Serverless disables background goroutines; callers flush and refresh
+	// state with FlushOnInvocationEnd at the end of each invocation
+	Serverless bool
+
+	// Synchronous exports every sampled record before the log call
+	// returns, with no batching and no background goroutines, so CLIs and
+	// tests behave deterministically and never lose their final records.
+	// As in Serverless mode, sampler state is only refreshed by
+	// FlushOnInvocationEnd.
+	Synchronous bool
+
+	// Offline guarantees no network calls: no policy fetches, pattern
+	// reports, coordination or PostHog export. Sampling, local logging and
+	// local sinks work as usual, for CI and air-gapped development
+	Offline bool
+
+	// StateFile is where learned sampler state is checkpointed so it
+	// survives restarts (empty disables checkpointing). A file that can't
+	// be restored is renamed with a .corrupt suffix and the sampler starts
+	// cold
+	StateFile string
+
+	// PatternDictionary is a file written by ExportPatternDictionary whose
+	// patterns seed the sampler at startup, e.g. to carry rates learned in
+	// staging into production (empty disables seeding)
+	PatternDictionary string
+
+	// CheckpointInterval is the interval between state checkpoints
+	// (defaults to 1m)
+	CheckpointInterval time.Duration
+
+	// SpoolDir is where batches that fail to export are kept for retry
+	// (empty disables the disk spool). Processes may share a SpoolDir.
+	SpoolDir string
+
+	// DeadLetterQueue receives records PostHog rejected as invalid and
+	// records that exhausted their retries without a spool to fall back on
+	DeadLetterQueue DeadLetterQueue
+
+	// DeadLetterPath opens a FileDeadLetterQueue at this path when
+	// DeadLetterQueue is not set (empty discards undeliverable records)
+	DeadLetterPath string
+
+	// IDGenerator creates the lipservice.record_id attribute given to every
+	// record (defaults to UUIDv7)
+	IDGenerator IDGenerator
+
+	// DisableRecordIDs stops records being given a lipservice.record_id
+	DisableRecordIDs bool
+
+	// MaxAttributeKeys is the number of distinct attribute keys exported
+	// per AttributeKeyWindow before new keys are hashed into overflow
+	// buckets (defaults to 256)
+	MaxAttributeKeys int
+
+	// AttributeKeyWindow is how long the MaxAttributeKeys cap applies
+	// before it starts over; the keys that overflowed in a window are
+	// reported as a diagnostic when it ends (defaults to 1h)
+	AttributeKeyWindow time.Duration
+
+	// AttributeValueLimits keeps the K most frequent values of each named
+	// attribute verbatim, e.g. {"url": 100}, and hashes the rest into 16
+	// lipservice.tail_N buckets so downstream cardinality stays bounded
+	AttributeValueLimits map[string]int
+
+	// MaxMessageBytes is the longest message body exported; longer ones
+	// are truncated and annotated with their original length (defaults to
+	// 16 KiB)
+	MaxMessageBytes int
+
+	// KeepFullMessages sends untruncated bodies to sinks other than
+	// PostHog, such as a FileSink, so the full text is stored somewhere
+	KeepFullMessages bool
+
+	// ExportSocket is a unix domain socket that export connections are
+	// dialed to, e.g. a local collector (PostHogEndpoint still supplies the
+	// URL, such as http://localhost)
+	ExportSocket string
+
+	// DialContext dials export connections in place of a direct TCP dial,
+	// e.g. through an SSH tunnel or a service mesh sidecar. ExportSocket
+	// takes precedence.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Transport carries export requests in place of the one built from the
+	// knobs below, e.g. from NewExportTransport to share a pool across
+	// LipService instances. ExportSocket and DialContext are then ignored.
+	Transport http.RoundTripper
+
+	// MaxIdleConnsPerHost is the number of idle export connections kept
+	// open for reuse (defaults to 32)
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout closes idle export connections after this long
+	// (defaults to 90s)
+	IdleConnTimeout time.Duration
+
+	// KeepAlive is the TCP keep-alive period for export connections
+	// (defaults to 30s; negative disables keep-alives)
+	KeepAlive time.Duration
+
+	// DisableHTTP2 keeps exports on HTTP/1.1, for proxies that mishandle
+	// HTTP/2
+	DisableHTTP2 bool
+
+	// Sinks are named destinations, besides PostHog, that ExportRoutes can
+	// send records to. The caller owns them and closes them after Close.
+	Sinks map[string]LogSink
+
+	// Exporters receive every kept record routed to PostHog, each batched
+	// and flushed independently so one slow or failing destination
+	// doesn't hold up the others. They are shut down by Close
+	Exporters []Exporter
+
+	// FailoverEndpoints are OTLP/HTTP endpoints, such as a collector,
+	// tried in order while PostHog is failing. Each is health-checked
+	// and exports fall back to PostHog once it recovers.
+	FailoverEndpoints []ExportEndpoint
+
+	// HTTPTrace adds DNS, connect, TLS and time-to-first-byte timings to
+	// the call logs written by RoundTripper
+	HTTPTrace bool
+
+	// ExportRoutes select sinks by severity and message pattern; records
+	// matching no route go to PostHog
+	ExportRoutes []ExportRoute
+
+	// Categories configure the budget, sinks and retention hint of each
+	// log category used with LipServiceLogger.Category
+	Categories map[string]CategoryConfig
+
+	// MaxMemoryBytes caps the memory each exporter holds in buffered
+	// records (0 is unlimited)
+	MaxMemoryBytes int64
+
+	// MemoryOverflowPolicy is what happens at MaxMemoryBytes:
+	// MemoryPolicySpill (the default) spools the buffer to SpoolDir,
+	// MemoryPolicyDrop drops the lowest-priority records
+	MemoryOverflowPolicy string
+
+	// MaxClockSkew replaces event times that are unset, before 2000, or more
+	// than this far in the future with the observed time, recording the
+	// original in lipservice.original_time (0 disables correction)
+	MaxClockSkew time.Duration
+
+	// Compression is the batch codec: "identity", "gzip", "zstd", or "auto"
+	// (the default) to measure identity and gzip on real batches and pick
+	// the best ratio within CompressionCPUBudget. Only pick zstd when the
+	// receiver is known to accept it
+	Compression string
+
+	// CompressionCPUBudget is the most time auto-selection may spend
+	// compressing one MiB of batch data (defaults to 20ms)
+	CompressionCPUBudget time.Duration
+
+	// DedupWindow suppresses records identical in severity, message,
+	// category and attributes seen within this window, logging one copy
+	// with RepeatCountAttribute when it closes (0 disables deduplication)
+	DedupWindow time.Duration
+
+	// DiagnosticsLevel is the lowest severity of the SDK's own diagnostics,
+	// such as failed exports, that is reported (defaults to WARN)
+	DiagnosticsLevel string
+
+	// DiagnosticsInterval is how often one diagnostic may repeat; repeats
+	// within it are counted and reported with the next one (defaults to
+	// one minute)
+	DiagnosticsInterval time.Duration
+
+	// DiagnosticsSink receives the SDK's diagnostics in place of the base
+	// logger, keeping them apart from application logs
+	DiagnosticsSink LogSink
+
+	// SecretPatterns are additional regular expressions whose matches are
+	// redacted from message bodies before they are logged or exported. A
+	// pattern with a group named "secret" redacts only that group
+	SecretPatterns []string
+
+	// SecretAllowlist holds regular expressions for known non-secrets, such
+	// as documented example keys; a secret any of them matches is kept
+	SecretAllowlist []string
+
+	// DisableSecretRedaction turns off the secret denylist, including the
+	// built-in patterns
+	DisableSecretRedaction bool
+
+	// EncryptedAttributes lists attribute keys whose values are encrypted
+	// with AttributeEncryptionKey or AttributeKeyProvider before export
+	EncryptedAttributes []string
+
+	// AttributeEncryptionKey is the AES key (16, 24 or 32 bytes) used for
+	// EncryptedAttributes
+	AttributeEncryptionKey []byte
+
+	// AttributeKeyProvider wraps a per-process data key for envelope
+	// encryption of EncryptedAttributes, in place of AttributeEncryptionKey
+	AttributeKeyProvider KeyProvider
+
+	// PseudonymizedAttributes lists attribute keys whose values are
+	// replaced by a keyed HMAC before export, so records stay correlated
+	// by e.g. user without exposing the raw identifier
+	PseudonymizedAttributes []string
+
+	// PseudonymizationKey is the secret HMAC key (at least 16 bytes) used
+	// for PseudonymizedAttributes
+	PseudonymizationKey []byte
+
+	// AuditLogPath is a JSON-lines file that records every sampling policy
+	// change applied by the SDK, hash-chained for tamper evidence
+	AuditLogPath string
+
+	// AuditHook is called with every policy change audit event
+	AuditHook func(PolicyAuditEvent)
+
+	// DataRegion is the home region logs are stored in ("us" or "eu" for
+	// PostHog Cloud, or a key of RegionEndpoints). When set it selects the
+	// export endpoint in place of PostHogEndpoint.
+	DataRegion string
+
+	// RegionEndpoints maps additional region names to export endpoints
+	RegionEndpoints map[string]string
+
+	// RegionFailoverEndpoints maps region names to the failover endpoints
+	// their records may be sent to. FailoverEndpoints serve DataRegion
+	// only, so other regions don't fail over unless listed here
+	RegionFailoverEndpoints map[string][]ExportEndpoint
+
+	// ResidencyAttribute names the attribute whose value selects the region
+	// a record is exported to; records without it go to DataRegion
+	ResidencyAttribute string
+
+	// PatternOwners assign records to owning teams by module or message
+	// prefix; kept records carry their team as OwnerAttribute
+	PatternOwners []PatternOwner
+
+	// PatternOwnersFile is a JSON file of further PatternOwners, e.g.
+	// generated from CODEOWNERS (empty disables it)
+	PatternOwnersFile string
+
+	// RetentionBySeverity maps severities to retention classes, such as
+	// "ERROR": "365d", exported as lipservice.retention for downstream
+	// storage policies
+	RetentionBySeverity map[string]string
+
+	// DefaultRetention is the retention class for records no category,
+	// policy or RetentionBySeverity entry covers (empty exports none)
+	DefaultRetention string
+
+	// MaxPatterns caps the pattern stats kept in memory; the least
+	// recently seen are evicted first, except those whose rate the policy
+	// sets (defaults to 10000, negative is unlimited)
+	MaxPatterns int
+
+	// PatternTTL drops the stats of patterns unseen for this long
+	// (defaults to 24h, negative keeps them forever)
+	PatternTTL time.Duration
+
+	// SpanEvents records logs dropped by sampling as events on the active
+	// span of the context they were logged with, so trace views keep the
+	// narrative
+	SpanEvents bool
+
+	// MaxSpanEvents caps the events added to one span (defaults to 32)
+	MaxSpanEvents int
 }
 
 // DefaultConfig returns a default configuration.
@@ -61,13 +517,21 @@ func DefaultConfig() Config {
 
 // LipService is the main LipService client.
 type LipService struct {
-	config        Config
-	sampler       *AdaptiveSampler
+	config          Config
+	sampler         *AdaptiveSampler
 	posthogExporter *PostHogExporter
-	logger        *LipServiceLogger
-	ctx           context.Context
-	cancel        context.CancelFunc
-	wg            sync.WaitGroup
+	fanout          []*fanoutExporter
+	logger          *LipServiceLogger
+	importer        *LipServiceLogger
+	router          *residencyRouter
+	metrics         *metricsReporter
+	pressure        *pressureMonitor
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	closeOnce       sync.Once
+	closeReport     ShutdownReport
+	closeErr        error
 }
 
 // New creates a new LipService instance.
@@ -88,6 +552,17 @@ func New(config Config) (*LipService, error) {
 	if config.Timeout == 0 {
 		config.Timeout = 10 * time.Second
 	}
+	if config.DataRegion != "" {
+		endpoint, ok := regionEndpoint(config, config.DataRegion)
+		if !ok {
+			return nil, fmt.Errorf("unknown data region %q", config.DataRegion)
+		}
+		config.PostHogEndpoint = endpoint
+	}
+
+	if err := checkOffline(config); err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -100,9 +575,42 @@ func New(config Config) (*LipService, error) {
 	// Initialize components
 	if err := ls.initialize(); err != nil {
 		cancel()
+		// Release whatever initialize got as far as opening: spool files,
+		// dead-letter files and the exporter's flush loop
+		if ls.posthogExporter != nil {
+			ls.posthogExporter.Close()
+		}
+		if ls.sampler != nil {
+			ls.sampler.Close()
+		}
 		return nil, err
 	}
 
+	if config.LevelSignals {
+		ls.watchLevelSignals()
+	}
+
+	// Metrics events are sent per invocation in Serverless mode
+	ls.metrics = newMetricsReporter(ls)
+	if ls.metrics != nil && !config.Serverless && !config.Synchronous {
+		ls.metrics.start()
+	}
+
+	// Serverless callers get their repeat counts when they flush
+	if ls.logger.deduper != nil && !config.Serverless && !config.Synchronous {
+		ls.wg.Add(1)
+		go func() {
+			defer ls.wg.Done()
+			ls.dedupLoop(ls.ctx)
+		}()
+	}
+
+	// Serverless callers are told about pressure at the end of each invocation
+	ls.pressure = newPressureMonitor(ls)
+	if ls.pressure != nil && !config.Serverless && !config.Synchronous {
+		ls.pressure.start()
+	}
+
 	return ls, nil
 }
 
@@ -116,16 +624,65 @@ func (ls *LipService) initialize() error {
 	ls.sampler = sampler
 
 	// Initialize PostHog exporter if configured
-	if ls.config.PostHogAPIKey != "" && ls.config.PostHogTeamID != "" {
-		exporter, err := NewPostHogExporter(ls.config)
+	if ls.config.PostHogAPIKey != "" && ls.config.PostHogTeamID != "" && !ls.config.Offline {
+		exporterConfig := ls.config
+		if ls.config.DataRegion != "" {
+			exporterConfig, _ = regionConfig(ls.config, ls.config.DataRegion)
+		}
+
+		exporter, err := NewPostHogExporter(exporterConfig)
 		if err != nil {
 			return fmt.Errorf("failed to create PostHog exporter: %w", err)
 		}
 		ls.posthogExporter = exporter
 	}
 
+	if err := checkDiagnosticsLevel(ls.config); err != nil {
+		return err
+	}
+
+	redactor, err := newSecretRedactor(ls.config)
+	if err != nil {
+		return fmt.Errorf("failed to compile secret patterns: %w", err)
+	}
+
+	// Policy attributes and tiers apply to every export, including regional
+	// exporters which share the home exporter's enrichment and priority floor
+	if ls.posthogExporter != nil {
+		ls.sampler.onPolicyChange(ls.posthogExporter.enrichment.apply)
+		ls.sampler.onPolicyChange(ls.posthogExporter.applyTier)
+	}
+
+	ls.router = newResidencyRouter(ls.config, ls.posthogExporter)
+
+	routes, err := newExportRouter(ls.config)
+	if err != nil {
+		return fmt.Errorf("failed to compile export routes: %w", err)
+	}
+
+	categories, err := newLogCategories(ls.config)
+	if err != nil {
+		return fmt.Errorf("failed to configure log categories: %w", err)
+	}
+
 	// Initialize logger
 	ls.logger = NewLipServiceLogger(ls.sampler, ls.posthogExporter)
+	ls.logger.redactor = redactor
+	ls.logger.router = ls.router
+	ls.logger.routes = routes
+	ls.logger.categories = categories
+	if ls.logger.privacy == nil {
+		privacy, err := newAttributePrivacy(ls.config, ls.logger.diag)
+		if err != nil {
+			return err
+		}
+		ls.logger.privacy = privacy
+	}
+
+	// Extra exporters batch and fail independently of PostHog
+	ls.fanout = newFanout(ls.config, ls.logger.diag)
+	ls.logger.fanout = ls.fanout
+	ls.importer = ls.logger.importer()
 
 	return nil
 }
@@ -135,173 +692,606 @@ func (ls *LipService) Logger() *LipServiceLogger {
 	return ls.logger
 }
 
-// Close shuts down the LipService instance.
-func (ls *LipService) Close() error {
-	ls.cancel()
-	ls.wg.Wait()
+// Sampler returns the adaptive sampler, for inspecting its health.
+func (ls *LipService) Sampler() *AdaptiveSampler {
+	return ls.sampler
+}
+
+// exporters returns every active exporter, home region first.
+func (ls *LipService) exporters() []*PostHogExporter {
+	if ls.posthogExporter == nil {
+		return nil
+	}
 
+	exporters := []*PostHogExporter{ls.posthogExporter}
+	if ls.router != nil {
+		exporters = append(exporters, ls.router.regional()...)
+	}
+	return exporters
+}
+
+// Flush immediately exports any buffered logs, including the repeat
+// counts of records suppressed by deduplication so far.
+func (ls *LipService) Flush() error {
+	logRepeats(ls.logger.deduper.drain())
+
+	var err error
+	for _, exporter := range ls.exporters() {
+		if ferr := exporter.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	if ferr := ls.flushFanout(context.Background()); ferr != nil && err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// Report returns a summary of records accepted, sampled, exported and
+// dropped so far.
+func (ls *LipService) Report() ShutdownReport {
+	pending, spooled := 0, 0
+	var pendingBytes int64
+	for _, exporter := range ls.exporters() {
+		pending += exporter.Pending()
+		spooled += exporter.Spooled()
+		pendingBytes += exporter.PendingBytes()
+	}
+
+	report := ls.logger.stats.report(pending, spooled)
+	report.PendingBytes = pendingBytes
+	report.Patterns, report.PatternEvictions = ls.sampler.patternCounts()
 	if ls.posthogExporter != nil {
-		return ls.posthogExporter.Close()
+		report.BatchSize = ls.posthogExporter.batchLimit()
+		report.FlushInterval = ls.posthogExporter.flushInterval()
 	}
+	return report
+}
 
-	return nil
+// Close shuts down the LipService instance.
+func (ls *LipService) Close() error {
+	_, err := ls.CloseWithReport()
+	return err
+}
+
+// CloseWithReport shuts down the LipService instance and returns a summary
+// of what happened to the records it handled. The summary is also reported
+// to the diagnostics at INFO.
+// Later calls return the same summary without shutting down again.
+func (ls *LipService) CloseWithReport() (ShutdownReport, error) {
+	ls.closeOnce.Do(func() {
+		ls.cancel()
+		ls.wg.Wait()
+		logRepeats(ls.logger.deduper.drain())
+
+		var err error
+		for _, exporter := range ls.exporters() {
+			if cerr := exporter.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		for _, f := range ls.fanout {
+			if serr := f.shutdown(context.Background()); serr != nil && err == nil {
+				err = serr
+			}
+		}
+		ls.metrics.final()
+
+		if cerr := ls.sampler.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		ls.closeReport = ls.Report()
+		ls.closeErr = err
+		ls.sampler.diag.log(slog.Default(), "INFO", "Shutdown report", "service", ls.config.ServiceName, "report", ls.closeReport.String())
+	})
+
+	return ls.closeReport, ls.closeErr
 }
 
+// Background task intervals for the adaptive sampler.
+const (
+	policyRefreshInterval = 5 * time.Minute
+	patternReportInterval = 10 * time.Minute
+)
+
 // AdaptiveSampler handles intelligent log sampling.
 type AdaptiveSampler struct {
-	config        Config
-	client        *http.Client
-	policy        *SamplingPolicy
-	patternStats  map[string]*PatternStats
-	mu            sync.RWMutex
+	config           Config
+	client           *http.Client
+	diag             *diagnostics
+	policy           *SamplingPolicy
+	patternStats     map[string]*PatternStats
+	patternOrder     *list.List
+	patternEvictions atomic.Int64
+	lastPatternSweep time.Time
+	mu               sync.RWMutex
 	lastPolicyUpdate time.Time
+	policyETag       string
+	// hashMismatch is the policy signature hash last warned about
+	hashMismatch      string
+	lastPatternReport time.Time
+	tallies           map[string]*patternTally
+	lastCheckpoint    time.Time
+	guard             *latencyGuard
+	coordinator       *coordinator
+	shedder           *loadShedder
+	auditor           *policyAuditor
+	capabilities      *BackendCapabilities
+	policyFetch       policyFetchState
+	engine            Sampler
+	grouper           *similarityGrouper
+	signer            *SignatureEngine
+	policyHooks       []func(*SamplingPolicy)
+	budget            tierBudget
+	slo               *sloTracker
+	warmup            *warmup
+	canary            bool
+	level             *logLevel
+	incident          atomic.Bool
+	pins              map[string]PatternPin
+	fairness          *fairnessTracker
+	events            *patternEvents
+	owners            *patternOwners
+	signatures        bool
+
+	// Background tasks share one lifecycle: ctx is cancelled by Close or
+	// by a failing task, and group waited on by Close
+	ctx       context.Context
+	cancel    context.CancelFunc
+	group     *errgroup.Group
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // SamplingPolicy represents a sampling policy from LipService backend.
 type SamplingPolicy struct {
-	PolicyID        string            `json:"policy_id"`
-	SamplingRate    float64           `json:"sampling_rate"`
-	Patterns        []string          `json:"patterns"`
-	MaxLogsPerMinute int              `json:"max_logs_per_minute"`
-	SeverityRates   map[string]float64 `json:"severity_rates"`
+	PolicyID     string  `json:"policy_id"`
+	SamplingRate float64 `json:"sampling_rate"`
+	// ZeroRate marks a SamplingRate of 0 as set on purpose, so records no
+	// other rule covers are dropped rather than given the default rate
+	ZeroRate         bool               `json:"zero_rate,omitempty"`
+	Patterns         []string           `json:"patterns"`
+	MaxLogsPerMinute int                `json:"max_logs_per_minute"`
+	SeverityRates    map[string]float64 `json:"severity_rates"`
+	// Attributes are static resource attributes added to every export,
+	// e.g. cost_center or team, so they can be managed centrally
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Tier selects a built-in profile whose severity rates and budget this
+	// policy's own values override
+	Tier string `json:"tier,omitempty"`
+	// CanaryRate replaces Config.CanaryRate while this policy is in force
+	CanaryRate float64 `json:"canary_rate,omitempty"`
+	// SLO replaces Config.SLO while this policy is in force
+	SLO *SLOTarget `json:"slo,omitempty"`
+	// PatternRates are rates the backend assigned to pattern signatures;
+	// they replace the previous policy's rates when the policy is fetched
+	PatternRates map[string]float64 `json:"pattern_rates,omitempty"`
+	// Owners replace Config.PatternOwners while this policy is in force
+	Owners []PatternOwner `json:"owners,omitempty"`
+	// Retention maps severities to retention classes, taking precedence
+	// over Config.RetentionBySeverity
+	Retention map[string]string `json:"retention,omitempty"`
+	// SignatureHash names the hash PatternRates are keyed by, e.g. "md5";
+	// rates keyed by a different hash than this instance's are ignored
+	SignatureHash string `json:"signature_hash,omitempty"`
 }
 
 // PatternStats tracks statistics for log patterns.
 type PatternStats struct {
-	Count       int       `json:"count"`
-	LastSeen    time.Time `json:"last_seen"`
-	Signature   string    `json:"signature"`
-	SamplingRate float64  `json:"sampling_rate"`
+	Count        int       `json:"count"`
+	LastSeen     time.Time `json:"last_seen"`
+	Signature    string    `json:"signature"`
+	SamplingRate float64   `json:"sampling_rate"`
+
+	// Template and Example are the pattern's normalized and example
+	// messages, kept for PatternDictionary exports
+	Template string `json:"template,omitempty"`
+	Example  string `json:"example,omitempty"`
+
+	// buckets holds the last hour of per-minute counts
+	buckets minuteBuckets
+
+	// element is the pattern's place in the sampler's recency order
+	element *list.Element
 }
 
 // NewAdaptiveSampler creates a new adaptive sampler.
 func NewAdaptiveSampler(config Config) (*AdaptiveSampler, error) {
+	if _, ok := tierProfile(config.Tier); config.Tier != "" && !ok {
+		return nil, fmt.Errorf("unknown service tier %q", config.Tier)
+	}
+	if config.FreeText != "" && config.FreeText != FreeTextStrip && config.FreeText != FreeTextBucket {
+		return nil, fmt.Errorf("unknown free-text mode %q", config.FreeText)
+	}
+
+	level, err := newLogLevel(config)
+	if err != nil {
+		return nil, err
+	}
+
 	client := &http.Client{
 		Timeout: config.Timeout,
 	}
 
+	parent, cancel := context.WithCancel(context.Background())
+	group, ctx := errgroup.WithContext(parent)
+
+	diag := newDiagnostics(config)
 	sampler := &AdaptiveSampler{
+		ctx:          ctx,
+		diag:         diag,
+		cancel:       cancel,
+		group:        group,
 		config:       config,
 		client:       client,
 		patternStats: make(map[string]*PatternStats),
+		patternOrder: list.New(),
+		guard:        newLatencyGuard(config.SamplerLatencyBudget, diag),
+		coordinator:  newCoordinator(config),
+		shedder:      newLoadShedder(config, diag),
+		engine:       newSampler(config),
+		grouper:      newSimilarityGrouper(config),
+		signer:       signatureEngine(config),
+		signatures:   needsSignatures(config),
+		slo:          newSLOTracker(config, diag),
+		warmup:       newWarmup(config, time.Now()),
+		canary:       isCanary(config),
+		level:        level,
+		fairness:     newFairnessTracker(config),
+		events:       newPatternEvents(),
+	}
+	if reportsPatterns(config) {
+		sampler.tallies = make(map[string]*patternTally)
+	}
+	if !config.Offline && config.LipServiceURL != "" {
+		sampler.policyFetch.ttl, sampler.policyFetch.started = policyTTL(config), time.Now()
+	}
+
+	owners, err := newPatternOwners(config, diag)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
+	sampler.owners = owners
 
-	// Start background tasks
-	go sampler.policyRefreshLoop()
-	go sampler.patternReportLoop()
+	auditor, err := newPolicyAuditor(config)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open policy audit log: %w", err)
+	}
+	sampler.auditor = auditor
+
+	if err := sampler.restore(); err != nil {
+		sampler.Close()
+		return nil, fmt.Errorf("failed to restore sampler state: %w", err)
+	}
+	if config.PatternDictionary != "" {
+		if _, err := sampler.importPatternFile(config.PatternDictionary); err != nil {
+			sampler.Close()
+			return nil, err
+		}
+	}
+
+	// Background tickers don't run reliably in frozen serverless
+	// environments, so state is refreshed per invocation instead
+	if config.Serverless || config.Synchronous {
+		return sampler, nil
+	}
+
+	// Start background tasks; offline samplers never talk to the backend
+	if !config.Offline {
+		sampler.start(func(ctx context.Context) {
+			if err := sampler.negotiate(); err != nil && ctx.Err() == nil {
+				sampler.diag.log(slog.Default(), "WARN", "Capability negotiation failed", "error", err)
+			}
+		})
+		sampler.start(sampler.policyRefreshLoop)
+		sampler.start(sampler.patternReportLoop)
+	}
+	sampler.start(sampler.patternEventLoop)
+	sampler.start(sampler.patternSweepLoop)
+	if sampler.coordinator != nil {
+		sampler.start(sampler.coordinationLoop)
+	}
+	if sampler.shedder != nil {
+		sampler.start(sampler.loadShedLoop)
+	}
+	if config.StateFile != "" {
+		sampler.start(sampler.checkpointLoop)
+	}
 
 	return sampler, nil
 }
 
 // ShouldSample determines if a log should be sampled.
 func (s *AdaptiveSampler) ShouldSample(message, severity string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.sample(message, severity).kept
+}
+
+// sample makes a sampling decision and describes how it was reached.
+func (s *AdaptiveSampler) sample(message, severity string) samplingOutcome {
+	if s.guard != nil {
+		if s.guard.degraded() {
+			return s.shouldSampleSeverity(message, severity)
+		}
+		start := time.Now()
+		defer func() { s.guard.observe(time.Since(start)) }()
+	}
+
+	if s.coordinator != nil {
+		s.coordinator.observed.Add(1)
+	}
+
+	// Under heavy CPU pressure skip signature work entirely
+	if s.shedder != nil && s.shedder.skipSignatures() {
+		return s.shouldSampleSeverity(message, severity)
+	}
+
+	// Pattern stats are updated while preparing, so this takes the write
+	// lock; the Sampler decides without it
+	s.mu.Lock()
+	outcome, pending := s.prepareSample(message, severity)
+	s.mu.Unlock()
+	if pending == nil {
+		return outcome
+	}
+	return s.decide(pending)
+}
+
+// prepareSample settles a record that needs no sampling decision, or
+// returns the decision pending for it. Callers must hold s.mu.
+func (s *AdaptiveSampler) prepareSample(message, severity string) (samplingOutcome, *pendingDecision) {
+	// Fold localized text so free text, accents and digit scripts don't
+	// split patterns
+	message = s.localize(message)
 
-	// Always sample errors and critical logs
+	// Always sample errors and critical logs; subscribers still hear about
+	// new error patterns
 	if severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL" {
-		return true
+		return s.outcome(true, SamplingReasonSeverity, s.observeKept(message, severity), 1), nil
+	}
+
+	// Everything is kept during an incident, past budgets and samplers
+	if s.incident.Load() {
+		return s.outcome(true, SamplingReasonIncident, s.observeKept(message, severity), 1), nil
 	}
 
-	// Compute signature
-	signature := computeSignature(message)
+	// Severity-only deployments don't pay for normalization they never use
+	if s.severityOnly() {
+		rate, _ := s.baseRate("", severity)
+		return samplingOutcome{}, s.pend(message, severity, "", rate, 0, SamplingReasonDefault)
+	}
+
+	signature := s.signature(message)
+	s.events.observe(signature, message, time.Now())
+
+	// A pin set from code beats everything learned or fetched
+	if rate, ok := s.pinnedRate(signature, time.Now()); ok {
+		seen := 0
+		if stats, exists := s.patternStats[signature]; exists {
+			stats.observe(time.Now())
+			s.touchPattern(signature, stats)
+			seen = stats.Count
+		}
+		return samplingOutcome{}, s.pend(message, severity, signature, rate, seen, SamplingReasonPin)
+	}
 
 	// Check pattern stats
 	if stats, exists := s.patternStats[signature]; exists {
-		stats.Count++
-		stats.LastSeen = time.Now()
-		return s.decideSampling(stats.SamplingRate)
+		stats.observe(time.Now())
+		s.touchPattern(signature, stats)
+		if stats.Example == "" {
+			stats.describe(message)
+		}
+		return samplingOutcome{}, s.pend(message, severity, signature, stats.SamplingRate, stats.Count, SamplingReasonPattern)
 	}
 
-	// Default sampling rate
-	return s.decideSampling(0.1) // 10% default
+	rate, _ := s.baseRate(signature, severity)
+	return samplingOutcome{}, s.pend(message, severity, signature, rate, 0, SamplingReasonDefault)
 }
 
-// decideSampling makes a sampling decision based on rate.
-func (s *AdaptiveSampler) decideSampling(rate float64) bool {
-	// Simple random sampling
-	return time.Now().UnixNano()%10000 < int64(rate*10000)
+// signature computes a message's pattern signature, fingerprinting panics by
+// their top frames and folding near-duplicates into their group. Callers
+// must hold s.mu.
+func (s *AdaptiveSampler) signature(message string) string {
+	if s.grouper != nil {
+		if _, ok := parsePanic(message); !ok {
+			return s.grouper.signature(s.signer.Normalize(message))
+		}
+	}
+	return s.signer.Signature(message)
 }
 
-// policyRefreshLoop refreshes the sampling policy periodically.
-func (s *AdaptiveSampler) policyRefreshLoop() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+// start runs a background task in the sampler's task group. The task must
+// return once ctx is done. A panicking task stops the others and is
+// reported by Close rather than crashing the application.
+func (s *AdaptiveSampler) start(task func(ctx context.Context)) {
+	s.group.Go(func() error {
+		return runTask(func() { task(s.ctx) })
+	})
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			s.refreshPolicy()
+// runTask runs a background task, turning a panic into an error.
+func runTask(task func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("background task panicked: %v", r)
 		}
-	}
+	}()
+	task()
+	return nil
 }
 
-// patternReportLoop reports pattern statistics periodically.
-func (s *AdaptiveSampler) patternReportLoop() {
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
+// Close stops the background tasks, waits for them to return, then persists
+// sampler state and releases the audit log. It is safe to call more than
+// once.
+func (s *AdaptiveSampler) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		err := s.group.Wait()
 
-	for {
-		select {
-		case <-ticker.C:
-			s.reportPatterns()
+		if cerr := s.checkpoint(); cerr != nil && err == nil {
+			err = cerr
 		}
+		if s.auditor != nil {
+			if aerr := s.auditor.close(); aerr != nil && err == nil {
+				err = aerr
+			}
+		}
+		s.closeErr = err
+	})
+	return s.closeErr
+}
+
+// shouldSampleSeverity makes a sampling decision from severity alone, used
+// when the latency guard has tripped or load is being shed. The rate
+// ignores pattern rules, but the signature is still computed when the
+// sampler or debug output reads it.
+func (s *AdaptiveSampler) shouldSampleSeverity(message, severity string) samplingOutcome {
+	s.mu.Lock()
+	signature := ""
+	if s.signatures {
+		signature = s.signature(s.localize(message))
 	}
+	if severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL" {
+		defer s.mu.Unlock()
+		return s.outcome(true, SamplingReasonSeverity, signature, 1)
+	}
+
+	rate, _ := s.baseRate("", severity)
+	rate = s.adjustRate(rate, time.Now(), nil)
+	s.mu.Unlock()
+
+	decision := s.engine.ShouldSample(Record{Message: message, Severity: severity, Timestamp: time.Now(), Signature: signature, Rate: rate})
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.outcome(decision.Keep, SamplingReasonDegraded, signature, decisionRate(decision, rate))
 }
 
-// refreshPolicy fetches the latest sampling policy.
-func (s *AdaptiveSampler) refreshPolicy() {
-	// Implementation would fetch policy from LipService backend
-	// For now, use a default policy
+// keepUnsampled keeps a record the SDK logs on the caller's behalf, such as
+// a request-completion summary, without a sampling decision.
+func (s *AdaptiveSampler) keepUnsampled(reason string) samplingOutcome {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.outcome(true, reason, "", 1)
+}
+
+// readSignature returns message's signature if anything configured reads
+// it, and "" otherwise. Callers must hold s.mu.
+func (s *AdaptiveSampler) readSignature(message string) string {
+	if !s.signatures {
+		return ""
+	}
+	return s.signature(message)
+}
+
+// Degraded reports whether the sampler has exceeded its latency budget and
+// is currently making severity-only decisions.
+func (s *AdaptiveSampler) Degraded() bool {
+	return s.guard != nil && s.guard.degraded()
+}
+
+// pendingDecision is a record awaiting the Sampler's verdict. It is
+// prepared under s.mu, but the Sampler is called without it so a slow one
+// doesn't stall other records.
+type pendingDecision struct {
+	record Record
+	reason string
+}
+
+// pend applies fleet coordination to rate and prepares the record for the
+// Sampler. Callers must hold s.mu.
+func (s *AdaptiveSampler) pend(message, severity, signature string, rate float64, seen int, reason string) *pendingDecision {
+	// Pinned rates are used exactly as set
+	if reason != SamplingReasonPin {
+		rate = s.adjustRate(rate, time.Now(), nil)
+	}
+	return &pendingDecision{
+		record: Record{
+			Message:   message,
+			Severity:  severity,
+			Timestamp: time.Now(),
+			Signature: signature,
+			Rate:      rate,
+			Seen:      seen,
+		},
+		reason: reason,
+	}
+}
+
+// decide asks the Sampler whether to keep a pending record, then applies
+// the budget and tallies the result under s.mu. Callers must not hold
+// s.mu.
+func (s *AdaptiveSampler) decide(p *pendingDecision) samplingOutcome {
+	decision := s.engine.ShouldSample(p.record)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.policy = &SamplingPolicy{
-		PolicyID:        "default",
-		SamplingRate:    0.1,
-		Patterns:        []string{"error", "warning"},
-		MaxLogsPerMinute: 1000,
-		SeverityRates: map[string]float64{
-			"ERROR":   1.0,
-			"WARNING": 0.5,
-			"INFO":    0.1,
-			"DEBUG":   0.05,
-		},
+	r, reason := p.record, p.reason
+	kept, rate := decision.Keep, decisionRate(decision, r.Rate)
+	if kept && !s.withinBudget(time.Now()) {
+		kept, reason = false, SamplingReasonBudget
 	}
-	s.lastPolicyUpdate = time.Now()
+	s.tally(r.Signature, r.Message, r.Severity, kept, time.Now())
+	return s.outcome(kept, reason, r.Signature, rate)
 }
 
-// reportPatterns reports pattern statistics to LipService backend.
-func (s *AdaptiveSampler) reportPatterns() {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// observeKept records a message kept without a sampling decision for
+// pattern events and reports, and returns its signature. The signature is
+// only computed if those, the sampler or debug output want it. Callers
+// must hold s.mu.
+func (s *AdaptiveSampler) observeKept(message, severity string) string {
+	if !s.events.active() && s.tallies == nil {
+		return s.readSignature(message)
+	}
+	signature := s.signature(message)
+	s.events.observe(signature, message, time.Now())
+	s.tally(signature, message, severity, true, time.Now())
+	return signature
+}
 
-	// Implementation would send pattern stats to LipService backend
-	// For now, just log the stats
-	fmt.Printf("Reporting %d patterns\n", len(s.patternStats))
+// patternReportLoop reports pattern statistics periodically.
+func (s *AdaptiveSampler) patternReportLoop(ctx context.Context) {
+	ticker := time.NewTicker(patternReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reportPatterns(ctx)
+		}
+	}
 }
 
-// computeSignature computes a signature for a log message.
-func computeSignature(message string) string {
-	// Normalize the message
-	normalized := strings.ToLower(strings.TrimSpace(message))
+// applyPolicy installs a new sampling policy and audits the change. Callers
+// must hold s.mu.
+func (s *AdaptiveSampler) applyPolicy(policy *SamplingPolicy, source string) {
+	if s.auditor != nil {
+		if err := s.auditor.record(s.policy, policy, source); err != nil {
+			s.diag.log(slog.Default(), "ERROR", "Failed to audit policy change", "error", err)
+		}
+	}
+	s.policy = policy
 
-	// Replace common patterns
-	patterns := map[string]string{
-		`\b\d+\b`:                    "N",
-		`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`: "UUID",
-		`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}`:                        "TIMESTAMP",
-		`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`:                            "IP",
-		`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Z|a-z]{2,}\b`:           "EMAIL",
-		`https?://[^\s]+`:                                               "URL",
+	if policy.SLO != nil {
+		s.slo.setTarget(*policy.SLO)
+	} else {
+		s.slo.setTarget(s.config.SLO)
 	}
+	s.owners.setPolicy(policy.Owners)
 
-	for pattern, replacement := range patterns {
-		re := regexp.MustCompile(pattern)
-		normalized = re.ReplaceAllString(normalized, replacement)
+	for _, hook := range s.policyHooks {
+		hook(policy)
 	}
+}
 
-	// Compute MD5 hash
-	hash := md5.Sum([]byte(normalized))
-	return fmt.Sprintf("%x", hash)
+// computeSignature computes a signature for a log message. Go panics are
+// fingerprinted by their top frames instead.
+func computeSignature(message string) string {
+	return defaultSignatureEngine.Signature(message)
 }
@@ -0,0 +1,58 @@
+package lipservice
+
+import "time"
+
+// Reasons a sampling decision was reached, echoed by DebugSampling.
+const (
+	SamplingReasonSeverity = "severity"
+	SamplingReasonPattern  = "pattern"
+	SamplingReasonDefault  = "default"
+	SamplingReasonDegraded = "degraded"
+	SamplingReasonIncident = "incident"
+)
+
+// Attributes added to locally-emitted logs when DebugSampling is set.
+const (
+	SampledAttribute        = "lipservice.sampled"
+	SamplingReasonAttribute = "lipservice.sampling_reason"
+	SignatureAttribute      = "lipservice.signature"
+	SamplingRateAttribute   = "lipservice.sampling_rate"
+	PolicyIDAttribute       = "lipservice.policy_id"
+)
+
+// samplingOutcome describes one sampling decision.
+type samplingOutcome struct {
+	kept      bool
+	reason    string
+	signature string
+	rate      float64
+	policyID  string
+	warmup    bool
+}
+
+// outcome builds a samplingOutcome under the current policy. Callers must
+// hold s.mu.
+func (s *AdaptiveSampler) outcome(kept bool, reason, signature string, rate float64) samplingOutcome {
+	o := samplingOutcome{kept: kept, reason: reason, signature: signature, rate: rate}
+	o.warmup = s.warmup.active(time.Now())
+	if s.policy != nil {
+		o.policyID = s.policy.PolicyID
+	}
+	return o
+}
+
+// attributes returns the outcome as key/value args for local logging.
+func (o samplingOutcome) attributes() []interface{} {
+	attrs := []interface{}{
+		SampledAttribute, o.kept,
+		SamplingReasonAttribute, o.reason,
+		SamplingRateAttribute, o.rate,
+	}
+	if o.signature != "" {
+		attrs = append(attrs, SignatureAttribute, o.signature)
+	}
+	if o.policyID != "" {
+		attrs = append(attrs, PolicyIDAttribute, o.policyID)
+	}
+	return attrs
+}
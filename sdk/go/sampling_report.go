@@ -0,0 +1,142 @@
+package lipservice
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pattern report formats for ExportPatternReport. Parquet is registered by
+// importing github.com/srex-dev/lipservice-go/parquet.
+const (
+	ReportFormatCSV     = "csv"
+	ReportFormatParquet = "parquet"
+)
+
+// ReportWriter writes a sampling report in one format.
+type ReportWriter func(w io.Writer, report SamplingReport) error
+
+var (
+	reportFormatsMu sync.RWMutex
+	reportFormats   = map[string]ReportWriter{
+		ReportFormatCSV: func(w io.Writer, report SamplingReport) error { return report.WriteCSV(w) },
+	}
+)
+
+// RegisterReportFormat makes a report format available to
+// ExportPatternReport, replacing any writer already registered under name.
+func RegisterReportFormat(name string, writer ReportWriter) {
+	reportFormatsMu.Lock()
+	defer reportFormatsMu.Unlock()
+	reportFormats[name] = writer
+}
+
+// PatternReport is one row of a SamplingReport.
+type PatternReport struct {
+	Signature    string    `json:"signature" parquet:"signature"`
+	Count        int       `json:"count" parquet:"count"`
+	SamplingRate float64   `json:"sampling_rate" parquet:"sampling_rate"`
+	LastSeen     time.Time `json:"last_seen" parquet:"last_seen,timestamp"`
+	PerMinute    float64   `json:"per_minute" parquet:"per_minute"`
+	Example      string    `json:"example,omitempty" parquet:"example"`
+}
+
+// SamplingReport is a point-in-time snapshot of the sampler's per-pattern
+// statistics and the overall delivery counters, for offline analysis.
+type SamplingReport struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	ServiceName string          `json:"service_name"`
+	Summary     ShutdownReport  `json:"summary"`
+	Patterns    []PatternReport `json:"patterns"`
+}
+
+// patternReportColumns is the CSV header for SamplingReport.WriteCSV.
+var patternReportColumns = []string{"signature", "count", "sampling_rate", "last_seen", "per_minute", "example"}
+
+// SamplingReport returns a snapshot of per-pattern sampling statistics,
+// most frequent patterns first.
+func (ls *LipService) SamplingReport() SamplingReport {
+	return SamplingReport{
+		GeneratedAt: time.Now().UTC(),
+		ServiceName: ls.config.ServiceName,
+		Summary:     ls.Report(),
+		Patterns:    ls.sampler.patternReports(),
+	}
+}
+
+// ExportPatternReport writes the current pattern table, with counts, rates
+// and example messages, to w in format: ReportFormatCSV, or any format
+// registered with RegisterReportFormat such as ReportFormatParquet.
+func (ls *LipService) ExportPatternReport(w io.Writer, format string) error {
+	reportFormatsMu.RLock()
+	writer, ok := reportFormats[format]
+	reportFormatsMu.RUnlock()
+	if !ok {
+		if format == ReportFormatParquet {
+			return fmt.Errorf("report format %q is not registered; import github.com/srex-dev/lipservice-go/parquet", format)
+		}
+		return fmt.Errorf("unknown report format %q", format)
+	}
+
+	if err := writer(w, ls.SamplingReport()); err != nil {
+		return fmt.Errorf("failed to export pattern report: %w", err)
+	}
+	return nil
+}
+
+// patternReports copies the pattern statistics into report rows.
+func (s *AdaptiveSampler) patternReports() []PatternReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	rows := make([]PatternReport, 0, len(s.patternStats))
+	for signature, stats := range s.patternStats {
+		rows = append(rows, PatternReport{
+			Signature:    signature,
+			Count:        stats.Count,
+			SamplingRate: stats.SamplingRate,
+			LastSeen:     stats.LastSeen,
+			PerMinute:    stats.buckets.perMinute(now, patternRateWindow),
+			Example:      stats.Example,
+		})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Signature < rows[j].Signature
+	})
+
+	return rows
+}
+
+// WriteCSV writes the per-pattern rows as CSV with a header line.
+func (r SamplingReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(patternReportColumns); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, row := range r.Patterns {
+		record := []string{
+			row.Signature,
+			strconv.Itoa(row.Count),
+			strconv.FormatFloat(row.SamplingRate, 'f', -1, 64),
+			row.LastSeen.UTC().Format(time.RFC3339Nano),
+			strconv.FormatFloat(row.PerMinute, 'f', -1, 64),
+			row.Example,
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
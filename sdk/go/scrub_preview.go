@@ -0,0 +1,124 @@
+package lipservice
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ScrubRecord is a log record as a caller writes it, or as it would be
+// exported.
+type ScrubRecord struct {
+	Message    string                 `json:"message"`
+	Severity   string                 `json:"severity"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// ScrubPreview shows what a record looks like once it has been through
+// redaction, transformation and truncation on its way to export.
+type ScrubPreview struct {
+	Input  ScrubRecord `json:"input"`
+	Output ScrubRecord `json:"output"`
+
+	// Changes describes each step that altered the record, in order
+	Changes []string `json:"changes"`
+}
+
+// PreviewScrub runs record through the same steps the logger and exporter
+// apply under config, without sampling or sending anything, so security
+// reviews can check a configuration before it reaches production. Output
+// attribute values are as exported, which is mostly strings.
+func PreviewScrub(config Config, record ScrubRecord) (ScrubPreview, error) {
+	preview := ScrubPreview{Input: record}
+
+	message := record.Message
+	attributes := make(map[string]interface{}, len(record.Attributes))
+	args := make([]interface{}, 0, 2*len(record.Attributes))
+	for key, value := range record.Attributes {
+		attributes[key] = value
+		args = append(args, key, value)
+	}
+
+	redactor, err := newSecretRedactor(config)
+	if err != nil {
+		return preview, fmt.Errorf("failed to compile secret patterns: %w", err)
+	}
+	if redactor != nil {
+		if redacted := redactor.redact(message); redacted != message {
+			preview.Changes = append(preview.Changes, "redacted secrets from the message")
+			message = redacted
+		}
+	}
+
+	// The attributes the logger adds, from a logger with no policy
+	owners, err := newPatternOwners(config, nil)
+	if err != nil {
+		return preview, err
+	}
+	logger := &LipServiceLogger{sampler: &AdaptiveSampler{config: config, owners: owners}}
+	if stack, ok := parsePanic(message); ok {
+		for key, value := range stack.attributes(signatureHasher(config)) {
+			attributes[key] = value
+		}
+		preview.Changes = append(preview.Changes, "added panic stack attributes")
+	}
+	if retention := logger.retention(record.Severity, nil, args); retention != "" && attributes[RetentionAttribute] != retention {
+		attributes[RetentionAttribute] = retention
+		preview.Changes = append(preview.Changes, fmt.Sprintf("added attribute %q", RetentionAttribute))
+	}
+	if owner := owners.owner(message, args, nil); owner != "" {
+		attributes[OwnerAttribute] = owner
+		preview.Changes = append(preview.Changes, fmt.Sprintf("added attribute %q", OwnerAttribute))
+	}
+
+	privacy, err := newAttributePrivacy(config, nil)
+	if err != nil {
+		return preview, err
+	}
+	// Keys are guarded in order, so which ones overflow is reproducible
+	keys := sortedKeys(attributes)
+	renamed := make(map[string]string, len(keys))
+	privacy.keyGuard.mu.Lock()
+	for _, key := range keys {
+		renamed[key] = privacy.keyGuard.key(key)
+	}
+	privacy.keyGuard.mu.Unlock()
+
+	scrubbed, exported, err := scrubForExport(config, privacy, message, attributes)
+	if err != nil {
+		return preview, err
+	}
+	if scrubbed != message {
+		preview.Changes = append(preview.Changes, fmt.Sprintf("truncated the message from %d to %d bytes", len(message), len(scrubbed)))
+	}
+	for _, key := range keys {
+		switch {
+		case containsString(config.PseudonymizedAttributes, key):
+			preview.Changes = append(preview.Changes, fmt.Sprintf("pseudonymized attribute %q", key))
+		case containsString(config.EncryptedAttributes, key):
+			preview.Changes = append(preview.Changes, fmt.Sprintf("encrypted attribute %q", key))
+		case fmt.Sprintf("%v", exported[renamed[key]]) != fmt.Sprintf("%v", attributes[key]):
+			preview.Changes = append(preview.Changes, fmt.Sprintf("limited the values of attribute %q", key))
+		}
+		if renamed[key] != key {
+			preview.Changes = append(preview.Changes, fmt.Sprintf("renamed attribute %q to %q", key, renamed[key]))
+		}
+	}
+
+	output := make(map[string]interface{}, len(exported))
+	for key, value := range exported {
+		output[key] = anyValue(exportKeyValue(key, value).Value)
+	}
+
+	preview.Output = ScrubRecord{Message: scrubbed, Severity: record.Severity, Attributes: output}
+	return preview, nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package lipservice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// FlushOnInvocationEnd exports buffered logs synchronously and refreshes
+// any sampler state that has fallen due. It is intended for AWS Lambda,
+// Cloud Functions and Cloud Run, where the process may be frozen between
+// invocations and background tickers can't be relied on. The flush and
+// the refresh's backend calls are bounded by ctx, so pass the invocation
+// context to stay within its deadline.
+func (ls *LipService) FlushOnInvocationEnd(ctx context.Context) error {
+	if ls.config.Serverless || ls.config.Synchronous {
+		ls.sampler.refreshIfDue(ctx, time.Now())
+		if now := time.Now(); ls.metrics.due(now) {
+			ls.metrics.report(ctx, now)
+		}
+	}
+	logRepeats(ls.logger.deduper.drain())
+
+	for _, exporter := range ls.exporters() {
+		if err := exporter.FlushContext(ctx); err != nil {
+			return fmt.Errorf("failed to flush logs at invocation end: %w", err)
+		}
+	}
+	if err := ls.flushFanout(ctx); err != nil {
+		return fmt.Errorf("failed to flush logs at invocation end: %w", err)
+	}
+	ls.pressure.check()
+
+	return nil
+}
+
+// refreshIfDue runs the periodic sampler tasks whose interval has elapsed,
+// bounding their backend calls by ctx. Comparing wall-clock times rather
+// than relying on tickers tolerates the process being frozen between
+// invocations.
+func (s *AdaptiveSampler) refreshIfDue(ctx context.Context, now time.Time) {
+	policyDue := s.policyFetch.due(now)
+
+	s.mu.RLock()
+	reportDue := now.Sub(s.lastPatternReport) >= patternReportInterval
+	s.mu.RUnlock()
+
+	if policyDue && !s.config.Offline {
+		s.refreshPolicy(ctx)
+	}
+	if reportDue && !s.config.Offline {
+		s.reportPatterns(ctx)
+	}
+	if s.coordinator != nil && s.coordinator.due(now) {
+		if err := s.coordinate(ctx); err != nil {
+			s.diag.log(slog.Default(), "WARN", "Rate coordination failed", "error", err)
+		}
+	}
+	if s.events.due(now) {
+		s.events.roll(now)
+	}
+	s.mu.RLock()
+	sweepDue := now.Sub(s.lastPatternSweep) >= patternSweepInterval
+	s.mu.RUnlock()
+	if sweepDue {
+		s.sweepPatterns(now)
+	}
+	if s.config.StateFile != "" && now.Sub(s.lastCheckpoint) >= s.checkpointInterval() {
+		if err := s.checkpoint(); err != nil {
+			s.diag.log(slog.Default(), "ERROR", "Sampler checkpoint failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package lipservice
+
+import (
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// ServiceAttribute names the service a record belongs to. Records carrying
+// it are exported under their own resource, so one process can host
+// several logical services.
+const ServiceAttribute = "service.name"
+
+// Service returns a logger whose records belong to the named logical
+// service, for modular monoliths hosting several services in one process.
+// Its records are grouped under a resource with that service.name rather
+// than Config.ServiceName, sharing the process's exporter and batches.
+func (l *LipServiceLogger) Service(name string) *LipServiceLogger {
+	return l.With(ServiceAttribute, name)
+}
+
+// serviceRecords is the records of one batch belonging to one service.
+type serviceRecords struct {
+	service string
+	records []*logs.LogRecord
+}
+
+// groupByService splits records by their service.name attribute, in order
+// of first appearance. Records without one belong to defaultService. The
+// attribute stays on each record, so batches rebuilt from a request (when
+// splitting, spooling or dead-lettering) regroup the same way.
+func groupByService(records []*logs.LogRecord, defaultService string) []serviceRecords {
+	var groups []serviceRecords
+	index := map[string]int{}
+	for _, record := range records {
+		service := recordService(record, defaultService)
+		i, ok := index[service]
+		if !ok {
+			i = len(groups)
+			index[service] = i
+			groups = append(groups, serviceRecords{service: service})
+		}
+		groups[i].records = append(groups[i].records, record)
+	}
+
+	// An empty batch still gets the default resource
+	if len(groups) == 0 {
+		groups = append(groups, serviceRecords{service: defaultService})
+	}
+	return groups
+}
+
+// recordService returns the service named by a record's attributes, or
+// defaultService.
+func recordService(record *logs.LogRecord, defaultService string) string {
+	for _, kv := range record.Attributes {
+		if kv.Key == ServiceAttribute {
+			if service := kv.Value.GetStringValue(); service != "" {
+				return service
+			}
+		}
+	}
+	return defaultService
+}
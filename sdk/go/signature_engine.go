@@ -0,0 +1,339 @@
+package lipservice
+
+import (
+	"container/list"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// normalizePatterns replace the variable parts of a lowercased message,
+// most specific first, so a UUID or IP isn't broken up into numbers.
+var normalizePatterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`https?://[^\s]+`), "URL"},
+	{regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Z|a-z]{2,}\b`), "EMAIL"},
+	{regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`), "UUID"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}`), "TIMESTAMP"},
+	{regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`), "IP"},
+	{regexp.MustCompile(`\b\d+\b`), "N"},
+}
+
+// signatureCacheMaxMessage is the longest message whose signature is
+// cached, so a cache full of huge messages can't hold much memory.
+const signatureCacheMaxMessage = 512
+
+// SignatureEngineConfig tunes a SignatureEngine.
+type SignatureEngineConfig struct {
+	// Tokenizer normalizes messages in a single pass instead of running
+	// the normalization regexes, falling back to them for messages with
+	// URLs or email addresses. Both produce the same signatures.
+	Tokenizer bool
+
+	// CacheSize is how many message signatures are cached, least recently
+	// used evicted first (0 disables the cache)
+	CacheSize int
+
+	// Hasher hashes normalized messages into signatures (defaults to
+	// FNVHasher)
+	Hasher SignatureHasher
+}
+
+// SignatureCacheStats reports a SignatureEngine's cache use.
+type SignatureCacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+// SignatureEngine computes the pattern signatures records are sampled by:
+// a hash of the message with variable parts such as numbers, UUIDs and IPs
+// replaced by placeholders. Set Config.SignatureEngine to tune it, and use
+// it directly to benchmark a configuration against real messages.
+type SignatureEngine struct {
+	config SignatureEngineConfig
+
+	mu    sync.Mutex
+	cache map[string]*list.Element
+	order *list.List
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// signatureEntry is one cached signature.
+type signatureEntry struct {
+	message   string
+	signature string
+}
+
+// NewSignatureEngine creates a signature engine.
+func NewSignatureEngine(config SignatureEngineConfig) *SignatureEngine {
+	e := &SignatureEngine{config: config}
+	if config.CacheSize > 0 {
+		e.cache = make(map[string]*list.Element, config.CacheSize)
+		e.order = list.New()
+	}
+	return e
+}
+
+// defaultSignatureEngine computes signatures for samplers without
+// Config.SignatureEngine and outside a sampler.
+var defaultSignatureEngine = NewSignatureEngine(SignatureEngineConfig{})
+
+// Signature returns a message's signature. Go panics are fingerprinted by
+// their top frames instead.
+func (e *SignatureEngine) Signature(message string) string {
+	cacheable := e.cache != nil && len(message) <= signatureCacheMaxMessage
+	if cacheable {
+		if signature, ok := e.cached(message); ok {
+			e.hits.Add(1)
+			return signature
+		}
+		e.misses.Add(1)
+	}
+
+	var signature string
+	if stack, ok := parsePanic(message); ok {
+		signature = stack.fingerprint(e.config.Hasher)
+	} else {
+		signature = e.hash(e.Normalize(message))
+	}
+
+	if cacheable {
+		e.store(message, signature)
+	}
+	return signature
+}
+
+// Normalize lowercases a message and replaces its variable parts with
+// placeholders, giving the template its signature is computed from.
+func (e *SignatureEngine) Normalize(message string) string {
+	if e.config.Tokenizer {
+		if normalized, ok := tokenizeMessage(message); ok {
+			return normalized
+		}
+	}
+	return normalizeMessage(message)
+}
+
+// hash hashes a normalized message with the engine's hasher.
+func (e *SignatureEngine) hash(normalized string) string {
+	return hashSignature(e.config.Hasher, normalized)
+}
+
+// Stats reports the engine's cache use.
+func (e *SignatureEngine) Stats() SignatureCacheStats {
+	stats := SignatureCacheStats{Hits: e.hits.Load(), Misses: e.misses.Load()}
+	if e.cache != nil {
+		e.mu.Lock()
+		stats.Size = len(e.cache)
+		e.mu.Unlock()
+	}
+	return stats
+}
+
+// cached returns a cached signature, marking it recently used.
+func (e *SignatureEngine) cached(message string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	element, ok := e.cache[message]
+	if !ok {
+		return "", false
+	}
+	e.order.MoveToFront(element)
+	return element.Value.(*signatureEntry).signature, true
+}
+
+// store caches a signature, evicting the least recently used if full.
+func (e *SignatureEngine) store(message, signature string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.cache[message]; ok {
+		return
+	}
+	if e.order.Len() >= e.config.CacheSize {
+		oldest := e.order.Back()
+		e.order.Remove(oldest)
+		delete(e.cache, oldest.Value.(*signatureEntry).message)
+	}
+	e.cache[message] = e.order.PushFront(&signatureEntry{message: message, signature: signature})
+}
+
+// normalizeMessage lowercases a message and replaces variable parts such as
+// numbers, UUIDs and IPs with placeholders.
+func normalizeMessage(message string) string {
+	// Only the head of a very long message decides its pattern
+	message, _ = truncateMessage(message, signatureMaxBytes)
+	normalized := strings.ToLower(strings.TrimSpace(message))
+
+	for _, pattern := range normalizePatterns {
+		normalized = pattern.re.ReplaceAllString(normalized, pattern.replacement)
+	}
+	return normalized
+}
+
+// tokenizeMessage is normalizeMessage in a single pass over the message,
+// for messages without URLs or email addresses (ok is false otherwise).
+// Like the regexes, UUIDs and timestamps may start anywhere, while IPs
+// and numbers must be whole words.
+func tokenizeMessage(message string) (string, bool) {
+	message, _ = truncateMessage(message, signatureMaxBytes)
+	s := strings.ToLower(strings.TrimSpace(message))
+	if strings.Contains(s, "://") || strings.IndexByte(s, '@') >= 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if n := matchUUID(s, i); n > 0 {
+			b.WriteString("UUID")
+			i += n
+			continue
+		}
+		if n := matchTimestamp(s, i); n > 0 {
+			b.WriteString("TIMESTAMP")
+			i += n
+			continue
+		}
+		if !isDigit(s[i]) || (i > 0 && isWordByte(s[i-1])) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		// A word starting with a digit: an IP, a number, or neither
+		if n := matchIP(s, i); n > 0 {
+			b.WriteString("IP")
+			i += n
+			continue
+		}
+		end := i
+		for end < len(s) && isDigit(s[end]) {
+			end++
+		}
+		// A UUID or timestamp starting inside the digits takes precedence,
+		// leaving the digits before it as they are
+		if j := specialWithin(s, i+1, end); j > 0 {
+			b.WriteString(s[i:j])
+			i = j
+			continue
+		}
+		if end < len(s) && isWordByte(s[end]) {
+			// Digits running into letters aren't a number
+			b.WriteString(s[i:end])
+			i = end
+			continue
+		}
+		b.WriteByte('N')
+		i = end
+	}
+	return b.String(), true
+}
+
+// specialWithin returns the first position in [from, to) where a UUID or
+// timestamp starts, or 0.
+func specialWithin(s string, from, to int) int {
+	for j := from; j < to; j++ {
+		if matchUUID(s, j) > 0 || matchTimestamp(s, j) > 0 {
+			return j
+		}
+	}
+	return 0
+}
+
+// matchUUID returns the length of a UUID at s[i:], or 0.
+func matchUUID(s string, i int) int {
+	const length = 36
+	if i+length > len(s) {
+		return 0
+	}
+	for j := 0; j < length; j++ {
+		c := s[i+j]
+		switch j {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return 0
+			}
+		default:
+			if !isDigit(c) && (c < 'a' || c > 'f') {
+				return 0
+			}
+		}
+	}
+	return length
+}
+
+// matchTimestamp returns the length of a "yyyy-mm-dd hh:mm:ss" timestamp
+// at s[i:], or 0. The message is lowercased, so as for the regex, a T
+// separator never matches.
+func matchTimestamp(s string, i int) int {
+	const layout = "dddd-dd-dd dd:dd:dd"
+	if i+len(layout) > len(s) {
+		return 0
+	}
+	for j := 0; j < len(layout); j++ {
+		c := s[i+j]
+		if layout[j] == 'd' {
+			if !isDigit(c) {
+				return 0
+			}
+		} else if c != layout[j] {
+			return 0
+		}
+	}
+	return len(layout)
+}
+
+// matchIP returns the length of a dotted IPv4 address forming a whole word
+// at s[i:], or 0.
+func matchIP(s string, i int) int {
+	j := i
+	for group := 0; group < 4; group++ {
+		start := j
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		if j == start || j-start > 3 {
+			return 0
+		}
+		if group < 3 {
+			if j >= len(s) || s[j] != '.' {
+				return 0
+			}
+			j++
+		}
+	}
+	if j < len(s) && isWordByte(s[j]) {
+		return 0
+	}
+	return j - i
+}
+
+// isDigit reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isWordByte reports whether c is an ASCII word character, as for \b.
+func isWordByte(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+// signatureEngine returns the engine the configuration's signatures are
+// computed with.
+func signatureEngine(config Config) *SignatureEngine {
+	if config.SignatureEngine != nil {
+		return config.SignatureEngine
+	}
+	if config.SignatureHasher != nil {
+		return NewSignatureEngine(SignatureEngineConfig{Hasher: config.SignatureHasher})
+	}
+	return defaultSignatureEngine
+}
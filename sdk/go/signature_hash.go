@@ -0,0 +1,109 @@
+package lipservice
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// SignatureHasher hashes a normalized message into its pattern signature.
+// Signatures key pattern stats, pins, checkpoints and the per-pattern rates
+// in backend policies, so every instance sharing a backend must use the
+// same hasher, and changing it starts patterns afresh.
+type SignatureHasher interface {
+	Hash(normalized string) string
+}
+
+// SignatureHasherFunc adapts a function to a SignatureHasher.
+type SignatureHasherFunc func(normalized string) string
+
+// Hash calls f(normalized).
+func (f SignatureHasherFunc) Hash(normalized string) string {
+	return f(normalized)
+}
+
+// namedHasher is a built-in hasher. Its name is sent to the backend with
+// pattern reports and policy fetches, so a backend keying rates by another
+// hash can be detected rather than silently missing every pattern.
+type namedHasher struct {
+	name string
+	hash func(normalized string) string
+}
+
+// Hash calls h.hash(normalized).
+func (h *namedHasher) Hash(normalized string) string {
+	return h.hash(normalized)
+}
+
+// Names of the built-in hashers, as sent to the backend.
+const (
+	hasherFNV    = "fnv1a64"
+	hasherSHA256 = "sha256"
+	hasherMD5    = "md5"
+	hasherCustom = "custom"
+)
+
+// FNVHasher hashes with 64-bit FNV-1a into a 16-character hex signature.
+// It is the default: fast, allocation-light and not a cryptographic hash,
+// which signatures don't need.
+var FNVHasher SignatureHasher = &namedHasher{name: hasherFNV, hash: fnvSignature}
+
+// SHA256Hasher hashes with SHA-256 truncated to a 32-character hex
+// signature, matching signatures from earlier FIPS builds.
+var SHA256Hasher SignatureHasher = &namedHasher{name: hasherSHA256, hash: sha256Signature}
+
+// FNV-1a parameters for 64-bit hashes.
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// fnvSignature hashes normalized with 64-bit FNV-1a. It is inlined rather
+// than built on hash/fnv because it runs for every sampled record, and
+// fnv.New64a would allocate the hasher and a []byte copy of normalized each
+// time; TestSignatureHasher checks the two agree.
+func fnvSignature(normalized string) string {
+	hash := uint64(fnvOffset64)
+	for i := 0; i < len(normalized); i++ {
+		hash ^= uint64(normalized[i])
+		hash *= fnvPrime64
+	}
+
+	var sum [8]byte
+	binary.BigEndian.PutUint64(sum[:], hash)
+	return hex.EncodeToString(sum[:])
+}
+
+// sha256Signature hashes normalized with truncated SHA-256.
+func sha256Signature(normalized string) string {
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:16])
+}
+
+// hashSignature hashes normalized with hasher, or FNVHasher if it is nil.
+func hashSignature(hasher SignatureHasher, normalized string) string {
+	if hasher == nil {
+		hasher = FNVHasher
+	}
+	return hasher.Hash(normalized)
+}
+
+// hasherName returns the name a hasher is reported to the backend by, or
+// hasherCustom for hashers from outside the package.
+func hasherName(hasher SignatureHasher) string {
+	if hasher == nil {
+		hasher = FNVHasher
+	}
+	if named, ok := hasher.(*namedHasher); ok {
+		return named.name
+	}
+	return hasherCustom
+}
+
+// signatureHasher returns the hasher the configuration's signatures use.
+func signatureHasher(config Config) SignatureHasher {
+	if config.SignatureEngine != nil {
+		return config.SignatureEngine.config.Hasher
+	}
+	return config.SignatureHasher
+}
@@ -0,0 +1,19 @@
+//go:build !lipservice_fips
+
+package lipservice
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+)
+
+// MD5Hasher hashes with MD5 into a 32-character hex signature, matching
+// earlier releases and the signatures the LipService backend computes for
+// per-pattern rates. FIPS builds leave it out.
+var MD5Hasher SignatureHasher = &namedHasher{name: hasherMD5, hash: md5Signature}
+
+// md5Signature hashes normalized with MD5.
+func md5Signature(normalized string) string {
+	hash := md5.Sum([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
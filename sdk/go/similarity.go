@@ -0,0 +1,130 @@
+package lipservice
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultSimilarityThreshold is the fraction of matching tokens needed for
+// two messages to be grouped.
+const defaultSimilarityThreshold = 0.7
+
+// similarityMaxGroups bounds the number of groups kept per token count and
+// leading token, so unbounded message variety can't grow memory forever.
+const similarityMaxGroups = 64
+
+// similarityWildcard replaces template tokens that vary within a group.
+const similarityWildcard = "<*>"
+
+// similarityGroup is a message template and the signature assigned to it.
+type similarityGroup struct {
+	template  []string
+	signature string
+}
+
+// similarityGrouper assigns near-duplicate messages to shared groups by
+// comparing tokens position by position. Messages are only compared with
+// groups that have the same token count and leading token, which keeps the
+// search cheap and avoids grouping structurally different messages.
+type similarityGrouper struct {
+	threshold float64
+	hasher    SignatureHasher
+	mu        sync.Mutex
+	groups    map[string][]*similarityGroup
+}
+
+// newSimilarityGrouper creates a grouper, or returns nil if grouping is
+// disabled.
+func newSimilarityGrouper(config Config) *similarityGrouper {
+	if !config.SimilarityGrouping {
+		return nil
+	}
+
+	threshold := config.SimilarityThreshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultSimilarityThreshold
+	}
+
+	return &similarityGrouper{
+		threshold: threshold,
+		hasher:    signatureHasher(config),
+		groups:    make(map[string][]*similarityGroup),
+	}
+}
+
+// signature returns the signature of the group a normalized message belongs
+// to, creating a group if none is similar enough. A group's signature is
+// fixed when it is created, so it stays stable as its template generalizes.
+func (g *similarityGrouper) signature(normalized string) string {
+	tokens := strings.Fields(normalized)
+	if len(tokens) == 0 {
+		return hashSignature(g.hasher, normalized)
+	}
+
+	key := strconv.Itoa(len(tokens)) + " " + tokens[0]
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	candidates := g.groups[key]
+	for i, group := range candidates {
+		if tokenSimilarity(group.template, tokens) >= g.threshold {
+			group.merge(tokens)
+
+			// Move to front so frequent groups are found first and
+			// rare ones are the first to be evicted
+			copy(candidates[1:i+1], candidates[:i])
+			candidates[0] = group
+			return group.signature
+		}
+	}
+
+	group := &similarityGroup{template: tokens, signature: hashSignature(g.hasher, normalized)}
+	if len(candidates) >= similarityMaxGroups {
+		candidates = candidates[:similarityMaxGroups-1]
+	}
+	g.groups[key] = append([]*similarityGroup{group}, candidates...)
+
+	return group.signature
+}
+
+// lookup returns the signature signature would assign a normalized message,
+// without merging it into a group or creating one.
+func (g *similarityGrouper) lookup(normalized string) string {
+	tokens := strings.Fields(normalized)
+	if len(tokens) == 0 {
+		return hashSignature(g.hasher, normalized)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, group := range g.groups[strconv.Itoa(len(tokens))+" "+tokens[0]] {
+		if tokenSimilarity(group.template, tokens) >= g.threshold {
+			return group.signature
+		}
+	}
+	return hashSignature(g.hasher, normalized)
+}
+
+// merge generalizes the template so positions that differ become wildcards.
+func (group *similarityGroup) merge(tokens []string) {
+	for i, token := range tokens {
+		if group.template[i] != token {
+			group.template[i] = similarityWildcard
+		}
+	}
+}
+
+// tokenSimilarity returns the fraction of positions where template and
+// tokens agree. Wildcards match anything. Both must be the same length.
+func tokenSimilarity(template, tokens []string) float64 {
+	matches := 0
+	for i, token := range tokens {
+		if template[i] == token || template[i] == similarityWildcard {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(tokens))
+}
@@ -0,0 +1,211 @@
+package lipservice
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SLO defaults and timing.
+const (
+	// sloWindow is the period the error ratio is measured over
+	sloWindow = time.Minute
+
+	// sloMinRecords is the fewest records in a window for its error ratio
+	// to be trusted
+	sloMinRecords = 20
+
+	defaultSLOBurnRate     = 2.0
+	defaultSLOBoostRate    = 1.0
+	defaultSLOBoostSeconds = 600
+)
+
+// DurationAttribute is the attribute, in milliseconds, checked against an
+// SLO latency target:
+//
+//	logger.Info("request completed", lipservice.DurationAttribute, 812)
+const DurationAttribute = "duration_ms"
+
+// SLOTarget describes a service level objective measured from the log
+// stream. When the share of bad records burns the error budget faster than
+// BurnRate, sampling is raised across the service for a while so there is
+// enough detail to debug the incident.
+type SLOTarget struct {
+	// ErrorRate is the target ratio of bad records, e.g. 0.01 for 99%
+	ErrorRate float64 `json:"error_rate"`
+
+	// LatencyMs counts records whose duration_ms exceeds it as bad
+	// (0 counts only ERROR and above)
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+
+	// BurnRate is how many times ErrorRate the measured ratio must reach
+	// to trigger a boost (default: 2)
+	BurnRate float64 `json:"burn_rate,omitempty"`
+
+	// BoostRate is the minimum sampling rate while boosted (default: 1)
+	BoostRate float64 `json:"boost_rate,omitempty"`
+
+	// BoostSeconds is how long a boost lasts (default: 600)
+	BoostSeconds int `json:"boost_seconds,omitempty"`
+}
+
+// enabled reports whether the target is set.
+func (t SLOTarget) enabled() bool {
+	return t.ErrorRate > 0
+}
+
+// withDefaults fills in unset fields.
+func (t SLOTarget) withDefaults() SLOTarget {
+	if t.BurnRate <= 0 {
+		t.BurnRate = defaultSLOBurnRate
+	}
+	if t.BoostRate <= 0 {
+		t.BoostRate = defaultSLOBoostRate
+	}
+	if t.BoostSeconds <= 0 {
+		t.BoostSeconds = defaultSLOBoostSeconds
+	}
+	return t
+}
+
+// sloTracker measures the error ratio per window and boosts sampling when
+// the SLO's error budget burns too fast.
+type sloTracker struct {
+	mu         sync.Mutex
+	target     SLOTarget
+	window     time.Time
+	total      int
+	bad        int
+	boostUntil time.Time
+	diag       *diagnostics
+}
+
+// newSLOTracker creates a tracker for the configured target. A backend
+// policy may enable or replace the target later.
+func newSLOTracker(config Config, diag *diagnostics) *sloTracker {
+	return &sloTracker{target: config.SLO.withDefaults(), diag: diag}
+}
+
+// setTarget replaces the SLO target.
+func (t *sloTracker) setTarget(target SLOTarget) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.target = target.withDefaults()
+}
+
+// currentTarget returns the SLO target in force.
+func (t *sloTracker) currentTarget() SLOTarget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.target
+}
+
+// observe counts one record against the SLO, starting a boost if the
+// window that just closed burned the error budget too fast.
+func (t *sloTracker) observe(severity string, args []interface{}, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.target.enabled() {
+		return
+	}
+
+	window := now.Truncate(sloWindow)
+	if !window.Equal(t.window) {
+		t.evaluate(now)
+		t.window = window
+		t.total, t.bad = 0, 0
+	}
+
+	t.total++
+	if t.isBad(severity, args) {
+		t.bad++
+	}
+}
+
+// evaluate checks the closing window's error ratio. Callers must hold t.mu.
+func (t *sloTracker) evaluate(now time.Time) {
+	if t.total < sloMinRecords {
+		return
+	}
+
+	ratio := float64(t.bad) / float64(t.total)
+	if ratio < t.target.ErrorRate*t.target.BurnRate {
+		return
+	}
+
+	if !now.Before(t.boostUntil) {
+		t.diag.log(slog.Default(), "WARN", "Error ratio is burning the SLO budget, boosting sampling",
+			"error_ratio", ratio, "target", t.target.ErrorRate, "boost_seconds", t.target.BoostSeconds)
+	}
+	t.boostUntil = now.Add(time.Duration(t.target.BoostSeconds) * time.Second)
+}
+
+// isBad reports whether a record counts against the SLO.
+func (t *sloTracker) isBad(severity string, args []interface{}) bool {
+	if severity == "ERROR" || severity == "CRITICAL" || severity == "FATAL" {
+		return true
+	}
+	if t.target.LatencyMs <= 0 {
+		return false
+	}
+
+	slow := false
+	eachAttribute(args, func(key string, value interface{}) bool {
+		if key != DurationAttribute {
+			return true
+		}
+		ms, ok := toFloat(value)
+		slow = ok && ms > t.target.LatencyMs
+		return false
+	})
+	return slow
+}
+
+// boostRate returns the minimum sampling rate while a boost is active.
+func (t *sloTracker) boostRate(now time.Time) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Before(t.boostUntil) {
+		return t.target.BoostRate, true
+	}
+	return 0, false
+}
+
+// boost raises rate to the boost rate while a boost is active.
+func (t *sloTracker) boost(rate float64, now time.Time) float64 {
+	if boosted, ok := t.boostRate(now); ok && boosted > rate {
+		return boosted
+	}
+	return rate
+}
+
+// SLOBoosted reports whether sampling is currently raised because the
+// service is burning its SLO error budget.
+func (s *AdaptiveSampler) SLOBoosted() bool {
+	_, ok := s.slo.boostRate(time.Now())
+	return ok
+}
+
+// toFloat converts a numeric attribute value to float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Duration:
+		return float64(v) / float64(time.Millisecond), true
+	default:
+		return 0, false
+	}
+}
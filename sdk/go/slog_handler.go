@@ -0,0 +1,165 @@
+package lipservice
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// SlogHandler is a slog.Handler that samples records and exports the kept
+// ones, so code already written against log/slog can adopt LipService by
+// swapping its handler:
+//
+//	slog.SetDefault(slog.New(lipservice.NewSlogHandler(ls)))
+//	slog.Error("payment failed", "order", id)
+type SlogHandler struct {
+	logger *LipServiceLogger
+	level  slog.Leveler
+
+	// prefix qualifies attribute keys with the groups opened by WithGroup,
+	// e.g. "request."
+	prefix string
+
+	// diagnostics receives the SDK's own diagnostics while this handler is
+	// the slog default, as exporting them could fail and report again
+	diagnostics *slog.Logger
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// HandlerOption configures a SlogHandler.
+type HandlerOption func(*SlogHandler)
+
+// WithLevel sets the minimum level the handler accepts (default: Debug).
+// Records must also pass the runtime log level.
+func WithLevel(level slog.Leveler) HandlerOption {
+	return func(h *SlogHandler) {
+		h.level = level
+	}
+}
+
+// WithLocalHandler also writes kept records and the SDK's diagnostics to
+// handler, e.g. a slog.TextHandler on stderr. By default kept records are
+// only exported and diagnostics go to the log package's output as it was
+// when the handler was created: the local logger is usually the slog
+// default this handler replaces, and writing back to it would loop.
+func WithLocalHandler(handler slog.Handler) HandlerOption {
+	return func(h *SlogHandler) {
+		h.logger.baseLogger = slog.New(handler)
+		h.diagnostics = h.logger.baseLogger
+	}
+}
+
+// NewSlogHandler creates a slog.Handler that writes through ls's logger.
+func NewSlogHandler(ls *LipService, opts ...HandlerOption) *SlogHandler {
+	logger := *ls.logger
+	logger.baseLogger = slog.New(discardHandler{})
+
+	h := &SlogHandler{
+		logger:      &logger,
+		level:       slog.LevelDebug,
+		diagnostics: slog.New(slog.NewTextHandler(log.Writer(), &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Enabled reports whether records at level are accepted.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.logger.sampler.level.enabled(slogSeverity(level))
+}
+
+// Handle samples r and exports it if kept, as of r.Time and correlated
+// with the span in ctx. Within a request handled by Middleware, the record
+// counts towards the request summary.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	args := make([]interface{}, 0, 2*r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		args = appendSlogAttr(args, h.prefix, attr)
+		return true
+	})
+
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	h.logger.logContextAt(ctx, now, slogSeverity(r.Level), r.Message, args)
+	return nil
+}
+
+// WithAttrs returns a handler whose records carry attrs, bound once as
+// With does.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	args := make([]interface{}, 0, 2*len(attrs))
+	for _, attr := range attrs {
+		args = appendSlogAttr(args, h.prefix, attr)
+	}
+
+	clone := *h
+	clone.logger = h.logger.With(args...)
+	return &clone
+}
+
+// WithGroup returns a handler that qualifies the keys of later attributes
+// with name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// appendSlogAttr appends attr to args as a key and value, flattening groups
+// into dotted keys as the export attributes are flat.
+func appendSlogAttr(args []interface{}, prefix string, attr slog.Attr) []interface{} {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return args
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		// An inline group (empty key) adds its attributes at this level
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			args = appendSlogAttr(args, prefix, member)
+		}
+		return args
+	}
+	return append(args, prefix+attr.Key, attr.Value.Any())
+}
+
+// slogSeverity maps a slog level to a LipService severity.
+func slogSeverity(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "ERROR"
+	case level >= slog.LevelWarn:
+		return "WARN"
+	case level >= slog.LevelInfo:
+		return "INFO"
+	case level >= slog.LevelDebug:
+		return "DEBUG"
+	default:
+		return "TRACE"
+	}
+}
+
+// discardHandler drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -0,0 +1,112 @@
+package lipservice
+
+import (
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanEventName is the name of span events recorded for sampled-out logs.
+const SpanEventName = "log"
+
+// Span event limits.
+const (
+	// defaultMaxSpanEvents is the default cap on events added to one span
+	defaultMaxSpanEvents = 32
+
+	// spanEventMaxSpans bounds the spans whose event counts are tracked;
+	// the counts are forgotten when it fills, as spans are short-lived
+	spanEventMaxSpans = 10000
+)
+
+// spanEvents records sampled-out logs on their active span, up to a
+// per-span limit.
+type spanEvents struct {
+	limit int
+
+	mu     sync.Mutex
+	counts map[trace.SpanID]int
+}
+
+// newSpanEvents returns the span event recorder, or nil unless
+// Config.SpanEvents is set.
+func newSpanEvents(config Config) *spanEvents {
+	if !config.SpanEvents {
+		return nil
+	}
+	limit := config.MaxSpanEvents
+	if limit <= 0 {
+		limit = defaultMaxSpanEvents
+	}
+	return &spanEvents{limit: limit, counts: make(map[trace.SpanID]int)}
+}
+
+// record adds a sampled-out log to span as an event, unless the span has
+// had its share of events. Its attributes go through the same
+// pseudonymization, encryption and key normalization as exported records,
+// as spans are exported to a tracing backend of their own.
+func (e *spanEvents) record(span trace.Span, severity, msg string, args, bound []interface{}, privacy *attributePrivacy) {
+	if e == nil || span == nil || !span.IsRecording() {
+		return
+	}
+
+	id := span.SpanContext().SpanID()
+	e.mu.Lock()
+	if e.counts[id] >= e.limit {
+		e.mu.Unlock()
+		return
+	}
+	if len(e.counts) >= spanEventMaxSpans {
+		e.counts = make(map[trace.SpanID]int)
+	}
+	e.counts[id]++
+	e.mu.Unlock()
+
+	attributes := make(map[string]interface{}, len(bound)/2+len(args)/2)
+	addAttributes(attributes, bound)
+	addAttributes(attributes, args)
+	if privacy != nil {
+		protected, err := privacy.protect(attributes)
+		if err != nil {
+			return
+		}
+		attributes = protected
+	}
+
+	attrs := make([]attribute.KeyValue, 0, 2+len(attributes))
+	attrs = append(attrs,
+		attribute.String("log.severity", severity),
+		attribute.String("log.message", msg),
+	)
+	attrs = appendSpanAttributes(attrs, attributes)
+	span.AddEvent(SpanEventName, trace.WithAttributes(attrs...))
+}
+
+// appendSpanAttributes converts log attributes to span attributes, in key
+// order, keeping strings, bools and numbers typed and formatting anything
+// else. The span IDs added by the context-aware methods are left out, as
+// the event is on the span already.
+func appendSpanAttributes(attrs []attribute.KeyValue, attributes map[string]interface{}) []attribute.KeyValue {
+	for _, key := range sortedKeys(attributes) {
+		if isSpanAttribute(key) {
+			continue
+		}
+		switch v := attributes[key].(type) {
+		case string:
+			attrs = append(attrs, attribute.String(key, v))
+		case bool:
+			attrs = append(attrs, attribute.Bool(key, v))
+		case int:
+			attrs = append(attrs, attribute.Int(key, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(key, v))
+		case float64:
+			attrs = append(attrs, attribute.Float64(key, v))
+		default:
+			attrs = append(attrs, attribute.String(key, fmt.Sprintf("%v", v)))
+		}
+	}
+	return attrs
+}
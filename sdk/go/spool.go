@@ -0,0 +1,243 @@
+package lipservice
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// spoolLockFile is the name of the lock file each process holds in its own
+// spool subdirectory.
+const spoolLockFile = "owner.lock"
+
+// spoolDirPrefix prefixes per-process spool subdirectories.
+const spoolDirPrefix = "proc-"
+
+// spoolSegmentExt is the file extension of spooled batch segments.
+const spoolSegmentExt = ".otlp"
+
+// spoolQuarantineDir is the directory under the spool root that segments
+// which can't be decoded are moved to, out of the replay path but still on
+// disk for inspection.
+const spoolQuarantineDir = "quarantine"
+
+// spoolReapGrace protects freshly created subdirectories from being reaped
+// before their owner has taken the lock.
+const spoolReapGrace = time.Minute
+
+// errSpoolLocked is returned by tryLockFile when another process holds the
+// lock.
+var errSpoolLocked = errors.New("spool directory is locked by another process")
+
+// diskSpool persists serialized batches that could not be exported so they
+// can be retried later. Several processes may share the same spool root:
+// each owns a subdirectory guarded by a lock file, and segments left behind
+// by processes that exited are adopted by the survivors.
+type diskSpool struct {
+	root string
+	dir  string
+	lock *os.File
+	diag *diagnostics
+	mu   sync.Mutex
+	seq  uint64
+
+	// lost counts records in segments recovery had to discard
+	lost atomic.Int64
+}
+
+// openDiskSpool creates this process's subdirectory under root and takes
+// ownership of it, reporting problems with segments to diag.
+func openDiskSpool(root string, diag *diagnostics) (*diskSpool, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(root, fmt.Sprintf("%s%d-", spoolDirPrefix, os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool subdirectory: %w", err)
+	}
+
+	lock, err := tryLockFile(filepath.Join(dir, spoolLockFile))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to lock spool subdirectory: %w", err)
+	}
+
+	sp := &diskSpool{root: root, dir: dir, lock: lock, diag: diag}
+
+	if _, err := sp.reap(); err != nil {
+		diag.log(slog.Default(), "ERROR", "Failed to reap orphaned spool segments", "error", err)
+	}
+
+	return sp, nil
+}
+
+// write stores a serialized batch of n records as a new segment.
+func (sp *diskSpool) write(data []byte, n int) error {
+	sp.mu.Lock()
+	sp.seq++
+	name := fmt.Sprintf("seg-%020d-%06d-%d%s", time.Now().UnixNano(), sp.seq, n, spoolSegmentExt)
+	sp.mu.Unlock()
+
+	// Sync before the rename so a crash can't leave a named but empty segment
+	tmp := filepath.Join(sp.dir, "."+name+".tmp")
+	if err := writeFileSync(tmp, encodeSegment(data)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(sp.dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to commit spool segment: %w", err)
+	}
+
+	return nil
+}
+
+// segments returns this process's segment names, oldest first.
+func (sp *diskSpool) segments() ([]string, error) {
+	entries, err := os.ReadDir(sp.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool segments: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "seg-") && strings.HasSuffix(entry.Name(), spoolSegmentExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// read returns the serialized batch held in a segment.
+func (sp *diskSpool) read(name string) ([]byte, error) {
+	segment, err := os.ReadFile(filepath.Join(sp.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return decodeSegment(segment)
+}
+
+// remove deletes a segment once it has been exported.
+func (sp *diskSpool) remove(name string) error {
+	return os.Remove(filepath.Join(sp.dir, name))
+}
+
+// quarantine moves a segment that can't be decoded out of the spool and
+// counts its records as lost.
+func (sp *diskSpool) quarantine(name string) error {
+	dir := filepath.Join(sp.root, spoolQuarantineDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create spool quarantine: %w", err)
+	}
+	if err := os.Rename(filepath.Join(sp.dir, name), filepath.Join(dir, filepath.Base(sp.dir)+"-"+name)); err != nil {
+		return fmt.Errorf("failed to quarantine spool segment: %w", err)
+	}
+	sp.lost.Add(int64(segmentRecords(name)))
+	return nil
+}
+
+// records returns the number of records held in the spool.
+func (sp *diskSpool) records() int {
+	names, err := sp.segments()
+	if err != nil {
+		return 0
+	}
+
+	total := 0
+	for _, name := range names {
+		total += segmentRecords(name)
+	}
+	return total
+}
+
+// reap adopts segments from sibling subdirectories whose owning process no
+// longer holds the lock, and removes those subdirectories. It returns the
+// number of segments adopted.
+func (sp *diskSpool) reap() (int, error) {
+	entries, err := os.ReadDir(sp.root)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list spool root: %w", err)
+	}
+
+	adopted := 0
+	for _, entry := range entries {
+		dir := filepath.Join(sp.root, entry.Name())
+		if !entry.IsDir() || dir == sp.dir || !strings.HasPrefix(entry.Name(), spoolDirPrefix) {
+			continue
+		}
+		if info, err := entry.Info(); err != nil || time.Since(info.ModTime()) < spoolReapGrace {
+			continue
+		}
+
+		lock, err := tryLockFile(filepath.Join(dir, spoolLockFile))
+		if errors.Is(err, errSpoolLocked) {
+			continue
+		}
+		if err != nil {
+			return adopted, err
+		}
+
+		orphan := &diskSpool{dir: dir}
+		names, err := orphan.segments()
+		if err == nil {
+			for _, name := range names {
+				if os.Rename(filepath.Join(dir, name), filepath.Join(sp.dir, name)) != nil {
+					continue
+				}
+				adopted++
+
+				// Its owner may have crashed mid-write
+				if err := sp.recoverSegment(name); err != nil {
+					sp.diag.log(slog.Default(), "ERROR", "Failed to recover spool segment", "segment", name, "error", err)
+				}
+			}
+		}
+
+		unlockFile(lock)
+		os.RemoveAll(dir)
+	}
+
+	return adopted, nil
+}
+
+// close releases ownership of the subdirectory. Segments that remain are
+// left on disk for the next process to adopt.
+func (sp *diskSpool) close() error {
+	names, err := sp.segments()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		unlockFile(sp.lock)
+		return os.RemoveAll(sp.dir)
+	}
+
+	return unlockFile(sp.lock)
+}
+
+// segmentRecords parses the record count encoded in a segment name.
+func segmentRecords(name string) int {
+	base := strings.TrimSuffix(name, spoolSegmentExt)
+	idx := strings.LastIndex(base, "-")
+	if idx < 0 {
+		return 0
+	}
+
+	n, err := strconv.Atoi(base[idx+1:])
+	if err != nil {
+		return 0
+	}
+	return n
+}
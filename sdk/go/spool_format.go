@@ -0,0 +1,140 @@
+package lipservice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// spoolMagic starts every framed spool segment. It is followed by frames
+// of a big-endian uint32 payload length, the payload's CRC-32C and the
+// payload itself. Segments without it predate framing and are read whole.
+var spoolMagic = []byte("LSPL\x01")
+
+// spoolFrameHeader is the size of a frame's length and checksum.
+const spoolFrameHeader = 8
+
+// spoolCRC is the Castagnoli table used for frame checksums.
+var spoolCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// Reasons a spool segment can't be read to the end.
+var (
+	errSpoolTorn    = errors.New("torn spool frame")
+	errSpoolCorrupt = errors.New("spool frame checksum mismatch")
+)
+
+// encodeSegment frames a serialized batch as a spool segment.
+func encodeSegment(payload []byte) []byte {
+	segment := make([]byte, 0, len(spoolMagic)+spoolFrameHeader+len(payload))
+	segment = append(segment, spoolMagic...)
+	segment = binary.BigEndian.AppendUint32(segment, uint32(len(payload)))
+	segment = binary.BigEndian.AppendUint32(segment, crc32.Checksum(payload, spoolCRC))
+	return append(segment, payload...)
+}
+
+// scanSegment returns the payloads of a segment's valid frames, joined,
+// and the offset just past the last of them. Payloads are whole OTLP
+// requests, so their concatenation decodes as one request holding every
+// frame's records. err says why the scan stopped before the end.
+func scanSegment(segment []byte) ([]byte, int, error) {
+	offset := len(spoolMagic)
+	var payloads []byte
+	for offset < len(segment) {
+		if len(segment)-offset < spoolFrameHeader {
+			return payloads, offset, errSpoolTorn
+		}
+		n := int(binary.BigEndian.Uint32(segment[offset:]))
+		sum := binary.BigEndian.Uint32(segment[offset+4:])
+
+		start := offset + spoolFrameHeader
+		if n > len(segment)-start {
+			return payloads, offset, errSpoolTorn
+		}
+		payload := segment[start : start+n]
+		if crc32.Checksum(payload, spoolCRC) != sum {
+			return payloads, offset, errSpoolCorrupt
+		}
+
+		payloads = append(payloads, payload...)
+		offset = start + n
+	}
+	return payloads, offset, nil
+}
+
+// decodeSegment returns the serialized batch held in a segment.
+func decodeSegment(segment []byte) ([]byte, error) {
+	if !bytes.HasPrefix(segment, spoolMagic) {
+		return segment, nil
+	}
+	payload, _, err := scanSegment(segment)
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// recoverSegment checks a segment left by a process that may have crashed
+// mid-write. Torn or corrupt frames are cut off, and a segment left with
+// no valid frames is removed, its records counted as lost.
+func (sp *diskSpool) recoverSegment(name string) error {
+	path := filepath.Join(sp.dir, name)
+	segment, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read spool segment: %w", err)
+	}
+	switch {
+	case len(segment) < len(spoolMagic):
+		// Torn before the header was complete; too short to hold records
+		// even in the unframed format
+		err = errSpoolTorn
+	case !bytes.HasPrefix(segment, spoolMagic):
+		return nil
+	default:
+		var end int
+		if _, end, err = scanSegment(segment); err == nil {
+			return nil
+		}
+		if end > len(spoolMagic) {
+			sp.diag.log(slog.Default(), "WARN", "Truncating damaged spool segment", "segment", name, "error", err, "offset", end)
+			if terr := os.Truncate(path, int64(end)); terr != nil {
+				return fmt.Errorf("failed to truncate spool segment: %w", terr)
+			}
+			return nil
+		}
+	}
+
+	sp.diag.log(slog.Default(), "WARN", "Removing spool segment with no intact records", "segment", name, "error", err)
+	sp.lost.Add(int64(segmentRecords(name)))
+	if rerr := os.Remove(path); rerr != nil {
+		return fmt.Errorf("failed to remove spool segment: %w", rerr)
+	}
+	return nil
+}
+
+// takeLost returns the number of records lost to corrupt segments since it
+// was last called.
+func (sp *diskSpool) takeLost() int64 {
+	return sp.lost.Swap(0)
+}
+
+// writeFileSync writes data to a new file at path and syncs it to disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
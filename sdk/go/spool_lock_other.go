@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package lipservice
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLockFile is not supported on this platform.
+func tryLockFile(path string) (*os.File, error) {
+	return nil, errors.New("spool file locking is not supported on this platform")
+}
+
+// unlockFile is not supported on this platform.
+func unlockFile(f *os.File) error {
+	return f.Close()
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package lipservice
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile opens path and takes a non-blocking exclusive lock on it. The
+// lock is released by the kernel if the process exits.
+func tryLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errSpoolLocked
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// unlockFile releases a lock taken by tryLockFile.
+func unlockFile(f *os.File) error {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return f.Close()
+}
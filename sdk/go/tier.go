@@ -0,0 +1,178 @@
+package lipservice
+
+import (
+	"sync/atomic"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// Built-in service tiers selectable with Config.Tier.
+const (
+	TierCritical = "critical"
+	TierStandard = "standard"
+	TierBatch    = "batch"
+)
+
+// SamplingReasonBudget is reported when a record the sampler would have
+// kept is dropped because the tier's per-minute budget is spent.
+const SamplingReasonBudget = "budget"
+
+// TierProfile is a set of sampling defaults for a class of service.
+type TierProfile struct {
+	// DefaultRate is the sampling rate for patterns not yet seen
+	DefaultRate float64
+
+	// SeverityRates override DefaultRate per severity
+	SeverityRates map[string]float64
+
+	// MaxLogsPerMinute caps records kept below ERROR (0 means no cap)
+	MaxLogsPerMinute int
+
+	// MinPriority is the lowest export priority records are given
+	MinPriority Priority
+}
+
+// tierProfiles are the built-in profiles by tier name.
+var tierProfiles = map[string]TierProfile{
+	TierCritical: {
+		DefaultRate:   0.5,
+		SeverityRates: map[string]float64{"WARN": 1.0, "WARNING": 1.0, "INFO": 0.5, "DEBUG": 0.1},
+		MinPriority:   PriorityHigh,
+	},
+	TierStandard: {
+		DefaultRate:      0.1,
+		SeverityRates:    map[string]float64{"WARN": 0.5, "WARNING": 0.5, "INFO": 0.1, "DEBUG": 0.01},
+		MaxLogsPerMinute: 10000,
+		MinPriority:      PriorityLow,
+	},
+	TierBatch: {
+		DefaultRate:      0.01,
+		SeverityRates:    map[string]float64{"WARN": 0.2, "WARNING": 0.2, "INFO": 0.01, "DEBUG": 0.001},
+		MaxLogsPerMinute: 1000,
+		MinPriority:      PriorityLow,
+	},
+}
+
+// tierBudget tracks records kept in the current minute.
+type tierBudget struct {
+	window time.Time
+	used   int
+}
+
+// take reports whether another record fits in limit for the minute
+// containing now, counting it if so.
+func (b *tierBudget) take(limit int, now time.Time) bool {
+	window := now.Truncate(time.Minute)
+	if !window.Equal(b.window) {
+		b.window = window
+		b.used = 0
+	}
+	if b.used >= limit {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// usedAt returns how many records have been counted in the minute
+// containing now, without counting another.
+func (b *tierBudget) usedAt(now time.Time) int {
+	if !now.Truncate(time.Minute).Equal(b.window) {
+		return 0
+	}
+	return b.used
+}
+
+// tier returns the active tier profile. A policy naming a tier selects it
+// in place of Config.Tier, and that policy's severity rates and budget
+// override the profile's. Callers must hold s.mu.
+func (s *AdaptiveSampler) tier() (TierProfile, bool) {
+	if s.policy == nil || s.policy.Tier == "" {
+		return tierProfile(s.config.Tier)
+	}
+
+	profile, ok := tierProfile(s.policy.Tier)
+	if !ok {
+		return tierProfile(s.config.Tier)
+	}
+	return overrideTier(profile, s.policy), true
+}
+
+// tierProfile looks up a built-in profile.
+func tierProfile(name string) (TierProfile, bool) {
+	profile, ok := tierProfiles[name]
+	return profile, ok
+}
+
+// overrideTier applies a policy's severity rates and budget to a profile.
+func overrideTier(profile TierProfile, policy *SamplingPolicy) TierProfile {
+	rates := make(map[string]float64, len(profile.SeverityRates)+len(policy.SeverityRates))
+	for severity, rate := range profile.SeverityRates {
+		rates[severity] = rate
+	}
+	for severity, rate := range policy.SeverityRates {
+		rates[severity] = rate
+	}
+	profile.SeverityRates = rates
+
+	if policy.MaxLogsPerMinute > 0 {
+		profile.MaxLogsPerMinute = policy.MaxLogsPerMinute
+	}
+	return profile
+}
+
+// rate returns the profile's sampling rate for a severity.
+func (p TierProfile) rate(severity string) float64 {
+	if rate, ok := p.SeverityRates[severity]; ok {
+		return rate
+	}
+	return p.DefaultRate
+}
+
+// withinBudget reports whether a kept record fits the active tier's
+// per-minute budget. Callers must hold the s.mu write lock.
+func (s *AdaptiveSampler) withinBudget(now time.Time) bool {
+	profile, ok := s.tier()
+	if !ok || profile.MaxLogsPerMinute == 0 {
+		return true
+	}
+	return s.budget.take(profile.MaxLogsPerMinute, now)
+}
+
+// newPriorityFloor returns the export priority floor for the configured
+// tier.
+func newPriorityFloor(config Config) *atomic.Int32 {
+	floor := &atomic.Int32{}
+	if profile, ok := tierProfile(config.Tier); ok {
+		floor.Store(int32(profile.MinPriority))
+	}
+	return floor
+}
+
+// applyTier moves the export priority floor to the tier a policy selects.
+// It runs as a sampler policy hook.
+func (e *PostHogExporter) applyTier(policy *SamplingPolicy) {
+	name := policy.Tier
+	if name == "" {
+		name = e.config.Tier
+	}
+	if profile, ok := tierProfile(name); ok {
+		e.priorityFloor.Store(int32(profile.MinPriority))
+	}
+}
+
+// raisePriority tags a record with the tier's minimum priority if its
+// severity gives it a lower one. Explicitly tagged records are left alone.
+func (e *PostHogExporter) raisePriority(record *logs.LogRecord) {
+	for _, kv := range record.Attributes {
+		if kv.Key == PriorityAttribute {
+			return
+		}
+	}
+
+	floor := Priority(e.priorityFloor.Load())
+	if severityPriority(record.SeverityNumber) < floor {
+		record.Attributes = append(record.Attributes, stringKeyValue(PriorityAttribute, floor.String()))
+	}
+}
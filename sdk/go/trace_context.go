@@ -0,0 +1,107 @@
+package lipservice
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes carrying the active span of records logged with a context.
+// Exporters move them onto the OTLP record's TraceId, SpanId and Flags, so
+// logs can be joined to traces.
+const (
+	TraceIDAttribute    = "trace_id"
+	SpanIDAttribute     = "span_id"
+	TraceFlagsAttribute = "trace_flags"
+)
+
+// InfoContext logs an info message correlated with the span in ctx.
+func (l *LipServiceLogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logContext(ctx, "INFO", msg, args...)
+}
+
+// WarnContext logs a warning message correlated with the span in ctx.
+func (l *LipServiceLogger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logContext(ctx, "WARN", msg, args...)
+}
+
+// ErrorContext logs an error message correlated with the span in ctx.
+func (l *LipServiceLogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logContext(ctx, "ERROR", msg, args...)
+}
+
+// DebugContext logs a debug message correlated with the span in ctx.
+func (l *LipServiceLogger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logContext(ctx, "DEBUG", msg, args...)
+}
+
+// FatalContext logs a fatal message correlated with the span in ctx.
+func (l *LipServiceLogger) FatalContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logContext(ctx, "FATAL", msg, args...)
+}
+
+// logContext logs a record carrying the IDs of ctx's active span. Within a
+// request handled by Middleware, it counts towards the request summary.
+func (l *LipServiceLogger) logContext(ctx context.Context, severity, msg string, args ...interface{}) {
+	l.logContextAt(ctx, time.Now(), severity, msg, args)
+}
+
+// logContextAt is logContext for a record that happened at now.
+func (l *LipServiceLogger) logContextAt(ctx context.Context, now time.Time, severity, msg string, args []interface{}) {
+	if ctx == nil {
+		l.process(severity, msg, now, args)
+		return
+	}
+
+	logger := l
+	tally := requestTallyFrom(ctx)
+	span := trace.SpanFromContext(ctx)
+	if tally != nil || (l.spanEvents != nil && span.IsRecording()) {
+		clone := *l
+		if tally != nil {
+			clone.tally = tally
+		}
+		// Sampled-out records become events on the span
+		clone.span = span
+		logger = &clone
+	}
+	if span := span.SpanContext(); span.IsValid() {
+		args = append(args[:len(args):len(args)],
+			String(TraceIDAttribute, span.TraceID().String()),
+			String(SpanIDAttribute, span.SpanID().String()),
+			String(TraceFlagsAttribute, span.TraceFlags().String()),
+		)
+	}
+	logger.process(severity, msg, now, args)
+}
+
+// spanFields parses the values of a record's span attributes into OTLP
+// trace and span IDs and flags. ok is false unless both IDs are present
+// and valid.
+func spanFields(traceValue, spanValue, flagsValue interface{}) (traceID, spanID []byte, flags uint32, ok bool) {
+	traceHex, _ := traceValue.(string)
+	spanHex, _ := spanValue.(string)
+	tid, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return nil, nil, 0, false
+	}
+	sid, err := trace.SpanIDFromHex(spanHex)
+	if err != nil {
+		return nil, nil, 0, false
+	}
+
+	if flagsHex, isString := flagsValue.(string); isString {
+		if parsed, err := strconv.ParseUint(flagsHex, 16, 8); err == nil {
+			flags = uint32(parsed)
+		}
+	}
+	return tid[:], sid[:], flags, true
+}
+
+// isSpanAttribute reports whether key is one of the span attributes moved
+// onto the OTLP record.
+func isSpanAttribute(key string) bool {
+	return key == TraceIDAttribute || key == SpanIDAttribute || key == TraceFlagsAttribute
+}
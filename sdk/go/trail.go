@@ -0,0 +1,251 @@
+package lipservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// TraceAttribute tags a record for a debug trail when Config.DebugTrail is
+// on, e.g. logger.Info("checkout", lipservice.TraceAttribute, true).
+const TraceAttribute = "lipservice.trace"
+
+// Stages a traced record passes through, in the order they can occur.
+const (
+	// TrailAccepted is the record reaching the logger
+	TrailAccepted = "accepted"
+
+	// TrailSampled is the sampling decision, with its reason and rate
+	TrailSampled = "sampled"
+
+	// TrailDropped is the record being dropped, with the drop reason
+	TrailDropped = "dropped"
+
+	// TrailEnqueued is the record being handed to its sinks
+	TrailEnqueued = "enqueued"
+
+	// TrailBatched is the record joining a batch, with the batch ID
+	TrailBatched = "batched"
+
+	// TrailHTTP is one export request carrying the record, with the
+	// endpoint and HTTP status
+	TrailHTTP = "http"
+
+	// TrailDelivered, TrailSpooled and TrailDeadLettered are the batch's
+	// final outcome
+	TrailDelivered    = "delivered"
+	TrailSpooled      = "spooled"
+	TrailDeadLettered = "dead_lettered"
+)
+
+// maxDebugTrails is how many trails are kept; the oldest are forgotten
+// first.
+const maxDebugTrails = 256
+
+// TrailStep is one breadcrumb in a record's trail.
+type TrailStep struct {
+	Time   time.Time `json:"time"`
+	Stage  string    `json:"stage"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// DebugTrail is the path one traced record took from the logger to the
+// backend.
+type DebugTrail struct {
+	RecordID string      `json:"record_id"`
+	Message  string      `json:"message"`
+	Severity string      `json:"severity"`
+	Steps    []TrailStep `json:"steps"`
+}
+
+// debugTrails holds the most recent trails by record ID.
+type debugTrails struct {
+	mu     sync.Mutex
+	trails map[string]*DebugTrail
+	order  []string
+	ids    IDGenerator
+}
+
+// newDebugTrails returns a trail store, or nil unless Config.DebugTrail is
+// set.
+func newDebugTrails(config Config) *debugTrails {
+	if !config.DebugTrail {
+		return nil
+	}
+	return &debugTrails{trails: make(map[string]*DebugTrail), ids: UUIDv7Generator()}
+}
+
+// start begins a trail for a tagged record and returns its record ID, or
+// "" if the record isn't tagged. The ID comes from ids, or a UUIDv7 if
+// record IDs are disabled, since a trail needs one to follow the record.
+func (d *debugTrails) start(ids IDGenerator, msg, severity string, args, bound []interface{}) string {
+	if d == nil {
+		return ""
+	}
+	tag, ok := lookupAttribute(TraceAttribute, args, bound)
+	if !ok || (tag != true && tag != "true") {
+		return ""
+	}
+	if ids == nil {
+		ids = d.ids
+	}
+	id := ids.NewID()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.order) >= maxDebugTrails {
+		delete(d.trails, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.trails[id] = &DebugTrail{RecordID: id, Message: msg, Severity: severity}
+	d.order = append(d.order, id)
+	d.add(id, TrailAccepted, "")
+	return id
+}
+
+// step appends a breadcrumb to a record's trail. Untraced records (an
+// empty id) are ignored.
+func (d *debugTrails) step(id, stage, format string, args ...interface{}) {
+	if d == nil || id == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.add(id, stage, fmt.Sprintf(format, args...))
+}
+
+// add appends a breadcrumb. Callers must hold d.mu.
+func (d *debugTrails) add(id, stage, detail string) {
+	if trail, ok := d.trails[id]; ok {
+		trail.Steps = append(trail.Steps, TrailStep{Time: time.Now(), Stage: stage, Detail: detail})
+	}
+}
+
+// get returns a copy of a record's trail.
+func (d *debugTrails) get(id string) (DebugTrail, bool) {
+	if d == nil {
+		return DebugTrail{}, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	trail, ok := d.trails[id]
+	if !ok {
+		return DebugTrail{}, false
+	}
+	copied := *trail
+	copied.Steps = append([]TrailStep(nil), trail.Steps...)
+	return copied, true
+}
+
+// list returns copies of every trail, most recent first.
+func (d *debugTrails) list() []DebugTrail {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	ids := append([]string(nil), d.order...)
+	d.mu.Unlock()
+
+	trails := make([]DebugTrail, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		if trail, ok := d.get(ids[i]); ok {
+			trails = append(trails, trail)
+		}
+	}
+	return trails
+}
+
+// tracedIDs returns the record IDs of the traced records among records.
+func (d *debugTrails) tracedIDs(records []*logs.LogRecord) []string {
+	if d == nil {
+		return nil
+	}
+
+	var ids []string
+	for _, record := range records {
+		traced, id := false, ""
+		for _, kv := range record.Attributes {
+			switch kv.Key {
+			case TraceAttribute:
+				traced = true
+			case RecordIDAttribute:
+				id = kv.Value.GetStringValue()
+			}
+		}
+		if traced && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// stepAll appends the same breadcrumb to several trails.
+func (d *debugTrails) stepAll(ids []string, stage, format string, args ...interface{}) {
+	for _, id := range ids {
+		d.step(id, stage, format, args...)
+	}
+}
+
+// trailKey carries the IDs of the traced records in a request, so each
+// HTTP attempt can be added to their trails.
+type trailKey struct{}
+
+// withTrail returns ctx carrying traced record IDs.
+func withTrail(ctx context.Context, ids []string) context.Context {
+	if len(ids) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, trailKey{}, ids)
+}
+
+// trailFrom returns the traced record IDs carried by ctx.
+func trailFrom(ctx context.Context) []string {
+	ids, _ := ctx.Value(trailKey{}).([]string)
+	return ids
+}
+
+// Trail returns the debug trail of a record tagged with TraceAttribute,
+// answering whether it was kept, which batch carried it and what the
+// backend said.
+func (ls *LipService) Trail(recordID string) (DebugTrail, bool) {
+	return ls.logger.trails.get(recordID)
+}
+
+// Trails returns the most recent debug trails, newest first.
+func (ls *LipService) Trails() []DebugTrail {
+	return ls.logger.trails.list()
+}
+
+// TrailHandler serves debug trails as JSON: one trail for ?record_id=,
+// otherwise the most recent trails.
+func (ls *LipService) TrailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ls.logger.trails == nil {
+			http.Error(w, "debug trails are disabled", http.StatusNotFound)
+			return
+		}
+
+		var body interface{} = ls.Trails()
+		if id := r.URL.Query().Get("record_id"); id != "" {
+			trail, ok := ls.Trail(id)
+			if !ok {
+				http.Error(w, "no trail for record "+id, http.StatusNotFound)
+				return
+			}
+			body = trail
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+}
@@ -0,0 +1,119 @@
+package lipservice
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transport defaults tuned for frequent small batches to one endpoint:
+// enough idle connections that concurrent flushes never redial.
+const (
+	defaultMaxIdleConnsPerHost = 32
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+)
+
+// transportKey identifies transports that can be shared between exporters.
+type transportKey struct {
+	socket              string
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	keepAlive           time.Duration
+	disableHTTP2        bool
+}
+
+// sharedTransports holds one transport per distinct set of knobs, so
+// exporters for every region and every LipService in the process reuse
+// connections instead of each opening their own.
+var sharedTransports sync.Map // transportKey -> *http.Transport
+
+// newExportClient creates the HTTP client used to export batches, dialing
+// through ExportSocket or DialContext when configured.
+func newExportClient(config Config) *http.Client {
+	return &http.Client{
+		Timeout:   config.Timeout,
+		Transport: exportTransport(config),
+	}
+}
+
+// NewExportTransport builds a transport from config's connection knobs.
+// Assign it to Config.Transport to share one pool across LipService
+// instances explicitly; exporters with identical knobs already share one.
+func NewExportTransport(config Config) *http.Transport {
+	key := newTransportKey(config)
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: key.keepAlive,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.MaxIdleConnsPerHost = key.maxIdleConnsPerHost
+	if transport.MaxIdleConns < key.maxIdleConnsPerHost {
+		transport.MaxIdleConns = key.maxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = key.idleConnTimeout
+	if key.disableHTTP2 {
+		// A non-nil, empty TLSNextProto is how net/http is told not to
+		// negotiate HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	switch {
+	case config.ExportSocket != "":
+		// The endpoint's host only names the request; every connection
+		// goes to the socket
+		socket := config.ExportSocket
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	case config.DialContext != nil:
+		transport.DialContext = config.DialContext
+	}
+
+	return transport
+}
+
+// exportTransport returns the transport for config's exports, reusing a
+// shared one unless a custom dialer makes the config unique.
+func exportTransport(config Config) http.RoundTripper {
+	if config.Transport != nil {
+		return config.Transport
+	}
+	if config.DialContext != nil && config.ExportSocket == "" {
+		return NewExportTransport(config)
+	}
+
+	key := newTransportKey(config)
+	if transport, ok := sharedTransports.Load(key); ok {
+		return transport.(*http.Transport)
+	}
+	transport, _ := sharedTransports.LoadOrStore(key, NewExportTransport(config))
+	return transport.(*http.Transport)
+}
+
+// newTransportKey applies the transport defaults to config's knobs.
+func newTransportKey(config Config) transportKey {
+	key := transportKey{
+		socket:              config.ExportSocket,
+		maxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		idleConnTimeout:     config.IdleConnTimeout,
+		keepAlive:           config.KeepAlive,
+		disableHTTP2:        config.DisableHTTP2,
+	}
+	if key.maxIdleConnsPerHost <= 0 {
+		key.maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	if key.idleConnTimeout <= 0 {
+		key.idleConnTimeout = defaultIdleConnTimeout
+	}
+	if key.keepAlive == 0 {
+		key.keepAlive = defaultKeepAlive
+	}
+	return key
+}
@@ -0,0 +1,173 @@
+package lipservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Version is the LipService Go SDK version.
+const Version = "0.2.0"
+
+// SDK capability flags advertised to the backend.
+const (
+	CapabilityDeterministicSampling = "deterministic_sampling"
+	CapabilityRateCoordination      = "rate_coordination"
+	CapabilityBatchDedupe           = "batch_dedupe"
+	CapabilityPolicyAudit           = "policy_audit"
+)
+
+// sdkCapabilities lists the capabilities this SDK build supports.
+var sdkCapabilities = []string{
+	CapabilityDeterministicSampling,
+	CapabilityRateCoordination,
+	CapabilityBatchDedupe,
+	CapabilityPolicyAudit,
+}
+
+// BackendCapabilities describes what the LipService backend supports, as
+// returned by the handshake.
+type BackendCapabilities struct {
+	APIVersion    string   `json:"api_version"`
+	MinSDKVersion string   `json:"min_sdk_version"`
+	Features      []string `json:"features"`
+}
+
+// Supports reports whether the backend advertised a feature.
+func (c *BackendCapabilities) Supports(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// handshakeRequest is sent to the backend to negotiate capabilities.
+type handshakeRequest struct {
+	ServiceName  string   `json:"service_name"`
+	SDK          string   `json:"sdk"`
+	Version      string   `json:"version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// newBackendRequest builds a request to the LipService backend with the
+// auth, content-type and SDK identification headers set.
+func (s *AdaptiveSampler) newBackendRequest(method, path string, body []byte) (*http.Request, error) {
+	if s.config.Offline {
+		return nil, ErrOffline
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	// Requests are cancelled when the sampler closes
+	req, err := http.NewRequestWithContext(s.ctx, method, s.config.LipServiceURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.config.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
+	}
+	req.Header.Set("User-Agent", "lipservice-go/"+Version)
+	req.Header.Set("X-LipService-SDK", "go/"+Version)
+	req.Header.Set("X-LipService-Capabilities", strings.Join(sdkCapabilities, ","))
+
+	return req, nil
+}
+
+// negotiate exchanges versions and capability flags with the backend. Until
+// it succeeds the backend is assumed to support every feature, so an older
+// backend without the handshake endpoint keeps working as before.
+func (s *AdaptiveSampler) negotiate() error {
+	body, err := json.Marshal(handshakeRequest{
+		ServiceName:  s.config.ServiceName,
+		SDK:          "go",
+		Version:      Version,
+		Capabilities: sdkCapabilities,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal handshake: %w", err)
+	}
+
+	req, err := s.newBackendRequest("POST", "/api/v1/sdk/handshake", body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("LipService returned status %d", resp.StatusCode)
+	}
+
+	var caps BackendCapabilities
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil {
+		return fmt.Errorf("failed to decode handshake response: %w", err)
+	}
+
+	if caps.MinSDKVersion != "" && compareVersions(Version, caps.MinSDKVersion) < 0 {
+		s.diag.log(slog.Default(), "WARN", "SDK version is older than the backend minimum; please upgrade",
+			"version", Version, "min_version", caps.MinSDKVersion)
+	}
+
+	s.mu.Lock()
+	s.capabilities = &caps
+	s.mu.Unlock()
+
+	return nil
+}
+
+// BackendSupports reports whether the backend supports a feature. It
+// returns true until a handshake has completed.
+func (s *AdaptiveSampler) BackendSupports(feature string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.capabilities == nil {
+		return true
+	}
+	return s.capabilities.Supports(feature)
+}
+
+// compareVersions compares two dotted numeric versions, returning -1, 0 or
+// 1. Missing or non-numeric components compare as zero.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package lipservice
+
+import "time"
+
+// WarmupAttribute is set to true on records logged during warmup, so the
+// extra volume right after a deploy can be told apart.
+const WarmupAttribute = "warmup"
+
+// defaultWarmupMultiplier is how much warmup raises sampling at start.
+const defaultWarmupMultiplier = 5.0
+
+// warmup raises sampling for a while after the process starts, when
+// deploys tend to break things, decaying linearly back to policy rates.
+type warmup struct {
+	start      time.Time
+	duration   time.Duration
+	multiplier float64
+}
+
+// newWarmup returns the warmup for config, or nil if WarmupDuration is not
+// set.
+func newWarmup(config Config, now time.Time) *warmup {
+	if config.WarmupDuration <= 0 {
+		return nil
+	}
+	multiplier := config.WarmupMultiplier
+	if multiplier <= 1 {
+		multiplier = defaultWarmupMultiplier
+	}
+	return &warmup{start: now, duration: config.WarmupDuration, multiplier: multiplier}
+}
+
+// active reports whether now falls within the warmup period.
+func (w *warmup) active(now time.Time) bool {
+	return w != nil && now.Sub(w.start) < w.duration
+}
+
+// boost scales rate by the warmup multiplier, which decays from its full
+// value at start to 1 once the period is over.
+func (w *warmup) boost(rate float64, now time.Time) float64 {
+	if !w.active(now) {
+		return rate
+	}
+	remaining := 1 - float64(now.Sub(w.start))/float64(w.duration)
+	rate *= 1 + (w.multiplier-1)*remaining
+	if rate > 1 {
+		rate = 1
+	}
+	return rate
+}
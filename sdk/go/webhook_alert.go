@@ -0,0 +1,288 @@
+package lipservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Webhook payload formats for WebhookAlertConfig.Format.
+const (
+	WebhookFormatGeneric   = "generic"
+	WebhookFormatSlack     = "slack"
+	WebhookFormatPagerDuty = "pagerduty"
+)
+
+// defaultWebhookThrottle is the default minimum time between alerts for
+// one signature.
+const defaultWebhookThrottle = 10 * time.Minute
+
+// webhookQueueSize bounds the alerts waiting to be posted; further alerts
+// are dropped so a flood never blocks logging.
+const webhookQueueSize = 64
+
+// webhookMaxSignatures bounds the signatures remembered for new-error
+// detection and throttling.
+const webhookMaxSignatures = 10000
+
+// WebhookAlertConfig configures a WebhookAlertSink.
+type WebhookAlertConfig struct {
+	// URL receives alert POSTs
+	URL string
+
+	// OwnerURLs maps owning teams (see PatternOwner) to the webhooks of
+	// their channels; alerts for records without a listed owner go to URL
+	OwnerURLs map[string]string
+
+	// Format is the payload shape: WebhookFormatGeneric (the default),
+	// WebhookFormatSlack or WebhookFormatPagerDuty (Events API v2)
+	Format string
+
+	// RoutingKey is the PagerDuty integration key
+	RoutingKey string
+
+	// Source names the alerting service in payloads
+	Source string
+
+	// Throttle is the minimum time between alerts for one signature
+	// (defaults to 10m)
+	Throttle time.Duration
+
+	// Client sends the alerts (defaults to a client with a 10s timeout)
+	Client *http.Client
+
+	// SignatureHasher hashes alert signatures; set it to the sampler's
+	// Config.SignatureHasher so they match pattern signatures (defaults
+	// to FNVHasher)
+	SignatureHasher SignatureHasher
+
+	// DiagnosticsSink receives failures to send alerts, throttled like the
+	// SDK's other diagnostics; set it to the LipService's
+	// Config.DiagnosticsSink (defaults to the default slog logger)
+	DiagnosticsSink LogSink
+
+	// DiagnosticsInterval is how often one send failure may repeat
+	// (defaults to one minute)
+	DiagnosticsInterval time.Duration
+}
+
+// webhookAlert is a record that triggered an alert.
+type webhookAlert struct {
+	Message   string    `json:"message"`
+	Severity  string    `json:"severity"`
+	Signature string    `json:"signature"`
+	Source    string    `json:"source,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	NewError  bool      `json:"new_error"`
+}
+
+// WebhookAlertSink is a LogSink that posts an alert to a webhook when a
+// FATAL record or a never-before-seen ERROR signature appears. Route
+// ERROR and above to it with an ExportRoute.
+type WebhookAlertSink struct {
+	config     WebhookAlertConfig
+	signatures *SignatureEngine
+	queue      chan webhookAlert
+	diag       *diagnostics
+	wg         sync.WaitGroup
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time
+	closed    bool
+}
+
+// NewWebhookAlertSink creates a webhook alert sink and starts its sender.
+func NewWebhookAlertSink(config WebhookAlertConfig) (*WebhookAlertSink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	switch config.Format {
+	case "":
+		config.Format = WebhookFormatGeneric
+	case WebhookFormatGeneric, WebhookFormatSlack:
+	case WebhookFormatPagerDuty:
+		if config.RoutingKey == "" {
+			return nil, fmt.Errorf("PagerDuty webhooks require a routing key")
+		}
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", config.Format)
+	}
+	if config.Throttle <= 0 {
+		config.Throttle = defaultWebhookThrottle
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	s := &WebhookAlertSink{
+		config:     config,
+		queue:      make(chan webhookAlert, webhookQueueSize),
+		lastAlert:  make(map[string]time.Time),
+		signatures: NewSignatureEngine(SignatureEngineConfig{Hasher: config.SignatureHasher}),
+		diag: newDiagnostics(Config{
+			DiagnosticsSink:     config.DiagnosticsSink,
+			DiagnosticsInterval: config.DiagnosticsInterval,
+		}),
+	}
+
+	s.wg.Add(1)
+	go s.sendLoop()
+
+	return s, nil
+}
+
+// ExportLog queues an alert if the record is FATAL or a new ERROR
+// signature and its signature isn't throttled. Other records are ignored.
+func (s *WebhookAlertSink) ExportLog(message, severity string, timestamp time.Time, attributes map[string]interface{}) error {
+	fatal := severity == "FATAL" || severity == "CRITICAL"
+	if !fatal && severity != "ERROR" {
+		return nil
+	}
+
+	signature := s.signatures.Signature(message)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	last, seen := s.lastAlert[signature]
+	if (!fatal && seen) || (seen && timestamp.Sub(last) < s.config.Throttle) {
+		return nil
+	}
+	if len(s.lastAlert) >= webhookMaxSignatures {
+		s.lastAlert = make(map[string]time.Time)
+	}
+	s.lastAlert[signature] = timestamp
+
+	alert := webhookAlert{
+		Message:   message,
+		Severity:  severity,
+		Signature: signature,
+		Source:    s.config.Source,
+		Owner:     ownerOf(attributes),
+		Timestamp: timestamp,
+		NewError:  !seen,
+	}
+
+	select {
+	case s.queue <- alert:
+	default:
+		return fmt.Errorf("webhook alert queue full, dropping alert")
+	}
+	return nil
+}
+
+// sendLoop posts queued alerts until the sink is closed.
+func (s *WebhookAlertSink) sendLoop() {
+	defer s.wg.Done()
+
+	for alert := range s.queue {
+		url := s.urlFor(alert)
+		if err := s.send(url, alert); err != nil {
+			s.diag.log(slog.Default(), "ERROR", "Failed to send webhook alert", "url", url, "error", err)
+		}
+	}
+}
+
+// urlFor picks the webhook for alert: its owner's, if listed, else URL.
+func (s *WebhookAlertSink) urlFor(alert webhookAlert) string {
+	if ownerURL, ok := s.config.OwnerURLs[alert.Owner]; ok && alert.Owner != "" {
+		return ownerURL
+	}
+	return s.config.URL
+}
+
+// send posts one alert to url in the configured format.
+func (s *WebhookAlertSink) send(url string, alert webhookAlert) error {
+	data, err := json.Marshal(s.payload(alert))
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	resp, err := s.config.Client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// payload shapes an alert for the configured format.
+func (s *WebhookAlertSink) payload(alert webhookAlert) interface{} {
+	summary := fmt.Sprintf("[%s] %s", alert.Severity, alert.Message)
+	if alert.NewError && alert.Severity == "ERROR" {
+		summary = "New error: " + alert.Message
+	}
+	if alert.Source != "" {
+		summary = alert.Source + ": " + summary
+	}
+	if alert.Owner != "" {
+		summary += " (owner: " + alert.Owner + ")"
+	}
+
+	switch s.config.Format {
+	case WebhookFormatSlack:
+		return map[string]string{"text": summary}
+	case WebhookFormatPagerDuty:
+		severity := "error"
+		if alert.Severity != "ERROR" {
+			severity = "critical"
+		}
+		source := alert.Source
+		if source == "" {
+			source = "lipservice"
+		}
+		payload := map[string]interface{}{
+			"summary":   summary,
+			"severity":  severity,
+			"source":    source,
+			"timestamp": alert.Timestamp.Format(time.RFC3339),
+		}
+		if alert.Owner != "" {
+			payload["group"] = alert.Owner
+		}
+		return map[string]interface{}{
+			"routing_key":  s.config.RoutingKey,
+			"event_action": "trigger",
+			"dedup_key":    alert.Signature,
+			"payload":      payload,
+		}
+	default:
+		return alert
+	}
+}
+
+// Close sends any queued alerts and stops the sender. Records exported
+// after Close are ignored.
+func (s *WebhookAlertSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// ownerOf returns a record's OwnerAttribute, or "".
+func ownerOf(attributes map[string]interface{}) string {
+	owner, ok := attributes[OwnerAttribute]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v", owner)
+}